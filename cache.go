@@ -0,0 +1,133 @@
+package lexp
+
+import (
+	"container/list"
+	"sync"
+)
+
+// DefaultCacheSize is the number of expressions kept by DefaultCache
+const DefaultCacheSize = 256
+
+// DefaultCache of the expressions evaluated by Eval
+var DefaultCache = NewCache(DefaultCacheSize)
+
+// Cache of compiled expressions keyed by their text, evicting the least
+// recently used ones. It is safe for concurrent use.
+type Cache struct {
+	mu      sync.Mutex
+	size    int
+	entries map[string]*list.Element
+	order   *list.List // most recently used first
+	hits    uint64
+	misses  uint64
+	limits  Limits
+}
+
+// CacheStats counts the lookups of a cache since it was created
+type CacheStats struct {
+	Hits, Misses uint64
+	Len, Size    int
+}
+
+type cacheEntry struct {
+	text string
+	expr *Expr
+}
+
+// NewCache keeping up to size expressions, 0 disabling the cache
+func NewCache(size int) *Cache {
+	return &Cache{size: max(size, 0), entries: map[string]*list.Element{}, order: list.New()}
+}
+
+// Eval evaluates text like the package Eval, see Compile
+func (c *Cache) Eval(text string) (float64, error) {
+	expr, err := c.Compile(text)
+	if err != nil {
+		return 0, err
+	}
+	return expr.Eval(nil)
+}
+
+// Compile text with the limits of c, unless it is in the cache.
+// Expressions failing to compile are not cached.
+func (c *Cache) Compile(text string) (*Expr, error) {
+	if expr, ok := c.get(text); ok {
+		return expr, nil
+	}
+	c.mu.Lock()
+	limits := c.limits
+	c.mu.Unlock()
+	expr, err := CompileWithLimits(text, limits)
+	if err != nil {
+		return nil, err
+	}
+	c.put(text, expr)
+	return expr, nil
+}
+
+// SetSize changes the number of expressions kept, evicting the least
+// recently used ones if there are more
+func (c *Cache) SetSize(size int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.size = max(size, 0)
+	c.evict()
+}
+
+// SetLimits of the expressions compiled by c, removing the ones compiled
+// with the previous limits
+func (c *Cache) SetLimits(limits Limits) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.limits = limits
+	clear(c.entries)
+	c.order.Init()
+}
+
+// Stats of c
+func (c *Cache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return CacheStats{c.hits, c.misses, c.order.Len(), c.size}
+}
+
+// Clear removes the expressions of c, keeping its statistics
+func (c *Cache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	clear(c.entries)
+	c.order.Init()
+}
+
+func (c *Cache) get(text string) (*Expr, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[text]
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+	c.hits++
+	c.order.MoveToFront(e)
+	return e.Value.(cacheEntry).expr, true
+}
+
+func (c *Cache) put(text string, expr *Expr) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[text]; ok {
+		c.order.MoveToFront(e)
+		return
+	}
+	c.entries[text] = c.order.PushFront(cacheEntry{text, expr})
+	c.evict()
+}
+
+// evict the least recently used expressions beyond the size of c
+func (c *Cache) evict() {
+	for c.order.Len() > c.size {
+		e := c.order.Back()
+		delete(c.entries, e.Value.(cacheEntry).text)
+		c.order.Remove(e)
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/fmarmol/lexp/parser"
+)
+
+// checkCommand reports the type mismatches of a script without evaluating
+// it, exiting with 1 if there are some
+func checkCommand(args []string, repl *REPL) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: lexp check file")
+	}
+	content, err := os.ReadFile(args[0])
+	if err != nil {
+		return err
+	}
+	expr, _, err := repl.parseFile(args[0], string(content), readImport)
+	if err != nil {
+		return err
+	}
+	if _, err := parser.TypeCheck(expr, repl.env); err != nil {
+		fmt.Fprintln(os.Stderr, diagnostic(err, isTerminal(os.Stderr)))
+		os.Exit(1)
+	}
+	return nil
+}
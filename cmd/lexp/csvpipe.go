@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/fmarmol/lexp/eval"
+	"github.com/fmarmol/lexp/lexer"
+	"github.com/fmarmol/lexp/parser"
+)
+
+// MapCSV evaluates expr for each row of the CSV read from r, the columns
+// being bound to the variables named by their header, and writes the rows
+// to w with the result appended as a new column. The identical
+// subexpressions of expr, frequent in generated formulas, are computed once
+// per row.
+func MapCSV(r io.Reader, w io.Writer, expr, column string) error {
+	in := csv.NewReader(r)
+	header, err := in.Read()
+	if err != nil {
+		return fmt.Errorf("csv: reading header: %w", err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.TrimSpace(name)] = i
+	}
+
+	tokens, err := lexer.New("expr", expr).MakeTokens()
+	if err != nil {
+		return err
+	}
+	env := parser.Env{}
+	p := parser.New(tokens)
+	p.Env = env
+	// the functions the expression defines must not leak into the builtins
+	p.Funcs = maps.Clone(eval.Builtins)
+	p.Consts = eval.Constants
+	node, err := p.Parse()
+	if err != nil {
+		return err
+	}
+	vars := parser.Variables(node)
+	for _, name := range vars {
+		if _, ok := columns[name]; !ok {
+			return fmt.Errorf("csv: no column %v", name)
+		}
+	}
+
+	out := csv.NewWriter(w)
+	if err := out.Write(append(header, column)); err != nil {
+		return err
+	}
+	for {
+		record, err := in.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("csv: %w", err)
+		}
+		line, _ := in.FieldPos(0)
+		for _, name := range vars {
+			cell := strings.TrimSpace(record[columns[name]])
+			v, err := strconv.ParseFloat(cell, 64)
+			if err != nil {
+				return fmt.Errorf("csv:%v: column %v is not a number: %q", line, name, cell)
+			}
+			env[name] = v
+		}
+		result, err := eval.EvalMemo(node)
+		if err != nil {
+			return fmt.Errorf("csv:%v: %w", line, err)
+		}
+		if err := out.Write(append(record, strconv.FormatFloat(result, 'g', -1, 64))); err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// csvCommand adds a column computed from the other ones to a CSV file, or
+// the standard input
+func csvCommand(args []string) error {
+	flags := flag.NewFlagSet("csv", flag.ExitOnError)
+	expr := flags.String("expr", "", "expression computing the new column from the columns named by the header")
+	column := flags.String("name", "result", "header of the new column")
+	flags.Parse(args)
+	if *expr == "" || flags.NArg() > 1 {
+		return fmt.Errorf("usage: lexp csv -expr expression [-name column] [file.csv]")
+	}
+	var r io.Reader = os.Stdin
+	if flags.NArg() == 1 && flags.Arg(0) != "-" {
+		f, err := os.Open(flags.Arg(0))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	return MapCSV(r, os.Stdout, *expr, *column)
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/fmarmol/lexp/eval"
+	"github.com/fmarmol/lexp/lexer"
+	"github.com/fmarmol/lexp/parser"
+)
+
+// errDebugQuit aborts a debugged evaluation
+var errDebugQuit = errors.New("debugging stopped")
+
+// debugKinds are the node kinds breakpoints can be set on
+var debugKinds = []parser.Kind{
+	parser.KindNumber, parser.KindBool, parser.KindString, parser.KindVar, parser.KindBinary,
+	parser.KindUnary, parser.KindCall, parser.KindAssign, parser.KindSeq, parser.KindProgram,
+	parser.KindWhile, parser.KindFor, parser.KindLambda, parser.KindDef, parser.KindLet,
+	parser.KindList, parser.KindIndex, parser.KindMap, parser.KindRange,
+	parser.KindNil, parser.KindCoalesce, parser.KindTry, parser.KindMatch, parser.KindImport,
+	parser.KindPercent,
+}
+
+const debugHelp = `s, step         step into the node
+n, next         step over the node
+c, continue     run to the next breakpoint
+b, break KIND   stop at the nodes of KIND, one of %v
+d, delete KIND  remove the breakpoint on KIND
+b, break        list the breakpoints
+v, values       show the values computed in the current node
+e, env          show the variables
+q, quit         stop debugging`
+
+// debugger stops an evaluation before the nodes stepped to or of a kind
+// with a breakpoint, reading commands from the REPL input
+type debugger struct {
+	r      *REPL
+	breaks map[parser.Kind]bool
+	// stepping stops at the next node at most as deep as depth, -1 not to
+	// stop but at breakpoints
+	depth int
+	// frames of the nodes being evaluated, the values of their children
+	// being collected as they are computed
+	frames []debugFrame
+}
+
+type debugFrame struct {
+	node   lexer.IExpression
+	values []string
+}
+
+// debug evaluates text, stopping before its first node
+func (r *REPL) debug(text string) error {
+	expr, _, err := r.parse(text)
+	if err != nil {
+		return err
+	}
+	if undefined := r.env.Undefined(expr); len(undefined) > 0 {
+		return parser.UndefinedVariable(undefined[0], r.env.Names(), r.env.Assigned(expr), r.values.Names(), eval.Constants.Names())
+	}
+	d := &debugger{r, map[parser.Kind]bool{}, 0, nil}
+	fmt.Fprintln(r.out, "debugging, h for help")
+	result, err := eval.EvalNumberWithHooks(expr, eval.Hooks{Enter: d.enter, Leave: d.leave})
+	if errors.Is(err, errDebugQuit) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if result.Scalar() {
+		r.env["ans"], r.env["_"] = result.Float64(), result.Float64()
+	}
+	fmt.Fprintln(r.out, r.format(result))
+	return nil
+}
+
+func (d *debugger) enter(node lexer.IExpression) error {
+	d.frames = append(d.frames, debugFrame{node, nil})
+	if (d.depth >= 0 && len(d.frames)-1 <= d.depth) || d.breaks[parser.KindOf(node)] {
+		return d.stop(node)
+	}
+	return nil
+}
+
+func (d *debugger) leave(node lexer.IExpression, v eval.Number) error {
+	value := d.r.Format.Number(v)
+	d.frames = d.frames[:len(d.frames)-1]
+	if len(d.frames) > 0 {
+		parent := &d.frames[len(d.frames)-1]
+		parent.values = append(parent.values, parser.Source(node)+" = "+value)
+	}
+	if d.depth >= 0 && len(d.frames) <= d.depth {
+		fmt.Fprintf(d.r.out, "%v%v => %v\n", d.indent(), parser.Source(node), value)
+	}
+	return nil
+}
+
+func (d *debugger) indent() string { return strings.Repeat("  ", len(d.frames)) }
+
+// stop before node, reading commands until one resumes the evaluation
+func (d *debugger) stop(node lexer.IExpression) error {
+	where := ""
+	if span := lexer.SpanOf(node); span.Start.Line > 0 {
+		where = " at " + spanString(span)
+	}
+	fmt.Fprintf(d.r.out, "%v-> %v [%v]%v\n", d.indent()[2:], parser.Source(node), parser.KindOf(node), where)
+	for {
+		line, err := d.r.readInput("(debug) ")
+		if err != nil {
+			return errDebugQuit
+		}
+		args := strings.Fields(line)
+		if len(args) == 0 {
+			args = []string{"s"}
+		}
+		switch args[0] {
+		case "s", "step":
+			d.depth = len(d.frames)
+			return nil
+		case "n", "next":
+			d.depth = len(d.frames) - 1
+			return nil
+		case "c", "continue":
+			d.depth = -1
+			return nil
+		case "b", "break", "d", "delete":
+			if err := d.breakpoint(args); err != nil {
+				d.r.report(err)
+			}
+		case "v", "values":
+			values := d.frames[len(d.frames)-1].values
+			if len(values) == 0 {
+				fmt.Fprintln(d.r.out, "no values computed yet")
+			}
+			for _, value := range values {
+				fmt.Fprintln(d.r.out, value)
+			}
+		case "e", "env":
+			for _, name := range d.r.env.Names() {
+				fmt.Fprintf(d.r.out, "%v = %v\n", name, d.r.Format.Result(d.r.env[name]))
+			}
+		case "q", "quit":
+			return errDebugQuit
+		case "h", "help":
+			fmt.Fprintf(d.r.out, debugHelp+"\n", debugKinds)
+		default:
+			d.r.report(fmt.Errorf("unknown debugger command %q, h for help", args[0]))
+		}
+	}
+}
+
+// breakpoint runs break and delete
+func (d *debugger) breakpoint(args []string) error {
+	if len(args) == 1 && (args[0] == "b" || args[0] == "break") {
+		kinds := []string{}
+		for kind := range d.breaks {
+			kinds = append(kinds, string(kind))
+		}
+		sort.Strings(kinds)
+		fmt.Fprintln(d.r.out, "breakpoints:", strings.Join(kinds, " "))
+		return nil
+	}
+	if len(args) != 2 {
+		return fmt.Errorf("usage: %v KIND", args[0])
+	}
+	kind := parser.Kind(args[1])
+	for _, k := range debugKinds {
+		if k == kind {
+			d.breaks[kind] = args[0] == "b" || args[0] == "break"
+			if !d.breaks[kind] {
+				delete(d.breaks, kind)
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown node kind %q, expected one of %v", kind, debugKinds)
+}
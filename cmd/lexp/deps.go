@@ -0,0 +1,45 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/fmarmol/lexp/eval"
+	"github.com/fmarmol/lexp/parser"
+)
+
+// depsCommand prints the evaluation order of the formulas of a file, or
+// their dependency graph with -dot
+func depsCommand(args []string) error {
+	flags := flag.NewFlagSet("deps", flag.ExitOnError)
+	dot := flags.Bool("dot", false, "export the dependency graph in the graphviz format")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: lexp deps [-dot] file")
+	}
+	content, err := os.ReadFile(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	formulas, err := parser.ParseFormulas(flags.Arg(0), string(content), eval.Constants)
+	if err != nil {
+		return err
+	}
+	g, err := parser.NewDependencyGraph(formulas)
+	if err != nil {
+		return err
+	}
+	if *dot {
+		fmt.Print(g.DOT())
+		return nil
+	}
+	order, err := g.Order()
+	if err != nil {
+		return err
+	}
+	for _, name := range order {
+		fmt.Println(name)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/fmarmol/lexp/lexer"
+)
+
+// ANSI escapes of the diagnostics
+const (
+	ansiRed   = "\x1b[1;31m"
+	ansiReset = "\x1b[0m"
+)
+
+// diagnostic renders err and, if it is located in the source, the source
+// line with the erroneous span underlined:
+//
+//	err: unexpected token MUL after expression
+//	  1 + 2 * * 3
+//	          ^
+//
+// Joined errors are rendered one after the other.
+func diagnostic(err error, color bool) string {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		diagnostics := []string{}
+		for _, err := range joined.Unwrap() {
+			diagnostics = append(diagnostics, diagnostic(err, color))
+		}
+		return strings.Join(diagnostics, "\n")
+	}
+	paint := func(s string) string {
+		if !color {
+			return s
+		}
+		return ansiRed + s + ansiReset
+	}
+	text := paint("err:") + " " + err.Error()
+	var located lexer.SpanError
+	if !errors.As(err, &located) {
+		return text
+	}
+	start, end := located.Span.Start, located.Span.End
+	content := start.FileContent
+	if start.Index < 0 || start.Index > len(content) {
+		return text
+	}
+	lineStart := strings.LastIndexByte(content[:start.Index], '\n') + 1
+	lineEnd := len(content)
+	if i := strings.IndexByte(content[start.Index:], '\n'); i >= 0 {
+		lineEnd = start.Index + i
+	}
+	// spans going past the line are underlined up to its end
+	width := utf8.RuneCountInString(content[start.Index:lineEnd])
+	if end.Line == start.Line {
+		width = end.Rune - start.Rune
+	}
+	// tabs are kept so that the caret lines up with the span
+	indent := strings.Map(func(r rune) rune {
+		if r == '\t' {
+			return r
+		}
+		return ' '
+	}, content[lineStart:start.Index])
+	caret := "^" + strings.Repeat("~", max(width-1, 0))
+	return text + "\n  " + content[lineStart:lineEnd] + "\n  " + indent + paint(caret)
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Key codes of the line editor
+const (
+	keyCtrlA     = 1
+	keyCtrlB     = 2
+	keyCtrlC     = 3
+	keyCtrlD     = 4
+	keyCtrlE     = 5
+	keyCtrlF     = 6
+	keyCtrlG     = 7
+	keyBackspace = 8
+	keyCtrlK     = 11
+	keyCtrlL     = 12
+	keyEnter     = 13
+	keyCtrlN     = 14
+	keyCtrlP     = 16
+	keyCtrlR     = 18
+	keyCtrlU     = 21
+	keyCtrlW     = 23
+	keyEscape    = 27
+	keyDelete    = 127
+)
+
+// lineEditor reads lines from a terminal in raw mode with readline style
+// editing: arrows, Ctrl-A/E to move to the start or end of the line, up and
+// down to navigate the history and Ctrl-R to search it
+type lineEditor struct {
+	in      *bufio.Reader
+	out     io.Writer
+	history []string
+
+	prompt string
+	line   []rune
+	cursor int
+	// index of the history entry being edited, len(history) for the new
+	// line, whose content is kept in draft while browsing the history
+	index int
+	draft []rune
+}
+
+// ReadLine prints prompt and reads a line, io.EOF being returned on Ctrl-D
+// on an empty line. A bracketed paste is returned as a whole.
+func (e *lineEditor) ReadLine(prompt string) (string, error) {
+	e.prompt, e.line, e.cursor = prompt, nil, 0
+	e.index, e.draft = len(e.history), nil
+	e.refresh()
+	for {
+		key, _, err := e.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+		switch key {
+		case keyEnter, '\n':
+			fmt.Fprint(e.out, "\r\n")
+			return string(e.line), nil
+		case keyCtrlD:
+			if len(e.line) == 0 {
+				fmt.Fprint(e.out, "\r\n")
+				return "", io.EOF
+			}
+			e.deleteAt(e.cursor)
+		case keyCtrlC:
+			fmt.Fprint(e.out, "^C\r\n")
+			e.line, e.cursor = nil, 0
+		case keyCtrlA:
+			e.cursor = 0
+		case keyCtrlE:
+			e.cursor = len(e.line)
+		case keyCtrlB:
+			e.cursor = max(e.cursor-1, 0)
+		case keyCtrlF:
+			e.cursor = min(e.cursor+1, len(e.line))
+		case keyCtrlP:
+			e.browse(-1)
+		case keyCtrlN:
+			e.browse(1)
+		case keyCtrlK:
+			e.line = e.line[:e.cursor]
+		case keyCtrlU:
+			e.line, e.cursor = e.line[e.cursor:], 0
+		case keyCtrlW:
+			start := e.cursor
+			for start > 0 && e.line[start-1] == ' ' {
+				start--
+			}
+			for start > 0 && e.line[start-1] != ' ' {
+				start--
+			}
+			e.line, e.cursor = append(e.line[:start], e.line[e.cursor:]...), start
+		case keyCtrlL:
+			fmt.Fprint(e.out, "\x1b[H\x1b[2J")
+		case keyBackspace, keyDelete:
+			if e.cursor > 0 {
+				e.cursor--
+				e.deleteAt(e.cursor)
+			}
+		case keyCtrlR:
+			if err := e.search(); err != nil {
+				return "", err
+			}
+		case keyEscape:
+			pasted, done, err := e.escape()
+			if err != nil {
+				return "", err
+			}
+			if done {
+				fmt.Fprint(e.out, "\r\n")
+				return string(e.line) + pasted, nil
+			}
+		default:
+			if key >= ' ' {
+				e.insert(key)
+			}
+		}
+		e.refresh()
+	}
+}
+
+// escape handles the escape sequences of the arrows, home, end, delete and
+// bracketed paste, reporting the pasted text if any
+func (e *lineEditor) escape() (string, bool, error) {
+	if next, _, err := e.in.ReadRune(); err != nil || next != '[' {
+		return "", false, err
+	}
+	seq := ""
+	for {
+		c, _, err := e.in.ReadRune()
+		if err != nil {
+			return "", false, err
+		}
+		seq += string(c)
+		if c >= 0x40 && c <= 0x7e {
+			break
+		}
+	}
+	switch seq {
+	case "A":
+		e.browse(-1)
+	case "B":
+		e.browse(1)
+	case "C":
+		e.cursor = min(e.cursor+1, len(e.line))
+	case "D":
+		e.cursor = max(e.cursor-1, 0)
+	case "H", "1~":
+		e.cursor = 0
+	case "F", "4~":
+		e.cursor = len(e.line)
+	case "3~":
+		e.deleteAt(e.cursor)
+	case "200~":
+		pasted, err := e.paste()
+		return pasted, true, err
+	}
+	return "", false, nil
+}
+
+// paste reads the pasted text, up to the end of the bracketed paste
+func (e *lineEditor) paste() (string, error) {
+	text := &strings.Builder{}
+	for !strings.HasSuffix(text.String(), pasteEnd) {
+		c, _, err := e.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+		if c == '\r' {
+			c = '\n'
+		}
+		text.WriteRune(c)
+	}
+	return strings.TrimSuffix(text.String(), pasteEnd), nil
+}
+
+// browse moves by delta in the history, keeping the new line as a draft
+func (e *lineEditor) browse(delta int) {
+	index := e.index + delta
+	if index < 0 || index > len(e.history) {
+		return
+	}
+	if e.index == len(e.history) {
+		e.draft = e.line
+	}
+	e.index = index
+	if index == len(e.history) {
+		e.line = e.draft
+	} else {
+		e.line = []rune(e.history[index])
+	}
+	e.cursor = len(e.line)
+}
+
+// search the history backward for the typed text, Ctrl-R finding older
+// matches. Any other key accepts the match, Ctrl-G cancels.
+func (e *lineEditor) search() error {
+	query, index := "", len(e.history)
+	saved, savedCursor := e.line, e.cursor
+	find := func(from int) {
+		for i := from; i >= 0; i-- {
+			if pos := strings.Index(e.history[i], query); pos >= 0 {
+				index = i
+				e.line = []rune(e.history[i])
+				e.cursor = len([]rune(e.history[i][:pos]))
+				return
+			}
+		}
+	}
+	for {
+		fmt.Fprintf(e.out, "\r(reverse-i-search)`%v': %v\x1b[K", query, string(e.line))
+		key, _, err := e.in.ReadRune()
+		if err != nil {
+			return err
+		}
+		switch {
+		case key == keyCtrlR:
+			find(index - 1)
+		case key == keyCtrlG:
+			e.line, e.cursor = saved, savedCursor
+			return nil
+		case key == keyBackspace || key == keyDelete:
+			if query != "" {
+				query = string([]rune(query)[:len([]rune(query))-1])
+				find(len(e.history) - 1)
+			}
+		case key >= ' ':
+			query += string(key)
+			find(min(index, len(e.history)-1))
+		default:
+			// the key is then handled as usual, Enter evaluating the match
+			if index < len(e.history) {
+				e.index = index
+			}
+			return e.in.UnreadRune()
+		}
+	}
+}
+
+func (e *lineEditor) insert(r rune) {
+	e.line = append(e.line[:e.cursor], append([]rune{r}, e.line[e.cursor:]...)...)
+	e.cursor++
+}
+
+func (e *lineEditor) deleteAt(i int) {
+	if i < len(e.line) {
+		e.line = append(e.line[:i], e.line[i+1:]...)
+	}
+}
+
+// refresh redraws the line and places the cursor
+func (e *lineEditor) refresh() {
+	fmt.Fprintf(e.out, "\r%v%v\x1b[K", e.prompt, string(e.line))
+	if back := len(e.line) - e.cursor; back > 0 {
+		fmt.Fprintf(e.out, "\x1b[%vD", back)
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/fmarmol/lexp/lexer"
+	"github.com/fmarmol/lexp/parser"
+)
+
+// fmtCommand prints programs formatted, or rewrites their files with -w
+func fmtCommand(args []string) error {
+	flags := flag.NewFlagSet("fmt", flag.ExitOnError)
+	write := flags.Bool("w", false, "write the result to the files instead of the standard output")
+	list := flags.Bool("l", false, "list the files whose formatting differs")
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		return fmt.Errorf("usage: lexp fmt [-l] [-w] file...")
+	}
+	for _, name := range flags.Args() {
+		content, err := os.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		formatted, err := formatProgram(name, string(content))
+		if err != nil {
+			return err
+		}
+		changed := formatted != string(content)
+		if *list && changed {
+			fmt.Println(name)
+		}
+		if *write && changed {
+			if err := os.WriteFile(name, []byte(formatted), 0o644); err != nil {
+				return err
+			}
+		}
+		if !*list && !*write {
+			fmt.Print(formatted)
+		}
+	}
+	return nil
+}
+
+// formatProgram prints the statements of text one per line, see
+// parser.Source, with their comments. The comments within a statement are
+// moved before it and blank lines between statements are kept, one at most.
+func formatProgram(fileName, text string) (string, error) {
+	l := lexer.New(fileName, text)
+	tokens, err := l.MakeTokens()
+	if err != nil {
+		return "", err
+	}
+	statements := []lexer.IExpression{}
+	if len(tokens) > 1 {
+		expr, err := parser.New(tokens).Parse()
+		if err != nil {
+			return "", err
+		}
+		statements = append(statements, expr)
+		if program, ok := expr.(parser.ProgramNode); ok {
+			statements = program.Statements
+		}
+	}
+	out := &strings.Builder{}
+	last := 0 // line of the last output
+	emit := func(line int, text string) {
+		if last > 0 && line > last+1 {
+			out.WriteString("\n")
+		}
+		out.WriteString(text + "\n")
+		last = max(last, line)
+	}
+	comments := l.Comments
+	comment := func() (int, string) {
+		line, text := lexer.SpanOf(comments[0]).Start.Line, strings.TrimRight(comments[0].(lexer.TokenComment).Value.(string), " \t\r")
+		comments = comments[1:]
+		return line, text
+	}
+	for i, statement := range statements {
+		span := lexer.SpanOf(statement)
+		for len(comments) > 0 && lexer.SpanOf(comments[0]).Start.Line < span.End.Line {
+			line, text := comment()
+			emit(min(line, span.Start.Line), text)
+		}
+		line := parser.Source(statement)
+		// a comment following statements on the same line ends the last one
+		sameLine := i+1 < len(statements) && lexer.SpanOf(statements[i+1]).End.Line == span.End.Line
+		if len(comments) > 0 && lexer.SpanOf(comments[0]).Start.Line == span.End.Line && !sameLine {
+			_, text := comment()
+			line += " " + text
+		}
+		emit(span.Start.Line, line)
+		last = max(last, span.End.Line)
+	}
+	for len(comments) > 0 {
+		emit(comment())
+	}
+	return out.String(), nil
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"go/token"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/fmarmol/lexp/eval"
+	"github.com/fmarmol/lexp/lexer"
+	"github.com/fmarmol/lexp/parser"
+)
+
+// GenerateGo compiles formulas into the Go source of a package with one
+// exported function per formula, taking the inputs it depends on as float64
+// parameters. Formulas referring to other formulas call their functions.
+func GenerateGo(pkg string, formulas []parser.Formula) ([]byte, error) {
+	g, err := parser.NewDependencyGraph(formulas)
+	if err != nil {
+		return nil, err
+	}
+	order, err := g.Order()
+	if err != nil {
+		return nil, err
+	}
+	gen := &goGenerator{graph: g, params: map[string][]string{}}
+	body := &bytes.Buffer{}
+	for _, name := range order {
+		params := gen.paramsOf(name)
+		expr, err := gen.expr(g.Formulas[name].Expr)
+		if err != nil {
+			return nil, fmt.Errorf("formula %v: %w", name, err)
+		}
+		expr = stripParens(expr)
+		args := make([]string, len(params))
+		for i, param := range params {
+			args[i] = gen.param(param)
+		}
+		typ := ""
+		if len(args) > 0 {
+			typ = " float64"
+		}
+		fmt.Fprintf(body, "\n// %v is generated from the formula %v\n", gen.funcName(name), name)
+		fmt.Fprintf(body, "func %v(%v%v) float64 {\n\treturn %v\n}\n", gen.funcName(name), strings.Join(args, ", "), typ, expr)
+	}
+
+	src := &bytes.Buffer{}
+	fmt.Fprintln(src, "// Code generated by lexp gen. DO NOT EDIT.")
+	fmt.Fprintf(src, "\npackage %v\n", pkg)
+	if gen.math {
+		fmt.Fprintln(src, "\nimport \"math\"")
+	}
+	src.Write(body.Bytes())
+	if gen.bools {
+		fmt.Fprint(src, "\n// boolFloat converts the result of a comparison or a logical operator\nfunc boolFloat(b bool) float64 {\n\tif b {\n\t\treturn 1\n\t}\n\treturn 0\n}\n")
+	}
+	return format.Source(src.Bytes())
+}
+
+type goGenerator struct {
+	graph  *parser.DependencyGraph
+	params map[string][]string // sorted inputs each formula depends on
+	math   bool                // the math package is used
+	bools  bool                // boolFloat is used
+}
+
+// paramsOf returns the inputs name depends on, directly or through other
+// formulas
+func (g *goGenerator) paramsOf(name string) []string {
+	if params, ok := g.params[name]; ok {
+		return params
+	}
+	seen := map[string]bool{}
+	params := []string{}
+	for _, dep := range g.graph.Deps[name] {
+		deps := []string{dep}
+		if _, ok := g.graph.Formulas[dep]; ok {
+			deps = g.paramsOf(dep)
+		}
+		for _, d := range deps {
+			if !seen[d] {
+				seen[d] = true
+				params = append(params, d)
+			}
+		}
+	}
+	sort.Strings(params)
+	g.params[name] = params
+	return params
+}
+
+func (g *goGenerator) expr(node lexer.IExpression) (string, error) {
+	switch n := node.(type) {
+	case lexer.TokenInt, lexer.TokenFloat, lexer.TokenBool:
+		v, _ := n.Eval()
+		return g.float(v), nil
+	case parser.VarNode:
+		if _, ok := g.graph.Formulas[n.Name()]; !ok {
+			return g.param(n.Name()), nil
+		}
+		args := []string{}
+		for _, param := range g.paramsOf(n.Name()) {
+			args = append(args, g.param(param))
+		}
+		return g.funcName(n.Name()) + "(" + strings.Join(args, ", ") + ")", nil
+	case parser.CallNode:
+		args := make([]string, len(n.Args))
+		for i, arg := range n.Args {
+			var err error
+			if args[i], err = g.expr(arg); err != nil {
+				return "", err
+			}
+		}
+		f, ok := goFuncs[n.Name()]
+		if !ok {
+			return "", fmt.Errorf("unsupported function %v", n.Name())
+		}
+		if err := eval.Builtins[n.Name()].CheckArgs(n.Name(), len(args)); err != nil {
+			return "", err
+		}
+		g.math = true
+		if n.Name() == "min" || n.Name() == "max" {
+			expr := args[0]
+			for _, arg := range args[1:] {
+				expr = f + "(" + expr + ", " + arg + ")"
+			}
+			return expr, nil
+		}
+		return f + "(" + strings.Join(args, ", ") + ")", nil
+	case parser.UnaryOpNode:
+		operand, err := g.expr(n.Operand)
+		if err != nil {
+			return "", err
+		}
+		switch parser.OpType(n.Op) {
+		case lexer.TypePlus:
+			return "(+" + operand + ")", nil
+		case lexer.TypeMinus:
+			return "(-" + operand + ")", nil
+		case lexer.TypeNot:
+			g.bools = true
+			return "boolFloat(" + operand + " == 0)", nil
+		}
+		return "", fmt.Errorf("unsupported operator %v", n.Op)
+	case parser.BinOpNode:
+		left, err := g.expr(n.Left)
+		if err != nil {
+			return "", err
+		}
+		right, err := g.expr(n.Right)
+		if err != nil {
+			return "", err
+		}
+		switch parser.OpType(n.Op) {
+		case lexer.TypePlus:
+			return "(" + left + " + " + right + ")", nil
+		case lexer.TypeMinus:
+			return "(" + left + " - " + right + ")", nil
+		case lexer.TypeMul:
+			return "(" + left + " * " + right + ")", nil
+		case lexer.TypeDiv:
+			return "(" + left + " / " + right + ")", nil
+		case lexer.TypePow:
+			g.math = true
+			return "math.Pow(" + left + ", " + right + ")", nil
+		case lexer.TypeShl:
+			g.math = true
+			return "(math.Trunc(" + left + ") * math.Pow(2, math.Trunc(" + right + ")))", nil
+		case lexer.TypeShr:
+			g.math = true
+			return "math.Floor(math.Trunc(" + left + ") / math.Pow(2, math.Trunc(" + right + ")))", nil
+		}
+		switch parser.OpType(n.Op) {
+		case lexer.TypeAnd:
+			g.bools = true
+			return "boolFloat(" + left + " != 0 && " + right + " != 0)", nil
+		case lexer.TypeOr:
+			g.bools = true
+			return "boolFloat(" + left + " != 0 || " + right + " != 0)", nil
+		}
+		if op, ok := goComparisons[parser.OpType(n.Op)]; ok {
+			g.bools = true
+			return "boolFloat(" + left + " " + op + " " + right + ")", nil
+		}
+		return "", fmt.Errorf("unsupported operator %v", n.Op)
+	case parser.PercentNode:
+		return g.percent(n)
+	}
+	return "", fmt.Errorf("unsupported expression %v", node)
+}
+
+// percent computes the part of the base of n, 1 if it has none, and adds it
+// to or subtracts it from the base if n is relative
+func (g *goGenerator) percent(n parser.PercentNode) (string, error) {
+	percent, err := g.expr(n.Percent)
+	if err != nil || n.Base == nil {
+		return "(" + percent + " / 100)", err
+	}
+	base, err := g.expr(n.Base)
+	if err != nil {
+		return "", err
+	}
+	part := "(" + base + " * " + percent + " / 100)"
+	switch {
+	case !n.Relative():
+		return part, nil
+	case parser.OpType(n.Op) == lexer.TypePlus:
+		return "(" + base + " + " + part + ")", nil
+	}
+	return "(" + base + " - " + part + ")", nil
+}
+
+// goComparisons maps the comparisons to their Go operator
+var goComparisons = map[lexer.Type]string{
+	lexer.TypeLT: "<", lexer.TypeLE: "<=", lexer.TypeGT: ">", lexer.TypeGE: ">=", lexer.TypeEQ: "==", lexer.TypeNE: "!=",
+}
+
+// goFuncs maps the builtins to their math package equivalent
+var goFuncs = map[string]string{
+	"abs": "math.Abs", "sqrt": "math.Sqrt", "cbrt": "math.Cbrt", "exp": "math.Exp",
+	"ln": "math.Log", "log": "math.Log", "log2": "math.Log2", "log10": "math.Log10",
+	"sin": "math.Sin", "cos": "math.Cos", "tan": "math.Tan",
+	"asin": "math.Asin", "acos": "math.Acos", "atan": "math.Atan",
+	"sinh": "math.Sinh", "cosh": "math.Cosh", "tanh": "math.Tanh",
+	"floor": "math.Floor", "ceil": "math.Ceil", "round": "math.Round", "trunc": "math.Trunc",
+	"pow": "math.Pow", "atan2": "math.Atan2", "hypot": "math.Hypot", "mod": "math.Mod",
+	"min": "math.Min", "max": "math.Max",
+}
+
+// goConsts maps the values of the constants to their math package
+// equivalent
+var goConsts = map[float64]string{math.Pi: "math.Pi", math.E: "math.E", 2 * math.Pi: "(2 * math.Pi)"}
+
+// stripParens removes the parentheses around the whole of expr
+func stripParens(expr string) string {
+	if !strings.HasPrefix(expr, "(") {
+		return expr
+	}
+	depth := 0
+	for i, c := range expr {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 && i < len(expr)-1 {
+				return expr
+			}
+		}
+	}
+	return expr[1 : len(expr)-1]
+}
+
+// funcName exports the name of a formula
+func (g *goGenerator) funcName(name string) string {
+	if name[0] == '_' {
+		return "F" + name
+	}
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+// param renames inputs clashing with Go keywords, the math package or a
+// generated function
+func (g *goGenerator) param(name string) string {
+	if token.IsKeyword(name) || name == "math" || name == "_" || g.isFunc(name) {
+		return name + "_"
+	}
+	return name
+}
+
+func (g *goGenerator) isFunc(name string) bool {
+	for formula := range g.graph.Formulas {
+		if g.funcName(formula) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// float formats v as a float64 constant
+func (g *goGenerator) float(v float64) string {
+	if math.IsInf(v, 0) {
+		g.math = true
+		return fmt.Sprintf("math.Inf(%v)", math.Copysign(1, v))
+	}
+	if c, ok := goConsts[v]; ok {
+		g.math = true
+		return c
+	}
+	s := strconv.FormatFloat(v, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".e") {
+		s += ".0"
+	}
+	return s
+}
+
+// genCommand writes the Go code of the formulas of a file
+func genCommand(args []string) error {
+	flags := flag.NewFlagSet("gen", flag.ExitOnError)
+	pkg := flags.String("pkg", "formulas", "name of the generated package")
+	out := flags.String("o", "", "output file, standard output by default")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: lexp gen [-pkg name] [-o file.go] file")
+	}
+	content, err := os.ReadFile(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	formulas, err := parser.ParseFormulas(flags.Arg(0), string(content), eval.Constants)
+	if err != nil {
+		return err
+	}
+	src, err := GenerateGo(*pkg, formulas)
+	if err != nil {
+		return err
+	}
+	if *out == "" {
+		_, err = os.Stdout.Write(src)
+		return err
+	}
+	return os.WriteFile(*out, src, 0o644)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// maxHistory entries loaded from the history file
+const maxHistory = 1000
+
+// defaultHistoryFile is ~/.lexp_history, empty if the home directory is
+// unknown
+func defaultHistoryFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".lexp_history")
+}
+
+// loadHistory reads the last maxHistory entries of the history file, one
+// quoted entry per line so that pasted blocks fit a line. A missing file is
+// an empty history.
+func loadHistory(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	history := []string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		entry, err := strconv.Unquote(scanner.Text())
+		if err != nil {
+			// written by hand
+			entry = scanner.Text()
+		}
+		history = append(history, entry)
+	}
+	if len(history) > maxHistory {
+		history = history[len(history)-maxHistory:]
+	}
+	return history, scanner.Err()
+}
+
+// openHistory opens the history file for appending entries
+func openHistory(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+}
+
+// appendHistory writes entry at the end of the history file
+func appendHistory(f *os.File, entry string) error {
+	_, err := fmt.Fprintln(f, strconv.Quote(entry))
+	return err
+}
@@ -0,0 +1,530 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fmarmol/lexp/eval"
+	"github.com/fmarmol/lexp/lexer"
+	"github.com/fmarmol/lexp/parser"
+)
+
+// KernelConnection is the connection file written by Jupyter for a kernel
+type KernelConnection struct {
+	Transport       string `json:"transport"`
+	IP              string `json:"ip"`
+	ShellPort       int    `json:"shell_port"`
+	IOPubPort       int    `json:"iopub_port"`
+	StdinPort       int    `json:"stdin_port"`
+	ControlPort     int    `json:"control_port"`
+	HBPort          int    `json:"hb_port"`
+	Key             string `json:"key"`
+	SignatureScheme string `json:"signature_scheme"`
+}
+
+const (
+	jupyterProtocolVersion = "5.3"
+	jupyterDelimiter       = "<IDS|MSG>"
+)
+
+// jupyterMsg of the Jupyter messaging protocol
+type jupyterMsg struct {
+	Identities   [][]byte
+	Header       map[string]any
+	ParentHeader map[string]any
+	Metadata     map[string]any
+	Content      map[string]any
+}
+
+// Type of the message
+func (m jupyterMsg) Type() string {
+	t, _ := m.Header["msg_type"].(string)
+	return t
+}
+
+// Kernel runs lexp as a Jupyter kernel, keeping one REPL session state
+// across cells
+type Kernel struct {
+	conn      KernelConnection
+	session   string
+	repl      *REPL
+	iopub     *zmtpPublisher
+	count     int
+	mu        sync.Mutex // cells are executed one at a time
+	shutdown  chan struct{}
+	closeOnce sync.Once
+	Log       *log.Logger
+}
+
+// NewKernel ...
+func NewKernel(conn KernelConnection, format eval.Format, width *parser.IntWidth) *Kernel {
+	repl := NewREPL(strings.NewReader(""), io.Discard)
+	repl.Format = format
+	repl.Width = width
+	return &Kernel{
+		conn:     conn,
+		session:  newUUID(),
+		repl:     repl,
+		iopub:    newZmtpPublisher(),
+		shutdown: make(chan struct{}),
+		Log:      log.Default(),
+	}
+}
+
+// Run listens on the ports of the connection file until a shutdown request
+func (k *Kernel) Run() error {
+	if k.conn.Transport != "tcp" {
+		return fmt.Errorf("kernel: unsupported transport %q", k.conn.Transport)
+	}
+	if k.conn.Key != "" && k.conn.SignatureScheme != "hmac-sha256" {
+		return fmt.Errorf("kernel: unsupported signature scheme %q", k.conn.SignatureScheme)
+	}
+	listen := func(port int) (net.Listener, error) {
+		return net.Listen("tcp", net.JoinHostPort(k.conn.IP, fmt.Sprint(port)))
+	}
+	ports := []int{k.conn.ShellPort, k.conn.ControlPort, k.conn.IOPubPort, k.conn.StdinPort, k.conn.HBPort}
+	listeners := make([]net.Listener, len(ports))
+	for i, port := range ports {
+		l, err := listen(port)
+		if err != nil {
+			return err
+		}
+		defer l.Close()
+		listeners[i] = l
+	}
+	go k.serveRouter(listeners[0])
+	go k.serveRouter(listeners[1])
+	go k.iopub.Serve(listeners[2])
+	go k.serveStdin(listeners[3])
+	go k.serveHeartbeat(listeners[4])
+	k.publish("status", map[string]any{"execution_state": "starting"}, nil)
+	<-k.shutdown
+	return nil
+}
+
+func (k *Kernel) serveRouter(l net.Listener) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			z, err := zmtpHandshake(conn, "ROUTER")
+			if err != nil {
+				k.Log.Println("kernel:", err)
+				conn.Close()
+				return
+			}
+			defer z.Close()
+			for {
+				frames, err := z.ReadMessage()
+				if errors.Is(err, errMessageTooLarge) {
+					k.Log.Println("kernel:", err)
+				}
+				if err != nil {
+					return
+				}
+				msg, err := k.decode(frames)
+				if err != nil {
+					k.Log.Println("kernel:", err)
+					continue
+				}
+				k.handle(z, msg)
+			}
+		}()
+	}
+}
+
+// serveStdin accepts connections, the kernel never asks for input
+func (k *Kernel) serveStdin(l net.Listener) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			z, err := zmtpHandshake(conn, "ROUTER")
+			if err != nil {
+				conn.Close()
+				return
+			}
+			defer z.Close()
+			for {
+				if _, err := z.ReadMessage(); err != nil {
+					return
+				}
+			}
+		}()
+	}
+}
+
+// serveHeartbeat echoes every message back
+func (k *Kernel) serveHeartbeat(l net.Listener) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			z, err := zmtpHandshake(conn, "REP")
+			if err != nil {
+				conn.Close()
+				return
+			}
+			defer z.Close()
+			for {
+				frames, err := z.ReadMessage()
+				if err != nil || z.WriteMessage(frames) != nil {
+					return
+				}
+			}
+		}()
+	}
+}
+
+func (k *Kernel) handle(z *zmtpConn, msg jupyterMsg) {
+	k.publish("status", map[string]any{"execution_state": "busy"}, msg.Header)
+	defer k.publish("status", map[string]any{"execution_state": "idle"}, msg.Header)
+
+	replyType := strings.TrimSuffix(msg.Type(), "_request") + "_reply"
+	var content map[string]any
+	switch msg.Type() {
+	case "kernel_info_request":
+		content = map[string]any{
+			"status":                 "ok",
+			"protocol_version":       jupyterProtocolVersion,
+			"implementation":         "lexp",
+			"implementation_version": "0.1",
+			"language_info": map[string]any{
+				"name":           "lexp",
+				"version":        "0.1",
+				"mimetype":       "text/x-lexp",
+				"file_extension": ".lx",
+			},
+			"banner":     "lexp, an arithmetic expression evaluator",
+			"help_links": []any{},
+		}
+	case "execute_request":
+		content = k.execute(msg)
+	case "is_complete_request":
+		content = map[string]any{"status": "complete"}
+	case "complete_request":
+		content = k.complete(msg)
+	case "inspect_request":
+		content = map[string]any{"status": "ok", "found": false, "data": map[string]any{}, "metadata": map[string]any{}}
+	case "history_request":
+		content = map[string]any{"status": "ok", "history": []any{}}
+	case "comm_info_request":
+		content = map[string]any{"status": "ok", "comms": map[string]any{}}
+	case "interrupt_request":
+		content = map[string]any{"status": "ok"}
+	case "shutdown_request":
+		restart, _ := msg.Content["restart"].(bool)
+		content = map[string]any{"status": "ok", "restart": restart}
+		defer k.closeOnce.Do(func() { close(k.shutdown) })
+	default:
+		k.Log.Println("kernel: unsupported message", msg.Type())
+		return
+	}
+	reply := k.newMsg(replyType, content, msg.Header)
+	reply.Identities = msg.Identities
+	if err := z.WriteMessage(k.encode(reply)); err != nil {
+		k.Log.Println("kernel:", err)
+	}
+}
+
+// execute runs each statement of a cell, the result of the last expression
+// being the result of the cell
+func (k *Kernel) execute(msg jupyterMsg) map[string]any {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	code, _ := msg.Content["code"].(string)
+	silent, _ := msg.Content["silent"].(bool)
+	storeHistory, ok := msg.Content["store_history"].(bool)
+	if !silent && (storeHistory || !ok) {
+		k.count++
+	}
+	k.publish("execute_input", map[string]any{"code": code, "execution_count": k.count}, msg.Header)
+
+	var last map[string]any
+	for _, line := range cellLines(code) {
+		data, err := k.executeLine(line)
+		if err != nil {
+			k.publish("error", map[string]any{
+				"ename":     "Error",
+				"evalue":    err.Error(),
+				"traceback": []string{"err: " + err.Error()},
+			}, msg.Header)
+			return map[string]any{
+				"status":          "error",
+				"execution_count": k.count,
+				"ename":           "Error",
+				"evalue":          err.Error(),
+				"traceback":       []string{"err: " + err.Error()},
+			}
+		}
+		if data["rich"] != nil {
+			k.publish("display_data", map[string]any{"data": data["rich"], "metadata": map[string]any{}, "transient": map[string]any{}}, msg.Header)
+		} else if data["stream"] != nil {
+			k.publish("stream", map[string]any{"name": "stdout", "text": data["stream"]}, msg.Header)
+		} else {
+			last = data
+		}
+	}
+	if last != nil && !silent {
+		k.publish("execute_result", map[string]any{
+			"execution_count": k.count,
+			"data":            last,
+			"metadata":        map[string]any{},
+		}, msg.Header)
+	}
+	return map[string]any{
+		"status":           "ok",
+		"execution_count":  k.count,
+		"payload":          []any{},
+		"user_expressions": map[string]any{},
+	}
+}
+
+// cellLines splits code into its commands and statements, the lines of an
+// expression cut short being joined to the following ones like in the REPL
+func cellLines(code string) []string {
+	var lines []string
+	text := ""
+	for _, line := range strings.Split(code, "\n") {
+		if text == "" {
+			if line = strings.TrimSpace(line); line == "" {
+				continue
+			}
+			text = line
+		} else {
+			text += "\n" + line
+		}
+		if !strings.HasPrefix(text, ":") {
+			if tokens, err := lexer.New("stdin", text).MakeTokens(); err == nil && tokens.Incomplete() {
+				continue
+			}
+		}
+		lines, text = append(lines, text), ""
+	}
+	if text != "" {
+		lines = append(lines, text)
+	}
+	return lines
+}
+
+// executeLine returns the mime bundle of an expression result, or under the
+// stream or rich keys the output of a REPL command
+func (k *Kernel) executeLine(line string) (map[string]any, error) {
+	if strings.HasPrefix(line, ":") {
+		args := strings.Fields(line[1:])
+		if len(args) > 0 && args[0] == "table" {
+			return k.table(strings.Join(args[1:], " "))
+		}
+		out := &bytes.Buffer{}
+		k.repl.out = out
+		err := k.repl.command(args)
+		k.repl.out = io.Discard
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"stream": out.String()}, nil
+	}
+	expr, _, err := k.repl.parse(line)
+	if err != nil {
+		return nil, err
+	}
+	result, err := k.repl.run(expr)
+	if err != nil {
+		return nil, err
+	}
+	text := k.repl.format(result)
+	if k.repl.bits && k.repl.Width != nil {
+		text += "\n" + k.repl.bitsView(result)
+	}
+	return map[string]any{
+		"text/plain": text,
+		"text/html": fmt.Sprintf("<pre>%v</pre><details><summary>AST</summary><pre>%v</pre></details>",
+			html.EscapeString(text), html.EscapeString(fmt.Sprint(expr))),
+	}, nil
+}
+
+// table renders :table as an HTML table
+func (k *Kernel) table(spec string) (map[string]any, error) {
+	s, err := eval.ParseTableSpec(k.repl.env, spec)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := eval.Tabulate(k.repl.env, s)
+	if err != nil {
+		return nil, err
+	}
+	text := &bytes.Buffer{}
+	eval.WriteTable(text, s, rows, k.repl.Format)
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "<table><tr><th>%v</th><th>%v</th></tr>", html.EscapeString(s.Var), html.EscapeString(s.Expr))
+	for _, row := range rows {
+		fmt.Fprintf(b, "<tr><td>%v</td><td>%v</td></tr>", k.repl.Format.Result(row[0]), k.repl.Format.Result(row[1]))
+	}
+	b.WriteString("</table>")
+	return map[string]any{"rich": map[string]any{"text/plain": text.String(), "text/html": b.String()}}, nil
+}
+
+// complete variable names from the session environment, builtin function
+// names, constants and keywords
+func (k *Kernel) complete(msg jupyterMsg) map[string]any {
+	code, _ := msg.Content["code"].(string)
+	cursor := len(code)
+	if c, ok := msg.Content["cursor_pos"].(float64); ok && int(c) <= len(code) {
+		cursor = int(c)
+	}
+	start := cursor
+	for start > 0 && (lexer.IsLetter(rune(code[start-1])) || lexer.IsDigit(rune(code[start-1]))) {
+		start--
+	}
+	prefix := code[start:cursor]
+	k.mu.Lock()
+	matches := []string{}
+	for _, name := range k.repl.env.Names() {
+		if strings.HasPrefix(name, prefix) {
+			matches = append(matches, name)
+		}
+	}
+	k.mu.Unlock()
+	for _, name := range slices.Concat(eval.Builtins.Names(), eval.Constants.Names(), lexer.Keywords) {
+		if strings.HasPrefix(name, prefix) {
+			matches = append(matches, name)
+		}
+	}
+	return map[string]any{
+		"status":       "ok",
+		"matches":      matches,
+		"cursor_start": start,
+		"cursor_end":   cursor,
+		"metadata":     map[string]any{},
+	}
+}
+
+func (k *Kernel) newMsg(msgType string, content map[string]any, parent map[string]any) jupyterMsg {
+	if parent == nil {
+		parent = map[string]any{}
+	}
+	return jupyterMsg{
+		Header: map[string]any{
+			"msg_id":   newUUID(),
+			"session":  k.session,
+			"username": "lexp",
+			"date":     time.Now().UTC().Format(time.RFC3339Nano),
+			"msg_type": msgType,
+			"version":  jupyterProtocolVersion,
+		},
+		ParentHeader: parent,
+		Metadata:     map[string]any{},
+		Content:      content,
+	}
+}
+
+func (k *Kernel) publish(msgType string, content map[string]any, parent map[string]any) {
+	msg := k.newMsg(msgType, content, parent)
+	msg.Identities = [][]byte{[]byte(msgType)}
+	k.iopub.Publish(k.encode(msg))
+}
+
+func (k *Kernel) sign(parts [][]byte) []byte {
+	if k.conn.Key == "" {
+		return nil
+	}
+	mac := hmac.New(sha256.New, []byte(k.conn.Key))
+	for _, part := range parts {
+		mac.Write(part)
+	}
+	return []byte(hex.EncodeToString(mac.Sum(nil)))
+}
+
+func (k *Kernel) encode(msg jupyterMsg) [][]byte {
+	parts := make([][]byte, 4)
+	for i, v := range []any{msg.Header, msg.ParentHeader, msg.Metadata, msg.Content} {
+		parts[i], _ = json.Marshal(v)
+	}
+	frames := append([][]byte{}, msg.Identities...)
+	frames = append(frames, []byte(jupyterDelimiter), k.sign(parts))
+	return append(frames, parts...)
+}
+
+func (k *Kernel) decode(frames [][]byte) (jupyterMsg, error) {
+	msg := jupyterMsg{}
+	i := 0
+	for i < len(frames) && string(frames[i]) != jupyterDelimiter {
+		i++
+	}
+	if len(frames) < i+6 {
+		return msg, fmt.Errorf("malformed message of %v frames", len(frames))
+	}
+	msg.Identities = frames[:i]
+	signature, parts := frames[i+1], frames[i+2:i+6]
+	if k.conn.Key != "" && !hmac.Equal(signature, k.sign(parts)) {
+		return msg, fmt.Errorf("invalid message signature")
+	}
+	for j, v := range []*map[string]any{&msg.Header, &msg.ParentHeader, &msg.Metadata, &msg.Content} {
+		if err := json.Unmarshal(parts[j], v); err != nil {
+			return msg, err
+		}
+	}
+	return msg, nil
+}
+
+func newUUID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// kernelSpec to install in a jupyter kernels directory as kernel.json
+const kernelSpec = `{
+  "argv": ["lexp", "kernel", "-f", "{connection_file}"],
+  "display_name": "lexp",
+  "language": "lexp"
+}
+`
+
+// kernelCommand runs the Jupyter kernel
+func kernelCommand(args []string, format eval.Format, width *parser.IntWidth) error {
+	flags := flag.NewFlagSet("kernel", flag.ExitOnError)
+	connectionFile := flags.String("f", "", "connection file written by Jupyter")
+	spec := flags.Bool("spec", false, "print the kernel.json spec to install the kernel")
+	flags.Parse(args)
+	if *spec {
+		fmt.Print(kernelSpec)
+		return nil
+	}
+	if *connectionFile == "" {
+		return fmt.Errorf("usage: lexp kernel -f connection.json")
+	}
+	content, err := os.ReadFile(*connectionFile)
+	if err != nil {
+		return err
+	}
+	conn := KernelConnection{}
+	if err := json.Unmarshal(content, &conn); err != nil {
+		return fmt.Errorf("%v: %w", *connectionFile, err)
+	}
+	return NewKernel(conn, format, width).Run()
+}
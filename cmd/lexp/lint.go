@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/fmarmol/lexp/parser"
+)
+
+// lintCommand prints the warnings about the files, as a JSON array with
+// -f json, exiting with 1 if there are some
+func lintCommand(args []string, repl *REPL) error {
+	flags := flag.NewFlagSet("lint", flag.ExitOnError)
+	format := flags.String("f", "text", "output format, text or json")
+	flags.Parse(args)
+	if flags.NArg() == 0 || *format != "text" && *format != "json" {
+		return fmt.Errorf("usage: lexp lint [-f text|json] file...")
+	}
+	warnings := []parser.Warning{}
+	for _, name := range flags.Args() {
+		content, err := os.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		expr, tokens, err := repl.parseFile(name, string(content), readImport)
+		if err != nil {
+			return err
+		}
+		warnings = append(warnings, parser.Lint(expr, tokens)...)
+	}
+	if *format == "json" {
+		out, err := json.Marshal(warnings)
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
+	} else {
+		for _, w := range warnings {
+			fmt.Println(w)
+		}
+	}
+	if len(warnings) > 0 {
+		os.Exit(1)
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/fmarmol/lexp/eval"
+	"github.com/fmarmol/lexp/parser"
+)
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func main() {
+	base := flag.Int("base", 10, "also show integer results in dec/hex/oct/bin, starting by this base (2, 8 or 16)")
+	notation := flag.String("notation", "plain", "notation of the results: plain, sci or eng")
+	sig := flag.Int("sig", 0, "round results to this many significant digits, 0 to disable")
+	precision := flag.Int("precision", 0, "round float results to this many decimal places, 0 to disable")
+	scientific := flag.Bool("scientific", false, "print results in scientific notation, like -notation sci")
+	printf := flag.String("format", "", "format float results with this printf verb, like %.4e")
+	zeros := flag.Bool("zeros", false, "keep the trailing zeros of rounded results")
+	widthSpec := flag.String("width", "off", "fixed width integer mode: off, i8, i16, i32, i64, u8, u16, u32 or u64")
+	angleSpec := flag.String("angle", "rad", "unit of the angles of the trigonometric functions: rad or deg")
+	divSpec := flag.String("div", "true", "division semantics: true (always float), trunc (truncate integers) or exact (fail on inexact integer division)")
+	big := flag.Bool("big", false, "compute with integers of unlimited size and arbitrary precision floats")
+	prec := flag.Uint("prec", eval.DefaultPrecision, "precision in bits of the floats with -big")
+	rat := flag.Bool("rat", false, "compute with exact rationals")
+	frac := flag.Bool("frac", false, "print rational results as fractions")
+	mixed := flag.Bool("mixed", false, "print rational results as mixed numbers, like 1 1/3")
+	decimals := flag.Int("decimals", eval.DefaultFormat.Decimals, "decimal places of rational results")
+	places := flag.Int("decimal", -1, "compute in fixed point, rounding results to this many decimal places, -1 to disable")
+	roundSpec := flag.String("round", string(eval.DefaultDecimal.Rounding), "rounding of -decimal: half-even, half-up, down, up, floor or ceiling")
+	oneShot := flag.String("e", "", "evaluate this expression, print its result and exit")
+	historyFile := flag.String("history", defaultHistoryFile(), "file keeping the REPL history across sessions")
+	noHistory := flag.Bool("no-history", false, "do not load nor save the REPL history")
+	trace := flag.Bool("trace", false, "print each reduction step of the evaluations")
+	maxSteps := flag.Int("max-steps", 0, "abort the evaluations taking more than this many steps, 0 for no limit")
+	flag.Parse()
+
+	format := eval.DefaultFormat
+	if err := format.SetBase(*base); err != nil {
+		log.Fatal(err)
+	}
+	if err := format.SetNotation(*notation); err != nil {
+		log.Fatal(err)
+	}
+	if err := format.SetSigFigs(*sig); err != nil {
+		log.Fatal(err)
+	}
+	if err := format.SetPrecision(*precision); err != nil {
+		log.Fatal(err)
+	}
+	if err := format.SetPrintf(*printf); err != nil {
+		log.Fatal(err)
+	}
+	if *scientific {
+		format.Notation = eval.NotationScientific
+	}
+	format.TrailingZeros = *zeros
+	if err := format.SetDecimals(*decimals); err != nil {
+		log.Fatal(err)
+	}
+	format.Fraction, format.Mixed = *frac || *mixed, *mixed
+
+	width, err := parser.ParseIntWidth(*widthSpec)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	division, err := parser.ParseDivision(*divSpec)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	angle, err := eval.ParseAngle(*angleSpec)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var decimal *eval.Decimal
+	if *places >= 0 {
+		rounding, err := eval.ParseRounding(*roundSpec)
+		if err != nil {
+			log.Fatal(err)
+		}
+		decimal = &eval.Decimal{Places: *places, Rounding: rounding}
+	}
+
+	repl := NewREPL(os.Stdin, os.Stdout)
+	repl.Color = isTerminal(os.Stderr)
+	repl.Format = format
+	repl.Width = width
+	repl.Division = division
+	repl.SetAngle(angle)
+	if *big {
+		repl.Precision = *prec
+	}
+	repl.Rational = *rat
+	repl.Decimal = decimal
+	repl.Trace = *trace
+	if repl.Trace && (repl.Rational || repl.Decimal != nil || repl.Precision > 0) {
+		log.Fatal("-trace needs the float arithmetic, without -rat, -big or -decimal")
+	}
+	repl.Budget.MaxNodes = *maxSteps
+
+	switch flag.Arg(0) {
+	case "deps":
+		err = depsCommand(flag.Args()[1:])
+	case "csv":
+		err = csvCommand(flag.Args()[1:])
+	case "gen":
+		err = genCommand(flag.Args()[1:])
+	case "parse":
+		err = parseCommand(flag.Args()[1:])
+	case "fmt":
+		err = fmtCommand(flag.Args()[1:])
+	case "run":
+		err = runCommand(flag.Args()[1:], repl)
+	case "check":
+		err = checkCommand(flag.Args()[1:], repl)
+	case "lint":
+		err = lintCommand(flag.Args()[1:], repl)
+	case "watch":
+		err = watchCommand(flag.Args()[1:], repl)
+	case "serve-repl":
+		err = serveReplCommand(flag.Args()[1:], format, width)
+	case "kernel":
+		err = kernelCommand(flag.Args()[1:], format, width)
+	case "tutor":
+		err = tutorCommand(os.Stdin, os.Stdout)
+	default:
+		if *oneShot != "" {
+			if err := repl.Print(*oneShot); err != nil {
+				repl.report(err)
+				os.Exit(1)
+			}
+			break
+		}
+		repl.BracketedPaste = isTerminal(os.Stdin)
+		if repl.BracketedPaste {
+			repl.Terminal = os.Stdin
+			if !*noHistory {
+				repl.HistoryFile = *historyFile
+			}
+		} else {
+			repl.Batch = true
+			repl.Log = log.New(os.Stderr, "", 0)
+		}
+		if isTerminal(os.Stderr) {
+			repl.Spinner = os.Stderr
+		}
+		repl.Run()
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/fmarmol/lexp/eval"
+	"github.com/fmarmol/lexp/lexer"
+	"github.com/fmarmol/lexp/parser"
+)
+
+// parseCommand prints the AST of an expression, as JSON with -json
+func parseCommand(args []string) error {
+	flags := flag.NewFlagSet("parse", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "print the AST as JSON, with the spans of the nodes")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: lexp parse [-json] expression")
+	}
+	tokens, err := lexer.New("expr", flags.Arg(0)).MakeTokens()
+	if err != nil {
+		return err
+	}
+	p := parser.New(tokens)
+	p.Funcs = eval.Builtins
+	p.Consts = eval.Constants
+	expr, err := p.Parse()
+	if err != nil {
+		return err
+	}
+	if !*asJSON {
+		fmt.Println(expr)
+		return nil
+	}
+	out, err := parser.MarshalNode(expr)
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(out))
+	return nil
+}
@@ -0,0 +1,736 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"math/big"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fmarmol/lexp/eval"
+	"github.com/fmarmol/lexp/lexer"
+	"github.com/fmarmol/lexp/parser"
+)
+
+// REPL ...
+type REPL struct {
+	in       *bufio.Reader
+	out      io.Writer
+	env      parser.Env
+	values   parser.Values // variables bound to strings, lists, maps, nil and big numbers
+	funcs    parser.Funcs  // builtins and the functions defined in the session
+	history  []string
+	Format   eval.Format
+	Width    *parser.IntWidth // fixed width integer mode, nil for floats
+	bits     bool             // show the bit pattern of results in fixed width mode
+	ast      string           // form the ASTs are shown in: flat, tree or sexp
+	Division parser.Division
+	angle    eval.Angle // unit of the trigonometric functions, see SetAngle
+	// Precision computes with arbitrary precision floats of this many bits,
+	// 0 for float64
+	Precision uint
+	// Rational computes with exact rationals, taking precedence over
+	// Decimal and Precision
+	Rational bool
+	// Decimal computes in fixed point, taking precedence over Precision,
+	// nil to disable
+	Decimal *eval.Decimal
+	// Spinner shows activity on the Spinner writer during long evaluations
+	Spinner io.Writer
+	// BracketedPaste asks the terminal to delimit pasted text so a multi
+	// line paste is evaluated as a single input
+	BracketedPaste bool
+	// Batch evaluates the input without prompting, printing results alone
+	Batch bool
+	// Terminal the input is read from with line editing, nil to read plain
+	// lines
+	Terminal *os.File
+	editor   *lineEditor
+	// HistoryFile keeps the history across sessions, empty to disable
+	HistoryFile string
+	Budget      Budget
+	// LockedBudget forbids changing the budget with :set steps, for the
+	// sessions of a server enforcing its own
+	LockedBudget bool
+	Log          *log.Logger // errors are reported to Log
+	// Color highlights the error diagnostics with ANSI escapes
+	Color bool
+	// Trace prints each reduction step of the evaluations
+	Trace bool
+}
+
+// Budget limits each evaluation, zero values meaning no limit. MaxDepth
+// and MaxTokens bound the expressions parsed, see parser.Parser.
+type Budget struct {
+	MaxNodes            int
+	Timeout             time.Duration
+	MaxDepth, MaxTokens int
+}
+
+// observer aborting evaluations exceeding the budget, nil if unlimited
+func (b Budget) observer(next eval.Observer) eval.Observer {
+	if b.MaxNodes <= 0 && b.Timeout <= 0 && next == nil {
+		return nil
+	}
+	start := time.Now()
+	return func(p eval.Progress) error {
+		if b.MaxNodes > 0 && p.Nodes > b.MaxNodes {
+			return fmt.Errorf("evaluation exceeds the budget of %v nodes", b.MaxNodes)
+		}
+		if b.Timeout > 0 && time.Since(start) > b.Timeout {
+			return fmt.Errorf("evaluation exceeds the budget of %v", b.Timeout)
+		}
+		if next != nil {
+			return next(p)
+		}
+		return nil
+	}
+}
+
+const (
+	pasteOn    = "\x1b[?2004h"
+	pasteOff   = "\x1b[?2004l"
+	pasteStart = "\x1b[200~"
+	pasteEnd   = "\x1b[201~"
+)
+
+// NewREPL ...
+func NewREPL(in io.Reader, out io.Writer) *REPL {
+	return &REPL{
+		in:     bufio.NewReader(in),
+		out:    out,
+		env:    parser.Env{"ans": 0, "_": 0}, // last result, 0 until the first one
+		values: parser.Values{},
+		funcs:  maps.Clone(eval.Builtins),
+		Format: eval.DefaultFormat,
+		Log:    log.Default(),
+	}
+}
+
+// fresh returns a REPL with the settings of r, discarding its output, and
+// a new session
+func (r *REPL) fresh() *REPL {
+	fresh := NewREPL(strings.NewReader(""), io.Discard)
+	fresh.Format, fresh.Width, fresh.bits, fresh.ast = r.Format, r.Width, r.bits, r.ast
+	fresh.Division, fresh.Precision, fresh.Rational, fresh.Decimal = r.Division, r.Precision, r.Rational, r.Decimal
+	fresh.Budget, fresh.Log, fresh.Color, fresh.Trace = r.Budget, r.Log, r.Color, r.Trace
+	fresh.SetAngle(r.angle)
+	return fresh
+}
+
+// Run reads and evaluates lines until the input is exhausted
+func (r *REPL) Run() {
+	if r.BracketedPaste {
+		fmt.Fprint(r.out, pasteOn)
+		defer fmt.Fprint(r.out, pasteOff)
+	}
+	var history *os.File
+	if r.HistoryFile != "" {
+		var err error
+		if r.history, err = loadHistory(r.HistoryFile); err != nil {
+			r.Log.Println("err: history:", err)
+		}
+		if history, err = openHistory(r.HistoryFile); err != nil {
+			r.Log.Println("err: history:", err)
+		} else {
+			defer history.Close()
+		}
+	}
+	for {
+		prompt := "Basic > "
+		if r.Batch {
+			prompt = ""
+		}
+		text, err := r.readInput(prompt)
+		if err != nil {
+			break
+		}
+		// expanded first, !! lexing as an incomplete not not
+		if expanded, err := r.expandHistory(text); err != nil {
+			r.report(err)
+			continue
+		} else if expanded != text {
+			fmt.Fprintln(r.out, expanded)
+			text = expanded
+		}
+		text = r.readContinuation(text)
+		if trimmed := strings.TrimSpace(text); trimmed == "" || (strings.HasPrefix(trimmed, "#") && !strings.Contains(trimmed, "\n")) {
+			continue // blank or a comment alone
+		}
+		r.history = append(r.history, text)
+		if history != nil {
+			if err := appendHistory(history, text); err != nil {
+				r.Log.Println("err: history:", err)
+				history = nil
+			}
+		}
+		if strings.HasPrefix(strings.TrimSpace(text), ":") {
+			if err := r.command(strings.Fields(strings.TrimSpace(text)[1:])); err != nil {
+				r.report(err)
+			}
+			continue
+		}
+		if r.Batch {
+			if err := r.Print(text); err != nil {
+				r.report(err)
+			}
+			continue
+		}
+		r.eval(text)
+	}
+}
+
+// readInput prompts for a line, edited on the terminal if any, or a whole
+// block of lines when they are pasted
+func (r *REPL) readInput(prompt string) (string, error) {
+	if r.Terminal != nil {
+		if restore, err := makeRaw(r.Terminal); err == nil {
+			defer restore()
+			if r.editor == nil {
+				r.editor = &lineEditor{in: r.in, out: r.out}
+			}
+			r.editor.history = r.history
+			return r.editor.ReadLine(prompt)
+		}
+	}
+	fmt.Fprint(r.out, prompt)
+	text, err := readLine(r.in)
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(text, pasteStart) {
+		return text, nil
+	}
+	text = strings.TrimPrefix(text, pasteStart)
+	for !strings.Contains(text, pasteEnd) {
+		l, err := readLine(r.in)
+		if err != nil {
+			break
+		}
+		text += "\n" + l
+	}
+	return strings.Replace(text, pasteEnd, "", 1), nil
+}
+
+// readLine reads a whole line of in, whatever its length, without its end
+func readLine(in *bufio.Reader) (string, error) {
+	line, err := in.ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r"), nil
+}
+
+// readContinuation reads lines following text, with a ... prompt, as long
+// as the expression is incomplete
+func (r *REPL) readContinuation(text string) string {
+	prompt := "... "
+	if r.Batch {
+		prompt = ""
+	}
+	for !strings.HasPrefix(strings.TrimSpace(text), ":") {
+		tokens, err := lexer.New("stdin", text).MakeTokens()
+		if err != nil || !tokens.Incomplete() {
+			break
+		}
+		line, err := r.readInput(prompt)
+		if err != nil {
+			break
+		}
+		text += "\n" + line
+	}
+	return text
+}
+
+// expandHistory replaces a line made of !! by the last input and !n by the
+// nth entry of the history
+func (r *REPL) expandHistory(line string) (string, error) {
+	event := strings.TrimSpace(line)
+	if !strings.HasPrefix(event, "!") {
+		return line, nil
+	}
+	if event == "!!" {
+		if len(r.history) == 0 {
+			return "", fmt.Errorf("!!: history is empty")
+		}
+		return r.history[len(r.history)-1], nil
+	}
+	n, err := strconv.Atoi(event[1:])
+	if err != nil {
+		return line, nil
+	}
+	if n < 1 || n > len(r.history) {
+		return "", fmt.Errorf("%v: event not found", event)
+	}
+	return r.history[n-1], nil
+}
+
+// command runs a REPL command such as :base 16
+func (r *REPL) command(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing command")
+	}
+	switch args[0] {
+	case "base":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: :base 2|8|10|16")
+		}
+		base, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid base %q", args[1])
+		}
+		return r.Format.SetBase(base)
+	case "notation":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: :notation plain|sci|eng")
+		}
+		return r.Format.SetNotation(args[1])
+	case "sig":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: :sig N (0 to disable)")
+		}
+		n, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid number of significant digits %q", args[1])
+		}
+		return r.Format.SetSigFigs(n)
+	case "frac":
+		switch {
+		case len(args) == 1:
+			r.Format.Fraction = !r.Format.Fraction
+		case len(args) == 2 && (args[1] == "on" || args[1] == "off"):
+			r.Format.Fraction, r.Format.Mixed = args[1] == "on", false
+		case len(args) == 2 && args[1] == "mixed":
+			r.Format.Fraction, r.Format.Mixed = true, true
+		default:
+			return fmt.Errorf("usage: :frac [on|off|mixed]")
+		}
+		return nil
+	case "decimals":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: :decimals N")
+		}
+		n, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid number of decimal places %q", args[1])
+		}
+		return r.Format.SetDecimals(n)
+	case "decimal":
+		return r.decimal(args[1:])
+	case "set":
+		return r.set(args[1:])
+	case "width":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: :width off|i8|i16|i32|i64|u8|u16|u32|u64")
+		}
+		width, err := parser.ParseIntWidth(args[1])
+		if err != nil {
+			return err
+		}
+		r.Width = width
+		return nil
+	case "div":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: :div true|trunc|exact")
+		}
+		division, err := parser.ParseDivision(args[1])
+		if err != nil {
+			return err
+		}
+		r.Division = division
+		return nil
+	case "bits":
+		if r.Width == nil {
+			return fmt.Errorf(":bits needs a fixed width integer mode, see :width")
+		}
+		switch {
+		case len(args) == 1:
+			r.bits = !r.bits
+		case len(args) == 2 && (args[1] == "on" || args[1] == "off"):
+			r.bits = args[1] == "on"
+		default:
+			return fmt.Errorf("usage: :bits [on|off]")
+		}
+		return nil
+	case "debug":
+		if len(args) == 1 {
+			return fmt.Errorf("usage: :debug EXPR")
+		}
+		return r.debug(strings.Join(args[1:], " "))
+	case "trace":
+		switch {
+		case len(args) == 1:
+			r.Trace = !r.Trace
+		case len(args) == 2 && (args[1] == "on" || args[1] == "off"):
+			r.Trace = args[1] == "on"
+		default:
+			return fmt.Errorf("usage: :trace [on|off]")
+		}
+		if r.Trace && !r.floats() {
+			r.Trace = false
+			return errTraceFloats
+		}
+		return nil
+	case "ast":
+		if len(args) != 2 || (args[1] != "flat" && args[1] != "tree" && args[1] != "sexp") {
+			return fmt.Errorf("usage: :ast flat|tree|sexp")
+		}
+		r.ast = args[1]
+		return nil
+	case "table", "csv":
+		spec, err := eval.ParseTableSpec(r.env, strings.Join(args[1:], " "))
+		if err != nil {
+			return err
+		}
+		rows, err := eval.Tabulate(r.env, spec)
+		if err != nil {
+			return err
+		}
+		if args[0] == "csv" {
+			eval.WriteCSV(r.out, spec, rows)
+		} else {
+			eval.WriteTable(r.out, spec, rows, r.Format)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown command %q", args[0])
+}
+
+// decimal runs :decimal off|PLACES [ROUNDING]
+func (r *REPL) decimal(args []string) error {
+	if len(args) == 1 && args[0] == "off" {
+		r.Decimal = nil
+		return nil
+	}
+	if len(args) == 0 || len(args) > 2 {
+		return fmt.Errorf("usage: :decimal off|PLACES [half-even|half-up|down|up|floor|ceiling]")
+	}
+	d := eval.DefaultDecimal
+	if r.Decimal != nil {
+		d = *r.Decimal
+	}
+	places, err := strconv.Atoi(args[0])
+	if err != nil || places < 0 {
+		return fmt.Errorf("invalid number of decimal places %q", args[0])
+	}
+	d.Places = places
+	if len(args) == 2 {
+		if d.Rounding, err = eval.ParseRounding(args[1]); err != nil {
+			return err
+		}
+	}
+	r.Decimal = &d
+	return nil
+}
+
+// set runs :set precision N|off, :set format SPEC|off, :set zeros on|off,
+// :set steps N|off or :set angle deg|rad
+func (r *REPL) set(args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: :set precision N|off, :set format SPEC|off, :set zeros on|off, :set steps N|off or :set angle deg|rad")
+	}
+	switch args[0] {
+	case "precision":
+		if args[1] == "off" {
+			return r.Format.SetPrecision(0)
+		}
+		n, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid precision %q", args[1])
+		}
+		return r.Format.SetPrecision(n)
+	case "format":
+		if args[1] == "off" {
+			return r.Format.SetPrintf("")
+		}
+		return r.Format.SetPrintf(args[1])
+	case "zeros":
+		if args[1] != "on" && args[1] != "off" {
+			return fmt.Errorf("usage: :set zeros on|off")
+		}
+		r.Format.TrailingZeros = args[1] == "on"
+		return nil
+	case "steps":
+		if r.LockedBudget {
+			return fmt.Errorf("the number of steps is limited by the server")
+		}
+		if args[1] == "off" {
+			r.Budget.MaxNodes = 0
+			return nil
+		}
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n <= 0 {
+			return fmt.Errorf("invalid number of steps %q", args[1])
+		}
+		r.Budget.MaxNodes = n
+		return nil
+	case "angle":
+		angle, err := eval.ParseAngle(args[1])
+		if err != nil {
+			return err
+		}
+		r.SetAngle(angle)
+		return nil
+	}
+	return fmt.Errorf("unknown setting %q, expected precision, format, zeros, steps or angle", args[0])
+}
+
+// SetAngle makes sin, cos, tan and their inverses work in angle
+func (r *REPL) SetAngle(angle eval.Angle) {
+	r.angle = angle
+	maps.Copy(r.funcs, angle.Funcs())
+}
+
+// spinnerDelay before showing the spinner
+const spinnerDelay = 200 * time.Millisecond
+
+// value of an evaluation, an eval.Number, an eval.BigNumber with a
+// precision, an eval.RatNumber with rationals or an eval.DecimalNumber in
+// fixed point
+type value interface{ Float64() float64 }
+
+// format renders res according to the format of the REPL
+func (r *REPL) format(res value) string {
+	switch n := res.(type) {
+	case eval.BigNumber:
+		return r.Format.Big(n)
+	case eval.RatNumber:
+		return r.Format.Rat(n)
+	case eval.DecimalNumber:
+		return r.Format.Decimal(n)
+	case eval.Number:
+		if r.Width != nil && n.Kind == eval.KindInt && n.Int < 0 && !r.Width.Signed {
+			// an u64 integer from 2^63
+			return r.Format.Big(eval.BigNumber{Int: new(big.Int).SetUint64(uint64(n.Int))})
+		}
+	}
+	return r.Format.Number(res.(eval.Number))
+}
+
+// evalExpr evaluates expr in the arithmetic of the REPL, within its budget
+// and until interrupted
+func (r *REPL) evalExpr(expr lexer.IExpression) (value, error) {
+	if r.Trace && !r.floats() {
+		return nil, errTraceFloats
+	}
+	if r.Trace {
+		return r.trace(expr)
+	}
+	var spin eval.Observer
+	if r.Spinner != nil {
+		s := newSpinner(r.Spinner, spinnerDelay)
+		defer s.Clear()
+		spin = s.Observe
+	}
+	if r.Terminal != nil {
+		interrupt, stop := interrupter(spin)
+		defer stop()
+		spin = interrupt
+	}
+	observer := r.Budget.observer(spin)
+	interval := 1000
+	if r.Budget.MaxNodes > 0 {
+		interval = min(interval, r.Budget.MaxNodes+1)
+	}
+	switch {
+	case r.Rational && observer != nil:
+		return eval.EvalRatWithProgress(expr, observer, interval)
+	case r.Rational:
+		return eval.EvalRat(expr)
+	case r.Decimal != nil && observer != nil:
+		return eval.EvalDecimalWithProgress(expr, *r.Decimal, observer, interval)
+	case r.Decimal != nil:
+		return eval.EvalDecimal(expr, *r.Decimal)
+	case r.Precision > 0 && observer != nil:
+		return eval.EvalBigWithProgress(expr, r.Precision, observer, interval)
+	case r.Precision > 0:
+		return eval.EvalBig(expr, r.Precision)
+	case observer != nil:
+		return eval.EvalNumberWithProgress(expr, observer, interval)
+	}
+	return eval.EvalNumber(expr)
+}
+
+// floats tells if the REPL computes with floats rather than rationals,
+// fixed point decimals or arbitrary precision
+func (r *REPL) floats() bool {
+	return !r.Rational && r.Decimal == nil && r.Precision == 0
+}
+
+// errTraceFloats is reported when tracing the evaluations which do not
+// compute with floats
+var errTraceFloats = errors.New("tracing needs the float arithmetic, without -rat, -big, -decimal or :decimal")
+
+// errInterrupted aborts the evaluations interrupted with Ctrl-C
+var errInterrupted = errors.New("evaluation interrupted")
+
+// interrupter returns an observer aborting the evaluation once interrupted,
+// then next, and a function to call when the evaluation is over
+func interrupter(next eval.Observer) (eval.Observer, func()) {
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	return func(p eval.Progress) error {
+		select {
+		case <-interrupts:
+			return errInterrupted
+		default:
+		}
+		if next != nil {
+			return next(p)
+		}
+		return nil
+	}, func() { signal.Stop(interrupts) }
+}
+
+// trace evaluates expr, printing each reduction step with its position
+func (r *REPL) trace(expr lexer.IExpression) (value, error) {
+	observer, steps := r.Budget.observer(nil), 0
+	return eval.EvalNumberWithTrace(expr, func(step eval.Step) error {
+		steps++
+		if observer != nil {
+			if err := observer(eval.Progress{Nodes: steps, Node: step.Node}); err != nil {
+				return err
+			}
+		}
+		line := step.Describe(r.Format.Number)
+		if span := lexer.SpanOf(step.Node); span.Start.Line > 0 {
+			line = fmt.Sprintf("%-30v %v", line, spanString(span))
+		}
+		_, err := fmt.Fprintln(r.out, line)
+		return err
+	})
+}
+
+// spanString is line:column-column, or line:column-line:column for spans
+// over several lines, the end being the last character
+func spanString(span lexer.Span) string {
+	start, end := span.Start, span.End
+	end.Column--
+	if start.Line == end.Line {
+		return fmt.Sprintf("%v:%v-%v", start.Line, start.Column, end.Column)
+	}
+	return fmt.Sprintf("%v:%v-%v:%v", start.Line, start.Column, end.Line, end.Column)
+}
+
+func (r *REPL) eval(text string) {
+	expr, tokens, err := r.parse(text)
+	if err != nil {
+		r.report(err)
+		return
+	}
+	switch r.ast {
+	case "tree":
+		fmt.Fprintln(r.out, parser.Pretty(expr))
+	case "sexp":
+		fmt.Fprintln(r.out, parser.SExpr(expr))
+	default:
+		fmt.Fprintln(r.out, expr)
+	}
+	fmt.Fprintln(r.out, tokens)
+	result, err := r.run(expr)
+	if err != nil {
+		r.report(err)
+		return
+	}
+	fmt.Fprintln(r.out, r.format(result))
+	if r.bits && r.Width != nil {
+		fmt.Fprintln(r.out, r.bitsView(result))
+	}
+}
+
+// bitsView renders the bits of res in fixed width integer mode
+func (r *REPL) bitsView(res value) string {
+	if n, ok := res.(eval.Number); ok && n.Kind == eval.KindInt {
+		return r.Width.BitsView(n.Int)
+	}
+	return r.Width.BitsView(r.Width.WrapFloat(res.Float64()))
+}
+
+// report err to Log, showing where it is in the source if known
+func (r *REPL) report(err error) {
+	r.Log.Println(diagnostic(err, r.Color))
+}
+
+// Print evaluates text and prints its result alone, followed by its bits
+// if they are shown
+func (r *REPL) Print(text string) error {
+	expr, _, err := r.parse(text)
+	if err != nil {
+		return err
+	}
+	result, err := r.run(expr)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(r.out, r.format(result))
+	if r.bits && r.Width != nil {
+		fmt.Fprintln(r.out, r.bitsView(result))
+	}
+	return nil
+}
+
+// parse text in the environment of the REPL
+func (r *REPL) parse(text string) (lexer.IExpression, lexer.Tokens, error) {
+	return r.parseFile("stdin", text, nil)
+}
+
+// parseFile parses the text of fileName in the environment of the REPL,
+// importer reading its imports, nil disabling them
+func (r *REPL) parseFile(fileName, text string, importer parser.Importer) (lexer.IExpression, lexer.Tokens, error) {
+	l := lexer.New(fileName, text)
+	l.AllErrors = true
+	l.MaxTokens = r.Budget.MaxTokens
+	tokens, err := l.MakeTokens()
+	if err != nil {
+		return nil, tokens, err
+	}
+	p := parser.New(tokens)
+	p.Env = r.env
+	p.Values = r.values
+	p.Funcs = r.funcs
+	p.Consts = eval.Constants
+	p.Width = r.Width
+	p.Division = r.Division
+	p.MaxDepth, p.MaxTokens = r.Budget.MaxDepth, r.Budget.MaxTokens
+	p.Importer = importer
+	expr, err := p.Parse()
+	return expr, tokens, err
+}
+
+// run evaluates expr, binding the result to ans and _ for the next expression
+func (r *REPL) run(expr lexer.IExpression) (value, error) {
+	if undefined := r.env.Undefined(expr); len(undefined) > 0 {
+		return nil, parser.UndefinedVariable(undefined[0], r.env.Names(), r.env.Assigned(expr), r.values.Names(), eval.Constants.Names())
+	}
+	result, err := r.evalExpr(expr)
+	if err != nil {
+		return nil, err
+	}
+	if n, ok := result.(interface{ Scalar() bool }); ok && !n.Scalar() {
+		// ans holds numbers
+		return result, nil
+	}
+	var exact any
+	switch n := result.(type) {
+	case eval.BigNumber, eval.RatNumber:
+		exact = n
+	case eval.DecimalNumber:
+		exact = eval.RatNumber{Rat: n.Rat}
+	}
+	if exact != nil {
+		// keeping its precision
+		delete(r.env, "ans")
+		delete(r.env, "_")
+		r.values["ans"], r.values["_"] = exact, exact
+		return result, nil
+	}
+	r.env["ans"], r.env["_"] = result.Float64(), result.Float64()
+	return result, nil
+}
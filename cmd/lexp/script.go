@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/fmarmol/lexp/lexer"
+	"github.com/fmarmol/lexp/parser"
+)
+
+// runCommand evaluates a script with the settings of repl
+func runCommand(args []string, repl *REPL) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: lexp run file")
+	}
+	content, err := os.ReadFile(args[0])
+	if err != nil {
+		return err
+	}
+	repl.Log = log.New(os.Stderr, "", 0)
+	if err := repl.Script(args[0], string(content)); err != nil {
+		repl.report(err)
+		os.Exit(1)
+	}
+	return nil
+}
+
+// Script evaluates the statements of the script fileName one after the
+// other, printing the results of those other than imports, and stops at the
+// first error. Its import statements read the files relative to its
+// directory.
+func (r *REPL) Script(fileName, text string) error {
+	return r.script(fileName, text, func(statement lexer.IExpression, result value, err error) {
+		if _, ok := statement.(parser.ImportNode); !ok && err == nil {
+			fmt.Fprintln(r.out, r.format(result))
+		}
+	})
+}
+
+// script evaluates the statements of a script like Script, calling done
+// with each statement evaluated and its result or error
+func (r *REPL) script(fileName, text string, done func(statement lexer.IExpression, result value, err error)) error {
+	expr, _, err := r.parseFile(fileName, text, readImport)
+	if err != nil {
+		return err
+	}
+	statements := []lexer.IExpression{expr}
+	if program, ok := expr.(parser.ProgramNode); ok {
+		statements = program.Statements
+	}
+	for _, statement := range statements {
+		result, err := r.run(statement)
+		done(statement, result, err)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readImport reads an imported file from the file system
+func readImport(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	return string(content), err
+}
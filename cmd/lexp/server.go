@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/fmarmol/lexp/eval"
+	"github.com/fmarmol/lexp/parser"
+)
+
+// AuthFunc authenticates a client before its session starts, reading from in
+// and prompting on out
+type AuthFunc func(in *bufio.Reader, out io.Writer, addr net.Addr) error
+
+// ErrUnauthorized ...
+var ErrUnauthorized = errors.New("unauthorized")
+
+// TokenAuth asks clients for a shared token
+func TokenAuth(token string) AuthFunc {
+	return func(in *bufio.Reader, out io.Writer, addr net.Addr) error {
+		fmt.Fprint(out, "token: ")
+		line, _, err := in.ReadLine()
+		if err != nil {
+			return err
+		}
+		if subtle.ConstantTimeCompare([]byte(strings.TrimSpace(string(line))), []byte(token)) != 1 {
+			return ErrUnauthorized
+		}
+		return nil
+	}
+}
+
+// Server of REPL sessions over TCP, each connection having its own
+// environment and evaluation budget
+type Server struct {
+	Format eval.Format
+	Width  *parser.IntWidth
+	Budget Budget
+	Auth   AuthFunc // nil accepts every client
+	// IdleTimeout closes sessions without input for this long, 0 for never
+	IdleTimeout time.Duration
+	Log         *log.Logger
+}
+
+// Serve accepts connections on l until it fails
+func (s *Server) Serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+	addr := conn.RemoteAddr()
+	s.Log.Println("session opened:", addr)
+	defer s.Log.Println("session closed:", addr)
+
+	var r io.Reader = conn
+	if s.IdleTimeout > 0 {
+		r = idleReader{conn, s.IdleTimeout}
+	}
+	in := bufio.NewReader(r)
+	if s.Auth != nil {
+		if err := s.Auth(in, conn, addr); err != nil {
+			fmt.Fprintln(conn, "err:", err)
+			s.Log.Println("authentication failed:", addr, err)
+			return
+		}
+	}
+	repl := NewREPL(in, conn)
+	repl.Format = s.Format
+	repl.Width = s.Width
+	repl.Budget, repl.LockedBudget = s.Budget, true
+	repl.Log = log.New(conn, "", 0)
+	repl.Run()
+}
+
+// idleReader extends the read deadline of conn before each read
+type idleReader struct {
+	conn    net.Conn
+	timeout time.Duration
+}
+
+func (r idleReader) Read(p []byte) (int, error) {
+	r.conn.SetReadDeadline(time.Now().Add(r.timeout))
+	return r.conn.Read(p)
+}
+
+// serveReplCommand runs a REPL server
+func serveReplCommand(args []string, format eval.Format, width *parser.IntWidth) error {
+	flags := flag.NewFlagSet("serve-repl", flag.ExitOnError)
+	addr := flags.String("addr", ":7070", "address to listen on")
+	token := flags.String("token", "", "token clients must send first, empty to disable authentication")
+	maxNodes := flags.Int("max-nodes", 1000000, "maximum number of nodes evaluated per expression, 0 for no limit")
+	timeout := flags.Duration("timeout", 5*time.Second, "maximum duration of an evaluation, 0 for no limit")
+	maxDepth := flags.Int("max-depth", 1000, "maximum nesting of an expression, 0 for no limit")
+	maxTokens := flags.Int("max-tokens", 100000, "maximum number of tokens of an expression, 0 for no limit")
+	idle := flags.Duration("idle", 30*time.Minute, "close sessions idle for this long, 0 for never")
+	flags.Parse(args)
+
+	s := &Server{
+		Format:      format,
+		Width:       width,
+		Budget:      Budget{*maxNodes, *timeout, *maxDepth, *maxTokens},
+		IdleTimeout: *idle,
+		Log:         log.Default(),
+	}
+	if *token != "" {
+		s.Auth = TokenAuth(*token)
+	}
+	l, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return err
+	}
+	s.Log.Println("listening on", l.Addr())
+	return s.Serve(l)
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/fmarmol/lexp/eval"
+)
+
+// spinner shows activity on w once an evaluation lasts more than delay
+type spinner struct {
+	w      io.Writer
+	delay  time.Duration
+	start  time.Time
+	last   time.Time
+	frame  int
+	active bool
+}
+
+var spinnerFrames = []string{"|", "/", "-", "\\"}
+
+func newSpinner(w io.Writer, delay time.Duration) *spinner {
+	now := time.Now()
+	return &spinner{w: w, delay: delay, start: now, last: now}
+}
+
+// Observe is an Observer updating the spinner
+func (s *spinner) Observe(p eval.Progress) error {
+	now := time.Now()
+	if now.Sub(s.start) < s.delay || now.Sub(s.last) < 100*time.Millisecond {
+		return nil
+	}
+	s.last, s.active = now, true
+	fmt.Fprintf(s.w, "\r%v %v nodes", spinnerFrames[s.frame%len(spinnerFrames)], p.Nodes)
+	s.frame++
+	return nil
+}
+
+// Clear erases the spinner if it was shown
+func (s *spinner) Clear() {
+	if s.active {
+		fmt.Fprint(s.w, "\r\x1b[K")
+	}
+}
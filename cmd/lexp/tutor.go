@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/fmarmol/lexp/eval"
+	"github.com/fmarmol/lexp/lexer"
+)
+
+type exercise struct {
+	prompt  string
+	want    float64
+	uses    string // operator or variable the answer must contain, if any
+	defines string // function the answer must define instead of computing want, if any
+}
+
+type lesson struct {
+	title, text string
+	exercises   []exercise
+}
+
+var lessons = []lesson{
+	{
+		"Numbers",
+		"Numbers are written as integers like 42 or with a decimal point like 3.14.\n" +
+			"Type an expression and the result is printed.",
+		[]exercise{
+			{"Add 1.5 and 2.5", 4, "+", ""},
+			{"Divide 7 by 2", 3.5, "/", ""},
+		},
+	},
+	{
+		"Precedence",
+		"* and / bind tighter than + and -, so 1 + 2 * 3 is 7, not 9.",
+		[]exercise{
+			{"Compute 2 plus 3 times 4, in this order of writing", 14, "*", ""},
+			{"Subtract 10 divided by 5 from 8", 6, "-", ""},
+		},
+	},
+	{
+		"Variables",
+		"A line like radius = 2 binds a name to a value for the next lines.\n" +
+			"The last result is always available as ans, or _ for short.",
+		[]exercise{
+			{"Bind the name rate to 3", 3, "rate", ""},
+			{"Multiply rate by 7", 21, "rate", ""},
+			{"Add 1 to the last result with ans", 22, "ans", ""},
+		},
+	},
+	{
+		"Functions",
+		"Functions like sqrt, abs or max take their arguments in parentheses: max(1, 2).\n" +
+			"A line like double = (x) -> x * 2 defines a function for the next lines.",
+		[]exercise{
+			{"Compute the square root of 81", 9, "sqrt", ""},
+			{"Define the function cube, taking x and yielding x ^ 3", 0, "->", "cube"},
+			{"Add the cube of 3 and the square root of 16", 31, "cube", ""},
+		},
+	},
+}
+
+// tutorCommand walks through the lessons, checking each answer with the
+// evaluator itself
+func tutorCommand(in io.Reader, out io.Writer) error {
+	reader := bufio.NewReader(in)
+	e := eval.NewEvaluator()
+	fmt.Fprintln(out, "Welcome to the lexp tutorial, type skip to skip an exercise and quit to leave.")
+	for i, l := range lessons {
+		fmt.Fprintf(out, "\nLesson %v/%v: %v\n%v\n", i+1, len(lessons), l.title, l.text)
+		for _, ex := range l.exercises {
+			for done := false; !done; {
+				fmt.Fprintf(out, "\n%v\ntutor > ", ex.prompt)
+				line, err := readLine(reader)
+				if err != nil {
+					return nil
+				}
+				answer := strings.TrimSpace(line)
+				switch answer {
+				case "":
+					continue
+				case "quit":
+					return nil
+				case "skip":
+					done = true
+					continue
+				}
+				done = checkAnswer(out, e, answer, ex)
+			}
+		}
+	}
+	fmt.Fprintln(out, "\nCongratulations, you completed the tutorial!")
+	return nil
+}
+
+func checkAnswer(out io.Writer, e *eval.Evaluator, answer string, ex exercise) bool {
+	tokens, err := lexer.New("tutor", answer).MakeTokens()
+	if err != nil {
+		fmt.Fprintln(out, "err:", err)
+		return false
+	}
+	if ex.uses != "" && !containsToken(tokens, ex.uses) {
+		fmt.Fprintf(out, "Try again using %v.\n", ex.uses)
+		return false
+	}
+	_, result, err := e.EvalLine("tutor", answer)
+	if err != nil {
+		fmt.Fprintln(out, "err:", err)
+		return false
+	}
+	if ex.defines != "" {
+		if e.Funcs[ex.defines].Closure == nil {
+			fmt.Fprintf(out, "%v does not define %v, try again.\n", answer, ex.defines)
+			return false
+		}
+		fmt.Fprintf(out, "Correct, %v is defined.\n", ex.defines)
+		return true
+	}
+	if result != ex.want {
+		fmt.Fprintf(out, "%v gives %v, try again.\n", answer, result)
+		return false
+	}
+	fmt.Fprintf(out, "Correct, %v gives %v.\n", answer, result)
+	return true
+}
+
+// containsToken tells if tokens contain the operator or variable text
+func containsToken(tokens lexer.Tokens, text string) bool {
+	for _, token := range tokens {
+		switch t := token.(type) {
+		case lexer.TokenIdent:
+			if t.Value == text {
+				return true
+			}
+		default:
+			if lexer.Symbols[lexer.TypeOf(t)] == text {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/fmarmol/lexp/lexer"
+	"github.com/fmarmol/lexp/parser"
+)
+
+// RunScript evaluates a script like run, with the settings of repl but
+// none of its variables, and stops at the first error. It returns the output
+// of each statement, assignments showing the name they bind, indexed by the
+// line it starts on.
+func RunScript(fileName, text string, repl *REPL) map[int]string {
+	r := repl.fresh()
+	outputs := map[int]string{}
+	err := r.script(fileName, text, func(statement lexer.IExpression, result value, err error) {
+		line := lexer.SpanOf(statement).Start.Line
+		if err != nil {
+			outputs[errorLine(err, line)] = "err: " + err.Error()
+			return
+		}
+		output := r.format(result)
+		if assign, ok := statement.(parser.AssignNode); ok {
+			output = assign.Name + " = " + output
+		}
+		outputs[line] = output
+	})
+	if err != nil && len(outputs) == 0 {
+		outputs[errorLine(err, 1)] = "err: " + err.Error()
+	}
+	return outputs
+}
+
+// errorLine returns the line err is located at, line if it has no location
+func errorLine(err error, line int) int {
+	var located lexer.SpanError
+	if errors.As(err, &located) && located.Span.Start.Line > 0 {
+		return located.Span.Start.Line
+	}
+	return line
+}
+
+// watchInterval between two checks of the watched file
+const watchInterval = 500 * time.Millisecond
+
+// watchCommand re-runs a script each time it changes, printing only the
+// outputs which differ from the previous run
+func watchCommand(args []string, repl *REPL) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: lexp watch file")
+	}
+	fileName := args[0]
+	var modTime time.Time
+	var previous map[int]string
+	for {
+		info, err := os.Stat(fileName)
+		if err != nil {
+			return err
+		}
+		if info.ModTime().Equal(modTime) {
+			time.Sleep(watchInterval)
+			continue
+		}
+		modTime = info.ModTime()
+		content, err := os.ReadFile(fileName)
+		if err != nil {
+			return err
+		}
+		outputs := RunScript(fileName, string(content), repl)
+		printOutputsDiff(previous, outputs)
+		previous = outputs
+	}
+}
+
+func printOutputsDiff(previous, outputs map[int]string) {
+	last := 0
+	for line := range outputs {
+		last = max(last, line)
+	}
+	for line := range previous {
+		last = max(last, line)
+	}
+	for line := 1; line <= last; line++ {
+		before, had := previous[line]
+		after, has := outputs[line]
+		switch {
+		case has && !had:
+			fmt.Printf("%v: %v\n", line, after)
+		case had && !has:
+			fmt.Printf("%v: removed (was %v)\n", line, before)
+		case had && has && before != after:
+			fmt.Printf("%v: %v -> %v\n", line, before, after)
+		}
+	}
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+)
+
+// zmtpConn is a ZMTP 3.0 connection with the NULL security mechanism, the
+// subset of ZeroMQ needed to talk to Jupyter clients
+type zmtpConn struct {
+	conn     net.Conn
+	r        *bufio.Reader
+	PeerType string
+	mu       sync.Mutex // serializes messages sent from several goroutines
+}
+
+const (
+	zmtpMore    = 0x01
+	zmtpLong    = 0x02
+	zmtpCommand = 0x04
+)
+
+// zmtpMaxMessage is the size of the largest message read, the frames of a
+// message included, before allocating their bodies
+const zmtpMaxMessage = 64 << 20
+
+// errMessageTooLarge is reported by the messages larger than zmtpMaxMessage,
+// closing the connection
+var errMessageTooLarge = errors.New("zmtp: message too large")
+
+// zmtpHandshake exchanges greetings and READY commands on conn, socketType
+// being the ZeroMQ type of our side of the connection
+func zmtpHandshake(conn net.Conn, socketType string) (*zmtpConn, error) {
+	z := &zmtpConn{conn: conn, r: bufio.NewReader(conn)}
+
+	greeting := make([]byte, 64)
+	greeting[0], greeting[9] = 0xFF, 0x7F
+	greeting[10], greeting[11] = 3, 0
+	copy(greeting[12:32], "NULL")
+	if _, err := conn.Write(greeting); err != nil {
+		return nil, err
+	}
+	peer := make([]byte, 64)
+	if _, err := io.ReadFull(z.r, peer); err != nil {
+		return nil, err
+	}
+	if peer[0] != 0xFF || peer[9] != 0x7F || peer[10] < 3 {
+		return nil, fmt.Errorf("zmtp: unsupported greeting from %v", conn.RemoteAddr())
+	}
+	if mechanism := string(bytes.TrimRight(peer[12:32], "\x00")); mechanism != "NULL" {
+		return nil, fmt.Errorf("zmtp: unsupported security mechanism %q", mechanism)
+	}
+
+	ready := []byte("\x05READY")
+	ready = append(ready, zmtpProperty("Socket-Type", socketType)...)
+	if err := z.writeFrame(ready, zmtpCommand); err != nil {
+		return nil, err
+	}
+	body, flags, err := z.readFrame(zmtpMaxMessage)
+	if err != nil {
+		return nil, err
+	}
+	if flags&zmtpCommand == 0 || !bytes.HasPrefix(body, []byte("\x05READY")) {
+		return nil, fmt.Errorf("zmtp: expected READY command")
+	}
+	props, err := zmtpProperties(body[6:])
+	if err != nil {
+		return nil, err
+	}
+	z.PeerType = props["Socket-Type"]
+	return z, nil
+}
+
+func zmtpProperty(name, value string) []byte {
+	ret := []byte{byte(len(name))}
+	ret = append(ret, name...)
+	ret = binary.BigEndian.AppendUint32(ret, uint32(len(value)))
+	return append(ret, value...)
+}
+
+func zmtpProperties(b []byte) (map[string]string, error) {
+	props := map[string]string{}
+	for len(b) > 0 {
+		n := int(b[0])
+		if len(b) < 1+n+4 {
+			return nil, fmt.Errorf("zmtp: truncated property")
+		}
+		name := string(b[1 : 1+n])
+		size := int(binary.BigEndian.Uint32(b[1+n:]))
+		b = b[1+n+4:]
+		if len(b) < size {
+			return nil, fmt.Errorf("zmtp: truncated property %v", name)
+		}
+		props[name] = string(b[:size])
+		b = b[size:]
+	}
+	return props, nil
+}
+
+// readFrame reads a frame of at most limit bytes
+func (z *zmtpConn) readFrame(limit int) ([]byte, byte, error) {
+	flags, err := z.r.ReadByte()
+	if err != nil {
+		return nil, 0, err
+	}
+	var size uint64
+	if flags&zmtpLong != 0 {
+		var b [8]byte
+		if _, err := io.ReadFull(z.r, b[:]); err != nil {
+			return nil, 0, err
+		}
+		size = binary.BigEndian.Uint64(b[:])
+	} else {
+		b, err := z.r.ReadByte()
+		if err != nil {
+			return nil, 0, err
+		}
+		size = uint64(b)
+	}
+	if size > uint64(limit) {
+		return nil, 0, fmt.Errorf("%w from %v, at most %v bytes", errMessageTooLarge, z.conn.RemoteAddr(), zmtpMaxMessage)
+	}
+	body := make([]byte, size)
+	if _, err := io.ReadFull(z.r, body); err != nil {
+		return nil, 0, err
+	}
+	return body, flags, nil
+}
+
+func (z *zmtpConn) writeFrame(body []byte, flags byte) error {
+	header := []byte{flags}
+	if len(body) > 255 {
+		header[0] |= zmtpLong
+		header = binary.BigEndian.AppendUint64(header, uint64(len(body)))
+	} else {
+		header = append(header, byte(len(body)))
+	}
+	if _, err := z.conn.Write(header); err != nil {
+		return err
+	}
+	_, err := z.conn.Write(body)
+	return err
+}
+
+// ReadMessage returns the frames of the next message, skipping commands. It
+// fails with errMessageTooLarge for messages of more than zmtpMaxMessage
+// bytes, after which the connection must be closed.
+func (z *zmtpConn) ReadMessage() ([][]byte, error) {
+	frames, size := [][]byte{}, 0
+	for {
+		body, flags, err := z.readFrame(zmtpMaxMessage - size)
+		if err != nil {
+			return nil, err
+		}
+		if flags&zmtpCommand != 0 {
+			continue
+		}
+		frames, size = append(frames, body), size+len(body)
+		if flags&zmtpMore == 0 {
+			return frames, nil
+		}
+	}
+}
+
+// WriteMessage sends frames as a single message
+func (z *zmtpConn) WriteMessage(frames [][]byte) error {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	for i, frame := range frames {
+		var flags byte
+		if i < len(frames)-1 {
+			flags = zmtpMore
+		}
+		if err := z.writeFrame(frame, flags); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close ...
+func (z *zmtpConn) Close() error { return z.conn.Close() }
+
+// zmtpPublisher is a PUB socket: messages are sent to every connected
+// subscriber whose subscriptions match the first frame
+type zmtpPublisher struct {
+	mu   sync.Mutex
+	subs map[*zmtpConn][][]byte
+}
+
+func newZmtpPublisher() *zmtpPublisher {
+	return &zmtpPublisher{subs: map[*zmtpConn][][]byte{}}
+}
+
+// Serve accepts subscribers on l
+func (p *zmtpPublisher) Serve(l net.Listener) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		go p.handle(conn)
+	}
+}
+
+func (p *zmtpPublisher) handle(conn net.Conn) {
+	z, err := zmtpHandshake(conn, "PUB")
+	if err != nil {
+		conn.Close()
+		return
+	}
+	defer func() {
+		p.mu.Lock()
+		delete(p.subs, z)
+		p.mu.Unlock()
+		z.Close()
+	}()
+	p.mu.Lock()
+	p.subs[z] = nil
+	p.mu.Unlock()
+	for {
+		// ZMTP 3.0 subscriptions are messages starting by 1, 0 to unsubscribe
+		frames, err := z.ReadMessage()
+		if err != nil {
+			return
+		}
+		if len(frames) != 1 || len(frames[0]) == 0 {
+			continue
+		}
+		topic := frames[0][1:]
+		p.mu.Lock()
+		if frames[0][0] == 1 {
+			p.subs[z] = append(p.subs[z], topic)
+		} else {
+			topics := p.subs[z]
+			for i, t := range topics {
+				if bytes.Equal(t, topic) {
+					p.subs[z] = append(topics[:i], topics[i+1:]...)
+					break
+				}
+			}
+		}
+		p.mu.Unlock()
+	}
+}
+
+// Publish sends frames to the matching subscribers
+func (p *zmtpPublisher) Publish(frames [][]byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for z, topics := range p.subs {
+		for _, topic := range topics {
+			if len(frames) > 0 && bytes.HasPrefix(frames[0], topic) {
+				z.WriteMessage(frames)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package eval
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/fmarmol/lexp/parser"
+)
+
+// Angle is the unit of the arguments of sin, cos and tan, and of the results
+// of their inverses
+type Angle int
+
+const (
+	// Radians, the unit of Builtins
+	Radians Angle = iota
+	// Degrees, sin(90) being 1
+	Degrees
+)
+
+var angleNames = map[Angle]string{Radians: "rad", Degrees: "deg"}
+
+// ParseAngle parses rad or deg
+func ParseAngle(spec string) (Angle, error) {
+	for a, name := range angleNames {
+		if name == spec {
+			return a, nil
+		}
+	}
+	return Radians, fmt.Errorf("unsupported angle unit %q, expected deg or rad", spec)
+}
+
+func (a Angle) String() string { return angleNames[a] }
+
+// Funcs returns the trigonometric functions working in a, also qualified
+// by the math module, to replace the ones of Builtins with
+func (a Angle) Funcs() parser.Funcs {
+	trig := pick(Builtins, "sin", "cos", "tan", "asin", "acos", "atan", "atan2")
+	if a == Degrees {
+		toDeg := func(f func(float64) float64) func(float64) float64 {
+			return func(x float64) float64 { return f(x) * 180 / math.Pi }
+		}
+		trig = parser.Funcs{
+			"sin":  unary(sinDeg),
+			"cos":  unary(func(x float64) float64 { return sinDeg(x + 90) }),
+			"tan":  unary(tanDeg),
+			"asin": unary(toDeg(math.Asin)),
+			"acos": unary(toDeg(math.Acos)),
+			"atan": unary(toDeg(math.Atan)),
+			"atan2": binary(func(y, x float64) float64 {
+				return math.Atan2(y, x) * 180 / math.Pi
+			}),
+		}
+	}
+	funcs := parser.Funcs{}
+	funcs.Register("math", trig)
+	return merge(funcs, trig)
+}
+
+// sinDeg computes the sine of x degrees, exact for the multiples of 30 and
+// 90, sin(30) being 0.5
+func sinDeg(x float64) float64 {
+	r := math.Mod(x, 360)
+	if r < 0 {
+		r += 360
+	}
+	switch r {
+	case 0, 180:
+		return 0
+	case 90:
+		return 1
+	case 270:
+		return -1
+	case 30, 150:
+		return 0.5
+	case 210, 330:
+		return -0.5
+	}
+	return math.Sin(r * math.Pi / 180)
+}
+
+// tanDeg computes the tangent of x degrees, exact for the multiples of 90,
+// infinite for the odd ones
+func tanDeg(x float64) float64 {
+	switch math.Mod(x, 180) {
+	case 0:
+		return 0
+	case 90, -90:
+		return math.Inf(1)
+	}
+	return math.Tan(x * math.Pi / 180)
+}
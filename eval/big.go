@@ -0,0 +1,465 @@
+package eval
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+
+	"github.com/fmarmol/lexp/lexer"
+	"github.com/fmarmol/lexp/parser"
+)
+
+// DefaultPrecision of the big.Float mantissas, in bits
+const DefaultPrecision = 256
+
+// maxBigBits bounds the size of the integers computed by powers and shifts,
+// larger results being computed as float64
+const maxBigBits = 1 << 24
+
+// BigNumber is the result of an arbitrary precision evaluation, Int being
+// set for integers, Value for the strings, lists, maps and nil, and Float
+// otherwise
+type BigNumber struct {
+	Int   *big.Int
+	Float *big.Float
+	Value *Number
+}
+
+// Float64 value of n, rounded to the nearest float64
+func (n BigNumber) Float64() float64 {
+	if n.Value != nil {
+		return n.Value.Float64()
+	}
+	if n.Int != nil {
+		f, _ := new(big.Float).SetInt(n.Int).Float64()
+		return f
+	}
+	f, _ := n.Float.Float64()
+	return f
+}
+
+func (n BigNumber) String() string {
+	if n.Value != nil {
+		return n.Value.String()
+	}
+	if n.Int != nil {
+		return n.Int.String()
+	}
+	return n.Float.Text('g', -1)
+}
+
+// Scalar tells if n is a number
+func (n BigNumber) Scalar() bool { return n.Value == nil }
+
+// number converts n, rounding its big numbers to int64 or float64
+func (n BigNumber) number() Number {
+	switch {
+	case n.Value != nil:
+		return *n.Value
+	case n.Int != nil && n.Int.IsInt64():
+		return IntNumber(n.Int.Int64())
+	}
+	return FloatNumber(n.Float64())
+}
+
+// ErrNaN is reported by arbitrary precision evaluations yielding NaN, which
+// big.Float cannot represent
+var ErrNaN = errors.New("result is not a number")
+
+// ErrFixedWidthBig is reported when evaluating fixed width integer
+// operations with arbitrary precision
+var ErrFixedWidthBig = errors.New("fixed width integers are not supported with arbitrary precision")
+
+// EvalBig evaluates node with integers of unlimited size and floats of prec
+// bits. Float literals and variables held as float64 are read as the
+// shortest decimal representing them, so that 0.1 is not the binary
+// approximation of a float64. The variables assigned keep their exact value
+// in their parser.Values. Operations without an exact implementation, like
+// most builtin calls, non integer powers or negative shifts, are computed
+// as float64, and so are the strings, lists, maps, nil and the functions
+// defined. Comparisons and logical operators yield 1 or 0.
+func EvalBig(node lexer.IExpression, prec uint) (BigNumber, error) {
+	return bigEval{prec, nil}.run(node)
+}
+
+// run evaluates node, reporting the undefined results as ErrNaN
+func (e bigEval) run(node lexer.IExpression) (n BigNumber, err error) {
+	defer func() {
+		// big.Float operations panic on undefined results like Inf - Inf
+		if r := recover(); r != nil {
+			if _, ok := r.(big.ErrNaN); !ok {
+				panic(r)
+			}
+			n, err = BigNumber{}, ErrNaN
+		}
+	}()
+	return e.eval(node)
+}
+
+type bigEval struct {
+	prec uint
+	// visit is called before evaluating each node, returning an error
+	// aborts the evaluation
+	visit func(lexer.IExpression) error
+}
+
+func (e bigEval) eval(node lexer.IExpression) (BigNumber, error) {
+	if e.visit != nil {
+		if err := e.visit(node); err != nil {
+			return BigNumber{}, aborted{err}
+		}
+	}
+	return e.evalNode(node)
+}
+
+func (e bigEval) evalNode(node lexer.IExpression) (BigNumber, error) {
+	switch n := node.(type) {
+	case lexer.TokenInt:
+		return BigNumber{Int: big.NewInt(n.Value.(int64))}, nil
+	case lexer.TokenFloat:
+		v, err := n.Eval()
+		if err != nil {
+			return BigNumber{}, err
+		}
+		return e.fromFloat64(v)
+	case parser.VarNode:
+		if _, ok := n.Env[n.Name()]; !ok {
+			if v, ok := n.Values[n.Name()].(BigNumber); ok {
+				return v, nil
+			}
+		}
+	case parser.BinOpNode:
+		if op, ok := n.Op.(lexer.Logical); ok {
+			return e.logical(op, n.Left, n.Right)
+		}
+		left, err := e.eval(n.Left)
+		if err != nil {
+			return BigNumber{}, err
+		}
+		right, err := e.eval(n.Right)
+		if err != nil {
+			return BigNumber{}, err
+		}
+		return e.apply(n.Op, left, right)
+	case parser.UnaryOpNode:
+		operand, err := e.eval(n.Operand)
+		if err != nil {
+			return BigNumber{}, err
+		}
+		if _, ok := n.Op.(lexer.TokenNot); !ok {
+			return e.apply(n.Op, BigNumber{Int: new(big.Int)}, operand)
+		}
+		if operand.Value != nil {
+			_, err := boolean(n.Op, *operand.Value)
+			return BigNumber{}, err
+		}
+		return bigBool(operand.sign() == 0), nil
+	case parser.CallNode:
+		return e.call(n)
+	case parser.AssignNode:
+		return e.assign(n)
+	case parser.SeqNode:
+		var v BigNumber
+		for _, item := range n.Items {
+			var err error
+			if v, err = e.eval(item); err != nil {
+				return BigNumber{}, err
+			}
+		}
+		return v, nil
+	case parser.ProgramNode:
+		return e.eval(parser.SeqNode{Items: n.Statements})
+	case parser.WhileNode:
+		v := BigNumber{Int: new(big.Int)}
+		for {
+			cond, err := e.eval(n.Cond)
+			if err == nil && cond.Value != nil {
+				err = n.Locate(fmt.Errorf("while needs a boolean or a number, got a %v%v", cond.Value.Kind, n.At()))
+			}
+			if err != nil || cond.sign() == 0 {
+				return v, err
+			}
+			if v, err = e.eval(n.Body); err != nil {
+				return BigNumber{}, err
+			}
+		}
+	case parser.ForNode:
+		return e.loop(n)
+	case parser.LetNode:
+		v, err := e.eval(n.Value)
+		if err != nil {
+			return BigNumber{}, err
+		}
+		if v.Value != nil {
+			defer n.BindValue(v.Value.value())()
+		} else {
+			defer n.BindValue(v)()
+		}
+		return e.eval(n.Body)
+	case parser.TryNode:
+		if v, err := e.eval(n.Body); err == nil || errors.As(err, new(aborted)) {
+			return v, err
+		}
+		return e.eval(n.Catch)
+	case parser.MatchNode:
+		arm, err := n.Arm()
+		if err != nil {
+			return BigNumber{}, err
+		}
+		return e.eval(arm)
+	}
+	v, err := e.numbers().evalNode(node)
+	if err != nil {
+		return BigNumber{}, err
+	}
+	return e.fromNumber(v)
+}
+
+// numbers evaluates as float64 the nodes without an arbitrary precision
+// implementation
+func (e bigEval) numbers() *numberEval { return &numberEval{e.visit, nil, nil} }
+
+// assign the value of n to its variable, keeping the big numbers exact
+func (e bigEval) assign(n parser.AssignNode) (BigNumber, error) {
+	v, err := e.eval(n.Value)
+	if err != nil {
+		return BigNumber{}, err
+	}
+	if v.Value != nil && v.Value.Kind == KindFunc && n.Funcs != nil {
+		delete(n.Env, n.Name)
+		delete(n.Values, n.Name)
+		n.Funcs[n.Name] = *v.Value.Func
+		return v, nil
+	}
+	if n.Values == nil {
+		if v.Value != nil {
+			return BigNumber{}, fmt.Errorf("cannot assign a %v to %v at %v", v.Value.Kind, n.Name, n.NamePos)
+		}
+		n.Env[n.Name] = v.Float64()
+		return v, nil
+	}
+	delete(n.Env, n.Name)
+	n.Values[n.Name] = v
+	if v.Value != nil {
+		n.Values[n.Name] = v.Value.value()
+	}
+	return v, nil
+}
+
+// loop evaluates the body of n for each value of its variable, counting
+// exactly
+func (e bigEval) loop(n parser.ForNode) (BigNumber, error) {
+	bounds := []lexer.IExpression{n.Start, n.End, n.By()}
+	values := make([]BigNumber, len(bounds))
+	for i, bound := range bounds {
+		v, err := e.eval(bound)
+		if err != nil {
+			return BigNumber{}, err
+		}
+		if v.Value != nil {
+			return BigNumber{}, n.Locate(fmt.Errorf("for needs numbers, got a %v at %v", v.Value.Kind, lexer.SpanOf(bound).Start))
+		}
+		values[i] = v
+	}
+	i, end, step := values[0], values[1], values[2]
+	if err := n.CheckStep(float64(step.sign())); err != nil {
+		return BigNumber{}, err
+	}
+	var within lexer.Operation = lexer.NewTokenLE()
+	if step.sign() < 0 {
+		within = lexer.NewTokenGE()
+	}
+	v := BigNumber{Int: new(big.Int)}
+	var err error
+	for {
+		if cond, err := e.apply(within, i, end); err != nil || cond.sign() == 0 {
+			return v, err
+		}
+		n.Env[n.Var] = i.Float64()
+		if v, err = e.eval(n.Body); err != nil {
+			return BigNumber{}, err
+		}
+		if i, err = e.apply(lexer.NewTokenPlus(), i, step); err != nil {
+			return BigNumber{}, err
+		}
+	}
+}
+
+// logical evaluates right only if left does not decide the result of op,
+// non zero operands being true
+func (e bigEval) logical(op lexer.Logical, left, right lexer.IExpression) (BigNumber, error) {
+	l, err := e.eval(left)
+	if err == nil && l.Value != nil {
+		_, err = boolean(op, *l.Value)
+	}
+	if err != nil {
+		return BigNumber{}, err
+	}
+	if op.Decides(l.sign() != 0) {
+		return bigBool(l.sign() != 0), nil
+	}
+	r, err := e.eval(right)
+	if err == nil && r.Value != nil {
+		_, err = boolean(op, *r.Value)
+	}
+	if err != nil {
+		return BigNumber{}, err
+	}
+	return bigBool(r.sign() != 0), nil
+}
+
+func (n BigNumber) sign() int {
+	if n.Int != nil {
+		return n.Int.Sign()
+	}
+	return n.Float.Sign()
+}
+
+// bigBool is 1 for true and 0 for false
+func bigBool(b bool) BigNumber {
+	return BigNumber{Int: big.NewInt(int64(lexer.Bool(b)))}
+}
+
+// fromNumber converts v, the integers and floats to big numbers
+func (e bigEval) fromNumber(v Number) (BigNumber, error) {
+	switch v.Kind {
+	case KindInt:
+		return BigNumber{Int: big.NewInt(v.Int)}, nil
+	case KindFloat:
+		return e.fromFloat64(v.Float)
+	case KindBool:
+		return bigBool(v.Bool), nil
+	}
+	return BigNumber{Value: &v}, nil
+}
+
+// fromFloat64 converts v through its shortest decimal representation
+func (e bigEval) fromFloat64(v float64) (BigNumber, error) {
+	switch {
+	case math.IsNaN(v):
+		return BigNumber{}, ErrNaN
+	case math.IsInf(v, 0):
+		return BigNumber{Float: e.newFloat().SetInf(v < 0)}, nil
+	case v == math.Trunc(v) && math.Abs(v) < 1<<63:
+		return BigNumber{Int: big.NewInt(int64(v))}, nil
+	}
+	f, _, err := big.ParseFloat(strconv.FormatFloat(v, 'g', -1, 64), 10, e.prec, big.ToNearestEven)
+	return BigNumber{Float: f}, err
+}
+
+func (e bigEval) float(n BigNumber) *big.Float {
+	if n.Int != nil {
+		return new(big.Float).SetPrec(e.prec).SetInt(n.Int)
+	}
+	return n.Float
+}
+
+// cmp compares a and b, integers exactly
+func (e bigEval) cmp(a, b BigNumber) int {
+	if a.Int != nil && b.Int != nil {
+		return a.Int.Cmp(b.Int)
+	}
+	return e.float(a).Cmp(e.float(b))
+}
+
+func (e bigEval) newFloat() *big.Float { return new(big.Float).SetPrec(e.prec) }
+
+func (e bigEval) apply(op lexer.Operation, left, right BigNumber) (BigNumber, error) {
+	if _, ok := op.(parser.WrappedOp); ok {
+		return BigNumber{}, ErrFixedWidthBig
+	}
+	if left.Value != nil || right.Value != nil {
+		v, err := apply(op, left.number(), right.number())
+		if err != nil {
+			return BigNumber{}, err
+		}
+		return e.fromNumber(v)
+	}
+	ints := left.Int != nil && right.Int != nil
+	if c, ok := op.(lexer.Comparison); ok {
+		return bigBool(c.Holds(e.cmp(left, right))), nil
+	}
+	switch parser.OpType(op) {
+	case lexer.TypePlus:
+		if ints {
+			return BigNumber{Int: new(big.Int).Add(left.Int, right.Int)}, nil
+		}
+		return BigNumber{Float: e.newFloat().Add(e.float(left), e.float(right))}, nil
+	case lexer.TypeMinus:
+		if ints {
+			return BigNumber{Int: new(big.Int).Sub(left.Int, right.Int)}, nil
+		}
+		return BigNumber{Float: e.newFloat().Sub(e.float(left), e.float(right))}, nil
+	case lexer.TypeMul:
+		if ints {
+			return BigNumber{Int: new(big.Int).Mul(left.Int, right.Int)}, nil
+		}
+		return BigNumber{Float: e.newFloat().Mul(e.float(left), e.float(right))}, nil
+	case lexer.TypeDiv:
+		return e.divide(op, left, right)
+	case lexer.TypePow:
+		if right.Int != nil && right.Int.IsInt64() && (left.Int == nil || fitsBig(left.Int, right.Int.Int64())) {
+			return e.pow(left, right.Int.Int64()), nil
+		}
+	case lexer.TypeShl, lexer.TypeShr:
+		if ints && right.Int.Sign() >= 0 && right.Int.Cmp(big.NewInt(maxBigBits)) <= 0 {
+			if parser.OpType(op) == lexer.TypeShl {
+				return BigNumber{Int: new(big.Int).Lsh(left.Int, uint(right.Int.Uint64()))}, nil
+			}
+			return BigNumber{Int: new(big.Int).Rsh(left.Int, uint(right.Int.Uint64()))}, nil
+		}
+	}
+	v, err := op.Eval(lexer.NewTokenFloat(left.Float64()), lexer.NewTokenFloat(right.Float64()))
+	if err != nil {
+		return BigNumber{}, err
+	}
+	return e.fromFloat64(v)
+}
+
+// divide according to the division mode of op, integers dividing evenly
+// yielding integers
+func (e bigEval) divide(op lexer.Operation, left, right BigNumber) (BigNumber, error) {
+	if (right.Int != nil && right.Int.Sign() == 0) || (right.Float != nil && right.Float.Sign() == 0) {
+		_, err := op.Eval(lexer.NewTokenInt(0), lexer.NewTokenInt(0))
+		return BigNumber{}, err
+	}
+	mode := parser.DivTrue
+	if d, ok := op.(parser.DivOp); ok {
+		mode = d.Mode
+	}
+	if left.Int != nil && right.Int != nil {
+		q, r := new(big.Int).QuoRem(left.Int, right.Int, new(big.Int))
+		switch {
+		case r.Sign() == 0 || mode == parser.DivTrunc:
+			return BigNumber{Int: q}, nil
+		case mode == parser.DivExact:
+			return BigNumber{}, fmt.Errorf("%w: %v / %v%v", parser.ErrInexactDivision, left, right, op.(parser.DivOp).At())
+		}
+	}
+	return BigNumber{Float: e.newFloat().Quo(e.float(left), e.float(right))}, nil
+}
+
+// fitsBig tells if base^exp has at most maxBigBits bits
+func fitsBig(base *big.Int, exp int64) bool {
+	return exp <= maxBigBits && int64(base.BitLen())*exp <= maxBigBits
+}
+
+// pow raises base to an integer power by squaring
+func (e bigEval) pow(base BigNumber, exp int64) BigNumber {
+	if base.Int != nil && exp >= 0 {
+		return BigNumber{Int: new(big.Int).Exp(base.Int, big.NewInt(exp), nil)}
+	}
+	x, v := e.newFloat().Set(e.float(base)), e.newFloat().SetInt64(1)
+	for n := exp; n != 0; n /= 2 {
+		if n%2 != 0 {
+			v.Mul(v, x)
+		}
+		x.Mul(x, x)
+	}
+	if exp < 0 {
+		v.Quo(e.newFloat().SetInt64(1), v)
+	}
+	return BigNumber{Float: v}
+}
@@ -0,0 +1,145 @@
+package eval
+
+import (
+	"math/big"
+
+	"github.com/fmarmol/lexp/parser"
+)
+
+// bigFuncs are the builtins computed with arbitrary precision, reporting if
+// they could, the other calls being computed as float64
+var bigFuncs = map[string]func(e bigEval, args []BigNumber) (BigNumber, bool){
+	"sqrt":  bigEval.sqrt,
+	"abs":   bigEval.abs,
+	"floor": integral(bigFloor),
+	"ceil":  integral(bigCeil),
+	"trunc": integral(bigTrunc),
+	"round": integral(bigRound),
+	"min":   extremum(-1),
+	"max":   extremum(1),
+}
+
+// call the function of n, computing the builtins of bigFuncs with arbitrary
+// precision and the other functions like EvalNumber
+func (e bigEval) call(n parser.CallNode) (BigNumber, error) {
+	numbers := e.numbers()
+	if v, ok, err := numbers.valueCall(n); ok {
+		if err != nil {
+			return BigNumber{}, err
+		}
+		return e.fromNumber(v)
+	}
+	args := make([]BigNumber, len(n.Args))
+	numeric := true
+	for i, arg := range n.Args {
+		v, err := e.eval(arg)
+		if err != nil {
+			return BigNumber{}, err
+		}
+		args[i], numeric = v, numeric && v.Value == nil
+	}
+	if f, ok := bigFuncs[n.Name()]; ok && numeric {
+		if builtin, err := n.Resolve(len(args)); err == nil && builtin.Closure == nil {
+			if v, ok := f(e, args); ok {
+				return v, nil
+			}
+		}
+	}
+	operands := make([]Number, len(args))
+	for i, arg := range args {
+		operands[i] = arg.number()
+		if err := operand(n, n.Args[i], operands[i]); err != nil {
+			return BigNumber{}, err
+		}
+	}
+	v, err := numbers.callNode(n, operands)
+	if err != nil {
+		return BigNumber{}, err
+	}
+	return e.fromNumber(v)
+}
+
+// sqrt is exact for the perfect squares
+func (e bigEval) sqrt(args []BigNumber) (BigNumber, bool) {
+	if len(args) != 1 || args[0].sign() < 0 {
+		return BigNumber{}, false
+	}
+	x := args[0]
+	if x.Int != nil {
+		if r := new(big.Int).Sqrt(x.Int); new(big.Int).Mul(r, r).Cmp(x.Int) == 0 {
+			return BigNumber{Int: r}, true
+		}
+	}
+	return BigNumber{Float: e.newFloat().Sqrt(e.float(x))}, true
+}
+
+func (e bigEval) abs(args []BigNumber) (BigNumber, bool) {
+	if len(args) != 1 {
+		return BigNumber{}, false
+	}
+	if args[0].Int != nil {
+		return BigNumber{Int: new(big.Int).Abs(args[0].Int)}, true
+	}
+	return BigNumber{Float: e.newFloat().Abs(args[0].Float)}, true
+}
+
+// extremum returns the smallest of its arguments for order -1, the largest
+// for 1
+func extremum(order int) func(e bigEval, args []BigNumber) (BigNumber, bool) {
+	return func(e bigEval, args []BigNumber) (BigNumber, bool) {
+		if len(args) == 0 {
+			return BigNumber{}, false
+		}
+		v := args[0]
+		for _, arg := range args[1:] {
+			if e.cmp(arg, v) == order {
+				v = arg
+			}
+		}
+		return v, true
+	}
+}
+
+// integral rounds its argument to an integer with round, which is given
+// the integer toward zero and the fraction left
+func integral(round func(t *big.Int, frac *big.Float) *big.Int) func(e bigEval, args []BigNumber) (BigNumber, bool) {
+	return func(e bigEval, args []BigNumber) (BigNumber, bool) {
+		if len(args) != 1 {
+			return BigNumber{}, false
+		}
+		x := args[0]
+		if x.Int != nil {
+			return x, true
+		}
+		if x.Float.IsInf() {
+			return BigNumber{}, false
+		}
+		t, _ := x.Float.Int(nil)
+		frac := new(big.Float).SetPrec(x.Float.Prec()).Sub(x.Float, new(big.Float).SetInt(t))
+		return BigNumber{Int: round(t, frac)}, true
+	}
+}
+
+func bigFloor(t *big.Int, frac *big.Float) *big.Int {
+	if frac.Sign() < 0 {
+		return t.Sub(t, big.NewInt(1))
+	}
+	return t
+}
+
+func bigCeil(t *big.Int, frac *big.Float) *big.Int {
+	if frac.Sign() > 0 {
+		return t.Add(t, big.NewInt(1))
+	}
+	return t
+}
+
+func bigTrunc(t *big.Int, _ *big.Float) *big.Int { return t }
+
+// bigRound rounds halves away from zero
+func bigRound(t *big.Int, frac *big.Float) *big.Int {
+	if new(big.Float).Abs(frac).Cmp(big.NewFloat(0.5)) >= 0 {
+		return t.Add(t, big.NewInt(int64(frac.Sign())))
+	}
+	return t
+}
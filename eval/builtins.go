@@ -0,0 +1,99 @@
+package eval
+
+import (
+	"math"
+
+	"github.com/fmarmol/lexp/parser"
+)
+
+// Constants are the named constants available to every expression
+var Constants = parser.Env{
+	"pi":  math.Pi,
+	"e":   math.E,
+	"tau": 2 * math.Pi,
+}
+
+// Builtins are the functions available to every expression
+var Builtins = parser.Funcs{
+	"abs":   unary(math.Abs),
+	"sqrt":  unary(math.Sqrt),
+	"cbrt":  unary(math.Cbrt),
+	"exp":   unary(math.Exp),
+	"ln":    unary(math.Log),
+	"log":   unary(math.Log),
+	"log2":  unary(math.Log2),
+	"log10": unary(math.Log10),
+	"sin":   unary(math.Sin),
+	"cos":   unary(math.Cos),
+	"tan":   unary(math.Tan),
+	"asin":  unary(math.Asin),
+	"acos":  unary(math.Acos),
+	"atan":  unary(math.Atan),
+	"sinh":  unary(math.Sinh),
+	"cosh":  unary(math.Cosh),
+	"tanh":  unary(math.Tanh),
+	"floor": unary(math.Floor),
+	"ceil":  unary(math.Ceil),
+	"round": unary(math.Round),
+	"trunc": unary(math.Trunc),
+	"pow":   binary(math.Pow),
+	"atan2": binary(math.Atan2),
+	"hypot": binary(math.Hypot),
+	"mod":   binary(math.Mod),
+	"min":   variadic(fold1(math.Min)),
+	"max":   variadic(fold1(math.Max)),
+
+	"sum":      {MinArgs: 0, MaxArgs: -1, Call: Sum},
+	"len":      {MinArgs: 0, MaxArgs: -1, Call: count},
+	"keys":     mapOnly("keys"),
+	"values":   mapOnly("values"),
+	"mean":     variadic(Mean),
+	"median":   variadic(Median),
+	"variance": variadic(Variance),
+	"stddev":   variadic(StdDev),
+	"percentile": {MinArgs: 2, MaxArgs: -1, Call: func(args ...float64) (float64, error) {
+		return Percentile(args[:len(args)-1], args[len(args)-1])
+	}},
+
+	"normpdf": ternary(NormPDF),
+	"normcdf": ternary(NormCDF),
+	"binom":   ternary(Binom),
+	"poisson": {MinArgs: 2, MaxArgs: 2, Call: func(args ...float64) (float64, error) {
+		return Poisson(args[0], args[1])
+	}},
+}
+
+func unary(f func(float64) float64) parser.Func {
+	return parser.Func{MinArgs: 1, MaxArgs: 1, Call: func(args ...float64) (float64, error) {
+		return f(args[0]), nil
+	}}
+}
+
+func binary(f func(float64, float64) float64) parser.Func {
+	return parser.Func{MinArgs: 2, MaxArgs: 2, Call: func(args ...float64) (float64, error) {
+		return f(args[0], args[1]), nil
+	}}
+}
+
+func ternary(f func(float64, float64, float64) (float64, error)) parser.Func {
+	return parser.Func{MinArgs: 3, MaxArgs: 3, Call: func(args ...float64) (float64, error) {
+		return f(args[0], args[1], args[2])
+	}}
+}
+
+func variadic(f func([]float64) (float64, error)) parser.Func {
+	return parser.Func{MinArgs: 1, MaxArgs: -1, Call: func(args ...float64) (float64, error) {
+		return f(args)
+	}}
+}
+
+// fold1 reduces its arguments with f from left to right
+func fold1(f func(float64, float64) float64) func([]float64) (float64, error) {
+	return func(xs []float64) (float64, error) {
+		v := xs[0]
+		for _, x := range xs[1:] {
+			v = f(v, x)
+		}
+		return v, nil
+	}
+}
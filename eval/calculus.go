@@ -0,0 +1,245 @@
+package eval
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+
+	"github.com/fmarmol/lexp/lexer"
+	"github.com/fmarmol/lexp/parser"
+)
+
+// Function of one variable: the expression evaluated with name bound to its
+// argument in env, env must be the environment expr was parsed with.
+// Evaluation errors, like a division by zero, yield NaN.
+func Function(expr lexer.IExpression, env parser.Env, name string) func(float64) float64 {
+	return func(x float64) float64 {
+		env[name] = x
+		v, err := expr.Eval()
+		if err != nil {
+			return math.NaN()
+		}
+		return v
+	}
+}
+
+// calculusFuncs are the value funcs called unqualified, the function they
+// take being named, a lambda, or an expression in the variable named by the
+// next argument: integrate((x) -> x^2, 0, 1) or integrate(x^2, x, 0, 1)
+var calculusFuncs = map[string]valueFunc{
+	"nderiv": {2, 3, func(e *numberEval, n parser.CallNode) (Number, error) {
+		f, args, err := e.realFunc(n, 1)
+		if err != nil {
+			return Number{}, err
+		}
+		v := NDeriv(f.eval, args[0])
+		return FloatNumber(v), f.err
+	}},
+	"integrate": {3, 4, func(e *numberEval, n parser.CallNode) (Number, error) {
+		f, args, err := e.realFunc(n, 2)
+		if err != nil {
+			return Number{}, err
+		}
+		v, err := Integrate(f.eval, args[0], args[1])
+		if f.err != nil {
+			err = f.err
+		}
+		return FloatNumber(v), err
+	}},
+	"solve": {2, 3, func(e *numberEval, n parser.CallNode) (Number, error) {
+		f, args, err := e.realFunc(n, 1)
+		if err != nil {
+			return Number{}, err
+		}
+		v, err := Solve(f.eval, args[0])
+		if f.err != nil && (err != nil || f.aborted()) {
+			err = f.err
+		}
+		return FloatNumber(v), err
+	}},
+	"table": {4, 5, (*numberEval).table},
+}
+
+// realFunc is a function of one number evaluated by the number evaluator
+type realFunc struct {
+	call func(x float64) (Number, error)
+	name string // of the calculus func calling it
+	err  error  // first error of call, the value being NaN
+}
+
+func (f *realFunc) eval(x float64) float64 {
+	if f.aborted() {
+		return math.NaN()
+	}
+	v, err := f.call(x)
+	if err == nil && (!v.Scalar() || v.Kind == KindBool) {
+		err = fmt.Errorf("%v expects a function returning numbers, got a %v", f.name, v.Kind)
+	}
+	if err != nil {
+		if f.err == nil || errors.As(err, new(aborted)) {
+			f.err = err
+		}
+		return math.NaN()
+	}
+	return v.Float64()
+}
+
+// aborted tells if an evaluation of f was aborted, the calculus func failing
+// with its error
+func (f *realFunc) aborted() bool {
+	return errors.As(f.err, new(aborted))
+}
+
+// realFunc resolves the function of the calculus func n and evaluates the
+// count numbers following it
+func (e *numberEval) realFunc(n parser.CallNode, count int) (*realFunc, []float64, error) {
+	f := &realFunc{name: n.Name()}
+	first := 1
+	if len(n.Args) == count+1 {
+		fn, err := e.funcArg(n, 0, 1)
+		if err != nil {
+			return nil, nil, err
+		}
+		f.call = func(x float64) (Number, error) { return e.invoke(fn, []float64{x}) }
+	} else {
+		v, ok := n.Args[1].(parser.VarNode)
+		if !ok {
+			return nil, nil, fmt.Errorf("%v expects the name of the variable of its expression at %v", n.Name(), lexer.SpanOf(n.Args[1]).Start)
+		}
+		name, env, values := v.Name(), maps.Clone(v.Env), maps.Clone(v.Values)
+		delete(values, name)
+		expr := parser.RebindValues(n.Args[0], func(parser.Env) parser.Env { return env }, func(parser.Values) parser.Values { return values }, nil)
+		f.call = func(x float64) (Number, error) {
+			env[name] = x
+			return e.eval(expr)
+		}
+		first = 2
+	}
+	args := make([]float64, count)
+	for i := range args {
+		v, err := e.eval(n.Args[first+i])
+		if err != nil {
+			return nil, nil, err
+		}
+		if !v.Scalar() || v.Kind == KindBool {
+			return nil, nil, fmt.Errorf("%v expects a number, got a %v at %v", n.Name(), v.Kind, lexer.SpanOf(n.Args[first+i]).Start)
+		}
+		args[i] = v.Float64()
+	}
+	return f, args, nil
+}
+
+// NDeriv approximates the derivative of f at x with a central difference
+func NDeriv(f func(float64) float64, x float64) float64 {
+	// the step balances truncation and rounding errors
+	h := math.Cbrt(2.220446049250313e-16) * math.Max(1, math.Abs(x))
+	return (f(x+h) - f(x-h)) / (2 * h)
+}
+
+const (
+	integrateTolerance = 1e-10
+	integrateMaxDepth  = 50
+)
+
+// Integrate approximates the integral of f over [a, b] with adaptive
+// Simpson quadrature
+func Integrate(f func(float64) float64, a, b float64) (float64, error) {
+	if a == b {
+		return 0, nil
+	}
+	fa, fb := f(a), f(b)
+	m := (a + b) / 2
+	fm := f(m)
+	whole := (b - a) / 6 * (fa + 4*fm + fb)
+	v, err := simpson(f, a, b, fa, fm, fb, whole, integrateTolerance, integrateMaxDepth)
+	if err != nil {
+		return v, err
+	}
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return v, fmt.Errorf("integral over [%v, %v] does not converge", a, b)
+	}
+	return v, nil
+}
+
+func simpson(f func(float64) float64, a, b, fa, fm, fb, whole, tolerance float64, depth int) (float64, error) {
+	m := (a + b) / 2
+	lm, rm := (a+m)/2, (m+b)/2
+	flm, frm := f(lm), f(rm)
+	left := (m - a) / 6 * (fa + 4*flm + fm)
+	right := (b - m) / 6 * (fm + 4*frm + fb)
+	delta := left + right - whole
+	if math.Abs(delta) <= 15*tolerance {
+		return left + right + delta/15, nil
+	}
+	if depth == 0 {
+		return left + right, fmt.Errorf("integral did not converge around %v", m)
+	}
+	l, err := simpson(f, a, m, fa, flm, fm, left, tolerance/2, depth-1)
+	if err != nil {
+		return l, err
+	}
+	r, err := simpson(f, m, b, fm, frm, fb, right, tolerance/2, depth-1)
+	return l + r, err
+}
+
+// ErrNoConvergence ...
+var ErrNoConvergence = errors.New("no convergence")
+
+const (
+	solveTolerance = 1e-12
+	solveMaxIter   = 100
+	solveMaxExpand = 60
+)
+
+// Solve finds a root of f starting from the guess x0, using Newton's method
+// and falling back to bisection over a bracket grown around x0
+func Solve(f func(float64) float64, x0 float64) (float64, error) {
+	x := x0
+	for i := 0; i < solveMaxIter; i++ {
+		fx := f(x)
+		if math.Abs(fx) < solveTolerance {
+			return x, nil
+		}
+		d := NDeriv(f, x)
+		if d == 0 || math.IsNaN(d) || math.IsInf(d, 0) {
+			break
+		}
+		next := x - fx/d
+		if math.IsNaN(next) || math.IsInf(next, 0) {
+			break
+		}
+		if math.Abs(next-x) <= solveTolerance*math.Max(1, math.Abs(x)) {
+			return next, nil
+		}
+		x = next
+	}
+	return bisect(f, x0)
+}
+
+func bisect(f func(float64) float64, x0 float64) (float64, error) {
+	a, b := x0, x0
+	fa, fb := f(a), f(b)
+	dx := math.Max(1, math.Abs(x0)) / 100
+	for i := 0; fa*fb > 0 || math.IsNaN(fa*fb); i++ {
+		if i == solveMaxExpand {
+			return x0, fmt.Errorf("%w: no sign change found around %v", ErrNoConvergence, x0)
+		}
+		a, b = a-dx, b+dx
+		fa, fb = f(a), f(b)
+		dx *= 2
+	}
+	for i := 0; i < solveMaxIter*2; i++ {
+		m := (a + b) / 2
+		fm := f(m)
+		if fm == 0 || (b-a)/2 <= solveTolerance*math.Max(1, math.Abs(m)) {
+			return m, nil
+		}
+		if fa*fm < 0 {
+			b = m
+		} else {
+			a, fa = m, fm
+		}
+	}
+	return (a + b) / 2, fmt.Errorf("%w after %v iterations", ErrNoConvergence, solveMaxIter*2)
+}
@@ -0,0 +1,37 @@
+package eval
+
+import (
+	"maps"
+	"reflect"
+
+	"github.com/fmarmol/lexp/lexer"
+	"github.com/fmarmol/lexp/parser"
+)
+
+// Clone returns an independent deep copy of node, the environments, values
+// and functions its variables, assignments and calls are bound to being
+// copied as well
+func Clone(node lexer.IExpression) lexer.IExpression {
+	return parser.RebindValues(node, copier(copyEnv), copier(maps.Clone[parser.Values]), copier(maps.Clone[parser.Funcs]))
+}
+
+// copier returns a function copying the tables with copy, once for each of
+// them so that the nodes sharing one share its copy
+func copier[M ~map[string]V, V any](copy func(M) M) func(M) M {
+	copies := map[uintptr]M{}
+	return func(m M) M {
+		key := reflect.ValueOf(m).Pointer()
+		if c, ok := copies[key]; ok {
+			return c
+		}
+		c := copy(m)
+		copies[key] = c
+		return c
+	}
+}
+
+// Bind returns a deep copy of node whose variables and assignments are bound
+// to env
+func Bind(node lexer.IExpression, env parser.Env) lexer.IExpression {
+	return parser.Rebind(node, func(parser.Env) parser.Env { return env }, nil)
+}
@@ -0,0 +1,796 @@
+package eval
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/fmarmol/lexp/lexer"
+	"github.com/fmarmol/lexp/parser"
+)
+
+// Program is an expression compiled to the instructions of a stack machine,
+// evaluating it without walking the tree. A Program is not modified by its
+// evaluations.
+type Program struct {
+	code []instruction
+	// depth is the maximum size of the stack
+	depth int
+	// vars read by the program, loaded once from their environment then
+	// held in slots indexed by arg
+	vars []parser.VarNode
+	// names of vars
+	names []string
+	// straight tells if the instructions only compute numbers, each of them
+	// running once
+	straight bool
+	// stacks reused by the runs, the slots of the variables first
+	stacks sync.Pool
+}
+
+type opcode uint8
+
+const (
+	opConst opcode = iota // push value
+	opLoad                // push the variable of slot arg
+	opStore               // assign the top of the stack to node and slot arg
+	opPop                 // drop the top of the stack
+	opAdd
+	opSub
+	opMul
+	opPow
+	opDiv // divide with the TokenDiv of op
+	// apply the operator to the top of the stack and value, saving the
+	// instruction pushing the constant
+	opAddConst
+	opSubConst
+	opMulConst
+	opDivConst // value is not 0
+	opCompare  // push whether the order of the operands holds
+	opApply    // apply the operation op to the floats
+	opNot
+	opDecide    // jump to arg if the top of the stack decides, else drop it
+	opBool      // replace the top of the stack by 1 if it is not 0
+	opCall      // call the function of node with the arg values on the stack
+	opEval      // push the value of node, evaluated as a tree
+	opJump      // continue at arg
+	opJumpFalse // drop the top of the stack, jumping to arg if it is 0
+	// push the counter of the for loop node, below its end and step, if it
+	// has not passed its end, else jump to arg
+	opForNext
+	// move the value of a for loop body to the result below its counter,
+	// count by step and continue at arg
+	opForStep
+	opDefine // define the function of node, pushing 0
+	// bind the let node to the value it pops, saving the previous value of
+	// slot arg
+	opLet
+	opUnlet // restore the variable of the innermost let bound
+	// continue at arg if an instruction fails until the matching opEndTry
+	opTry
+	opEndTry // stop catching the failures of the innermost try, continue at arg
+	// replace the two values at the top of the stack by the hundredths of
+	// their product, applying oper if set to the first one and them
+	opPercent
+)
+
+var opcodes = [...]string{"const", "load", "store", "pop", "add", "sub", "mul", "pow", "div", "addconst", "subconst", "mulconst", "divconst", "compare", "apply", "not", "decide", "bool", "call", "eval", "jump", "jumpfalse", "fornext", "forstep", "define", "let", "unlet", "try", "endtry", "percent"}
+
+func (o opcode) String() string { return opcodes[o] }
+
+type instruction struct {
+	op    opcode
+	arg   int
+	value float64
+	node  lexer.IExpression
+	oper  lexer.Operation
+	// fn of opCall, nil if resolved when it runs
+	fn func(args ...float64) (float64, error)
+	// holds tells for each order, -1, 0, 1 or lexer.Unordered shifted by
+	// one, if a comparison holds and for false and true if a logical
+	// operation decides
+	holds [4]bool
+}
+
+func (i instruction) String() string {
+	switch i.op {
+	case opConst, opAddConst, opSubConst, opMulConst, opDivConst:
+		return fmt.Sprintf("%v %v", i.op, i.value)
+	case opLoad, opJump, opJumpFalse, opForNext, opForStep, opTry, opEndTry:
+		return fmt.Sprintf("%v %v", i.op, i.arg)
+	case opStore:
+		return fmt.Sprintf("%v %v %v", i.op, i.node.(parser.AssignNode).Name, i.arg)
+	case opCall:
+		return fmt.Sprintf("%v %v %v", i.op, i.node.(parser.CallNode).Name(), i.arg)
+	case opEval:
+		return fmt.Sprintf("%v %v", i.op, parser.Source(i.node))
+	case opDefine:
+		return fmt.Sprintf("%v %v", i.op, i.node.(parser.DefNode).Signature())
+	case opLet:
+		return fmt.Sprintf("%v %v %v", i.op, i.node.(parser.LetNode).Name, i.arg)
+	case opDiv, opCompare, opApply:
+		return fmt.Sprintf("%v %v", i.op, lexer.Symbols[parser.OpType(i.oper)])
+	case opPercent:
+		if i.oper != nil {
+			return fmt.Sprintf("%v %v", i.op, lexer.Symbols[parser.OpType(i.oper)])
+		}
+	case opDecide:
+		return fmt.Sprintf("%v %v %v", i.op, lexer.Symbols[parser.OpType(i.oper)], i.arg)
+	}
+	return i.op.String()
+}
+
+// String lists the instructions of p, one per line
+func (p *Program) String() string {
+	lines := make([]string, len(p.code))
+	for i, instr := range p.code {
+		lines[i] = fmt.Sprintf("%4d %v", i, instr)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Compile node to a Program evaluating it like Eval. Variables are read
+// from their environment when the program starts and assignments are
+// written back to it, so a program can be evaluated many times for
+// different values. The calls are bound to the functions defined when node
+// is compiled, unless it defines functions itself. The nodes holding values
+// which are not numbers, like lists, are not compiled: their programs walk
+// the tree of node, with the values of EvalNumber.
+func Compile(node lexer.IExpression) *Program {
+	c := &compiler{slots: map[string]int{}, defines: defines(node)}
+	if compiled(node) {
+		c.compile(node)
+	} else {
+		c.emit(instruction{op: opEval, node: node}, 1)
+	}
+	names := make([]string, len(c.vars))
+	for i, v := range c.vars {
+		names[i] = v.Name()
+	}
+	straight := true
+	for _, instr := range c.code {
+		switch instr.op {
+		case opConst, opLoad, opAdd, opSub, opMul, opPow, opDiv, opAddConst, opSubConst, opMulConst, opDivConst, opCompare, opApply, opNot, opPercent:
+		case opCall:
+			straight = straight && instr.fn != nil
+		default:
+			straight = false
+		}
+	}
+	return &Program{code: c.code, depth: c.depth, vars: c.vars, names: names, straight: straight}
+}
+
+// compiled tells if the nodes of node only hold numbers, so that they can be
+// compiled
+func compiled(node lexer.IExpression) bool {
+	ok := true
+	parser.Walk(node, func(n lexer.IExpression) bool {
+		switch n := n.(type) {
+		case lexer.TokenInt, lexer.TokenFloat, lexer.TokenBool, parser.VarNode, parser.BinOpNode,
+			parser.UnaryOpNode, parser.AssignNode, parser.SeqNode, parser.ProgramNode, parser.WhileNode,
+			parser.ForNode, parser.LambdaNode, parser.DefNode, parser.LetNode, parser.TryNode, parser.PercentNode:
+		case parser.CallNode:
+			ok = ok && n.Funcs[n.Name()].Value == nil
+		default:
+			ok = false
+		}
+		return ok
+	})
+	return ok
+}
+
+// defines tells if node defines functions
+func defines(node lexer.IExpression) bool {
+	defines := false
+	parser.Walk(node, func(n lexer.IExpression) bool {
+		_, ok := n.(parser.DefNode)
+		defines = defines || ok
+		return !defines
+	})
+	return defines
+}
+
+type compiler struct {
+	code        []instruction
+	size, depth int
+	vars        []parser.VarNode
+	slots       map[string]int
+	// defines tells if the program defines functions, its calls being
+	// resolved when it runs
+	defines bool
+}
+
+// slot of the variable name, node providing its environment
+func (c *compiler) slot(name string, node parser.VarNode) int {
+	slot, ok := c.slots[name]
+	if !ok {
+		slot = len(c.vars)
+		c.slots[name] = slot
+		c.vars = append(c.vars, node)
+	}
+	return slot
+}
+
+func (c *compiler) emit(instr instruction, delta int) {
+	c.code = append(c.code, instr)
+	c.size += delta
+	c.depth = max(c.depth, c.size)
+}
+
+func (c *compiler) compile(node lexer.IExpression) {
+	switch n := node.(type) {
+	case lexer.TokenInt, lexer.TokenFloat, lexer.TokenBool:
+		v, _ := n.Eval()
+		c.emit(instruction{op: opConst, value: v}, 1)
+	case parser.VarNode:
+		c.emit(instruction{op: opLoad, arg: c.slot(n.Name(), n)}, 1)
+	case parser.BinOpNode:
+		if op, ok := n.Op.(lexer.Logical); ok {
+			c.compile(n.Left)
+			decide := len(c.code)
+			c.emit(instruction{op: opDecide, oper: op, holds: [4]bool{op.Decides(false), op.Decides(true)}}, -1)
+			c.compile(n.Right)
+			c.emit(instruction{op: opBool}, 0)
+			c.code[decide].arg = len(c.code)
+			return
+		}
+		c.compile(n.Left)
+		if instr, ok := c.constOperation(n.Op, n.Right); ok {
+			c.emit(instr, 0)
+			return
+		}
+		c.compile(n.Right)
+		c.emit(c.operation(n.Op), -1)
+	case parser.UnaryOpNode:
+		if _, ok := n.Op.(lexer.TokenNot); ok {
+			c.compile(n.Operand)
+			c.emit(instruction{op: opNot}, 0)
+			return
+		}
+		c.emit(instruction{op: opConst}, 1)
+		c.compile(n.Operand)
+		c.emit(c.operation(n.Op), -1)
+	case parser.CallNode:
+		for _, arg := range n.Args {
+			c.compile(arg)
+		}
+		instr := instruction{op: opCall, arg: len(n.Args), node: n}
+		if f, err := n.Resolve(len(n.Args)); err == nil && !c.defines {
+			instr.fn = f.Call
+		}
+		c.emit(instr, 1-len(n.Args))
+	case parser.AssignNode:
+		c.compile(n.Value)
+		name := lexer.NewTokenIdent(n.Name)
+		c.emit(instruction{op: opStore, arg: c.slot(n.Name, parser.VarNode{Token: name.Token, Env: n.Env}), node: n}, 0)
+	case parser.SeqNode:
+		c.sequence(n.Items)
+	case parser.ProgramNode:
+		c.sequence(n.Statements)
+	case parser.WhileNode:
+		c.emit(instruction{op: opConst}, 1)
+		start := len(c.code)
+		c.compile(n.Cond)
+		exit := len(c.code)
+		c.emit(instruction{op: opJumpFalse}, -1)
+		c.emit(instruction{op: opPop}, -1)
+		c.compile(n.Body)
+		c.emit(instruction{op: opJump, arg: start}, 0)
+		c.code[exit].arg = len(c.code)
+	case parser.ForNode:
+		c.emit(instruction{op: opConst}, 1)
+		c.compile(n.Start)
+		c.compile(n.End)
+		c.compile(n.By())
+		start := len(c.code)
+		c.emit(instruction{op: opForNext, node: n}, 1)
+		name := lexer.NewTokenIdent(n.Var)
+		assign := parser.AssignNode{Name: n.Var, Env: n.Env, NamePos: n.NamePos}
+		c.emit(instruction{op: opStore, arg: c.slot(n.Var, parser.VarNode{Token: name.Token, Env: n.Env}), node: assign}, 0)
+		c.emit(instruction{op: opPop}, -1)
+		c.compile(n.Body)
+		c.emit(instruction{op: opForStep, arg: start}, -1)
+		// the counter, end and step are left when the loop ends
+		c.code[start].arg = len(c.code)
+		for range 3 {
+			c.emit(instruction{op: opPop}, -1)
+		}
+	case parser.DefNode:
+		c.emit(instruction{op: opDefine, node: n}, 1)
+	case parser.LetNode:
+		c.compile(n.Value)
+		name := lexer.NewTokenIdent(n.Name)
+		c.emit(instruction{op: opLet, arg: c.slot(n.Name, parser.VarNode{Token: name.Token, Env: n.Env}), node: n}, -1)
+		c.compile(n.Body)
+		c.emit(instruction{op: opUnlet}, 0)
+	case parser.TryNode:
+		try := len(c.code)
+		c.emit(instruction{op: opTry}, 0)
+		c.compile(n.Body)
+		end := len(c.code)
+		c.emit(instruction{op: opEndTry}, 0)
+		// the fallback runs without the value of the body
+		c.code[try].arg = len(c.code)
+		c.size--
+		c.compile(n.Catch)
+		c.code[end].arg = len(c.code)
+	case parser.PercentNode:
+		// the base of a relative percentage is evaluated first
+		if n.Relative() {
+			c.compile(n.Base)
+		}
+		c.compile(n.Percent)
+		switch {
+		case n.Base == nil:
+			c.emit(instruction{op: opConst, value: 1}, 1)
+		case !n.Relative():
+			c.compile(n.Base)
+		}
+		c.emit(instruction{op: opPercent, oper: n.Op}, -1)
+	default:
+		c.emit(instruction{op: opEval, node: node}, 1)
+	}
+}
+
+// sequence keeps the value of the last node only
+func (c *compiler) sequence(nodes []lexer.IExpression) {
+	if len(nodes) == 0 {
+		c.emit(instruction{op: opConst}, 1)
+	}
+	for i, node := range nodes {
+		if i > 0 {
+			c.emit(instruction{op: opPop}, -1)
+		}
+		c.compile(node)
+	}
+}
+
+// constOperation computing op on the top of the stack and right if it is a
+// number literal
+func (c *compiler) constOperation(op lexer.Operation, right lexer.IExpression) (instruction, bool) {
+	switch right.(type) {
+	case lexer.TokenInt, lexer.TokenFloat:
+	default:
+		return instruction{}, false
+	}
+	v, _ := right.Eval()
+	switch op.(type) {
+	case lexer.TokenPlus:
+		return instruction{op: opAddConst, value: v}, true
+	case lexer.TokenMinus:
+		return instruction{op: opSubConst, value: v}, true
+	case lexer.TokenMul:
+		return instruction{op: opMulConst, value: v}, true
+	case lexer.TokenDiv:
+		return instruction{op: opDivConst, value: v}, v != 0
+	}
+	return instruction{}, false
+}
+
+// operation computing op on the two values at the top of the stack, the
+// plain float operators running without calling op
+func (c *compiler) operation(op lexer.Operation) instruction {
+	switch c := op.(type) {
+	case lexer.TokenPlus:
+		return instruction{op: opAdd}
+	case lexer.TokenMinus:
+		return instruction{op: opSub}
+	case lexer.TokenMul:
+		return instruction{op: opMul}
+	case lexer.TokenPow:
+		return instruction{op: opPow}
+	case lexer.TokenDiv:
+		return instruction{op: opDiv, oper: op}
+	case lexer.Comparison:
+		instr := instruction{op: opCompare, oper: op}
+		for order := -1; order <= lexer.Unordered; order++ {
+			instr.holds[order+1] = c.Holds(order)
+		}
+		return instr
+	}
+	return instruction{op: opApply, oper: op}
+}
+
+// Eval runs p, failing like the evaluation of the compiled node would
+func (p *Program) Eval() (float64, error) {
+	return p.run(nil, 0)
+}
+
+// EvalIn runs p with the variables of env, instead of the environments the
+// compiled node is bound to. Assignments and function definitions only last
+// for the run, env is not modified, so that a program can run in several
+// goroutines at once for different environments.
+func (p *Program) EvalIn(env parser.Env) (float64, error) {
+	return p.EvalLimited(env, 0)
+}
+
+// ErrTooManySteps is reported by the programs running more instructions
+// than allowed
+var ErrTooManySteps = errors.New("too many evaluation steps")
+
+// EvalLimited runs p like EvalIn, failing with ErrTooManySteps if it runs
+// more than steps instructions, 0 for no limit
+func (p *Program) EvalLimited(env parser.Env, steps int) (float64, error) {
+	if env == nil {
+		env = parser.Env{}
+	}
+	return p.run(env, steps)
+}
+
+// operate computes the opApply or opPercent instr on the operands x and y
+func (p *Program) operate(instr *instruction, x, y float64) (float64, error) {
+	if instr.op == opApply {
+		return instr.oper.Eval(lexer.NewTokenFloat(x), lexer.NewTokenFloat(y))
+	}
+	v := x * y / 100
+	if instr.oper != nil {
+		return instr.oper.Eval(lexer.NewTokenFloat(x), lexer.NewTokenFloat(v))
+	}
+	return v, nil
+}
+
+// binding saves the variable of a let, held in slot, before it is bound:
+// its previous value, or the error loading it if it was undefined
+type binding struct {
+	let  parser.LetNode
+	slot int
+	old  float64
+	err  error
+}
+
+// handler of the failures of a try body: the instruction of its fallback,
+// the size of the stack and the number of lets bound when it started
+type handler struct{ catch, size, lets int }
+
+// run p with the variables of env, or of their environments if nil, for at
+// most steps instructions if not 0
+func (p *Program) run(env parser.Env, steps int) (float64, error) {
+	buf, _ := p.stacks.Get().(*[]float64)
+	if buf == nil {
+		buf = new([]float64)
+		*buf = make([]float64, len(p.vars)+p.depth)
+	}
+	defer p.stacks.Put(buf)
+	slots := (*buf)[:len(p.vars)]
+	stack := (*buf)[len(p.vars):len(p.vars)]
+	// the variables undefined when the program starts fail when loaded
+	// before being assigned
+	var undefined map[int]error
+	// functions defined by the run, with the ones of the program, and the
+	// variables they see, kept up to date by the assignments, when env is
+	// not nil
+	var defined parser.Funcs
+	var scope parser.Env
+	// lets being evaluated, innermost last, and the previous values of
+	// their variables
+	var lets []binding
+	// tries being evaluated, innermost last
+	var handlers []handler
+	// bind the variable of b to v, or undefine it if err is not nil, in the
+	// environment the assignments are written to as well
+	bind := func(b binding, v float64, err error) {
+		slots[b.slot] = v
+		if err == nil {
+			delete(undefined, b.slot)
+		} else if undefined == nil {
+			undefined = map[int]error{b.slot: err}
+		} else {
+			undefined[b.slot] = err
+		}
+		vars := scope
+		if env == nil {
+			vars = b.let.Env
+		}
+		if vars != nil && err == nil {
+			vars[b.let.Name] = v
+		} else if vars != nil {
+			delete(vars, b.let.Name)
+		}
+	}
+	// unbind the lets bound after the first n ones
+	unbind := func(n int) {
+		for i := len(lets) - 1; i >= n; i-- {
+			bind(lets[i], lets[i].old, lets[i].err)
+		}
+		lets = lets[:n]
+	}
+	// the environments are restored if the run fails within lets
+	defer unbind(0)
+	for i := range p.vars {
+		v := &p.vars[i]
+		vars := env
+		if vars == nil {
+			vars = v.Env
+		}
+		if x, ok := vars[p.names[i]]; ok {
+			slots[i] = x
+			continue
+		}
+		// the error of the undefined variable or of its value
+		node := *v
+		node.Env = vars
+		if _, err := node.Eval(); err != nil {
+			if undefined == nil {
+				undefined = map[int]error{}
+			}
+			undefined[i] = err
+		}
+	}
+	if p.straight && undefined == nil && (steps == 0 || len(p.code) <= steps) {
+		return p.runStraight(slots, stack)
+	}
+	for pc, step := 0, 1; pc < len(p.code); pc, step = pc+1, step+1 {
+		if steps > 0 && step > steps {
+			return 0, fmt.Errorf("%w, at most %v", ErrTooManySteps, steps)
+		}
+		instr := &p.code[pc]
+		top := len(stack) - 1
+		var err error
+		switch instr.op {
+		case opConst:
+			stack = append(stack, instr.value)
+		case opLoad:
+			if undefined != nil && undefined[instr.arg] != nil {
+				err = undefined[instr.arg]
+				break
+			}
+			stack = append(stack, slots[instr.arg])
+		case opStore:
+			if assign := instr.node.(parser.AssignNode); env == nil {
+				assign.Env[assign.Name] = stack[top]
+			} else if scope != nil {
+				scope[assign.Name] = stack[top]
+			}
+			slots[instr.arg] = stack[top]
+			delete(undefined, instr.arg)
+		case opPop:
+			stack = stack[:top]
+		case opAdd:
+			stack[top-1] += stack[top]
+			stack = stack[:top]
+		case opSub:
+			stack[top-1] -= stack[top]
+			stack = stack[:top]
+		case opMul:
+			stack[top-1] *= stack[top]
+			stack = stack[:top]
+		case opPow:
+			stack[top-1] = math.Pow(stack[top-1], stack[top])
+			stack = stack[:top]
+		case opAddConst:
+			stack[top] += instr.value
+		case opSubConst:
+			stack[top] -= instr.value
+		case opMulConst:
+			stack[top] *= instr.value
+		case opDivConst:
+			stack[top] /= instr.value
+		case opDiv:
+			if stack[top] == 0 {
+				_, err = instr.oper.(lexer.TokenDiv).Divide(stack[top-1], 0)
+				break
+			}
+			stack[top-1] /= stack[top]
+			stack = stack[:top]
+		case opCompare:
+			stack[top-1] = lexer.Bool(instr.holds[lexer.Order(stack[top-1], stack[top])+1])
+			stack = stack[:top]
+		case opApply, opPercent:
+			var v float64
+			if v, err = p.operate(instr, stack[top-1], stack[top]); err != nil {
+				break
+			}
+			stack[top-1], stack = v, stack[:top]
+		case opNot:
+			stack[top] = lexer.Bool(stack[top] == 0)
+		case opDecide:
+			decides := instr.holds[0]
+			if stack[top] != 0 {
+				decides = instr.holds[1]
+			}
+			if decides {
+				stack[top] = lexer.Bool(stack[top] != 0)
+				pc = instr.arg - 1
+			} else {
+				stack = stack[:top]
+			}
+		case opBool:
+			stack[top] = lexer.Bool(stack[top] != 0)
+		case opCall:
+			base := len(stack) - instr.arg
+			fn := instr.fn
+			if fn == nil {
+				call := instr.node.(parser.CallNode)
+				if defined != nil {
+					call.Funcs = defined
+				}
+				var f parser.Func
+				if f, err = call.Resolve(instr.arg); err != nil {
+					break
+				}
+				fn = f.Call
+			}
+			var v float64
+			if v, err = fn(stack[base:]...); err != nil {
+				break
+			}
+			stack = append(stack[:base], v)
+		case opEval:
+			var v float64
+			if v, err = evalTree(instr.node, env, steps); err != nil {
+				break
+			}
+			stack = append(stack, v)
+		case opDefine:
+			def := instr.node.(parser.DefNode)
+			if env == nil {
+				def.Eval()
+			} else {
+				if defined == nil {
+					defined, scope = maps.Clone(def.Funcs), maps.Clone(env)
+					for i, v := range p.vars {
+						if undefined[i] == nil {
+							scope[v.Name()] = slots[i]
+						}
+					}
+				}
+				defined[def.Name] = (&parser.Closure{Lambda: def.Lambda, Env: scope, Funcs: defined}).Func()
+			}
+			stack = append(stack, 0)
+		case opLet:
+			b := binding{instr.node.(parser.LetNode), instr.arg, slots[instr.arg], undefined[instr.arg]}
+			lets = append(lets, b)
+			bind(b, stack[top], nil)
+			stack = stack[:top]
+		case opUnlet:
+			unbind(len(lets) - 1)
+		case opTry:
+			handlers = append(handlers, handler{instr.arg, len(stack), len(lets)})
+		case opEndTry:
+			handlers = handlers[:len(handlers)-1]
+			pc = instr.arg - 1
+		case opJump:
+			pc = instr.arg - 1
+		case opJumpFalse:
+			if stack[top] == 0 {
+				pc = instr.arg - 1
+			}
+			stack = stack[:top]
+		case opForNext:
+			i, end, step := stack[top-2], stack[top-1], stack[top]
+			if err = instr.node.(parser.ForNode).CheckStep(step); err != nil {
+				break
+			}
+			if parser.Within(i, end, step) {
+				stack = append(stack, i)
+			} else {
+				pc = instr.arg - 1
+			}
+		case opForStep:
+			stack[top-4], stack[top-3] = stack[top], stack[top-3]+stack[top-1]
+			stack = stack[:top]
+			pc = instr.arg - 1
+		}
+		if err != nil {
+			if len(handlers) == 0 {
+				return 0, err
+			}
+			// continue with the fallback of the innermost try
+			h := handlers[len(handlers)-1]
+			handlers = handlers[:len(handlers)-1]
+			unbind(h.lets)
+			stack, pc = stack[:h.size], h.catch-1
+		}
+	}
+	return stack[0], nil
+}
+
+// runStraight runs the straight instructions of p, without the bookkeeping
+// of the jumps, lets and tries, the variables being loaded in slots
+func (p *Program) runStraight(slots, stack []float64) (float64, error) {
+	for i := range p.code {
+		instr := &p.code[i]
+		top := len(stack) - 1
+		switch instr.op {
+		case opConst:
+			stack = append(stack, instr.value)
+		case opLoad:
+			stack = append(stack, slots[instr.arg])
+		case opAdd:
+			stack[top-1] += stack[top]
+			stack = stack[:top]
+		case opSub:
+			stack[top-1] -= stack[top]
+			stack = stack[:top]
+		case opMul:
+			stack[top-1] *= stack[top]
+			stack = stack[:top]
+		case opPow:
+			stack[top-1] = math.Pow(stack[top-1], stack[top])
+			stack = stack[:top]
+		case opDiv:
+			if stack[top] == 0 {
+				_, err := instr.oper.(lexer.TokenDiv).Divide(stack[top-1], 0)
+				return 0, err
+			}
+			stack[top-1] /= stack[top]
+			stack = stack[:top]
+		case opAddConst:
+			stack[top] += instr.value
+		case opSubConst:
+			stack[top] -= instr.value
+		case opMulConst:
+			stack[top] *= instr.value
+		case opDivConst:
+			stack[top] /= instr.value
+		case opCompare:
+			stack[top-1] = lexer.Bool(instr.holds[lexer.Order(stack[top-1], stack[top])+1])
+			stack = stack[:top]
+		case opNot:
+			stack[top] = lexer.Bool(stack[top] == 0)
+		case opCall:
+			base := len(stack) - instr.arg
+			v, err := instr.fn(stack[base:]...)
+			if err != nil {
+				return 0, err
+			}
+			stack = append(stack[:base], v)
+		default:
+			// opApply and opPercent
+			v, err := p.operate(instr, stack[top-1], stack[top])
+			if err != nil {
+				return 0, err
+			}
+			stack[top-1], stack = v, stack[:top]
+		}
+	}
+	return stack[0], nil
+}
+
+// evalTree evaluates node, which is not compiled, like EvalNumber, for at
+// most steps nodes if not 0. With env, node is evaluated in a copy of it, its
+// values and functions definitions lasting for the evaluation, rather than
+// in the environments it is bound to.
+func evalTree(node lexer.IExpression, env parser.Env, steps int) (float64, error) {
+	if env != nil {
+		scope, values, funcs := maps.Clone(env), parser.Values{}, map[uintptr]parser.Funcs{}
+		defines := defines(node)
+		node = parser.RebindValues(node, func(parser.Env) parser.Env { return scope }, func(parser.Values) parser.Values { return values }, func(f parser.Funcs) parser.Funcs {
+			if !defines {
+				return f
+			}
+			key := reflect.ValueOf(f).Pointer()
+			if _, ok := funcs[key]; !ok {
+				funcs[key] = maps.Clone(f)
+			}
+			return funcs[key]
+		})
+	}
+	e, nodes := &numberEval{}, 0
+	if steps > 0 {
+		e.visit = func(lexer.IExpression) error {
+			if nodes++; nodes > steps {
+				return fmt.Errorf("%w, at most %v", ErrTooManySteps, steps)
+			}
+			return nil
+		}
+	}
+	v, err := e.eval(node)
+	switch {
+	case err != nil:
+		return 0, err
+	case v.Kind == KindList:
+		return 0, parser.ErrListValue
+	case v.Kind == KindMap:
+		return 0, parser.ErrMapValue
+	case v.Kind == KindNil:
+		return 0, lexer.ErrNil
+	case !v.Scalar():
+		return 0, fmt.Errorf("a %v is %w", v.Kind, lexer.ErrNotANumber)
+	}
+	return v.Float64(), nil
+}
@@ -0,0 +1,81 @@
+package eval
+
+import (
+	"testing"
+
+	"github.com/fmarmol/lexp/lexer"
+	"github.com/fmarmol/lexp/parser"
+)
+
+const benchmarkExpr = "x*(y+2) - x/3 + sqrt(y)*2"
+
+// parse text bound to env with the builtins and constants
+func parse(tb testing.TB, text string, env parser.Env) lexer.IExpression {
+	tokens, err := lexer.New("bench", text).MakeTokens()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	p := parser.New(tokens)
+	p.Env, p.Funcs, p.Consts = env, Builtins, Constants
+	node, err := p.Parse()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return node
+}
+
+func BenchmarkTree(b *testing.B) {
+	env := parser.Env{"x": 3, "y": 4}
+	node := parse(b, benchmarkExpr, env)
+	for i := range b.N {
+		env["x"] = float64(i)
+		if _, err := node.Eval(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEvalNumber(b *testing.B) {
+	env := parser.Env{"x": 3, "y": 4}
+	node := parse(b, benchmarkExpr, env)
+	for i := range b.N {
+		env["x"] = float64(i)
+		if _, err := EvalNumber(node); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkProgram(b *testing.B) {
+	env := parser.Env{"x": 3, "y": 4}
+	program := Compile(parse(b, benchmarkExpr, env))
+	for i := range b.N {
+		env["x"] = float64(i)
+		if _, err := program.Eval(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkProgramEvalIn(b *testing.B) {
+	program := Compile(parse(b, benchmarkExpr, parser.Env{}))
+	env := parser.Env{"x": 3, "y": 4}
+	for i := range b.N {
+		env["x"] = float64(i)
+		if _, err := program.EvalIn(env); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestCompile(t *testing.T) {
+	texts := []string{benchmarkExpr, "x - 2 - 3", "x / 4 / 0.5", "x / 0", "x * 2 + y * 0.5", "2 ^ x / y", "x < 2 || y > 3", "50% * y + 1"}
+	for _, text := range texts {
+		node := parse(t, text, parser.Env{"x": 3, "y": 4})
+		want, wantErr := node.Eval()
+		got, err := Compile(node).Eval()
+		if got != want || (err == nil) != (wantErr == nil) {
+			t.Errorf("Compile(%q).Eval() = %v, %v, want %v, %v", text, got, err, want, wantErr)
+		}
+	}
+}
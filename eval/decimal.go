@@ -0,0 +1,96 @@
+package eval
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/fmarmol/lexp/lexer"
+)
+
+// Rounding mode of fixed point decimal results
+type Rounding string
+
+const (
+	RoundHalfEven Rounding = "half-even" // to the nearest, ties to even digit
+	RoundHalfUp   Rounding = "half-up"   // to the nearest, ties away from zero
+	RoundDown     Rounding = "down"      // toward zero
+	RoundUp       Rounding = "up"        // away from zero
+	RoundFloor    Rounding = "floor"     // toward -Inf
+	RoundCeiling  Rounding = "ceiling"   // toward +Inf
+)
+
+// ParseRounding parses half-even, half-up, down, up, floor or ceiling
+func ParseRounding(spec string) (Rounding, error) {
+	switch r := Rounding(spec); r {
+	case RoundHalfEven, RoundHalfUp, RoundDown, RoundUp, RoundFloor, RoundCeiling:
+		return r, nil
+	}
+	return "", fmt.Errorf("unsupported rounding %q, expected half-even, half-up, down, up, floor or ceiling", spec)
+}
+
+// Decimal is a fixed point arithmetic: the result of every operation is
+// rounded to Places decimal places, literals being kept as written
+type Decimal struct {
+	Places   int
+	Rounding Rounding
+}
+
+// DefaultDecimal suits money calculations, rounding to cents like banks do
+var DefaultDecimal = Decimal{2, RoundHalfEven}
+
+// DecimalNumber is the result of a fixed point decimal evaluation
+type DecimalNumber struct {
+	Rat    *big.Rat
+	Places int
+}
+
+// Float64 value of n, rounded to the nearest float64
+func (n DecimalNumber) Float64() float64 {
+	f, _ := n.Rat.Float64()
+	return f
+}
+
+// String of n with at least Places decimal places, 0.1 + 0.2 being 0.30
+func (n DecimalNumber) String() string {
+	places, _ := decimalPlaces(n.Rat.Denom())
+	return n.Rat.FloatString(max(places, n.Places))
+}
+
+// EvalDecimal evaluates node like EvalRat, rounding the results of the
+// operations according to d
+func EvalDecimal(node lexer.IExpression, d Decimal) (DecimalNumber, error) {
+	r, err := (&ratEval{map[string]RatNumber{}, d.Round, nil, nil}).eval(node)
+	return DecimalNumber{r, d.Places}, err
+}
+
+// Round r to d.Places decimal places according to d.Rounding
+func (d Decimal) Round(r *big.Rat) *big.Rat {
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(d.Places)), nil)
+	x := new(big.Rat).Mul(r, new(big.Rat).SetInt(scale))
+	q, rem := new(big.Int).QuoRem(x.Num(), x.Denom(), new(big.Int))
+	if rem.Sign() != 0 && d.away(q, rem, x.Denom(), x.Sign()) {
+		q.Add(q, big.NewInt(int64(x.Sign())))
+	}
+	return new(big.Rat).SetFrac(q, scale)
+}
+
+// away tells if the truncated quotient q, of non zero remainder rem over
+// denom, must be rounded away from zero
+func (d Decimal) away(q, rem, denom *big.Int, sign int) bool {
+	half := new(big.Int).Abs(rem)
+	half.Lsh(half, 1)
+	switch d.Rounding {
+	case RoundDown:
+		return false
+	case RoundUp:
+		return true
+	case RoundFloor:
+		return sign < 0
+	case RoundCeiling:
+		return sign > 0
+	case RoundHalfUp:
+		return half.Cmp(denom) >= 0
+	}
+	c := half.Cmp(denom)
+	return c > 0 || (c == 0 && q.Bit(0) == 1)
+}
@@ -0,0 +1,306 @@
+package eval
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/fmarmol/lexp/lexer"
+	"github.com/fmarmol/lexp/parser"
+)
+
+// DiffKind of a structural difference
+type DiffKind string
+
+const (
+	DiffOperator DiffKind = "OPERATOR"
+	DiffLiteral  DiffKind = "LITERAL"
+	DiffName     DiffKind = "NAME"
+	DiffAdded    DiffKind = "ADDED"
+	DiffRemoved  DiffKind = "REMOVED"
+)
+
+// Difference between two expressions at a given path.
+// Path is a list of steps from the root, "/" being the root itself: L/R for
+// operands, X for the operand of unary operators, V for assigned values, C
+// and B for the condition and body of while loops, S, E, T and B for the
+// start, end, step and body of for loops, S, E and T for the bounds of
+// ranges, B and C for the body and the fallback of try, S for the subject
+// of match, P and V prefixed with the index of an arm for its pattern and
+// value and D for the _ arm, P and B for the percentage and the base of %,
+// B for the body of lambdas, F for the lambda of
+// function definitions and indexes for sequence items, program statements,
+// call arguments and list and map items.
+type Difference struct {
+	Path     string
+	Kind     DiffKind
+	Old, New lexer.IExpression
+}
+
+func (d Difference) String() string {
+	switch d.Kind {
+	case DiffOperator:
+		return fmt.Sprintf("%v: operator %v -> %v", d.Path, operator(d.Old), operator(d.New))
+	case DiffName:
+		return fmt.Sprintf("%v: name %v -> %v", d.Path, name(d.Old), name(d.New))
+	case DiffAdded:
+		return fmt.Sprintf("%v: added %v (was %v)", d.Path, d.New, d.Old)
+	case DiffRemoved:
+		return fmt.Sprintf("%v: removed %v (now %v)", d.Path, d.Old, d.New)
+	}
+	return fmt.Sprintf("%v: literal %v -> %v", d.Path, d.Old, d.New)
+}
+
+// Diff reports the structural differences between a and b
+func Diff(a, b lexer.IExpression) []Difference {
+	return diff(nil, "", a, b)
+}
+
+func diff(ret []Difference, path string, a, b lexer.IExpression) []Difference {
+	at := path
+	if at == "" {
+		at = "/"
+	}
+	switch left := a.(type) {
+	case parser.BinOpNode:
+		if right, ok := b.(parser.BinOpNode); ok {
+			if parser.OpType(left.Op) != parser.OpType(right.Op) {
+				ret = append(ret, Difference{at, DiffOperator, a, b})
+			}
+			ret = diff(ret, path+"/L", left.Left, right.Left)
+			return diff(ret, path+"/R", left.Right, right.Right)
+		}
+	case parser.UnaryOpNode:
+		if right, ok := b.(parser.UnaryOpNode); ok {
+			if parser.OpType(left.Op) != parser.OpType(right.Op) {
+				ret = append(ret, Difference{at, DiffOperator, a, b})
+			}
+			return diff(ret, path+"/X", left.Operand, right.Operand)
+		}
+	case parser.CallNode:
+		if right, ok := b.(parser.CallNode); ok {
+			if left.Name() != right.Name() {
+				ret = append(ret, Difference{at, DiffName, a, b})
+			}
+			for i := 0; i < max(len(left.Args), len(right.Args)); i++ {
+				var x, y lexer.IExpression
+				if i < len(left.Args) {
+					x = left.Args[i]
+				}
+				if i < len(right.Args) {
+					y = right.Args[i]
+				}
+				ret = diff(ret, path+"/"+strconv.Itoa(i), x, y)
+			}
+			return ret
+		}
+	case parser.AssignNode:
+		if right, ok := b.(parser.AssignNode); ok {
+			if left.Name != right.Name {
+				ret = append(ret, Difference{at, DiffName, a, b})
+			}
+			return diff(ret, path+"/V", left.Value, right.Value)
+		}
+	case parser.SeqNode:
+		if right, ok := b.(parser.SeqNode); ok {
+			for i := 0; i < max(len(left.Items), len(right.Items)); i++ {
+				var x, y lexer.IExpression
+				if i < len(left.Items) {
+					x = left.Items[i]
+				}
+				if i < len(right.Items) {
+					y = right.Items[i]
+				}
+				ret = diff(ret, path+"/"+strconv.Itoa(i), x, y)
+			}
+			return ret
+		}
+	case parser.ProgramNode:
+		if right, ok := b.(parser.ProgramNode); ok {
+			return diff(ret, path, parser.SeqNode{Items: left.Statements}, parser.SeqNode{Items: right.Statements})
+		}
+	case parser.WhileNode:
+		if right, ok := b.(parser.WhileNode); ok {
+			ret = diff(ret, path+"/C", left.Cond, right.Cond)
+			return diff(ret, path+"/B", left.Body, right.Body)
+		}
+	case parser.ForNode:
+		if right, ok := b.(parser.ForNode); ok {
+			if left.Var != right.Var {
+				ret = append(ret, Difference{at, DiffName, a, b})
+			}
+			ret = diff(ret, path+"/S", left.Start, right.Start)
+			ret = diff(ret, path+"/E", left.End, right.End)
+			ret = diff(ret, path+"/T", left.By(), right.By())
+			return diff(ret, path+"/B", left.Body, right.Body)
+		}
+	case parser.LambdaNode:
+		if right, ok := b.(parser.LambdaNode); ok {
+			if name(left) != name(right) {
+				ret = append(ret, Difference{at, DiffName, a, b})
+			}
+			return diff(ret, path+"/B", left.Body, right.Body)
+		}
+	case parser.DefNode:
+		if right, ok := b.(parser.DefNode); ok {
+			if left.Name != right.Name {
+				ret = append(ret, Difference{at, DiffName, a, b})
+			}
+			return diff(ret, path+"/F", left.Lambda, right.Lambda)
+		}
+	case parser.ListNode:
+		if right, ok := b.(parser.ListNode); ok {
+			return diff(ret, path, parser.SeqNode{Items: left.Items}, parser.SeqNode{Items: right.Items})
+		}
+	case parser.TryNode:
+		if right, ok := b.(parser.TryNode); ok {
+			ret = diff(ret, path+"/B", left.Body, right.Body)
+			return diff(ret, path+"/C", left.Catch, right.Catch)
+		}
+	case parser.ImportNode:
+		if right, ok := b.(parser.ImportNode); ok {
+			if left.Path != right.Path {
+				ret = append(ret, Difference{at, DiffName, a, b})
+			}
+			return ret
+		}
+	case parser.MatchNode:
+		if right, ok := b.(parser.MatchNode); ok {
+			ret = diff(ret, path+"/S", left.Subject, right.Subject)
+			for i := 0; i < max(len(left.Arms), len(right.Arms)); i++ {
+				var x, y parser.MatchArm
+				if i < len(left.Arms) {
+					x = left.Arms[i]
+				}
+				if i < len(right.Arms) {
+					y = right.Arms[i]
+				}
+				arm := path + "/" + strconv.Itoa(i)
+				ret = diff(ret, arm+"P", x.Pattern, y.Pattern)
+				ret = diff(ret, arm+"V", x.Value, y.Value)
+			}
+			return diff(ret, path+"/D", left.Default, right.Default)
+		}
+	case parser.PercentNode:
+		if right, ok := b.(parser.PercentNode); ok {
+			if left.Relative() != right.Relative() || left.Relative() && parser.OpType(left.Op) != parser.OpType(right.Op) {
+				ret = append(ret, Difference{at, DiffOperator, a, b})
+			}
+			ret = diff(ret, path+"/P", left.Percent, right.Percent)
+			return diff(ret, path+"/B", left.Base, right.Base)
+		}
+	case parser.CoalesceNode:
+		if right, ok := b.(parser.CoalesceNode); ok {
+			ret = diff(ret, path+"/L", left.Left, right.Left)
+			return diff(ret, path+"/R", left.Right, right.Right)
+		}
+	case parser.RangeNode:
+		if right, ok := b.(parser.RangeNode); ok {
+			ret = diff(ret, path+"/S", left.Start, right.Start)
+			ret = diff(ret, path+"/E", left.End, right.End)
+			return diff(ret, path+"/T", left.By(), right.By())
+		}
+	case parser.MapNode:
+		if right, ok := b.(parser.MapNode); ok {
+			if !slices.Equal(left.Keys, right.Keys) {
+				return append(ret, Difference{at, DiffName, a, b})
+			}
+			return diff(ret, path, parser.SeqNode{Items: left.Items}, parser.SeqNode{Items: right.Items})
+		}
+	case parser.IndexNode:
+		if right, ok := b.(parser.IndexNode); ok {
+			ret = diff(ret, path+"/L", left.Target, right.Target)
+			return diff(ret, path+"/I", left.Index, right.Index)
+		}
+	case parser.LetNode:
+		if right, ok := b.(parser.LetNode); ok {
+			if left.Name != right.Name {
+				ret = append(ret, Difference{at, DiffName, a, b})
+			}
+			ret = diff(ret, path+"/V", left.Value, right.Value)
+			return diff(ret, path+"/B", left.Body, right.Body)
+		}
+	}
+	switch {
+	case isComposite(b) || (a == nil && b != nil):
+		return append(ret, Difference{at, DiffAdded, a, b})
+	case isComposite(a) || (a != nil && b == nil):
+		return append(ret, Difference{at, DiffRemoved, a, b})
+	}
+	if !sameLiteral(a, b) {
+		ret = append(ret, Difference{at, DiffLiteral, a, b})
+	}
+	return ret
+}
+
+func isComposite(node lexer.IExpression) bool {
+	switch node.(type) {
+	case parser.BinOpNode, parser.UnaryOpNode, parser.CallNode, parser.AssignNode, parser.SeqNode, parser.ProgramNode,
+		parser.WhileNode, parser.ForNode, parser.LambdaNode, parser.DefNode, parser.LetNode,
+		parser.ListNode, parser.IndexNode, parser.MapNode, parser.RangeNode, parser.CoalesceNode, parser.TryNode,
+		parser.MatchNode, parser.ImportNode, parser.PercentNode:
+		return true
+	}
+	return false
+}
+
+func operator(node lexer.IExpression) lexer.Operation {
+	switch n := node.(type) {
+	case parser.BinOpNode:
+		return n.Op
+	case parser.UnaryOpNode:
+		return n.Op
+	case parser.PercentNode:
+		return n.Op
+	}
+	return nil
+}
+
+// name of an assignment, a call, a function definition, the variable of a
+// for loop or a let, the parameters of a lambda, the keys of a map or the
+// path of an import
+func name(node lexer.IExpression) string {
+	switch n := node.(type) {
+	case parser.CallNode:
+		return n.Name()
+	case parser.ForNode:
+		return n.Var
+	case parser.DefNode:
+		return n.Name
+	case parser.LetNode:
+		return n.Name
+	case parser.LambdaNode:
+		return "(" + strings.Join(n.Params, ", ") + ")"
+	case parser.ImportNode:
+		return strconv.Quote(n.Path)
+	case parser.MapNode:
+		keys := make([]string, len(n.Keys))
+		for i, key := range n.Keys {
+			keys[i] = strconv.Quote(key)
+		}
+		return "{" + strings.Join(keys, ", ") + "}"
+	}
+	return node.(parser.AssignNode).Name
+}
+
+func sameLiteral(a, b lexer.IExpression) bool {
+	ta, ok := a.(interface{ TokenType() lexer.Type })
+	if !ok {
+		return canonicalString(a) == canonicalString(b)
+	}
+	tb, ok := b.(interface{ TokenType() lexer.Type })
+	if !ok || ta.TokenType() != tb.TokenType() {
+		return false
+	}
+	return canonicalString(a) == canonicalString(b)
+}
+
+// FormatDiff renders differences one per line
+func FormatDiff(diffs []Difference) string {
+	lines := make([]string, len(diffs))
+	for i, d := range diffs {
+		lines[i] = d.String()
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,73 @@
+package eval
+
+import (
+	"fmt"
+	"math"
+)
+
+// NormPDF is the density at x of the normal distribution N(mu, sigma²)
+func NormPDF(x, mu, sigma float64) (float64, error) {
+	if sigma <= 0 {
+		return 0, fmt.Errorf("normpdf: sigma must be positive, got %v", sigma)
+	}
+	z := (x - mu) / sigma
+	return math.Exp(-z*z/2) / (sigma * math.Sqrt(2*math.Pi)), nil
+}
+
+// NormCDF is the probability P(X <= x) for X following N(mu, sigma²)
+func NormCDF(x, mu, sigma float64) (float64, error) {
+	if sigma <= 0 {
+		return 0, fmt.Errorf("normcdf: sigma must be positive, got %v", sigma)
+	}
+	return math.Erfc(-(x-mu)/(sigma*math.Sqrt2)) / 2, nil
+}
+
+// Binom is the probability of k successes out of n trials of probability p
+func Binom(k, n, p float64) (float64, error) {
+	if n < 0 || n != math.Trunc(n) {
+		return 0, fmt.Errorf("binom: n must be a non negative integer, got %v", n)
+	}
+	if p < 0 || p > 1 {
+		return 0, fmt.Errorf("binom: p must be in [0, 1], got %v", p)
+	}
+	if k < 0 || k > n || k != math.Trunc(k) {
+		return 0, nil
+	}
+	switch p {
+	case 0:
+		return boolToFloat(k == 0), nil
+	case 1:
+		return boolToFloat(k == n), nil
+	}
+	// computed in log space so large n don't overflow
+	return math.Exp(logChoose(n, k) + k*math.Log(p) + (n-k)*math.Log1p(-p)), nil
+}
+
+// Poisson is the probability of k events for a mean rate lambda
+func Poisson(k, lambda float64) (float64, error) {
+	if lambda < 0 {
+		return 0, fmt.Errorf("poisson: lambda must be non negative, got %v", lambda)
+	}
+	if k < 0 || k != math.Trunc(k) {
+		return 0, nil
+	}
+	if lambda == 0 {
+		return boolToFloat(k == 0), nil
+	}
+	lk, _ := math.Lgamma(k + 1)
+	return math.Exp(k*math.Log(lambda) - lambda - lk), nil
+}
+
+func logChoose(n, k float64) float64 {
+	ln, _ := math.Lgamma(n + 1)
+	lk, _ := math.Lgamma(k + 1)
+	lnk, _ := math.Lgamma(n - k + 1)
+	return ln - lk - lnk
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,17 @@
+package eval
+
+import (
+	"github.com/fmarmol/lexp/lexer"
+	"github.com/fmarmol/lexp/parser"
+)
+
+// Eval evaluates node
+func Eval(node lexer.IExpression) (float64, error) {
+	return node.Eval()
+}
+
+// EvalLine evaluates a line in env with the builtins and constants, see
+// Evaluator.EvalLine
+func EvalLine(env parser.Env, fileName, line string) (string, float64, error) {
+	return (&Evaluator{env, Builtins, Constants, 0, false, nil}).EvalLine(fileName, line)
+}
@@ -0,0 +1,204 @@
+package eval
+
+import (
+	"github.com/fmarmol/lexp/lexer"
+	"github.com/fmarmol/lexp/parser"
+)
+
+// Evaluator evaluates lines against its own environment, functions and
+// constants, so that evaluators used by different goroutines do not share
+// any state. An Evaluator itself must not be used by several goroutines at
+// once.
+type Evaluator struct {
+	Env    parser.Env
+	Funcs  parser.Funcs
+	Consts parser.Env
+	// Precision of the floats in bits, 0 to compute with float64 instead of
+	// arbitrary precision
+	Precision uint
+	// Rational computes with exact rationals, taking precedence over
+	// Decimal and Precision
+	Rational bool
+	// Decimal computes in fixed point, taking precedence over Precision,
+	// nil to disable
+	Decimal *Decimal
+}
+
+// Option configures an Evaluator
+type Option func(*Evaluator)
+
+// WithPrecision computes with integers of unlimited size and floats of prec
+// bits, see EvalBig
+func WithPrecision(prec uint) Option {
+	return func(e *Evaluator) { e.Precision = prec }
+}
+
+// WithRationals computes with exact rationals, see EvalRat
+func WithRationals() Option {
+	return func(e *Evaluator) { e.Rational = true }
+}
+
+// WithDecimal computes in the fixed point arithmetic d, see EvalDecimal
+func WithDecimal(d Decimal) Option {
+	return func(e *Evaluator) { e.Decimal = &d }
+}
+
+// NewEvaluator with an empty environment, the builtins and the constants
+func NewEvaluator(options ...Option) *Evaluator {
+	funcs := parser.Funcs{}
+	for name, f := range Builtins {
+		funcs[name] = f
+	}
+	e := &Evaluator{parser.Env{}, funcs, copyEnv(Constants), 0, false, nil}
+	for _, option := range options {
+		option(e)
+	}
+	return e
+}
+
+// RegisterFunc makes f callable as name, with any number of arguments, from
+// the expressions of e. It replaces any function of the same name, its calls
+// being assumed impure so that they are never cached.
+func (e *Evaluator) RegisterFunc(name string, f func(args ...float64) (float64, error)) {
+	e.Funcs[name] = parser.Func{MinArgs: 0, MaxArgs: -1, Call: f, Impure: true}
+}
+
+// RegisterValueFunc makes f callable as name, with from min to max
+// arguments, max being -1 for no limit, of any kind: strings, lists, maps or
+// functions. Like with RegisterFunc, its calls are never cached.
+func (e *Evaluator) RegisterValueFunc(name string, min, max int, f ValueFunc) {
+	fn := NewValueFunc(min, max, f)
+	fn.Impure = true
+	e.Funcs[name] = fn
+}
+
+// RegisterConst makes name a constant of value v in the expressions of e
+func (e *Evaluator) RegisterConst(name string, v float64) {
+	e.Consts[name] = v
+}
+
+// Eval evaluates text, see EvalLine
+func (e *Evaluator) Eval(text string) (float64, error) {
+	_, v, err := e.EvalLine("expr", text)
+	return v, err
+}
+
+// EvalLine evaluates a line, returning the assigned name if the line is an
+// assignment. The result is bound to ans and _ in the environment of e.
+// With rationals, a decimal arithmetic or a precision, the result is the
+// exact, fixed point or arbitrary precision one rounded to a float64.
+func (e *Evaluator) EvalLine(fileName, line string) (string, float64, error) {
+	expr, err := e.parse(fileName, line)
+	if err != nil {
+		return "", 0, err
+	}
+	result, err := e.eval(expr)
+	if err != nil {
+		return "", 0, err
+	}
+	return e.bind(expr, result), result, nil
+}
+
+// eval expr according to the mode of e
+func (e *Evaluator) eval(expr lexer.IExpression) (float64, error) {
+	var n interface{ Float64() float64 }
+	var err error
+	switch {
+	case e.Rational:
+		n, err = EvalRat(expr)
+	case e.Decimal != nil:
+		n, err = EvalDecimal(expr, *e.Decimal)
+	case e.Precision > 0:
+		n, err = EvalBig(expr, e.Precision)
+	default:
+		return Eval(expr)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return n.Float64(), nil
+}
+
+// EvalBig evaluates text with arbitrary precision, DefaultPrecision if e has
+// none, binding the result to ans and _ like EvalLine
+func (e *Evaluator) EvalBig(text string) (BigNumber, error) {
+	expr, err := e.parse("expr", text)
+	if err != nil {
+		return BigNumber{}, err
+	}
+	prec := e.Precision
+	if prec == 0 {
+		prec = DefaultPrecision
+	}
+	n, err := EvalBig(expr, prec)
+	if err != nil {
+		return BigNumber{}, err
+	}
+	e.bind(expr, n.Float64())
+	return n, nil
+}
+
+// EvalRat evaluates text with exact rationals, binding the result to ans and
+// _ like EvalLine
+func (e *Evaluator) EvalRat(text string) (RatNumber, error) {
+	expr, err := e.parse("expr", text)
+	if err != nil {
+		return RatNumber{}, err
+	}
+	n, err := EvalRat(expr)
+	if err != nil {
+		return RatNumber{}, err
+	}
+	e.bind(expr, n.Float64())
+	return n, nil
+}
+
+// EvalDecimal evaluates text in the fixed point arithmetic of e,
+// DefaultDecimal if it has none, binding the result to ans and _ like
+// EvalLine
+func (e *Evaluator) EvalDecimal(text string) (DecimalNumber, error) {
+	expr, err := e.parse("expr", text)
+	if err != nil {
+		return DecimalNumber{}, err
+	}
+	d := DefaultDecimal
+	if e.Decimal != nil {
+		d = *e.Decimal
+	}
+	n, err := EvalDecimal(expr, d)
+	if err != nil {
+		return DecimalNumber{}, err
+	}
+	e.bind(expr, n.Float64())
+	return n, nil
+}
+
+// parse line, checking its variables are defined
+func (e *Evaluator) parse(fileName, line string) (lexer.IExpression, error) {
+	tokens, err := lexer.New(fileName, line).MakeTokens()
+	if err != nil {
+		return nil, err
+	}
+	p := parser.New(tokens)
+	p.Env = e.Env
+	p.Funcs = e.Funcs
+	p.Consts = e.Consts
+	expr, err := p.Parse()
+	if err != nil {
+		return nil, err
+	}
+	if undefined := e.Env.Undefined(expr); len(undefined) > 0 {
+		return nil, parser.UndefinedVariable(undefined[0], e.Env.Names(), e.Env.Assigned(expr), e.Consts.Names())
+	}
+	return expr, nil
+}
+
+// bind result to ans and _, returning the assigned name if expr is an
+// assignment
+func (e *Evaluator) bind(expr lexer.IExpression, result float64) string {
+	e.Env["ans"], e.Env["_"] = result, result
+	if assign, ok := expr.(parser.AssignNode); ok {
+		return assign.Name
+	}
+	return ""
+}
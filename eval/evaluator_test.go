@@ -0,0 +1,28 @@
+package eval
+
+import "testing"
+
+func TestRegisterValueFunc(t *testing.T) {
+	e := NewEvaluator()
+	e.RegisterValueFunc("count", 1, 1, func(args ...Number) (Number, error) {
+		return IntNumber(int64(len(args[0].List))), nil
+	})
+	node, err := e.parse("test", "count([1, 2, 3]) + count([])")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := EvalNumber(node); err != nil || got.String() != "3" {
+		t.Errorf("EvalNumber(count([1, 2, 3]) + count([])) = %v, %v, want 3", got, err)
+	}
+	if got, err := e.Eval("count(7)"); err != nil || got != 0 {
+		t.Errorf("Eval(count(7)) = %v, %v, want 0", got, err)
+	}
+}
+
+func TestRegisterFuncReplacesValueFunc(t *testing.T) {
+	e := NewEvaluator()
+	e.RegisterFunc("table", func(args ...float64) (float64, error) { return args[0] * 2, nil })
+	if got, err := e.Eval("table(21)"); err != nil || got != 42 {
+		t.Errorf("Eval(table(21)) = %v, %v, want 42", got, err)
+	}
+}
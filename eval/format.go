@@ -0,0 +1,340 @@
+package eval
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Notation of non integer or non decimal base results
+type Notation string
+
+const (
+	NotationPlain       Notation = "plain"
+	NotationScientific  Notation = "sci" // 1.234e+06
+	NotationEngineering Notation = "eng" // 12.345e+03, exponent multiple of 3
+)
+
+// Format of the results printed by the REPL
+type Format struct {
+	// Base 2, 8 or 16 prints integer results in every base, starting by this one
+	Base     int
+	Notation Notation
+	// SigFigs rounds results to this many significant digits, 0 to disable
+	SigFigs int
+	// Fraction prints rational results as reduced fractions, otherwise as
+	// decimals rounded to Decimals places unless they terminate sooner
+	Fraction bool
+	Decimals int
+	// Mixed prints the fractions beyond 1 as mixed numbers, like 1 1/3
+	Mixed bool
+	// Precision rounds float results to this many decimal places, 0 to
+	// disable
+	Precision int
+	// Printf formats float results with this verb, like %.4e, instead of the
+	// notation
+	Printf string
+	// TrailingZeros keeps the zeros ending rounded results, 1.50 being
+	// printed 1.5 otherwise
+	TrailingZeros bool
+}
+
+// DefaultFormat ...
+var DefaultFormat = Format{Base: 10, Notation: NotationPlain, Decimals: 20}
+
+var basePrefixes = map[int]string{2: "0b", 8: "0o", 10: "", 16: "0x"}
+
+// Result renders a float result according to the format, in base 10 as the
+// floats are not integers even when integral
+func (f Format) Result(v float64) string {
+	if f.Printf != "" {
+		return f.trim(fmt.Sprintf(f.Printf, v))
+	}
+	prec := f.SigFigs - 1 // -1 for the shortest exact representation
+	if f.SigFigs == 0 && f.Precision > 0 {
+		prec = f.Precision
+	}
+	switch f.Notation {
+	case NotationScientific:
+		return f.trim(strconv.FormatFloat(v, 'e', prec, 64))
+	case NotationEngineering:
+		return f.trim(formatEngineering(v, prec))
+	}
+	if f.SigFigs > 0 && v != 0 && !math.IsInf(v, 0) && !math.IsNaN(v) {
+		rounded, _ := strconv.ParseFloat(strconv.FormatFloat(v, 'e', prec, 64), 64)
+		decimals := prec - int(math.Floor(math.Log10(math.Abs(rounded))))
+		return f.trim(strconv.FormatFloat(rounded, 'f', max(decimals, 0), 64))
+	}
+	if f.Precision > 0 {
+		return f.trim(strconv.FormatFloat(v, 'f', f.Precision, 64))
+	}
+	return fmt.Sprint(v)
+}
+
+// rewrites tells if results are rewritten by the format rather than printed
+// exactly
+func (f Format) rewrites() bool {
+	return f.Notation != NotationPlain || f.SigFigs > 0 || f.Printf != ""
+}
+
+// trim the trailing zeros of the fractional part of s, and the decimal
+// point if nothing is left after it, unless TrailingZeros is set
+func (f Format) trim(s string) string {
+	if f.TrailingZeros {
+		return s
+	}
+	if _, err := strconv.ParseFloat(strings.TrimSpace(s), 64); err != nil || strings.ContainsAny(s, "xX") {
+		return s // not a decimal number, like a Printf with text around the verb
+	}
+	mantissa, exp, found := strings.Cut(strings.ToLower(s), "e")
+	if !strings.Contains(mantissa, ".") {
+		return s
+	}
+	mantissa = strings.TrimRight(strings.TrimRight(mantissa, "0"), ".")
+	if found {
+		return mantissa + s[len(s)-len(exp)-1:]
+	}
+	return mantissa
+}
+
+// Number renders a result according to the format, integers being printed
+// exactly in the plain notation unless rounded to significant digits
+func (f Format) Number(n Number) string {
+	switch n.Kind {
+	case KindBool:
+		return n.String()
+	case KindString:
+		return strconv.Quote(n.Str)
+	case KindList:
+		return n.listString(f.Number)
+	case KindMap:
+		return n.mapString(f.Number)
+	case KindNil, KindFunc:
+		return n.String()
+	}
+	if n.Kind != KindInt || f.rewrites() {
+		return f.Result(n.Float64())
+	}
+	if f.Base != 10 {
+		return formatBases(n.Int, f.Base)
+	}
+	return strconv.FormatInt(n.Int, 10)
+}
+
+// Big renders an arbitrary precision result according to the format, like
+// Number
+func (f Format) Big(n BigNumber) string {
+	if n.Value != nil {
+		return f.Number(*n.Value)
+	}
+	if n.Int != nil {
+		if f.rewrites() {
+			return f.bigFloat(new(big.Float).SetInt(n.Int))
+		}
+		if f.Base != 10 {
+			return formatBigBases(n.Int, f.Base)
+		}
+		return formatBigBase(n.Int, f.Base)
+	}
+	return f.bigFloat(n.Float)
+}
+
+func (f Format) bigFloat(v *big.Float) string {
+	prec := f.SigFigs - 1
+	if f.SigFigs == 0 && f.Precision > 0 {
+		prec = f.Precision
+	}
+	switch {
+	case v.IsInf():
+		return v.String()
+	case f.Printf != "":
+		return f.trim(fmt.Sprintf(f.Printf, v))
+	case f.Notation == NotationScientific:
+		return f.trim(v.Text('e', prec))
+	case f.Notation == NotationEngineering:
+		return f.trim(engineering(v.Text('e', prec)))
+	case f.SigFigs > 0:
+		return f.trim(v.Text('g', f.SigFigs))
+	case f.Precision > 0:
+		return f.trim(v.Text('f', f.Precision))
+	}
+	return v.Text('g', -1)
+}
+
+// Rat renders a rational result according to the format, rounded decimals
+// ending with ...
+func (f Format) Rat(n RatNumber) string {
+	switch {
+	case n.Value != nil:
+		return f.Number(*n.Value)
+	case n.Items != nil:
+		items := make([]string, len(n.Items))
+		for i, item := range n.Items {
+			items[i] = f.Rat(item)
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	}
+	if n.Rat.IsInt() {
+		return f.Big(BigNumber{Int: n.Rat.Num()})
+	}
+	if f.Fraction && f.Mixed {
+		return mixed(n.Rat)
+	}
+	if f.Fraction {
+		return n.Rat.RatString()
+	}
+	if f.rewrites() {
+		return f.bigFloat(new(big.Float).SetPrec(DefaultPrecision).SetRat(n.Rat))
+	}
+	if places, ok := decimalPlaces(n.Rat.Denom()); ok && places <= f.Decimals {
+		return n.Rat.FloatString(places)
+	}
+	return n.Rat.FloatString(f.Decimals) + "..."
+}
+
+// mixed writes r, which is not an integer, as a mixed number, like -1 1/3
+func mixed(r *big.Rat) string {
+	whole, rest := new(big.Int).QuoRem(r.Num(), r.Denom(), new(big.Int))
+	if whole.Sign() == 0 {
+		return r.RatString()
+	}
+	return fmt.Sprintf("%v %v/%v", whole, rest.Abs(rest), r.Denom())
+}
+
+// Decimal renders a fixed point result according to the format, with at
+// least its number of decimal places in the plain notation
+func (f Format) Decimal(n DecimalNumber) string {
+	if f.rewrites() {
+		return f.bigFloat(new(big.Float).SetPrec(DefaultPrecision).SetRat(n.Rat))
+	}
+	return n.String()
+}
+
+// decimalPlaces needed to write the fractions of denominator denom, if they
+// terminate
+func decimalPlaces(denom *big.Int) (int, bool) {
+	twos := denom.TrailingZeroBits()
+	d := new(big.Int).Rsh(denom, twos)
+	fives := 0
+	five, q, r := big.NewInt(5), new(big.Int), new(big.Int)
+	for q.QuoRem(d, five, r); r.Sign() == 0; q.QuoRem(d, five, r) {
+		d.Set(q)
+		fives++
+	}
+	return max(int(twos), fives), d.Cmp(big.NewInt(1)) == 0
+}
+
+// SetDecimals checks n is a valid number of decimal places
+func (f *Format) SetDecimals(n int) error {
+	if n < 0 {
+		return fmt.Errorf("invalid number of decimal places %v", n)
+	}
+	f.Decimals = n
+	return nil
+}
+
+// SetPrecision checks n is a valid number of decimal places
+func (f *Format) SetPrecision(n int) error {
+	if n < 0 {
+		return fmt.Errorf("invalid precision %v", n)
+	}
+	f.Precision = n
+	return nil
+}
+
+// SetPrintf checks spec formats a float with a single verb, like %.4e, the
+// empty spec disabling it
+func (f *Format) SetPrintf(spec string) error {
+	if out := fmt.Sprintf(spec, 1.0); spec != "" && (strings.Contains(out, "%!") || !strings.ContainsAny(out, "0123456789")) {
+		return fmt.Errorf("invalid format %q, expected a float verb like %%.4e", spec)
+	}
+	f.Printf = spec
+	return nil
+}
+
+// SetSigFigs checks n is a valid number of significant digits
+func (f *Format) SetSigFigs(n int) error {
+	if n < 0 {
+		return fmt.Errorf("invalid number of significant digits %v", n)
+	}
+	f.SigFigs = n
+	return nil
+}
+
+// SetNotation checks notation is supported
+func (f *Format) SetNotation(notation string) error {
+	switch n := Notation(notation); n {
+	case NotationPlain, NotationScientific, NotationEngineering:
+		f.Notation = n
+		return nil
+	}
+	return fmt.Errorf("unsupported notation %q, expected plain, sci or eng", notation)
+}
+
+func formatEngineering(v float64, prec int) string {
+	s := strconv.FormatFloat(v, 'e', prec, 64)
+	if v == 0 || math.IsInf(v, 0) || math.IsNaN(v) {
+		return s
+	}
+	return engineering(s)
+}
+
+// engineering rewrites s, a number in scientific notation, with an exponent
+// multiple of 3
+func engineering(s string) string {
+	// shift the decimal point of the scientific form, not the value, to
+	// avoid introducing rounding errors
+	mantissa, expStr, _ := strings.Cut(s, "e")
+	exp, _ := strconv.Atoi(expStr)
+	shift := ((exp % 3) + 3) % 3
+	sign := ""
+	if strings.HasPrefix(mantissa, "-") {
+		sign, mantissa = "-", mantissa[1:]
+	}
+	digits := strings.Replace(mantissa, ".", "", 1)
+	for len(digits) < shift+1 {
+		digits += "0"
+	}
+	mantissa = digits[:shift+1]
+	if rest := digits[shift+1:]; rest != "" {
+		mantissa += "." + rest
+	}
+	exp -= shift
+	expSign := "+"
+	if exp < 0 {
+		expSign, exp = "-", -exp
+	}
+	return fmt.Sprintf("%v%ve%v%02d", sign, mantissa, expSign, exp)
+}
+
+// SetBase checks base is supported
+func (f *Format) SetBase(base int) error {
+	if _, ok := basePrefixes[base]; !ok {
+		return fmt.Errorf("unsupported base %v, expected 2, 8, 10 or 16", base)
+	}
+	f.Base = base
+	return nil
+}
+
+func formatBigBase(n *big.Int, base int) string {
+	if n.Sign() < 0 {
+		return "-" + basePrefixes[base] + new(big.Int).Neg(n).Text(base)
+	}
+	return basePrefixes[base] + n.Text(base)
+}
+
+func formatBases(n int64, primary int) string {
+	return formatBigBases(big.NewInt(n), primary)
+}
+
+func formatBigBases(n *big.Int, primary int) string {
+	others := []string{}
+	for _, base := range []int{10, 16, 8, 2} {
+		if base != primary {
+			others = append(others, formatBigBase(n, base))
+		}
+	}
+	return fmt.Sprintf("%v (%v)", formatBigBase(n, primary), strings.Join(others, ", "))
+}
@@ -0,0 +1,44 @@
+package eval
+
+import (
+	"fmt"
+
+	"github.com/fmarmol/lexp/lexer"
+	"github.com/fmarmol/lexp/parser"
+)
+
+// FuncNumber is the value of the function f, passed to other functions,
+// returned by them or assigned to names to call it
+func FuncNumber(f parser.Func) Number {
+	return Number{KindFunc, 0, 0, false, "", nil, nil, &f}
+}
+
+// funcString writes a closure as its lambda
+func (n Number) funcString() string {
+	if n.Func.Closure != nil {
+		return parser.Source(n.Func.Closure.Lambda)
+	}
+	return "function"
+}
+
+// callFuncs calls the closure of n with operands, some of them functions
+// bound to its parameters for the call
+func (e *numberEval) callFuncs(n parser.CallNode, operands []Number) (Number, error) {
+	f, err := n.Resolve(len(operands))
+	if err != nil {
+		return Number{}, err
+	}
+	args := make([]float64, len(operands))
+	funcs := parser.Funcs{}
+	for i, v := range operands {
+		switch {
+		case v.Kind == KindFunc && f.Closure != nil:
+			funcs[f.Closure.Lambda.Params[i]] = *v.Func
+		case !v.Scalar():
+			return Number{}, fmt.Errorf("%v expects numbers, got a %v at %v", n.Name(), v.Kind, lexer.SpanOf(n.Args[i]).Start)
+		default:
+			args[i] = v.Float64()
+		}
+	}
+	return e.call(f.Closure, args, funcs)
+}
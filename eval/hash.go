@@ -0,0 +1,361 @@
+package eval
+
+import (
+	"hash/fnv"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/fmarmol/lexp/lexer"
+	"github.com/fmarmol/lexp/parser"
+)
+
+// Canonical returns an equivalent expression in canonical form:
+// constant subtrees are folded, the chains of + and * are flattened and the
+// operands of commutative operators are sorted. The integers are normalized
+// to floats, but for the operands of / which divides them as integers.
+func Canonical(node lexer.IExpression) lexer.IExpression {
+	switch n := node.(type) {
+	case parser.BinOpNode:
+		if isAssociative(n.Op) {
+			return chain(n)
+		}
+		left, right := Canonical(n.Left), Canonical(n.Right)
+		if !divides(n.Op) {
+			left, right = asFloat(left), asFloat(right)
+		}
+		if isConstant(left) && isConstant(right) {
+			if v, ok := fold(n.Op, left, right); ok {
+				return lexer.NewTokenFloat(v)
+			}
+		}
+		if isCommutative(n.Op) && canonicalString(left) > canonicalString(right) {
+			left, right = right, left
+		}
+		return parser.BinOpNode{Left: left, Right: right, Op: n.Op}
+	case parser.UnaryOpNode:
+		operand := asFloat(Canonical(n.Operand))
+		if isConstant(operand) {
+			if v, ok := fold(n.Op, lexer.NewTokenInt(0), operand); ok {
+				return lexer.NewTokenFloat(v)
+			}
+		}
+		return parser.UnaryOpNode{Op: n.Op, Operand: operand}
+	case parser.CallNode:
+		args := make([]lexer.IExpression, len(n.Args))
+		for i, arg := range n.Args {
+			args[i] = Canonical(arg)
+		}
+		return parser.CallNode{Token: n.Token, Args: args, Funcs: n.Funcs, Close: n.Close}
+	case parser.AssignNode:
+		return parser.AssignNode{Name: n.Name, Value: Canonical(n.Value), Env: n.Env, NamePos: n.NamePos}
+	case parser.SeqNode:
+		items := make([]lexer.IExpression, len(n.Items))
+		for i, item := range n.Items {
+			items[i] = Canonical(item)
+		}
+		return parser.SeqNode{Items: items}
+	case parser.ProgramNode:
+		statements := make([]lexer.IExpression, len(n.Statements))
+		for i, statement := range n.Statements {
+			statements[i] = Canonical(statement)
+		}
+		return parser.ProgramNode{Statements: statements}
+	case parser.WhileNode:
+		return parser.WhileNode{Token: n.Token, Cond: Canonical(n.Cond), Body: Canonical(n.Body), Close: n.Close}
+	case parser.ForNode:
+		loop := n
+		loop.Start, loop.End, loop.Step, loop.Body = Canonical(n.Start), Canonical(n.End), Canonical(n.By()), Canonical(n.Body)
+		return loop
+	case parser.LetNode:
+		let := n
+		let.Value, let.Body = Canonical(n.Value), Canonical(n.Body)
+		return let
+	case parser.ListNode:
+		items := make([]lexer.IExpression, len(n.Items))
+		for i, item := range n.Items {
+			items[i] = Canonical(item)
+		}
+		return parser.ListNode{Token: n.Token, Items: items, Close: n.Close}
+	case parser.IndexNode:
+		return parser.IndexNode{Token: n.Token, Target: Canonical(n.Target), Index: Canonical(n.Index), Close: n.Close}
+	case parser.TryNode:
+		return parser.TryNode{Token: n.Token, Body: Canonical(n.Body), Catch: Canonical(n.Catch)}
+	case parser.MatchNode:
+		// the arms stay ordered, being tried from top to bottom
+		m := parser.MatchNode{Token: n.Token, Subject: Canonical(n.Subject), Default: Canonical(n.Default), Close: n.Close}
+		for _, arm := range n.Arms {
+			m.Arms = append(m.Arms, parser.MatchArm{Pattern: Canonical(arm.Pattern), Value: Canonical(arm.Value)})
+		}
+		return m
+	case parser.CoalesceNode:
+		return parser.CoalesceNode{Token: n.Token, Left: Canonical(n.Left), Right: Canonical(n.Right)}
+	case parser.RangeNode:
+		r := n
+		r.Start, r.End, r.Step = Canonical(n.Start), Canonical(n.End), Canonical(n.By())
+		return r
+	case parser.PercentNode:
+		percent := n
+		percent.Percent = Canonical(n.Percent)
+		if n.Base != nil {
+			percent.Base = Canonical(n.Base)
+		}
+		constant := true
+		for _, child := range parser.Children(percent) {
+			constant = constant && isConstant(child)
+		}
+		if v, err := percent.Eval(); constant && err == nil && !math.IsInf(v, 0) && !math.IsNaN(v) {
+			return lexer.NewTokenFloat(v)
+		}
+		return percent
+	case parser.MapNode:
+		// the entries are unordered
+		m := parser.MapNode{Token: n.Token, Close: n.Close}
+		for _, i := range sortedKeys(n.Keys) {
+			m.Keys, m.Items = append(m.Keys, n.Keys[i]), append(m.Items, Canonical(n.Items[i]))
+		}
+		return m
+	}
+	return node
+}
+
+// chain canonicalizes the chain of the associative and commutative operation
+// of n, x + 2 + 3 becoming x + 5: its operands are gathered, the constant ones
+// folded together and the others sorted
+func chain(n parser.BinOpNode) lexer.IExpression {
+	typ := parser.OpType(n.Op)
+	var operands []lexer.IExpression
+	var gather func(node lexer.IExpression)
+	gather = func(node lexer.IExpression) {
+		if b, ok := node.(parser.BinOpNode); ok && parser.OpType(b.Op) == typ {
+			gather(b.Left)
+			gather(b.Right)
+			return
+		}
+		operands = append(operands, asFloat(Canonical(node)))
+	}
+	gather(n)
+	var constant lexer.IExpression
+	terms := []lexer.IExpression{}
+	for _, operand := range operands {
+		switch {
+		case !isConstant(operand):
+			terms = append(terms, operand)
+		case constant == nil:
+			constant = operand
+		default:
+			if v, ok := fold(n.Op, constant, operand); ok {
+				constant = lexer.NewTokenFloat(v)
+			} else {
+				terms = append(terms, operand)
+			}
+		}
+	}
+	if constant != nil {
+		terms = append(terms, constant)
+	}
+	slices.SortStableFunc(terms, func(a, b lexer.IExpression) int {
+		return strings.Compare(canonicalString(a), canonicalString(b))
+	})
+	node := terms[0]
+	for _, term := range terms[1:] {
+		node = parser.BinOpNode{Left: node, Right: term, Op: n.Op}
+	}
+	return node
+}
+
+// asFloat normalizes the integer literal node to a float
+func asFloat(node lexer.IExpression) lexer.IExpression {
+	if n, ok := node.(lexer.TokenInt); ok {
+		v, _ := n.Eval()
+		return lexer.NewTokenFloat(v)
+	}
+	return node
+}
+
+func isConstant(node lexer.IExpression) bool {
+	switch node.(type) {
+	case lexer.TokenInt, lexer.TokenFloat:
+		return true
+	}
+	return false
+}
+
+// divides tells if op is /, which divides the integer literals as integers
+func divides(op lexer.Operation) bool {
+	if w, ok := op.(parser.WrappedOp); ok {
+		op = w.Operation
+	}
+	_, ok := op.(parser.DivOp)
+	return ok
+}
+
+// Hash of the canonical form of node, equivalent expressions share the same hash
+func Hash(node lexer.IExpression) uint64 { return hashTree(node).key }
+
+// hashed is an expression annotated bottom-up with its canonical hash
+type hashed struct {
+	node        lexer.IExpression
+	key         uint64
+	constant    bool
+	value       float64
+	purity      Purity
+	left, right *hashed
+}
+
+func hashTree(node lexer.IExpression) *hashed {
+	h := &hashed{node: node}
+	switch n := node.(type) {
+	case parser.BinOpNode:
+		h.left, h.right = hashTree(n.Left), hashTree(n.Right)
+		h.purity = h.left.purity.and(h.right.purity)
+	case parser.UnaryOpNode:
+		h.left = hashTree(n.Operand)
+		h.purity = h.left.purity
+	default:
+		h.purity = AnalyzePurity(node)
+	}
+	canonical := Canonical(node)
+	if h.constant = isConstant(canonical); h.constant {
+		h.value, _ = canonical.Eval()
+	}
+	h.key = hashString(canonicalString(canonical))
+	return h
+}
+
+// fold evaluates an operation on constants, unless it fails or yields an
+// infinity or NaN which are left for the evaluation to report
+func fold(op lexer.Operation, left, right lexer.IExpression) (float64, bool) {
+	v, err := op.Eval(left, right)
+	return v, err == nil && !math.IsInf(v, 0) && !math.IsNaN(v)
+}
+
+func hashString(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	return h.Sum64()
+}
+
+func isAssociative(op lexer.Operation) bool {
+	switch parser.OpType(op) {
+	case lexer.TypePlus, lexer.TypeMul:
+		return true
+	}
+	return false
+}
+
+func isCommutative(op lexer.Operation) bool {
+	switch parser.OpType(op) {
+	case lexer.TypePlus, lexer.TypeMul, lexer.TypeEQ, lexer.TypeNE:
+		return true
+	}
+	return false
+}
+
+func canonicalString(node lexer.IExpression) string {
+	switch n := node.(type) {
+	case nil:
+		return "nil"
+	case lexer.TokenInt:
+		return strconv.FormatInt(n.Value.(int64), 10)
+	case lexer.TokenFloat:
+		s := strconv.FormatFloat(n.Value.(float64), 'g', -1, 64)
+		if !strings.ContainsAny(s, ".eIN") {
+			// distinct from the integers
+			s += ".0"
+		}
+		return s
+	case lexer.TokenBool:
+		return strconv.FormatBool(n.Value.(bool))
+	case lexer.TokenString:
+		return strconv.Quote(n.Value.(string))
+	case lexer.TokenNil:
+		return string(lexer.TypeNil)
+	case parser.VarNode:
+		return n.Name()
+	case parser.BinOpNode:
+		return "(" + string(parser.OpType(n.Op)) + " " + canonicalString(n.Left) + " " + canonicalString(n.Right) + ")"
+	case parser.UnaryOpNode:
+		return "(" + string(parser.OpType(n.Op)) + " " + canonicalString(n.Operand) + ")"
+	case parser.CallNode:
+		ret := "(" + n.Name()
+		for _, arg := range n.Args {
+			ret += " " + canonicalString(arg)
+		}
+		return ret + ")"
+	case parser.AssignNode:
+		return "(" + string(lexer.TypeAssign) + " " + n.Name + " " + canonicalString(n.Value) + ")"
+	case parser.SeqNode:
+		ret := "(" + string(lexer.TypeComma)
+		for _, item := range n.Items {
+			ret += " " + canonicalString(item)
+		}
+		return ret + ")"
+	case parser.ProgramNode:
+		ret := "(" + string(lexer.TypeSemi)
+		for _, statement := range n.Statements {
+			ret += " " + canonicalString(statement)
+		}
+		return ret + ")"
+	case parser.WhileNode:
+		return "(" + string(lexer.TypeWhile) + " " + canonicalString(n.Cond) + " " + canonicalString(n.Body) + ")"
+	case parser.ForNode:
+		ret := "(" + string(lexer.TypeFor) + " " + n.Var
+		for _, child := range []lexer.IExpression{n.Start, n.End, n.By(), n.Body} {
+			ret += " " + canonicalString(child)
+		}
+		return ret + ")"
+	case parser.LambdaNode:
+		return "(" + string(lexer.TypeArrow) + " (" + strings.Join(n.Params, " ") + ") " + canonicalString(n.Body) + ")"
+	case parser.DefNode:
+		return "(" + string(lexer.TypeAssign) + " " + n.Name + " " + canonicalString(n.Lambda) + ")"
+	case parser.LetNode:
+		return "(" + string(lexer.TypeLet) + " " + n.Name + " " + canonicalString(n.Value) + " " + canonicalString(n.Body) + ")"
+	case parser.ListNode:
+		ret := "(" + string(lexer.TypeLBracket)
+		for _, item := range n.Items {
+			ret += " " + canonicalString(item)
+		}
+		return ret + ")"
+	case parser.IndexNode:
+		return "(" + string(lexer.TypeRBracket) + " " + canonicalString(n.Target) + " " + canonicalString(n.Index) + ")"
+	case parser.TryNode:
+		return "(" + string(lexer.TypeTry) + " " + canonicalString(n.Body) + " " + canonicalString(n.Catch) + ")"
+	case parser.ImportNode:
+		return "(" + string(lexer.TypeImport) + " " + strconv.Quote(n.Path) + ")"
+	case parser.MatchNode:
+		ret := "(" + string(lexer.TypeMatch) + " " + canonicalString(n.Subject)
+		for _, arm := range n.Arms {
+			ret += " " + canonicalString(arm.Pattern) + " " + canonicalString(arm.Value)
+		}
+		return ret + " _ " + canonicalString(n.Default) + ")"
+	case parser.CoalesceNode:
+		return "(" + string(lexer.TypeCoalesce) + " " + canonicalString(n.Left) + " " + canonicalString(n.Right) + ")"
+	case parser.PercentNode:
+		ret := "(" + string(lexer.TypePercent)
+		if n.Relative() {
+			ret += " " + string(parser.OpType(n.Op))
+		}
+		return ret + " " + canonicalString(n.Percent) + " " + canonicalString(n.Base) + ")"
+	case parser.RangeNode:
+		return "(" + string(lexer.TypeRange) + " " + canonicalString(n.Start) + " " + canonicalString(n.End) + " " + canonicalString(n.By()) + ")"
+	case parser.MapNode:
+		ret := "(" + string(lexer.TypeLBrace)
+		for _, i := range sortedKeys(n.Keys) {
+			ret += " " + strconv.Quote(n.Keys[i]) + " " + canonicalString(n.Items[i])
+		}
+		return ret + ")"
+	}
+	return "?"
+}
+
+// sortedKeys returns the indexes of keys in the order of the keys
+func sortedKeys(keys []string) []int {
+	indexes := make([]int, len(keys))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	slices.SortFunc(indexes, func(a, b int) int { return strings.Compare(keys[a], keys[b]) })
+	return indexes
+}
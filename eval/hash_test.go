@@ -0,0 +1,45 @@
+package eval
+
+import (
+	"testing"
+
+	"github.com/fmarmol/lexp/lexer"
+	"github.com/fmarmol/lexp/parser"
+)
+
+func TestHash(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		division parser.Division
+		same     bool
+	}{
+		{"x + 2 + 3", "x + 5", parser.DivTrue, true},
+		{"2 + x + 3", "5 + x", parser.DivTrue, true},
+		{"x * 2 * 3", "6 * x", parser.DivTrue, true},
+		{"(x + y) * 2", "2 * (y + x)", parser.DivTrue, true},
+		{"x + 2", "x + 2.0", parser.DivTrue, true},
+		{"7 / 2", "3.5", parser.DivTrue, true},
+		{"7 / 2", "7.0 / 2", parser.DivTrunc, false},
+		{"7 / 2", "3.5", parser.DivTrunc, false},
+		{"7 / 2", "3.0", parser.DivTrunc, true},
+		{"x - 2 - 3", "x - 5", parser.DivTrue, false},
+	}
+	hash := func(text string, division parser.Division) uint64 {
+		tokens, err := lexer.New("test", text).MakeTokens()
+		if err != nil {
+			t.Fatal(err)
+		}
+		p := parser.New(tokens)
+		p.Env, p.Division = parser.Env{"x": 1, "y": 2}, division
+		node, err := p.Parse()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return Hash(node)
+	}
+	for _, test := range tests {
+		if same := hash(test.a, test.division) == hash(test.b, test.division); same != test.same {
+			t.Errorf("Hash(%q) == Hash(%q) with %v division is %v, want %v", test.a, test.b, test.division, same, test.same)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package eval
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/fmarmol/lexp/lexer"
+	"github.com/fmarmol/lexp/parser"
+)
+
+// ListNumber ...
+func ListNumber(items []Number) Number { return Number{KindList, 0, 0, false, "", items, nil, nil} }
+
+// exact returns f as an integer if it is one, up to 2^53
+func exact(f float64) Number {
+	if f == math.Trunc(f) && math.Abs(f) <= maxExactInt {
+		return IntNumber(int64(f))
+	}
+	return FloatNumber(f)
+}
+
+// Floats of the items of a list
+func (n Number) Floats() []float64 {
+	floats := make([]float64, len(n.List))
+	for i, item := range n.List {
+		floats[i] = item.Float64()
+	}
+	return floats
+}
+
+// item checks that v can be an item of a list or a map, at the span of
+// node
+func item(node lexer.IExpression, v Number) error {
+	if v.Kind == KindList || v.Kind == KindMap || v.Kind == KindFunc {
+		return fmt.Errorf("lists and maps hold numbers and strings, got a %v at %v", v.Kind, lexer.SpanOf(node).Start)
+	}
+	return nil
+}
+
+// list evaluates the items of n
+func (e *numberEval) list(n parser.ListNode) (Number, error) {
+	items := make([]Number, len(n.Items))
+	for i, node := range n.Items {
+		v, err := e.eval(node)
+		if err != nil {
+			return Number{}, err
+		}
+		if err := item(node, v); err != nil {
+			return Number{}, err
+		}
+		items[i] = v
+	}
+	return ListNumber(items), nil
+}
+
+// rangeList evaluates the items of n, integers if its bounds are
+func (e *numberEval) rangeList(n parser.RangeNode) (Number, error) {
+	values, err := e.bounds(n)
+	if err != nil {
+		return Number{}, err
+	}
+	floats, err := n.Items(values[0].Float64(), values[1].Float64(), values[2].Float64())
+	if err != nil {
+		return Number{}, err
+	}
+	items := make([]Number, len(floats))
+	for i, f := range floats {
+		items[i] = exact(f)
+	}
+	v := ListNumber(items)
+	return v, e.step(n, v, values...)
+}
+
+// bounds evaluates the start, end and step of n, which must be numbers
+func (e *numberEval) bounds(n parser.RangeNode) ([]Number, error) {
+	bounds := []lexer.IExpression{n.Start, n.End, n.By()}
+	values := make([]Number, len(bounds))
+	for i, bound := range bounds {
+		v, err := e.eval(bound)
+		if err != nil {
+			return nil, err
+		}
+		if !v.Scalar() || v.Kind == KindBool {
+			return nil, n.Locate(fmt.Errorf("range needs numbers, got a %v at %v", v.Kind, lexer.SpanOf(bound).Start))
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+// index evaluates the item of a list or a map
+func (e *numberEval) index(n parser.IndexNode) (Number, error) {
+	list, err := e.eval(n.Target)
+	if err != nil {
+		return Number{}, err
+	}
+	if list.Kind == KindMap {
+		return e.key(n, list)
+	}
+	if list.Kind != KindList {
+		return Number{}, n.Locate(fmt.Errorf("cannot index %v, not a list%v", parser.Source(n.Target), n.At()))
+	}
+	i, err := e.eval(n.Index)
+	if err != nil {
+		return Number{}, err
+	}
+	if i.Kind != KindInt && i.Kind != KindFloat {
+		return Number{}, n.Locate(fmt.Errorf("index must be a number, got a %v%v", i.Kind, n.At()))
+	}
+	offset, err := n.Offset(i.Float64(), len(list.List))
+	if err != nil {
+		return Number{}, err
+	}
+	v := list.List[offset]
+	return v, e.step(n, v, list, i)
+}
+
+// elementwise applies op to the items of the list operands, a number
+// operand applying to each item
+func elementwise(op lexer.Operation, left, right Number) (Number, error) {
+	if left.Kind == KindList && right.Kind == KindList && len(left.List) != len(right.List) {
+		return Number{}, locate(op, fmt.Errorf("cannot apply %v to lists of %v and %v items%v", op, len(left.List), len(right.List), at(op)))
+	}
+	n := len(left.List)
+	if left.Kind != KindList {
+		n = len(right.List)
+	}
+	items := make([]Number, n)
+	for i := range items {
+		l, r := left, right
+		if l.Kind == KindList {
+			l = left.List[i]
+		}
+		if r.Kind == KindList {
+			r = right.List[i]
+		}
+		var err error
+		if items[i], err = apply(op, l, r); err != nil {
+			return Number{}, err
+		}
+	}
+	return ListNumber(items), nil
+}
+
+// args of the call n, the items of the list operands being passed as
+// separate arguments
+func args(n parser.CallNode, operands []Number) ([]float64, error) {
+	args := make([]float64, 0, len(operands))
+	for i, v := range operands {
+		if v.Kind == KindMap {
+			return nil, fmt.Errorf("%v expects numbers, got a map at %v", n.Name(), lexer.SpanOf(n.Args[i]).Start)
+		}
+		if v.Kind != KindList {
+			args = append(args, v.Float64())
+			continue
+		}
+		for _, item := range v.List {
+			if err := numeric(n, i, item); err != nil {
+				return nil, err
+			}
+		}
+		args = append(args, v.Floats()...)
+	}
+	return args, nil
+}
+
+// numeric checks that the item of a list passed as the argument i of n is a
+// number
+func numeric(n parser.CallNode, i int, item Number) error {
+	switch item.Kind {
+	case KindString:
+		return fmt.Errorf("%v expects numbers, got a list of strings at %v", n.Name(), lexer.SpanOf(n.Args[i]).Start)
+	case KindNil:
+		return fmt.Errorf("%w, %v expects numbers, got a list with nil at %v", lexer.ErrNil, n.Name(), lexer.SpanOf(n.Args[i]).Start)
+	}
+	return nil
+}
+
+// mapped calls the function f of n, taking a single argument, for each
+// item of list
+func (e *numberEval) mapped(n parser.CallNode, f parser.Func, list Number) (Number, error) {
+	items := make([]Number, len(list.List))
+	for i, arg := range list.List {
+		if err := numeric(n, 0, arg); err != nil {
+			return Number{}, err
+		}
+		v, err := e.invoke(f, []float64{arg.Float64()})
+		if err != nil {
+			return Number{}, err
+		}
+		if err := item(n, v); err != nil {
+			return Number{}, err
+		}
+		items[i] = v
+	}
+	return ListNumber(items), nil
+}
+
+// invoke f for args
+func (e *numberEval) invoke(f parser.Func, args []float64) (Number, error) {
+	if f.Closure != nil {
+		return e.call(f.Closure, args, nil)
+	}
+	if call, ok := f.Value.(ValueFunc); ok {
+		numbers := make([]Number, len(args))
+		for i, arg := range args {
+			numbers[i] = FloatNumber(arg)
+		}
+		return call(numbers...)
+	}
+	v, err := f.Call(args...)
+	return FloatNumber(v), err
+}
+
+func (n Number) listString(format func(Number) string) string {
+	items := make([]string, len(n.List))
+	for i, item := range n.List {
+		items[i] = format(item)
+	}
+	return "[" + strings.Join(items, ", ") + "]"
+}
@@ -0,0 +1,105 @@
+package eval
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/fmarmol/lexp/lexer"
+	"github.com/fmarmol/lexp/parser"
+)
+
+// MapNumber ...
+func MapNumber(m map[string]Number) Number { return Number{KindMap, 0, 0, false, "", nil, m, nil} }
+
+// Keys of a map, sorted
+func (n Number) Keys() []string {
+	keys := make([]string, 0, len(n.Map))
+	for key := range n.Map {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// mapFuncs are the builtins taking a map, called instead of the float
+// builtins of the same name when their argument is a map
+var mapFuncs = map[string]func(Number) Number{
+	"keys": func(m Number) Number {
+		keys := []Number{}
+		for _, key := range m.Keys() {
+			keys = append(keys, StringNumber(key))
+		}
+		return ListNumber(keys)
+	},
+	"values": func(m Number) Number {
+		values := []Number{}
+		for _, key := range m.Keys() {
+			values = append(values, m.Map[key])
+		}
+		return ListNumber(values)
+	},
+	"len": func(m Number) Number { return IntNumber(int64(len(m.Map))) },
+}
+
+// mapOnly is the float builtin of the map funcs not taking numbers
+func mapOnly(name string) parser.Func {
+	return parser.Func{MinArgs: 1, MaxArgs: 1, Call: func(...float64) (float64, error) {
+		return 0, errors.New(name + " expects a map")
+	}}
+}
+
+// mapValue evaluates the items of n
+func (e *numberEval) mapValue(n parser.MapNode) (Number, error) {
+	m := make(map[string]Number, len(n.Keys))
+	for i, node := range n.Items {
+		v, err := e.eval(node)
+		if err != nil {
+			return Number{}, err
+		}
+		if err := item(node, v); err != nil {
+			return Number{}, err
+		}
+		m[n.Keys[i]] = v
+	}
+	return MapNumber(m), nil
+}
+
+// key evaluates the item of a map at a string index
+func (e *numberEval) key(n parser.IndexNode, m Number) (Number, error) {
+	key, err := e.eval(n.Index)
+	if err != nil {
+		return Number{}, err
+	}
+	if key.Kind != KindString {
+		return Number{}, n.Locate(fmt.Errorf("map keys are strings, got a %v%v", key.Kind, n.At()))
+	}
+	v, ok := m.Map[key.Str]
+	if !ok {
+		return Number{}, n.MissingKey(key.Str)
+	}
+	return v, e.step(n, v, m, key)
+}
+
+// mapCall calls the map func of n if its single operand is a map and the
+// function is not one defined in the session
+func (e *numberEval) mapCall(n parser.CallNode, operands []Number) (Number, bool, error) {
+	if len(operands) != 1 || operands[0].Kind != KindMap {
+		return Number{}, false, nil
+	}
+	call, ok := mapFuncs[n.Name()]
+	if f, err := n.Resolve(1); !ok || err != nil || f.Closure != nil {
+		return Number{}, true, fmt.Errorf("%v expects numbers, got a map at %v", n.Name(), lexer.SpanOf(n.Args[0]).Start)
+	}
+	return call(operands[0]), true, nil
+}
+
+func (n Number) mapString(format func(Number) string) string {
+	entries := []string{}
+	for _, key := range n.Keys() {
+		entries = append(entries, strconv.Quote(key)+": "+format(n.Map[key]))
+	}
+	return "{" + strings.Join(entries, ", ") + "}"
+}
@@ -0,0 +1,98 @@
+package eval
+
+import (
+	"github.com/fmarmol/lexp/lexer"
+	"github.com/fmarmol/lexp/parser"
+)
+
+// match evaluates the value of the first arm of n matching its subject, or
+// of the _ arm
+func (e *numberEval) match(n parser.MatchNode) (Number, error) {
+	value, subject, err := e.arm(n)
+	if err != nil {
+		return Number{}, err
+	}
+	v, err := e.eval(value)
+	if err != nil {
+		return Number{}, err
+	}
+	return v, e.step(n, v, subject)
+}
+
+// arm returns the value of the first arm of n matching its subject, and the
+// subject
+func (e *numberEval) arm(n parser.MatchNode) (lexer.IExpression, Number, error) {
+	subject, err := e.eval(n.Subject)
+	if err != nil {
+		return nil, Number{}, err
+	}
+	for _, arm := range n.Arms {
+		ok, err := e.matches(arm.Pattern, subject)
+		if err != nil {
+			return nil, Number{}, err
+		}
+		if ok {
+			return arm.Value, subject, nil
+		}
+	}
+	return n.Default, subject, nil
+}
+
+// matches tells if subject equals the value of pattern, or is one of its
+// items if it is a range, the values of other kinds never matching
+func (e *numberEval) matches(pattern lexer.IExpression, subject Number) (bool, error) {
+	r, ok := pattern.(parser.RangeNode)
+	if !ok {
+		v, err := e.eval(pattern)
+		return err == nil && equal(v, subject), err
+	}
+	bounds, err := e.bounds(r)
+	if err != nil {
+		return false, err
+	}
+	if subject.Kind != KindInt && subject.Kind != KindFloat {
+		return false, nil
+	}
+	return r.Contains(subject.Float64(), bounds[0].Float64(), bounds[1].Float64(), bounds[2].Float64())
+}
+
+// equal tells if a and b are the same value, integers and floats being
+// compared as numbers and lists and maps item by item
+func equal(a, b Number) bool {
+	switch {
+	case a.Kind == KindInt && b.Kind == KindInt:
+		return a.Int == b.Int
+	case (a.Kind == KindInt || a.Kind == KindFloat) && (b.Kind == KindInt || b.Kind == KindFloat):
+		return a.Float64() == b.Float64()
+	case a.Kind != b.Kind:
+		return false
+	}
+	switch a.Kind {
+	case KindBool:
+		return a.Bool == b.Bool
+	case KindString:
+		return a.Str == b.Str
+	case KindList:
+		if len(a.List) != len(b.List) {
+			return false
+		}
+		for i := range a.List {
+			if !equal(a.List[i], b.List[i]) {
+				return false
+			}
+		}
+		return true
+	case KindMap:
+		if len(a.Map) != len(b.Map) {
+			return false
+		}
+		for key, v := range a.Map {
+			if w, ok := b.Map[key]; !ok || !equal(v, w) {
+				return false
+			}
+		}
+		return true
+	}
+	// nil
+	return true
+}
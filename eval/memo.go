@@ -0,0 +1,100 @@
+package eval
+
+import (
+	"github.com/fmarmol/lexp/lexer"
+	"github.com/fmarmol/lexp/parser"
+)
+
+// EvalMemo evaluates node like Eval but computes identical pure subtrees,
+// as identified by their canonical hash, only once. The subtrees reading a
+// variable node assigns are computed each time, their value changing with
+// the assignments.
+func EvalMemo(node lexer.IExpression) (float64, error) {
+	m := memo{map[uint64]float64{}, map[string]bool{}}
+	parser.Walk(node, func(n lexer.IExpression) bool {
+		switch n := n.(type) {
+		case parser.AssignNode:
+			m.assigned[n.Name] = true
+		case parser.ForNode:
+			m.assigned[n.Var] = true
+		case parser.LetNode:
+			m.assigned[n.Name] = true
+		}
+		return true
+	})
+	return m.eval(hashTree(node))
+}
+
+type memo struct {
+	cache map[uint64]float64
+	// assigned are the variables assigned by the expression
+	assigned map[string]bool
+}
+
+func (m memo) eval(h *hashed) (float64, error) {
+	if !h.purity.Cacheable() || m.reads(h.node) {
+		return m.compute(h)
+	}
+	if v, ok := m.cache[h.key]; ok {
+		return v, nil
+	}
+	v, err := m.compute(h)
+	if err != nil {
+		return 0, err
+	}
+	m.cache[h.key] = v
+	return v, nil
+}
+
+func (m memo) compute(h *hashed) (float64, error) {
+	if h.constant {
+		return h.value, nil
+	}
+	switch n := h.node.(type) {
+	case parser.BinOpNode:
+		left, err := m.eval(h.left)
+		if err != nil {
+			return 0, err
+		}
+		if op, ok := n.Op.(lexer.Logical); ok && op.Decides(left != 0) {
+			return lexer.Bool(left != 0), nil
+		}
+		right, err := m.eval(h.right)
+		if err != nil {
+			return 0, err
+		}
+		return n.Op.Eval(h.left.operand(left), h.right.operand(right))
+	case parser.UnaryOpNode:
+		v, err := m.eval(h.left)
+		if err != nil {
+			return 0, err
+		}
+		return n.Op.Eval(lexer.NewTokenInt(0), h.left.operand(v))
+	}
+	return h.node.Eval()
+}
+
+// operand passes the integer literals as is to the operations, / dividing
+// them as integers, and the values of the other operands as floats, like
+// BinOpNode.Eval
+func (h *hashed) operand(v float64) lexer.IExpression {
+	if n, ok := h.node.(lexer.TokenInt); ok {
+		return n
+	}
+	return lexer.NewTokenFloat(v)
+}
+
+// reads tells if node reads an assigned variable
+func (m memo) reads(node lexer.IExpression) bool {
+	if len(m.assigned) == 0 {
+		return false
+	}
+	found := false
+	parser.Walk(node, func(n lexer.IExpression) bool {
+		if v, ok := n.(parser.VarNode); ok && m.assigned[v.Name()] {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
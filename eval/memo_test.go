@@ -0,0 +1,79 @@
+package eval
+
+import (
+	"testing"
+
+	"github.com/fmarmol/lexp/lexer"
+	"github.com/fmarmol/lexp/parser"
+)
+
+func TestEvalMemoImpure(t *testing.T) {
+	e := NewEvaluator()
+	ticks := 0.0
+	e.RegisterFunc("tick", func(...float64) (float64, error) {
+		ticks++
+		return ticks, nil
+	})
+	node, err := e.parse("test", "tick() + tick()")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := EvalMemo(node); err != nil || got != 3 {
+		t.Errorf("EvalMemo(tick() + tick()) = %v, %v, want 3", got, err)
+	}
+}
+
+func TestAnalyzePurity(t *testing.T) {
+	funcs := parser.Funcs{}
+	for name, f := range Builtins {
+		funcs[name] = f
+	}
+	funcs["tick"] = parser.Func{MinArgs: 0, MaxArgs: 0, Call: func(...float64) (float64, error) { return 0, nil }, Impure: true}
+	tests := []struct {
+		text string
+		want bool
+	}{
+		{"sqrt(2) + 1", true},
+		{"tick() + 1", false},
+		{"undefined(1)", false},
+		{"sqrt(tick())", false},
+	}
+	for _, test := range tests {
+		tokens, err := lexer.New("test", test.text).MakeTokens()
+		if err != nil {
+			t.Fatal(err)
+		}
+		p := parser.New(tokens)
+		p.Funcs = funcs
+		node, err := p.Parse()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := AnalyzePurity(node).Cacheable(); got != test.want {
+			t.Errorf("AnalyzePurity(%q).Cacheable() = %v, want %v", test.text, got, test.want)
+		}
+	}
+}
+
+func TestEvalMemoDivision(t *testing.T) {
+	texts := []string{"7/2", "7/2 + 7.0/2", "-7/2", "(7/2)/2", "2*3/4", "x/2 + x/2", "6/3 + 6/3"}
+	for _, division := range []parser.Division{parser.DivTrue, parser.DivTrunc, parser.DivExact} {
+		for _, text := range texts {
+			tokens, err := lexer.New("test", text).MakeTokens()
+			if err != nil {
+				t.Fatal(err)
+			}
+			p := parser.New(tokens)
+			p.Env, p.Division = parser.Env{"x": 7}, division
+			node, err := p.Parse()
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, wantErr := Eval(node)
+			got, err := EvalMemo(node)
+			if got != want || (err == nil) != (wantErr == nil) {
+				t.Errorf("EvalMemo(%q) with %v division = %v, %v, want %v, %v", text, division, got, err, want, wantErr)
+			}
+		}
+	}
+}
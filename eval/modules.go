@@ -0,0 +1,413 @@
+package eval
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/fmarmol/lexp/lexer"
+	"github.com/fmarmol/lexp/parser"
+)
+
+// Module is a namespace of functions and constants, called and used
+// qualified by its name, like math.floor(x) or math.pi
+type Module struct {
+	Funcs  parser.Funcs
+	Consts parser.Env
+}
+
+// Register adds the functions and the constants of m to funcs and consts,
+// qualified by name
+func (m Module) Register(name string, funcs parser.Funcs, consts parser.Env) {
+	funcs.Register(name, m.Funcs)
+	consts.Register(name, m.Consts)
+}
+
+// Modules of the standard library, registered in Builtins and Constants
+// like embedders register theirs
+var Modules = map[string]Module{
+	"math": {
+		Funcs: pick(Builtins, "abs", "sqrt", "cbrt", "exp", "ln", "log", "log2", "log10", "sin", "cos", "tan",
+			"asin", "acos", "atan", "sinh", "cosh", "tanh", "floor", "ceil", "round", "trunc",
+			"pow", "atan2", "hypot", "mod", "min", "max"),
+		Consts: parser.Env{"pi": math.Pi, "e": math.E, "tau": 2 * math.Pi},
+	},
+	"string": {Funcs: signatures(stringFuncs), Consts: parser.Env{}},
+	"list": {
+		Funcs:  merge(pick(Builtins, "len", "sum", "min", "max", "mean", "median"), signatures(listFuncs)),
+		Consts: parser.Env{},
+	},
+}
+
+func init() {
+	for name, m := range Modules {
+		m.Register(name, Builtins, Constants)
+	}
+	for name, f := range signatures(calculusFuncs) {
+		f.Binds = true
+		Builtins[name] = f
+	}
+}
+
+// pick the functions called names among funcs
+func pick(funcs parser.Funcs, names ...string) parser.Funcs {
+	picked := parser.Funcs{}
+	for _, name := range names {
+		picked[name] = funcs[name]
+	}
+	return picked
+}
+
+func merge(a, b parser.Funcs) parser.Funcs {
+	for name, f := range b {
+		a[name] = f
+	}
+	return a
+}
+
+// valueFunc is a function of a module taking values which are not numbers,
+// or functions, evaluating the arguments of n itself
+type valueFunc struct {
+	min, max int
+	call     func(e *numberEval, n parser.CallNode) (Number, error)
+}
+
+// signatures of value funcs, whose Call fails as they need the number
+// evaluator
+func signatures(funcs map[string]valueFunc) parser.Funcs {
+	ret := parser.Funcs{}
+	for name, f := range funcs {
+		ret[name] = parser.Func{MinArgs: f.min, MaxArgs: f.max, Call: func(...float64) (float64, error) {
+			return 0, fmt.Errorf("%v takes values which are not numbers", name)
+		}, Value: f}
+	}
+	return ret
+}
+
+// ValueFunc is a function taking values which are not numbers, like strings,
+// lists, maps or functions
+type ValueFunc func(args ...Number) (Number, error)
+
+// NewValueFunc returns the function taking from min to max arguments, max
+// being -1 for no limit, that f implements. Called with numbers only, by the
+// float evaluators, it fails unless f yields a number.
+func NewValueFunc(min, max int, f ValueFunc) parser.Func {
+	return parser.Func{MinArgs: min, MaxArgs: max, Call: func(args ...float64) (float64, error) {
+		numbers := make([]Number, len(args))
+		for i, arg := range args {
+			numbers[i] = FloatNumber(arg)
+		}
+		v, err := f(numbers...)
+		if err != nil {
+			return 0, err
+		}
+		if !v.Scalar() || v.Kind == KindFunc {
+			return 0, fmt.Errorf("a %v is %w", v.Kind, lexer.ErrNotANumber)
+		}
+		return v.Float64(), nil
+	}, Value: f}
+}
+
+// stringFuncs are the value funcs of the string module, indexes counting
+// runes
+var stringFuncs = map[string]valueFunc{
+	"len": {1, 1, func(e *numberEval, n parser.CallNode) (Number, error) {
+		s, err := e.stringArg(n, 0)
+		return IntNumber(int64(utf8.RuneCountInString(s))), err
+	}},
+	"upper": {1, 1, func(e *numberEval, n parser.CallNode) (Number, error) {
+		s, err := e.stringArg(n, 0)
+		return StringNumber(strings.ToUpper(s)), err
+	}},
+	"lower": {1, 1, func(e *numberEval, n parser.CallNode) (Number, error) {
+		s, err := e.stringArg(n, 0)
+		return StringNumber(strings.ToLower(s)), err
+	}},
+	"trim": {1, 1, func(e *numberEval, n parser.CallNode) (Number, error) {
+		s, err := e.stringArg(n, 0)
+		return StringNumber(strings.TrimSpace(s)), err
+	}},
+	"contains": {2, 2, func(e *numberEval, n parser.CallNode) (Number, error) {
+		s, sub, err := e.stringArgs(n)
+		return BoolNumber(strings.Contains(s, sub)), err
+	}},
+	"index": {2, 2, func(e *numberEval, n parser.CallNode) (Number, error) {
+		s, sub, err := e.stringArgs(n)
+		i := strings.Index(s, sub)
+		if i > 0 {
+			i = utf8.RuneCountInString(s[:i])
+		}
+		return IntNumber(int64(i)), err
+	}},
+	"slice": {3, 3, func(e *numberEval, n parser.CallNode) (Number, error) {
+		s, err := e.stringArg(n, 0)
+		if err != nil {
+			return Number{}, err
+		}
+		runes := []rune(s)
+		start, err := e.intArg(n, 1, 0, len(runes))
+		if err != nil {
+			return Number{}, err
+		}
+		end, err := e.intArg(n, 2, start, len(runes))
+		return StringNumber(string(runes[start:end])), err
+	}},
+	"split": {2, 2, func(e *numberEval, n parser.CallNode) (Number, error) {
+		s, sep, err := e.stringArgs(n)
+		if err != nil {
+			return Number{}, err
+		}
+		items := []Number{}
+		for _, part := range strings.Split(s, sep) {
+			items = append(items, StringNumber(part))
+		}
+		return ListNumber(items), nil
+	}},
+	"join": {2, 2, func(e *numberEval, n parser.CallNode) (Number, error) {
+		list, err := e.listArg(n, 0)
+		if err != nil {
+			return Number{}, err
+		}
+		sep, err := e.stringArg(n, 1)
+		if err != nil {
+			return Number{}, err
+		}
+		parts := make([]string, len(list))
+		for i, item := range list {
+			if item.Kind != KindString {
+				return Number{}, fmt.Errorf("%v expects a list of strings, got a %v at %v", n.Name(), item.Kind, lexer.SpanOf(n.Args[0]).Start)
+			}
+			parts[i] = item.Str
+		}
+		return StringNumber(strings.Join(parts, sep)), nil
+	}},
+	"repeat": {2, 2, func(e *numberEval, n parser.CallNode) (Number, error) {
+		s, err := e.stringArg(n, 0)
+		if err != nil {
+			return Number{}, err
+		}
+		count, err := e.intArg(n, 1, 0, math.MaxInt32/max(len(s), 1))
+		return StringNumber(strings.Repeat(s, count)), err
+	}},
+}
+
+// listFuncs are the value funcs of the list module, the functions they
+// take being named or lambdas: list.map(xs, (x) -> x * 2)
+var listFuncs = map[string]valueFunc{
+	"map": {2, 2, func(e *numberEval, n parser.CallNode) (Number, error) {
+		list, f, err := e.listAndFunc(n, 1)
+		if err != nil {
+			return Number{}, err
+		}
+		return e.mapped(n, f, ListNumber(list))
+	}},
+	"filter": {2, 2, func(e *numberEval, n parser.CallNode) (Number, error) {
+		list, f, err := e.listAndFunc(n, 1)
+		if err != nil {
+			return Number{}, err
+		}
+		kept := []Number{}
+		for _, item := range list {
+			if err := numeric(n, 0, item); err != nil {
+				return Number{}, err
+			}
+			v, err := e.invoke(f, []float64{item.Float64()})
+			if err != nil {
+				return Number{}, err
+			}
+			if v.Kind != KindBool {
+				return Number{}, fmt.Errorf("%v expects a function returning booleans, got a %v at %v", n.Name(), v.Kind, lexer.SpanOf(n.Args[1]).Start)
+			}
+			if v.Bool {
+				kept = append(kept, item)
+			}
+		}
+		return ListNumber(kept), nil
+	}},
+	"reduce": {3, 3, func(e *numberEval, n parser.CallNode) (Number, error) {
+		list, f, err := e.listAndFunc(n, 2)
+		if err != nil {
+			return Number{}, err
+		}
+		acc, err := e.eval(n.Args[2])
+		if err != nil {
+			return Number{}, err
+		}
+		for _, item := range list {
+			if err := numeric(n, 0, item); err != nil {
+				return Number{}, err
+			}
+			if !acc.Scalar() {
+				return Number{}, fmt.Errorf("%v expects numbers, got a %v at %v", n.Name(), acc.Kind, lexer.SpanOf(n.Args[2]).Start)
+			}
+			if acc, err = e.invoke(f, []float64{acc.Float64(), item.Float64()}); err != nil {
+				return Number{}, err
+			}
+		}
+		return acc, nil
+	}},
+	"sort": {1, 1, func(e *numberEval, n parser.CallNode) (Number, error) {
+		list, err := e.listArg(n, 0)
+		if err != nil {
+			return Number{}, err
+		}
+		for _, item := range list {
+			if (item.Kind == KindString) != (list[0].Kind == KindString) || !(item.Kind == KindString || item.Scalar()) || item.Kind == KindBool {
+				return Number{}, fmt.Errorf("%v expects a list of numbers or of strings at %v", n.Name(), lexer.SpanOf(n.Args[0]).Start)
+			}
+		}
+		sorted := slices.Clone(list)
+		slices.SortStableFunc(sorted, func(a, b Number) int {
+			if a.Kind == KindString {
+				return strings.Compare(a.Str, b.Str)
+			}
+			if a.Kind == KindInt && b.Kind == KindInt {
+				return cmp.Compare(a.Int, b.Int)
+			}
+			return cmp.Compare(a.Float64(), b.Float64())
+		})
+		return ListNumber(sorted), nil
+	}},
+	"reverse": {1, 1, func(e *numberEval, n parser.CallNode) (Number, error) {
+		list, err := e.listArg(n, 0)
+		reversed := slices.Clone(list)
+		slices.Reverse(reversed)
+		return ListNumber(reversed), err
+	}},
+}
+
+// valueCall calls the function of n if it takes values which are not
+// numbers, told by its implementation rather than its name so that the
+// functions registered in place of the value funcs are called like the
+// others
+func (e *numberEval) valueCall(n parser.CallNode) (Number, bool, error) {
+	if f := n.Funcs[n.Name()]; f.Value == nil {
+		return Number{}, false, nil
+	}
+	f, err := n.Resolve(len(n.Args))
+	if err != nil {
+		return Number{}, true, err
+	}
+	var v Number
+	switch call := f.Value.(type) {
+	case valueFunc:
+		v, err = call.call(e, n)
+	case ValueFunc:
+		v, err = e.callValues(n, call)
+	default:
+		return Number{}, false, nil
+	}
+	if err != nil {
+		return Number{}, true, err
+	}
+	return v, true, e.step(n, v)
+}
+
+// callValues calls f with the values of the arguments of n
+func (e *numberEval) callValues(n parser.CallNode, f ValueFunc) (Number, error) {
+	args := make([]Number, len(n.Args))
+	for i, arg := range n.Args {
+		var err error
+		if args[i], err = e.eval(arg); err != nil {
+			return Number{}, err
+		}
+	}
+	v, err := f(args...)
+	if err != nil {
+		return Number{}, n.Locate(fmt.Errorf("%w%v", err, n.At()))
+	}
+	return v, nil
+}
+
+// stringArg evaluates the argument i of n, which must be a string
+func (e *numberEval) stringArg(n parser.CallNode, i int) (string, error) {
+	v, err := e.eval(n.Args[i])
+	if err != nil {
+		return "", err
+	}
+	if v.Kind != KindString {
+		return "", fmt.Errorf("%v expects a string, got a %v at %v", n.Name(), v.Kind, lexer.SpanOf(n.Args[i]).Start)
+	}
+	return v.Str, nil
+}
+
+// stringArgs evaluates the two string arguments of n
+func (e *numberEval) stringArgs(n parser.CallNode) (string, string, error) {
+	a, err := e.stringArg(n, 0)
+	if err != nil {
+		return "", "", err
+	}
+	b, err := e.stringArg(n, 1)
+	return a, b, err
+}
+
+// intArg evaluates the argument i of n, which must be an integer from lo to
+// hi
+func (e *numberEval) intArg(n parser.CallNode, i, lo, hi int) (int, error) {
+	v, err := e.eval(n.Args[i])
+	if err != nil {
+		return 0, err
+	}
+	f := v.Float64()
+	if !v.Scalar() || v.Kind == KindBool || f != math.Trunc(f) {
+		return 0, fmt.Errorf("%v expects an integer, got a %v at %v", n.Name(), v.Kind, lexer.SpanOf(n.Args[i]).Start)
+	}
+	if f < float64(lo) || f > float64(hi) {
+		return 0, fmt.Errorf("%v expects an integer from %v to %v, got %v at %v", n.Name(), lo, hi, f, lexer.SpanOf(n.Args[i]).Start)
+	}
+	return int(f), nil
+}
+
+// listArg evaluates the argument i of n, which must be a list
+func (e *numberEval) listArg(n parser.CallNode, i int) ([]Number, error) {
+	v, err := e.eval(n.Args[i])
+	if err != nil {
+		return nil, err
+	}
+	if v.Kind != KindList {
+		return nil, fmt.Errorf("%v expects a list, got a %v at %v", n.Name(), v.Kind, lexer.SpanOf(n.Args[i]).Start)
+	}
+	return v.List, nil
+}
+
+// listAndFunc evaluates the first argument of n, a list, and resolves the
+// second one, a function taking arity numbers
+func (e *numberEval) listAndFunc(n parser.CallNode, arity int) ([]Number, parser.Func, error) {
+	list, err := e.listArg(n, 0)
+	if err != nil {
+		return nil, parser.Func{}, err
+	}
+	f, err := e.funcArg(n, 1, arity)
+	return list, f, err
+}
+
+// funcArg resolves the argument i of n, a lambda, the name of a function or
+// an expression yielding one, taking arity numbers
+func (e *numberEval) funcArg(n parser.CallNode, i, arity int) (parser.Func, error) {
+	var f parser.Func
+	switch arg := n.Args[i].(type) {
+	case parser.LambdaNode:
+		f = arg.Closure().Func()
+	case parser.VarNode:
+		var ok bool
+		if f, ok = n.Funcs[arg.Name()]; !ok {
+			return parser.Func{}, arg.Locate(fmt.Errorf("undefined function %v%v%v", arg.Name(), arg.At(), parser.DidYouMean(arg.Name(), n.Funcs.Names())))
+		}
+	default:
+		v, err := e.eval(arg)
+		if err != nil {
+			return parser.Func{}, err
+		}
+		if v.Kind != KindFunc {
+			return parser.Func{}, fmt.Errorf("%v expects a function, like (x) -> x * 2 or the name of one, at %v", n.Name(), lexer.SpanOf(arg).Start)
+		}
+		f = *v.Func
+	}
+	if err := f.CheckArgs("the function of "+n.Name(), arity); err != nil {
+		return parser.Func{}, fmt.Errorf("%w at %v", err, lexer.SpanOf(n.Args[i]).Start)
+	}
+	return f, nil
+}
@@ -0,0 +1,46 @@
+package eval
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/fmarmol/lexp/lexer"
+	"github.com/fmarmol/lexp/parser"
+)
+
+// value of n in parser.Values, nil for nil
+func (n Number) value() any {
+	if n.Kind == KindNil {
+		return nil
+	}
+	return n
+}
+
+// coalesce evaluates the right operand of n only if the left one is nil or
+// fails because nil is used in it
+func (e *numberEval) coalesce(n parser.CoalesceNode) (Number, error) {
+	left, err := e.eval(n.Left)
+	if err != nil && !errors.Is(err, lexer.ErrNil) {
+		return Number{}, err
+	}
+	if err == nil && left.Kind != KindNil {
+		return left, e.step(n, left, left)
+	}
+	v, err := e.eval(n.Right)
+	if err != nil {
+		return Number{}, err
+	}
+	return v, e.step(n, v, v)
+}
+
+// nilOperand applies op to left and right, one of them being nil: equal if
+// both are
+func nilOperand(op lexer.Operation, left, right Number) (Number, error) {
+	if c, ok := op.(lexer.Comparison); ok && c.Holds(-1) == c.Holds(1) {
+		if left.Kind == right.Kind {
+			return BoolNumber(c.Holds(0)), nil
+		}
+		return BoolNumber(c.Holds(1)), nil
+	}
+	return Number{}, locate(op, fmt.Errorf("%w, cannot apply %v to %v and %v%v", lexer.ErrNil, op, left.Kind, right.Kind, at(op)))
+}
@@ -0,0 +1,677 @@
+package eval
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/fmarmol/lexp/lexer"
+	"github.com/fmarmol/lexp/parser"
+)
+
+// Kind of a Number
+type Kind string
+
+const (
+	KindInt    Kind = "int"
+	KindFloat  Kind = "float"
+	KindBool   Kind = "bool"
+	KindString Kind = "string"
+	KindList   Kind = "list"
+	KindMap    Kind = "map"
+	KindNil    Kind = "nil"
+	KindFunc   Kind = "function"
+)
+
+// Number is the result of an evaluation, an integer, a float, a boolean, a
+// string, a list, a map from strings to values, nil or a function
+type Number struct {
+	Kind  Kind
+	Int   int64
+	Float float64
+	Bool  bool
+	Str   string
+	List  []Number
+	Map   map[string]Number
+	Func  *parser.Func
+}
+
+// IntNumber ...
+func IntNumber(n int64) Number { return Number{KindInt, n, 0, false, "", nil, nil, nil} }
+
+// FloatNumber ...
+func FloatNumber(f float64) Number { return Number{KindFloat, 0, f, false, "", nil, nil, nil} }
+
+// BoolNumber ...
+func BoolNumber(b bool) Number { return Number{KindBool, 0, 0, b, "", nil, nil, nil} }
+
+// StringNumber ...
+func StringNumber(s string) Number { return Number{KindString, 0, 0, false, s, nil, nil, nil} }
+
+// NilNumber ...
+func NilNumber() Number { return Number{KindNil, 0, 0, false, "", nil, nil, nil} }
+
+// Float64 value of n, integers beyond 2^53 being rounded, booleans being 1
+// or 0 and strings, lists, maps, nil and functions NaN
+func (n Number) Float64() float64 {
+	switch n.Kind {
+	case KindInt:
+		return float64(n.Int)
+	case KindBool:
+		return lexer.Bool(n.Bool)
+	case KindString, KindList, KindMap, KindNil, KindFunc:
+		return math.NaN()
+	}
+	return n.Float
+}
+
+func (n Number) String() string {
+	switch n.Kind {
+	case KindInt:
+		return fmt.Sprint(n.Int)
+	case KindBool:
+		return fmt.Sprint(n.Bool)
+	case KindString:
+		return n.Str
+	case KindList:
+		return n.listString(Number.String)
+	case KindMap:
+		return n.mapString(Number.String)
+	case KindNil:
+		return lexer.Symbols[lexer.TypeNil]
+	case KindFunc:
+		return n.funcString()
+	}
+	return fmt.Sprint(n.Float)
+}
+
+// maxExactInt is the largest integer below which all integers are exact
+// float64 values
+const maxExactInt = 1 << 53
+
+// EvalNumber evaluates node like Eval, using integer arithmetic as long as
+// the operands are integers: a float is only involved by a float literal, a
+// builtin call or an operation whose result is not an integer, like an
+// inexact division or an overflow. The environment holding floats,
+// variables are integers when their values are, up to 2^53.
+func EvalNumber(node lexer.IExpression) (Number, error) {
+	return (&numberEval{}).eval(node)
+}
+
+type numberEval struct {
+	// visit is called before evaluating each node, trace after each
+	// reduction and leave after evaluating each node, returning an error
+	// aborts the evaluation
+	visit func(lexer.IExpression) error
+	trace Tracer
+	leave func(lexer.IExpression, Number) error
+}
+
+func (e *numberEval) eval(node lexer.IExpression) (Number, error) {
+	if err := e.enter(node); err != nil {
+		return Number{}, err
+	}
+	v, err := e.evalNode(node)
+	if err == nil && e.leave != nil {
+		if err = e.leave(node, v); err != nil {
+			return Number{}, aborted{err}
+		}
+	}
+	return v, err
+}
+
+// enter node, calling the visit hook
+func (e *numberEval) enter(node lexer.IExpression) error {
+	if e.visit != nil {
+		if err := e.visit(node); err != nil {
+			return aborted{err}
+		}
+	}
+	return nil
+}
+
+func (e *numberEval) evalNode(node lexer.IExpression) (Number, error) {
+	// the cases call methods to keep the frame of the recursion small
+	switch n := node.(type) {
+	case lexer.TokenInt:
+		return IntNumber(n.Value.(int64)), nil
+	case lexer.TokenBool:
+		return BoolNumber(n.Value.(bool)), nil
+	case lexer.TokenString:
+		return StringNumber(n.Value.(string)), nil
+	case lexer.TokenNil:
+		return NilNumber(), nil
+	case parser.VarNode:
+		return e.variable(node, n)
+	case parser.ListNode:
+		return e.list(n)
+	case parser.MapNode:
+		return e.mapValue(n)
+	case parser.RangeNode:
+		return e.rangeList(n)
+	case parser.CoalesceNode:
+		return e.coalesce(n)
+	case parser.PercentNode:
+		return e.percent(n)
+	case parser.TryNode:
+		return e.try(n)
+	case parser.MatchNode:
+		return e.match(n)
+	case parser.ImportNode:
+		if _, err := e.eval(n.Program); err != nil {
+			return Number{}, err
+		}
+		return IntNumber(0), nil
+	case parser.IndexNode:
+		return e.index(n)
+	case parser.BinOpNode:
+		if op, ok := n.Op.(lexer.Logical); ok {
+			return e.logical(n, op)
+		}
+		return e.binOp(node, n)
+	case parser.UnaryOpNode:
+		return e.unaryOp(node, n)
+	case parser.CallNode:
+		return e.callExpr(node, n)
+	case parser.AssignNode:
+		return e.assign(n)
+	case parser.SeqNode:
+		return e.seq(n.Items)
+	case parser.ProgramNode:
+		return e.seq(n.Statements)
+	case parser.WhileNode:
+		return e.while(n)
+	case parser.ForNode:
+		return e.loop(n)
+	case parser.DefNode:
+		if _, err := n.Eval(); err != nil {
+			return Number{}, err
+		}
+		return StringNumber(n.Signature()), nil
+	case parser.LetNode:
+		return e.let(n)
+	case parser.LambdaNode:
+		return FuncNumber(n.Closure().Func()), nil
+	}
+	v, err := node.Eval()
+	return FloatNumber(v), err
+}
+
+// variable n, the node traced
+func (e *numberEval) variable(node lexer.IExpression, n parser.VarNode) (Number, error) {
+	if f, ok := n.Env[n.Name()]; ok {
+		v := exact(f)
+		return v, e.step(node, v)
+	}
+	if value, ok := n.Values[n.Name()]; ok {
+		v := NilNumber()
+		switch value := value.(type) {
+		case Number:
+			v = value
+		case BigNumber:
+			v = value.number()
+		case RatNumber:
+			v = value.number()
+		}
+		return v, e.step(node, v)
+	}
+	if f, ok := n.Funcs[n.Name()]; ok {
+		v := FuncNumber(f)
+		return v, e.step(node, v)
+	}
+	_, err := n.Eval()
+	return Number{}, err
+}
+
+// binOp applies the operation of n, which is not logical, to its operands
+func (e *numberEval) binOp(node lexer.IExpression, n parser.BinOpNode) (Number, error) {
+	left, err := e.eval(n.Left)
+	if err != nil {
+		return Number{}, err
+	}
+	right, err := e.eval(n.Right)
+	if err != nil {
+		return Number{}, err
+	}
+	v, err := apply(n.Op, left, right)
+	if err != nil {
+		return Number{}, err
+	}
+	return v, e.step(node, v, left, right)
+}
+
+// unaryOp applies the prefix operator of n to its operand
+func (e *numberEval) unaryOp(node lexer.IExpression, n parser.UnaryOpNode) (Number, error) {
+	operand, err := e.eval(n.Operand)
+	if err != nil {
+		return Number{}, err
+	}
+	var v Number
+	if _, ok := n.Op.(lexer.TokenNot); ok {
+		var b bool
+		b, err = boolean(n.Op, operand)
+		v = BoolNumber(!b)
+	} else {
+		v, err = apply(n.Op, IntNumber(0), operand)
+	}
+	if err != nil {
+		return Number{}, err
+	}
+	return v, e.step(node, v, operand)
+}
+
+// callExpr evaluates the call n
+func (e *numberEval) callExpr(node lexer.IExpression, n parser.CallNode) (Number, error) {
+	if v, ok, err := e.valueCall(n); ok {
+		return v, err
+	}
+	operands, err := e.operands(n)
+	if err != nil {
+		return Number{}, err
+	}
+	v, err := e.callNode(n, operands)
+	if err != nil {
+		return Number{}, err
+	}
+	return v, e.step(node, v, operands...)
+}
+
+// assign the value of n to its variable
+func (e *numberEval) assign(n parser.AssignNode) (Number, error) {
+	v, err := e.eval(n.Value)
+	if err != nil {
+		return Number{}, err
+	}
+	if v.Kind == KindFunc && n.Funcs != nil {
+		delete(n.Env, n.Name)
+		delete(n.Values, n.Name)
+		n.Funcs[n.Name] = *v.Func
+		return v, nil
+	}
+	if !v.Scalar() {
+		if n.Values == nil || v.Kind == KindFunc {
+			return Number{}, fmt.Errorf("cannot assign a %v to %v at %v", v.Kind, n.Name, n.NamePos)
+		}
+		delete(n.Env, n.Name)
+		n.Values[n.Name] = v.value()
+		return v, nil
+	}
+	n.Env[n.Name] = v.Float64()
+	delete(n.Values, n.Name)
+	return v, nil
+}
+
+// seq evaluates items in order, yielding the value of the last one
+func (e *numberEval) seq(items []lexer.IExpression) (Number, error) {
+	var v Number
+	for _, item := range items {
+		var err error
+		if v, err = e.eval(item); err != nil {
+			return Number{}, err
+		}
+	}
+	return v, nil
+}
+
+// while evaluates the body of n as long as its condition holds
+func (e *numberEval) while(n parser.WhileNode) (Number, error) {
+	v := IntNumber(0)
+	for {
+		cond, err := e.eval(n.Cond)
+		if err != nil {
+			return Number{}, err
+		}
+		if !cond.Scalar() {
+			return Number{}, n.Locate(fmt.Errorf("while needs a boolean or a number, got a %v%v", cond.Kind, n.At()))
+		}
+		if cond.Float64() == 0 {
+			return v, nil
+		}
+		if v, err = e.eval(n.Body); err != nil {
+			return Number{}, err
+		}
+	}
+}
+
+// try evaluates the body of n, or its fallback if the body fails
+func (e *numberEval) try(n parser.TryNode) (Number, error) {
+	v, err := e.eval(n.Body)
+	if err == nil || errors.As(err, new(aborted)) {
+		return v, err
+	}
+	return e.eval(n.Catch)
+}
+
+// let evaluates the body of n with its variable bound
+func (e *numberEval) let(n parser.LetNode) (Number, error) {
+	v, err := e.eval(n.Value)
+	if err != nil {
+		return Number{}, err
+	}
+	defer bind(n, v)()
+	return e.eval(n.Body)
+}
+
+// bind the variable of n to v, returning the function restoring its
+// previous value
+func bind(n parser.LetNode, v Number) func() {
+	if !v.Scalar() && n.Values != nil {
+		return n.BindValue(v.value())
+	}
+	return n.Bind(v.Float64())
+}
+
+// operands evaluates the arguments of the call n
+func (e *numberEval) operands(n parser.CallNode) ([]Number, error) {
+	operands := make([]Number, 0, len(n.Args))
+	for _, arg := range n.Args {
+		v, err := e.eval(arg)
+		if err != nil {
+			return nil, err
+		}
+		if err := operand(n, arg, v); err != nil {
+			return nil, err
+		}
+		operands = append(operands, v)
+	}
+	return operands, nil
+}
+
+// operand checks v, the value of the argument arg of n, can be passed to a
+// function
+func operand(n parser.CallNode, arg lexer.IExpression, v Number) error {
+	switch v.Kind {
+	case KindString:
+		return fmt.Errorf("%v expects numbers, got a string at %v", n.Name(), lexer.SpanOf(arg).Start)
+	case KindNil:
+		return fmt.Errorf("%w, %v expects numbers at %v", lexer.ErrNil, n.Name(), lexer.SpanOf(arg).Start)
+	}
+	return nil
+}
+
+// callNode calls the function of n with the values of its arguments, the
+// caller tracing the step
+func (e *numberEval) callNode(n parser.CallNode, operands []Number) (Number, error) {
+	if slices.ContainsFunc(operands, func(v Number) bool { return v.Kind == KindFunc }) {
+		return e.callFuncs(n, operands)
+	}
+	if v, ok, err := e.mapCall(n, operands); ok {
+		return v, err
+	}
+	// a function of one number maps a list to the list of its values,
+	// the other functions taking the items of lists as arguments
+	if len(operands) == 1 && operands[0].Kind == KindList {
+		if f, err := n.Resolve(1); err == nil && f.MaxArgs == 1 {
+			return e.mapped(n, f, operands[0])
+		}
+	}
+	args, err := args(n, operands)
+	if err != nil {
+		return Number{}, err
+	}
+	f, err := n.Resolve(len(args))
+	if err != nil {
+		return Number{}, err
+	}
+	return e.invoke(f, args)
+}
+
+// call the function of c, evaluating its body like any other node, with
+// the parameters named in funcs bound to these functions. The calls of
+// closures it ends with are made once it is left so that tail recursion does
+// not nest, unless the steps are traced, needing the value of each call.
+func (e *numberEval) call(c *parser.Closure, args []float64, funcs parser.Funcs) (Number, error) {
+	for {
+		body, err := c.EnterFuncs(args, funcs)
+		if err != nil {
+			c.Leave()
+			return Number{}, err
+		}
+		v, next, err := e.tail(body)
+		c.Leave()
+		if err != nil || next == nil {
+			return v, err
+		}
+		c, args, funcs = next.Closure, next.Args, nil
+	}
+}
+
+// tail evaluates node like eval, but for the call of a closure it ends with,
+// returned instead of being made
+func (e *numberEval) tail(node lexer.IExpression) (Number, *parser.TailCall, error) {
+	if e.trace != nil || e.leave != nil {
+		v, err := e.eval(node)
+		return v, nil, err
+	}
+	switch n := node.(type) {
+	case parser.CallNode:
+		c := n.TailClosure()
+		if c == nil {
+			break
+		}
+		if err := e.enter(n); err != nil {
+			return Number{}, nil, err
+		}
+		operands, err := e.operands(n)
+		if err != nil {
+			return Number{}, nil, err
+		}
+		if !slices.ContainsFunc(operands, func(v Number) bool { return !v.Scalar() }) {
+			args, err := args(n, operands)
+			return Number{}, &parser.TailCall{Closure: c, Args: args}, err
+		}
+		v, err := e.callNode(n, operands)
+		return v, nil, err
+	case parser.MatchNode:
+		if err := e.enter(n); err != nil {
+			return Number{}, nil, err
+		}
+		arm, _, err := e.arm(n)
+		if err != nil {
+			return Number{}, nil, err
+		}
+		return e.tail(arm)
+	case parser.SeqNode:
+		return e.tailSeq(n, n.Items)
+	case parser.ProgramNode:
+		return e.tailSeq(n, n.Statements)
+	case parser.LetNode:
+		if err := e.enter(n); err != nil {
+			return Number{}, nil, err
+		}
+		v, err := e.eval(n.Value)
+		if err != nil {
+			return Number{}, nil, err
+		}
+		defer bind(n, v)()
+		return e.tail(n.Body)
+	}
+	v, err := e.eval(node)
+	return v, nil, err
+}
+
+// tailSeq evaluates the items of node in order, the last one with tail
+func (e *numberEval) tailSeq(node lexer.IExpression, items []lexer.IExpression) (Number, *parser.TailCall, error) {
+	if err := e.enter(node); err != nil || len(items) == 0 {
+		return IntNumber(0), nil, err
+	}
+	for _, item := range items[:len(items)-1] {
+		if _, err := e.eval(item); err != nil {
+			return Number{}, nil, err
+		}
+	}
+	return e.tail(items[len(items)-1])
+}
+
+// loop evaluates the body of n for each value of its variable
+func (e *numberEval) loop(n parser.ForNode) (Number, error) {
+	bounds := []lexer.IExpression{n.Start, n.End, n.By()}
+	values := make([]float64, len(bounds))
+	for i, bound := range bounds {
+		v, err := e.eval(bound)
+		if err != nil {
+			return Number{}, err
+		}
+		if !v.Scalar() {
+			return Number{}, n.Locate(fmt.Errorf("for needs numbers, got a %v at %v", v.Kind, lexer.SpanOf(bound).Start))
+		}
+		values[i] = v.Float64()
+	}
+	start, end, step := values[0], values[1], values[2]
+	if err := n.CheckStep(step); err != nil {
+		return Number{}, err
+	}
+	v := IntNumber(0)
+	for i := start; parser.Within(i, end, step); i += step {
+		n.Env[n.Var] = i
+		var err error
+		if v, err = e.eval(n.Body); err != nil {
+			return Number{}, err
+		}
+	}
+	return v, nil
+}
+
+// logical evaluates the right operand of n only if the left one does not
+// decide the result of op
+func (e *numberEval) logical(n parser.BinOpNode, op lexer.Logical) (Number, error) {
+	l, err := e.eval(n.Left)
+	if err != nil {
+		return Number{}, err
+	}
+	b, err := boolean(op, l)
+	if err != nil {
+		return Number{}, err
+	}
+	if op.Decides(b) {
+		return BoolNumber(b), e.step(n, BoolNumber(b), l)
+	}
+	r, err := e.eval(n.Right)
+	if err != nil {
+		return Number{}, err
+	}
+	if b, err = boolean(op, r); err != nil {
+		return Number{}, err
+	}
+	return BoolNumber(b), e.step(n, BoolNumber(b), l, r)
+}
+
+// boolean value of an operand of op
+func boolean(op lexer.Operation, n Number) (bool, error) {
+	if n.Kind == KindNil {
+		return false, locate(op, fmt.Errorf("%w, %v needs booleans%v", lexer.ErrNil, op, at(op)))
+	}
+	if n.Kind != KindBool {
+		return false, locate(op, fmt.Errorf("%v needs booleans, got %v%v", op, n.Kind, at(op)))
+	}
+	return n.Bool, nil
+}
+
+// apply op to integers if both operands are, falling back to floats.
+// Booleans can only be compared for equality and strings concatenated with
+// + and compared. Lists apply op to their items, maps to none. Nil can only
+// be compared for equality.
+func apply(op lexer.Operation, left, right Number) (Number, error) {
+	if left.Kind == KindNil || right.Kind == KindNil {
+		return nilOperand(op, left, right)
+	}
+	if left.Kind == KindMap || right.Kind == KindMap || left.Kind == KindFunc || right.Kind == KindFunc {
+		return Number{}, locate(op, fmt.Errorf("cannot apply %v to %v and %v%v", op, left.Kind, right.Kind, at(op)))
+	}
+	if left.Kind == KindList || right.Kind == KindList {
+		return elementwise(op, left, right)
+	}
+	if c, ok := op.(lexer.Comparison); ok {
+		return compare(c, left, right)
+	}
+	if left.Kind == KindString && right.Kind == KindString && parser.OpType(op) == lexer.TypePlus {
+		return StringNumber(left.Str + right.Str), nil
+	}
+	if left.Kind == KindBool || right.Kind == KindBool || left.Kind == KindString || right.Kind == KindString {
+		return Number{}, locate(op, fmt.Errorf("cannot apply %v to %v and %v%v", op, left.Kind, right.Kind, at(op)))
+	}
+	if intOp, ok := op.(lexer.IntOperation); ok && left.Kind == KindInt && right.Kind == KindInt {
+		v, ok, err := intOp.EvalInt(left.Int, right.Int)
+		if err != nil {
+			return Number{}, err
+		}
+		if ok {
+			return IntNumber(v), nil
+		}
+	}
+	v, err := floatOp(op, left.Float64(), right.Float64())
+	if w, ok := op.(parser.WrappedOp); ok && err == nil && !math.IsNaN(v) && !math.IsInf(v, 0) {
+		// the results of the fixed width operations are integers
+		return IntNumber(w.Width.WrapFloat(v)), nil
+	}
+	return FloatNumber(v), err
+}
+
+// floatOp applies op to l and r, the plain float operators without boxing
+// the operands
+func floatOp(op lexer.Operation, l, r float64) (float64, error) {
+	switch op := op.(type) {
+	case lexer.TokenPlus:
+		return l + r, nil
+	case lexer.TokenMinus:
+		return l - r, nil
+	case lexer.TokenMul:
+		return l * r, nil
+	case lexer.TokenDiv:
+		return op.Divide(l, r)
+	case lexer.TokenPow:
+		return math.Pow(l, r), nil
+	}
+	return op.Eval(lexer.NewTokenFloat(l), lexer.NewTokenFloat(r))
+}
+
+// compare integers exactly, even beyond 2^53, and strings
+// lexicographically
+func compare(c lexer.Comparison, left, right Number) (Number, error) {
+	if (left.Kind == KindBool) != (right.Kind == KindBool) || (left.Kind == KindString) != (right.Kind == KindString) {
+		return Number{}, locate(c, fmt.Errorf("cannot compare %v and %v with %v%v", left.Kind, right.Kind, c, at(c)))
+	}
+	switch {
+	case left.Kind == KindString:
+		return BoolNumber(c.Holds(strings.Compare(left.Str, right.Str))), nil
+	case left.Kind == KindBool:
+		// only == and != hold the same for both orders of unequal operands
+		if c.Holds(-1) != c.Holds(1) {
+			return Number{}, locate(c, fmt.Errorf("cannot order booleans with %v%v", c, at(c)))
+		}
+		if left.Bool == right.Bool {
+			return BoolNumber(c.Holds(0)), nil
+		}
+		return BoolNumber(c.Holds(1)), nil
+	case left.Kind == KindInt && right.Kind == KindInt:
+		if _, ok := c.(parser.UnsignedComparison); ok {
+			return BoolNumber(c.Holds(cmp.Compare(uint64(left.Int), uint64(right.Int)))), nil
+		}
+		return BoolNumber(c.Holds(cmp.Compare(left.Int, right.Int))), nil
+	}
+	return BoolNumber(c.Holds(lexer.Order(left.Float64(), right.Float64()))), nil
+}
+
+// Scalar tells if n is a number or a boolean
+func (n Number) Scalar() bool {
+	return n.Kind == KindInt || n.Kind == KindFloat || n.Kind == KindBool
+}
+
+// at describes the position of op for error messages
+func at(op lexer.Operation) string {
+	if t, ok := op.(interface{ At() string }); ok {
+		return t.At()
+	}
+	return ""
+}
+
+// locate err at the span of op, if it is known
+func locate(op lexer.Operation, err error) error {
+	if t, ok := op.(interface{ Locate(error) error }); ok {
+		return t.Locate(err)
+	}
+	return err
+}
@@ -0,0 +1,36 @@
+package eval
+
+import "github.com/fmarmol/lexp/parser"
+
+// percent computes the hundredths of the base of n like the integers and
+// the lists are computed, the parts of integers being integers when exact
+func (e *numberEval) percent(n parser.PercentNode) (Number, error) {
+	operands := make([]Number, 0, 2)
+	for _, child := range parser.Children(n) {
+		v, err := e.eval(child)
+		if err != nil {
+			return Number{}, err
+		}
+		operands = append(operands, v)
+	}
+	base, percent := IntNumber(1), operands[0]
+	if n.Relative() {
+		base, percent = operands[0], operands[1]
+	} else if n.Base != nil {
+		base = operands[1]
+	}
+	part, err := apply(n.Mul(), base, percent)
+	if err == nil {
+		part, err = apply(n.Div(), part, IntNumber(100))
+	}
+	if err != nil {
+		return Number{}, err
+	}
+	v := part
+	if n.Relative() {
+		if v, err = apply(n.Op, base, part); err != nil {
+			return Number{}, err
+		}
+	}
+	return v, e.step(n, v, operands...)
+}
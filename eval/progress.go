@@ -0,0 +1,72 @@
+package eval
+
+import "github.com/fmarmol/lexp/lexer"
+
+// Progress of an evaluation
+type Progress struct {
+	Nodes int               // number of nodes evaluated so far
+	Node  lexer.IExpression // node being evaluated
+}
+
+// Observer of an evaluation, returning an error aborts it
+type Observer func(Progress) error
+
+// EvalWithProgress evaluates node like Eval, calling observer every interval
+// nodes
+func EvalWithProgress(node lexer.IExpression, observer Observer, interval int) (float64, error) {
+	v, err := EvalNumberWithProgress(node, observer, interval)
+	return v.Float64(), err
+}
+
+// EvalNumberWithProgress evaluates node like EvalNumber, calling observer
+// every interval nodes
+func EvalNumberWithProgress(node lexer.IExpression, observer Observer, interval int) (Number, error) {
+	return (&numberEval{progress(observer, interval), nil, nil}).eval(node)
+}
+
+// EvalRatWithProgress evaluates node like EvalRat, calling observer every
+// interval nodes
+func EvalRatWithProgress(node lexer.IExpression, observer Observer, interval int) (RatNumber, error) {
+	return (&ratEval{map[string]RatNumber{}, nil, progress(observer, interval), nil}).value(node)
+}
+
+// EvalDecimalWithProgress evaluates node like EvalDecimal, calling observer
+// every interval nodes
+func EvalDecimalWithProgress(node lexer.IExpression, d Decimal, observer Observer, interval int) (DecimalNumber, error) {
+	r, err := (&ratEval{map[string]RatNumber{}, d.Round, progress(observer, interval), nil}).eval(node)
+	return DecimalNumber{r, d.Places}, err
+}
+
+// EvalBigWithProgress evaluates node like EvalBig, calling observer every
+// interval nodes
+func EvalBigWithProgress(node lexer.IExpression, prec uint, observer Observer, interval int) (BigNumber, error) {
+	return bigEval{prec, progress(observer, interval)}.run(node)
+}
+
+// progress returns the visitor counting the nodes evaluated, calling
+// observer every interval nodes
+func progress(observer Observer, interval int) func(lexer.IExpression) error {
+	interval = max(interval, 1)
+	nodes := 0
+	return func(node lexer.IExpression) error {
+		nodes++
+		if nodes%interval == 0 {
+			return observer(Progress{nodes, node})
+		}
+		return nil
+	}
+}
+
+// Hooks of an evaluation, the nil ones being ignored. Enter is called before
+// evaluating each node and Leave after, with its value. Returning an error
+// aborts the evaluation.
+type Hooks struct {
+	Enter func(node lexer.IExpression) error
+	Leave func(node lexer.IExpression, v Number) error
+}
+
+// EvalNumberWithHooks evaluates node like EvalNumber, calling hooks around
+// the evaluation of each node
+func EvalNumberWithHooks(node lexer.IExpression, hooks Hooks) (Number, error) {
+	return (&numberEval{hooks.Enter, nil, hooks.Leave}).eval(node)
+}
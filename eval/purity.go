@@ -0,0 +1,104 @@
+package eval
+
+import (
+	"github.com/fmarmol/lexp/lexer"
+	"github.com/fmarmol/lexp/parser"
+)
+
+// Purity of an expression
+type Purity struct {
+	Pure          bool // evaluation has no side effects
+	Deterministic bool // evaluation always yields the same result
+}
+
+// Cacheable tells if the result of the expression can be safely reused
+func (p Purity) Cacheable() bool { return p.Pure && p.Deterministic }
+
+func (p Purity) and(o Purity) Purity {
+	return Purity{p.Pure && o.Pure, p.Deterministic && o.Deterministic}
+}
+
+// AnalyzePurity reports whether node is pure and deterministic. The calls of
+// the functions registered as impure, or not defined yet, are not
+// deterministic, and those of the closures are as deterministic as their
+// body.
+func AnalyzePurity(node lexer.IExpression) Purity {
+	return purity{}.analyze(node)
+}
+
+// purity analyzes the expressions, holding the closures being analyzed so
+// that the recursive ones are analyzed once
+type purity map[*parser.Closure]bool
+
+func (a purity) analyze(node lexer.IExpression) Purity {
+	switch n := node.(type) {
+	case parser.BinOpNode:
+		return a.analyze(n.Left).and(a.analyze(n.Right))
+	case parser.UnaryOpNode:
+		return a.analyze(n.Operand)
+	case parser.CallNode:
+		p := a.call(n)
+		for _, arg := range n.Args {
+			p = p.and(a.analyze(arg))
+		}
+		return p
+	case parser.AssignNode:
+		// binding a variable is a side effect
+		return Purity{false, a.analyze(n.Value).Deterministic}
+	case parser.SeqNode:
+		p := Purity{true, true}
+		for _, item := range n.Items {
+			p = p.and(a.analyze(item))
+		}
+		return p
+	case parser.ProgramNode:
+		return a.analyze(parser.SeqNode{Items: n.Statements})
+	case parser.WhileNode:
+		return a.analyze(n.Cond).and(a.analyze(n.Body))
+	case parser.ForNode:
+		// the loop variable is assigned
+		p := a.analyze(parser.SeqNode{Items: parser.Children(n)})
+		return Purity{false, p.Deterministic}
+	case parser.DefNode:
+		// defining a function is a side effect
+		return Purity{false, true}
+	case parser.ListNode:
+		return a.analyze(parser.SeqNode{Items: n.Items})
+	case parser.MapNode:
+		return a.analyze(parser.SeqNode{Items: n.Items})
+	case parser.TryNode:
+		return a.analyze(n.Body).and(a.analyze(n.Catch))
+	case parser.MatchNode:
+		return a.analyze(parser.SeqNode{Items: parser.Children(n)})
+	case parser.ImportNode:
+		// importing defines the functions and the variables of the file
+		return Purity{false, a.analyze(n.Program).Deterministic}
+	case parser.CoalesceNode:
+		return a.analyze(n.Left).and(a.analyze(n.Right))
+	case parser.RangeNode:
+		return a.analyze(parser.SeqNode{Items: parser.Children(n)})
+	case parser.PercentNode:
+		return a.analyze(parser.SeqNode{Items: parser.Children(n)})
+	case parser.IndexNode:
+		return a.analyze(n.Target).and(a.analyze(n.Index))
+	case parser.LetNode:
+		// the variable is restored once the body is evaluated
+		return a.analyze(n.Value).and(a.analyze(n.Body))
+	}
+	// literals and arithmetic operators are always pure
+	return Purity{true, true}
+}
+
+// call analyzes the function n calls, its arguments aside
+func (a purity) call(n parser.CallNode) Purity {
+	f, ok := n.Funcs[n.Name()]
+	switch {
+	case !ok || f.Impure:
+		return Purity{false, false}
+	case f.Closure == nil || a[f.Closure]:
+		return Purity{true, true}
+	}
+	a[f.Closure] = true
+	// the assignments of the body are local to the call
+	return Purity{true, a.analyze(f.Closure.Lambda.Body).Deterministic}
+}
@@ -0,0 +1,516 @@
+package eval
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/fmarmol/lexp/lexer"
+	"github.com/fmarmol/lexp/parser"
+)
+
+// RatNumber is the result of an exact rational evaluation, Rat being set
+// for numbers, Items for lists and Value for the strings, maps and nil
+type RatNumber struct {
+	Rat   *big.Rat
+	Items []RatNumber
+	Value *Number
+}
+
+// ratList of items, never nil so that it is a list
+func ratList(items []RatNumber) RatNumber {
+	if items == nil {
+		items = []RatNumber{}
+	}
+	return RatNumber{Items: items}
+}
+
+// Float64 value of n, rounded to the nearest float64
+func (n RatNumber) Float64() float64 {
+	if n.Rat == nil {
+		return n.number().Float64()
+	}
+	f, _ := n.Rat.Float64()
+	return f
+}
+
+func (n RatNumber) String() string {
+	switch {
+	case n.Value != nil:
+		return n.Value.String()
+	case n.Items != nil:
+		items := make([]string, len(n.Items))
+		for i, item := range n.Items {
+			items[i] = item.String()
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	}
+	return n.Rat.RatString()
+}
+
+// Scalar tells if n is a number
+func (n RatNumber) Scalar() bool { return n.Rat != nil }
+
+// number converts n, rounding its rationals to int64 or float64
+func (n RatNumber) number() Number {
+	switch {
+	case n.Value != nil:
+		return *n.Value
+	case n.Items != nil:
+		items := make([]Number, len(n.Items))
+		for i, item := range n.Items {
+			items[i] = item.number()
+		}
+		return ListNumber(items)
+	case n.Rat.IsInt() && n.Rat.Num().IsInt64():
+		return IntNumber(n.Rat.Num().Int64())
+	}
+	return FloatNumber(n.Float64())
+}
+
+// ratFromNumber converts v, its floats through their shortest decimal
+// representation
+func ratFromNumber(v Number) (RatNumber, error) {
+	switch v.Kind {
+	case KindInt:
+		return RatNumber{Rat: new(big.Rat).SetInt64(v.Int)}, nil
+	case KindFloat:
+		r, err := ratFromFloat64(v.Float)
+		return RatNumber{Rat: r}, err
+	case KindBool:
+		return RatNumber{Rat: ratBool(v.Bool)}, nil
+	case KindList:
+		items := make([]RatNumber, len(v.List))
+		for i, item := range v.List {
+			var err error
+			if items[i], err = ratFromNumber(item); err != nil {
+				return RatNumber{}, err
+			}
+		}
+		return ratList(items), nil
+	}
+	return RatNumber{Value: &v}, nil
+}
+
+// EvalRat evaluates node with exact rationals, so that 1/3 + 1/3 + 1/3 is 1.
+// Float literals are read as the decimal they are written with, 0.1 being
+// 1/10. The variables assigned and the parameters of the functions keep
+// their exact value, in the parser.Values of the variables. Lists hold exact
+// rationals. Operations without an exact result,
+// like most builtin calls or non integer powers, are computed as float64 and
+// fail if the result is not finite, and so are the strings, maps and nil.
+// Comparisons and logical operators yield 1 or 0.
+func EvalRat(node lexer.IExpression) (RatNumber, error) {
+	return (&ratEval{map[string]RatNumber{}, nil, nil, nil}).value(node)
+}
+
+type ratEval struct {
+	// assigned holds the exact values of the variables assigned so far
+	assigned map[string]RatNumber
+	// round the results of binary operations and calls, nil to keep them
+	// exact
+	round func(*big.Rat) *big.Rat
+	// visit is called before evaluating each node, returning an error
+	// aborts the evaluation
+	visit func(lexer.IExpression) error
+	// global holds the variables assigned outside of the functions, which
+	// their calls see, nil outside of them
+	global map[string]RatNumber
+}
+
+// eval evaluates node, which must be a number
+func (e *ratEval) eval(node lexer.IExpression) (*big.Rat, error) {
+	v, err := e.value(node)
+	if err != nil || v.Rat != nil {
+		return v.Rat, err
+	}
+	return nil, notNumber(node, v)
+}
+
+// notNumber reports v, the value of node, used as a number
+func notNumber(node lexer.IExpression, v RatNumber) error {
+	err := fmt.Errorf("a %v is not a number", v.Value.Kind)
+	if v.Items != nil {
+		err = parser.ErrListValue
+	}
+	span := lexer.SpanOf(node)
+	if span.Start.Line == 0 {
+		return err
+	}
+	return lexer.SpanError{Span: span, Err: fmt.Errorf("%w at %v", err, span.Start)}
+}
+
+// value evaluates node, which may be a list, a string, a map or nil
+func (e *ratEval) value(node lexer.IExpression) (RatNumber, error) {
+	if err := e.enter(node); err != nil {
+		return RatNumber{}, err
+	}
+	switch n := node.(type) {
+	case parser.VarNode:
+		if v, ok := e.assigned[n.Name()]; ok {
+			return v, nil
+		}
+		if _, ok := n.Env[n.Name()]; !ok {
+			if v, ok := n.Values[n.Name()].(RatNumber); ok {
+				return v, nil
+			}
+		}
+	case lexer.TokenInt, lexer.TokenFloat, parser.WhileNode, parser.ForNode:
+		r, err := e.evalNode(node)
+		return RatNumber{Rat: r}, err
+	case parser.ListNode:
+		return e.list(n)
+	case parser.RangeNode:
+		return e.rangeList(n)
+	case parser.IndexNode:
+		return e.index(n)
+	case parser.BinOpNode:
+		return e.binOp(n)
+	case parser.UnaryOpNode:
+		return e.unaryOp(n)
+	case parser.CallNode:
+		return e.call(n)
+	case parser.AssignNode:
+		return e.assign(n)
+	case parser.SeqNode:
+		return e.seq(n.Items)
+	case parser.ProgramNode:
+		return e.seq(n.Statements)
+	case parser.LetNode:
+		return e.let(n)
+	case parser.TryNode:
+		if v, err := e.value(n.Body); err == nil || errors.As(err, new(aborted)) {
+			return v, err
+		}
+		return e.value(n.Catch)
+	case parser.MatchNode:
+		arm, err := n.Arm()
+		if err != nil {
+			return RatNumber{}, err
+		}
+		return e.value(arm)
+	}
+	v, err := (&numberEval{e.visit, nil, nil}).evalNode(node)
+	if err != nil {
+		return RatNumber{}, err
+	}
+	return ratFromNumber(v)
+}
+
+// enter node, calling visit
+func (e *ratEval) enter(node lexer.IExpression) error {
+	if e.visit == nil {
+		return nil
+	}
+	if err := e.visit(node); err != nil {
+		return aborted{err}
+	}
+	return nil
+}
+
+// evalNode evaluates the numbers and the loops
+func (e *ratEval) evalNode(node lexer.IExpression) (*big.Rat, error) {
+	switch n := node.(type) {
+	case lexer.TokenInt:
+		return new(big.Rat).SetInt64(n.Value.(int64)), nil
+	case parser.WhileNode:
+		v := new(big.Rat)
+		for {
+			cond, err := e.eval(n.Cond)
+			if err != nil || cond.Sign() == 0 {
+				return v, err
+			}
+			if v, err = e.eval(n.Body); err != nil {
+				return nil, err
+			}
+		}
+	case parser.ForNode:
+		return e.loop(n)
+	}
+	v, err := node.Eval()
+	if err != nil {
+		return nil, err
+	}
+	return ratFromFloat64(v)
+}
+
+// binOp applies the operation of n to its operands
+func (e *ratEval) binOp(n parser.BinOpNode) (RatNumber, error) {
+	if op, ok := n.Op.(lexer.Logical); ok {
+		r, err := e.logical(op, n.Left, n.Right)
+		return RatNumber{Rat: r}, err
+	}
+	left, err := e.value(n.Left)
+	if err != nil {
+		return RatNumber{}, err
+	}
+	right, err := e.value(n.Right)
+	if err != nil {
+		return RatNumber{}, err
+	}
+	return e.applyValues(n.Op, left, right)
+}
+
+// unaryOp applies the operation of n to its operand
+func (e *ratEval) unaryOp(n parser.UnaryOpNode) (RatNumber, error) {
+	operand, err := e.value(n.Operand)
+	if err != nil {
+		return RatNumber{}, err
+	}
+	if _, ok := n.Op.(lexer.TokenNot); !ok {
+		return e.applyValues(n.Op, RatNumber{Rat: new(big.Rat)}, operand)
+	}
+	if operand.Rat == nil {
+		_, err := boolean(n.Op, operand.number())
+		return RatNumber{}, err
+	}
+	return RatNumber{Rat: ratBool(operand.Rat.Sign() == 0)}, nil
+}
+
+// applyValues applies op to rationals exactly and to the items of lists,
+// the other operands like EvalNumber
+func (e *ratEval) applyValues(op lexer.Operation, left, right RatNumber) (RatNumber, error) {
+	switch {
+	case left.Rat != nil && right.Rat != nil:
+		r, err := e.rounded(e.apply(op, left.Rat, right.Rat))
+		return RatNumber{Rat: r}, err
+	case left.Value != nil || right.Value != nil:
+		v, err := apply(op, left.number(), right.number())
+		if err != nil {
+			return RatNumber{}, err
+		}
+		return ratFromNumber(v)
+	case left.Items != nil && right.Items != nil && len(left.Items) != len(right.Items):
+		_, err := elementwise(op, left.number(), right.number())
+		return RatNumber{}, err
+	}
+	n := len(left.Items)
+	if left.Items == nil {
+		n = len(right.Items)
+	}
+	items := make([]RatNumber, n)
+	for i := range items {
+		l, r := left, right
+		if l.Items != nil {
+			l = left.Items[i]
+		}
+		if r.Items != nil {
+			r = right.Items[i]
+		}
+		var err error
+		if items[i], err = e.applyValues(op, l, r); err != nil {
+			return RatNumber{}, err
+		}
+	}
+	return ratList(items), nil
+}
+
+// assign the value of n to its variable
+func (e *ratEval) assign(n parser.AssignNode) (RatNumber, error) {
+	v, err := e.value(n.Value)
+	if err != nil {
+		return RatNumber{}, err
+	}
+	switch {
+	case v.isFunc() && n.Funcs != nil:
+		delete(n.Env, n.Name)
+		delete(n.Values, n.Name)
+		delete(e.assigned, n.Name)
+		n.Funcs[n.Name] = *v.Value.Func
+		return v, nil
+	case n.Values != nil:
+		delete(n.Env, n.Name)
+		n.Values[n.Name] = v
+	case v.Rat == nil:
+		return RatNumber{}, fmt.Errorf("cannot assign a %v to %v at %v", v.number().Kind, n.Name, n.NamePos)
+	default:
+		n.Env[n.Name], _ = v.Rat.Float64()
+	}
+	e.assigned[n.Name] = v
+	return v, nil
+}
+
+// seq evaluates items in order, yielding the value of the last one
+func (e *ratEval) seq(items []lexer.IExpression) (RatNumber, error) {
+	var v RatNumber
+	for _, item := range items {
+		var err error
+		if v, err = e.value(item); err != nil {
+			return RatNumber{}, err
+		}
+	}
+	return v, nil
+}
+
+// let evaluates the body of n with its variable bound to its value
+func (e *ratEval) let(n parser.LetNode) (RatNumber, error) {
+	v, err := e.value(n.Value)
+	if err != nil {
+		return RatNumber{}, err
+	}
+	defer e.bind(n, v)()
+	return e.value(n.Body)
+}
+
+// bind the variable of n to v, returning the function restoring its
+// previous value
+func (e *ratEval) bind(n parser.LetNode, v RatNumber) func() {
+	old, ok := e.assigned[n.Name]
+	e.assigned[n.Name] = v
+	restore := n.BindValue(v)
+	return func() {
+		restore()
+		if ok {
+			e.assigned[n.Name] = old
+		} else {
+			delete(e.assigned, n.Name)
+		}
+	}
+}
+
+// loop evaluates the body of n for each value of its variable, counting
+// exactly
+func (e *ratEval) loop(n parser.ForNode) (*big.Rat, error) {
+	i, err := e.eval(n.Start)
+	if err != nil {
+		return nil, err
+	}
+	end, err := e.eval(n.End)
+	if err != nil {
+		return nil, err
+	}
+	step, err := e.eval(n.By())
+	if err != nil {
+		return nil, err
+	}
+	if err := n.CheckStep(float64(step.Sign())); err != nil {
+		return nil, err
+	}
+	v := new(big.Rat)
+	for ; i.Cmp(end)*step.Sign() <= 0; i = new(big.Rat).Add(i, step) {
+		e.assigned[n.Var] = RatNumber{Rat: i}
+		n.Env[n.Var], _ = i.Float64()
+		if v, err = e.eval(n.Body); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+// logical evaluates right only if left does not decide the result of op,
+// non zero operands being true
+func (e *ratEval) logical(op lexer.Logical, left, right lexer.IExpression) (*big.Rat, error) {
+	l, err := e.eval(left)
+	if err != nil {
+		return nil, err
+	}
+	if op.Decides(l.Sign() != 0) {
+		return ratBool(l.Sign() != 0), nil
+	}
+	r, err := e.eval(right)
+	if err != nil {
+		return nil, err
+	}
+	return ratBool(r.Sign() != 0), nil
+}
+
+// ratBool is 1 for true and 0 for false
+func ratBool(b bool) *big.Rat {
+	return new(big.Rat).SetInt64(int64(lexer.Bool(b)))
+}
+
+func (e *ratEval) rounded(r *big.Rat, err error) (*big.Rat, error) {
+	if err != nil || e.round == nil {
+		return r, err
+	}
+	return e.round(r), nil
+}
+
+// ratFromFloat64 converts v through its shortest decimal representation
+func ratFromFloat64(v float64) (*big.Rat, error) {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return nil, fmt.Errorf("%v is not a rational number", v)
+	}
+	r, _ := new(big.Rat).SetString(strconv.FormatFloat(v, 'g', -1, 64))
+	return r, nil
+}
+
+func (e *ratEval) apply(op lexer.Operation, left, right *big.Rat) (*big.Rat, error) {
+	if _, ok := op.(parser.WrappedOp); ok {
+		return nil, ErrFixedWidthBig
+	}
+	if c, ok := op.(lexer.Comparison); ok {
+		return ratBool(c.Holds(left.Cmp(right))), nil
+	}
+	switch parser.OpType(op) {
+	case lexer.TypePlus:
+		return new(big.Rat).Add(left, right), nil
+	case lexer.TypeMinus:
+		return new(big.Rat).Sub(left, right), nil
+	case lexer.TypeMul:
+		return new(big.Rat).Mul(left, right), nil
+	case lexer.TypeDiv:
+		return e.divide(op, left, right)
+	case lexer.TypePow:
+		if right.IsInt() && right.Num().IsInt64() {
+			exp := right.Num().Int64()
+			if (left.Sign() != 0 || exp >= 0) && fitsBig(left.Num(), abs(exp)) && fitsBig(left.Denom(), abs(exp)) {
+				return ratPow(left, exp), nil
+			}
+		}
+	case lexer.TypeShl, lexer.TypeShr:
+		if left.IsInt() && right.IsInt() && right.Sign() >= 0 && right.Num().Cmp(big.NewInt(maxBigBits)) <= 0 {
+			shift := uint(right.Num().Uint64())
+			if parser.OpType(op) == lexer.TypeShl {
+				return new(big.Rat).SetInt(new(big.Int).Lsh(left.Num(), shift)), nil
+			}
+			return new(big.Rat).SetInt(new(big.Int).Rsh(left.Num(), shift)), nil
+		}
+	}
+	l, _ := left.Float64()
+	r, _ := right.Float64()
+	v, err := op.Eval(lexer.NewTokenFloat(l), lexer.NewTokenFloat(r))
+	if err != nil {
+		return nil, err
+	}
+	return ratFromFloat64(v)
+}
+
+// divide according to the division mode of op, trunc and exact only
+// applying to integers
+func (e *ratEval) divide(op lexer.Operation, left, right *big.Rat) (*big.Rat, error) {
+	if right.Sign() == 0 {
+		_, err := op.Eval(lexer.NewTokenInt(0), lexer.NewTokenInt(0))
+		return nil, err
+	}
+	q := new(big.Rat).Quo(left, right)
+	d, ok := op.(parser.DivOp)
+	if !ok || q.IsInt() || !left.IsInt() || !right.IsInt() {
+		return q, nil
+	}
+	if d.Mode == parser.DivExact {
+		return nil, fmt.Errorf("%w: %v / %v%v", parser.ErrInexactDivision, left.RatString(), right.RatString(), d.At())
+	}
+	return new(big.Rat).SetInt(new(big.Int).Quo(left.Num(), right.Num())), nil
+}
+
+// ratPow raises a non zero base, or any base to a non negative power
+func ratPow(base *big.Rat, exp int64) *big.Rat {
+	if exp < 0 {
+		base, exp = new(big.Rat).Inv(base), -exp
+	}
+	e := big.NewInt(exp)
+	num := new(big.Int).Exp(base.Num(), e, nil)
+	denom := new(big.Int).Exp(base.Denom(), e, nil)
+	return new(big.Rat).SetFrac(num, denom)
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,360 @@
+package eval
+
+import (
+	"fmt"
+	"maps"
+	"math/big"
+	"slices"
+
+	"github.com/fmarmol/lexp/lexer"
+	"github.com/fmarmol/lexp/parser"
+)
+
+// list evaluates the items of n
+func (e *ratEval) list(n parser.ListNode) (RatNumber, error) {
+	items := make([]RatNumber, len(n.Items))
+	for i, node := range n.Items {
+		v, err := e.value(node)
+		if err != nil {
+			return RatNumber{}, err
+		}
+		if err := item(node, v.number()); err != nil {
+			return RatNumber{}, err
+		}
+		items[i] = v
+	}
+	return ratList(items), nil
+}
+
+// rangeList evaluates the items of n exactly, the range being checked like
+// with EvalNumber
+func (e *ratEval) rangeList(n parser.RangeNode) (RatNumber, error) {
+	bounds := []lexer.IExpression{n.Start, n.End, n.By()}
+	values := make([]*big.Rat, len(bounds))
+	floats := make([]float64, len(bounds))
+	for i, bound := range bounds {
+		var err error
+		if values[i], err = e.eval(bound); err != nil {
+			return RatNumber{}, err
+		}
+		floats[i], _ = values[i].Float64()
+	}
+	if _, err := n.Items(floats[0], floats[1], floats[2]); err != nil {
+		return RatNumber{}, err
+	}
+	start, end, step := values[0], values[1], values[2]
+	items := []RatNumber{}
+	for i := start; i.Cmp(end)*step.Sign() <= 0; i = new(big.Rat).Add(i, step) {
+		items = append(items, RatNumber{Rat: i})
+	}
+	return ratList(items), nil
+}
+
+// index evaluates the item of a list, or the value of a key of a map
+func (e *ratEval) index(n parser.IndexNode) (RatNumber, error) {
+	target, err := e.value(n.Target)
+	if err != nil {
+		return RatNumber{}, err
+	}
+	if target.Value != nil && target.Value.Kind == KindMap {
+		v, err := (&numberEval{e.visit, nil, nil}).key(n, *target.Value)
+		if err != nil {
+			return RatNumber{}, err
+		}
+		return ratFromNumber(v)
+	}
+	if target.Items == nil {
+		return RatNumber{}, n.Locate(fmt.Errorf("cannot index %v, not a list%v", parser.Source(n.Target), n.At()))
+	}
+	i, err := e.eval(n.Index)
+	if err != nil {
+		return RatNumber{}, err
+	}
+	f, _ := i.Float64()
+	offset, err := n.Offset(f, len(target.Items))
+	if err != nil {
+		return RatNumber{}, err
+	}
+	return target.Items[offset], nil
+}
+
+// call the function of n with the exact values of its arguments, the items
+// of the lists being passed as separate arguments, or mapped by the
+// functions of a single number. Strings, maps and nil are passed like with
+// EvalNumber.
+func (e *ratEval) call(n parser.CallNode) (RatNumber, error) {
+	numbers := &numberEval{e.visit, nil, nil}
+	if v, ok, err := numbers.valueCall(n); ok {
+		if err != nil {
+			return RatNumber{}, err
+		}
+		return ratFromNumber(v)
+	}
+	args, err := e.args(n)
+	if err != nil {
+		return RatNumber{}, err
+	}
+	return e.callWith(n, args)
+}
+
+// args evaluates the arguments of n
+func (e *ratEval) args(n parser.CallNode) ([]RatNumber, error) {
+	args := make([]RatNumber, len(n.Args))
+	for i, arg := range n.Args {
+		var err error
+		if args[i], err = e.value(arg); err != nil {
+			return nil, err
+		}
+	}
+	return args, nil
+}
+
+// callWith calls the function of n with args, the values of its arguments
+func (e *ratEval) callWith(n parser.CallNode, args []RatNumber) (RatNumber, error) {
+	numbers := &numberEval{e.visit, nil, nil}
+	if f, err := n.Resolve(len(args)); err == nil && f.Closure != nil && slices.ContainsFunc(args, RatNumber.isFunc) {
+		return e.callFuncs(f.Closure, n, args)
+	}
+	if f, err := n.Resolve(1); err == nil && f.MaxArgs == 1 && len(args) == 1 && args[0].Items != nil {
+		if items, ok := rats(args[0].Items); ok {
+			mapped := make([]RatNumber, len(items))
+			for i, item := range items {
+				if mapped[i], err = e.invoke(n, []*big.Rat{item}); err != nil {
+					return RatNumber{}, err
+				}
+			}
+			return ratList(mapped), nil
+		}
+	}
+	flat := []*big.Rat{}
+	for _, arg := range args {
+		items, ok := rats([]RatNumber{arg})
+		if arg.Items != nil {
+			items, ok = rats(arg.Items)
+		}
+		if !ok {
+			return e.callNumbers(numbers, n, args)
+		}
+		flat = append(flat, items...)
+	}
+	return e.invoke(n, flat)
+}
+
+// rats of values, if they are all rationals
+func rats(values []RatNumber) ([]*big.Rat, bool) {
+	rats := make([]*big.Rat, len(values))
+	for i, v := range values {
+		if v.Rat == nil {
+			return nil, false
+		}
+		rats[i] = v.Rat
+	}
+	return rats, true
+}
+
+// callNumbers calls the function of n like EvalNumber
+func (e *ratEval) callNumbers(numbers *numberEval, n parser.CallNode, args []RatNumber) (RatNumber, error) {
+	operands := make([]Number, len(args))
+	for i, arg := range args {
+		operands[i] = arg.number()
+		if err := operand(n, n.Args[i], operands[i]); err != nil {
+			return RatNumber{}, err
+		}
+	}
+	v, err := numbers.callNode(n, operands)
+	if err != nil {
+		return RatNumber{}, err
+	}
+	return ratFromNumber(v)
+}
+
+// invoke the function of n with args, the functions defined and the
+// builtins of ratFuncs exactly and the other builtins as float64
+func (e *ratEval) invoke(n parser.CallNode, args []*big.Rat) (RatNumber, error) {
+	f, err := n.Resolve(len(args))
+	if err != nil {
+		return RatNumber{}, err
+	}
+	if f.Closure != nil {
+		return e.callClosure(f.Closure, args, nil)
+	}
+	if exact, ok := ratFuncs[n.Name()]; ok {
+		if r, ok := exact(args); ok {
+			r, err := e.rounded(r, nil)
+			return RatNumber{Rat: r}, err
+		}
+	}
+	floats := make([]float64, len(args))
+	for i, arg := range args {
+		floats[i], _ = arg.Float64()
+	}
+	v, err := n.Apply(floats)
+	if err != nil {
+		return RatNumber{}, err
+	}
+	r, err := e.rounded(ratFromFloat64(v))
+	return RatNumber{Rat: r}, err
+}
+
+// callFuncs calls c, the closure of n, with args, some of them functions
+// bound to its parameters for the call
+func (e *ratEval) callFuncs(c *parser.Closure, n parser.CallNode, args []RatNumber) (RatNumber, error) {
+	rats := make([]*big.Rat, len(args))
+	funcs := parser.Funcs{}
+	for i, arg := range args {
+		switch {
+		case arg.isFunc():
+			funcs[c.Lambda.Params[i]], rats[i] = *arg.Value.Func, new(big.Rat)
+		case arg.Rat == nil:
+			return RatNumber{}, fmt.Errorf("%v expects numbers, got a %v at %v", n.Name(), arg.number().Kind, lexer.SpanOf(n.Args[i]).Start)
+		default:
+			rats[i] = arg.Rat
+		}
+	}
+	return e.callClosure(c, rats, funcs)
+}
+
+// isFunc tells if v is a function
+func (v RatNumber) isFunc() bool { return v.Value != nil && v.Value.Kind == KindFunc }
+
+// callClosure evaluates the body of c with its parameters bound to args, or
+// to the functions of funcs for those it names, seeing the variables
+// assigned outside of the functions. The calls of closures it ends with are
+// made once it is left so that tail recursion does not nest.
+func (e *ratEval) callClosure(c *parser.Closure, args []*big.Rat, funcs parser.Funcs) (RatNumber, error) {
+	global := e.global
+	if global == nil {
+		global = e.assigned
+	}
+	for {
+		floats := make([]float64, len(args))
+		for i, arg := range args {
+			floats[i], _ = arg.Float64()
+		}
+		body, err := c.EnterFuncs(floats, funcs)
+		if err != nil {
+			c.Leave()
+			return RatNumber{}, err
+		}
+		call := &ratEval{maps.Clone(global), e.round, e.visit, global}
+		for i, param := range c.Lambda.Params {
+			call.assigned[param] = RatNumber{Rat: args[i]}
+			if _, ok := funcs[param]; ok {
+				delete(call.assigned, param)
+			}
+		}
+		v, next, err := call.tail(body)
+		c.Leave()
+		if err != nil || next == nil {
+			return v, err
+		}
+		c, args, funcs = next.closure, next.args, nil
+	}
+}
+
+// ratTail is the call of a closure a function ends with
+type ratTail struct {
+	closure *parser.Closure
+	args    []*big.Rat
+}
+
+// tail evaluates node like value, but for the call of a closure with
+// numbers it ends with, returned instead of being made
+func (e *ratEval) tail(node lexer.IExpression) (RatNumber, *ratTail, error) {
+	switch n := node.(type) {
+	case parser.CallNode:
+		c := n.TailClosure()
+		if c == nil {
+			break
+		}
+		if err := e.enter(n); err != nil {
+			return RatNumber{}, nil, err
+		}
+		args, err := e.args(n)
+		if err != nil {
+			return RatNumber{}, nil, err
+		}
+		if rats, ok := rats(args); ok {
+			return RatNumber{}, &ratTail{c, rats}, nil
+		}
+		v, err := e.callWith(n, args)
+		return v, nil, err
+	case parser.MatchNode:
+		if err := e.enter(n); err != nil {
+			return RatNumber{}, nil, err
+		}
+		arm, err := n.Arm()
+		if err != nil {
+			return RatNumber{}, nil, err
+		}
+		return e.tail(arm)
+	case parser.SeqNode:
+		return e.tailSeq(n, n.Items)
+	case parser.ProgramNode:
+		return e.tailSeq(n, n.Statements)
+	case parser.LetNode:
+		if err := e.enter(n); err != nil {
+			return RatNumber{}, nil, err
+		}
+		v, err := e.value(n.Value)
+		if err != nil {
+			return RatNumber{}, nil, err
+		}
+		defer e.bind(n, v)()
+		return e.tail(n.Body)
+	}
+	v, err := e.value(node)
+	return v, nil, err
+}
+
+// tailSeq evaluates the items of node in order, the last one with tail
+func (e *ratEval) tailSeq(node lexer.IExpression, items []lexer.IExpression) (RatNumber, *ratTail, error) {
+	if err := e.enter(node); err != nil {
+		return RatNumber{}, nil, err
+	}
+	for _, item := range items[:len(items)-1] {
+		if _, err := e.value(item); err != nil {
+			return RatNumber{}, nil, err
+		}
+	}
+	return e.tail(items[len(items)-1])
+}
+
+// ratFuncs are the builtins computed exactly, reporting if they could
+var ratFuncs = map[string]func(args []*big.Rat) (*big.Rat, bool){
+	"sum": func(args []*big.Rat) (*big.Rat, bool) { return ratSum(args), true },
+	"mean": func(args []*big.Rat) (*big.Rat, bool) {
+		if len(args) == 0 {
+			return nil, false
+		}
+		sum := ratSum(args)
+		return sum.Quo(sum, new(big.Rat).SetInt64(int64(len(args)))), true
+	},
+	"min": ratExtremum(-1),
+	"max": ratExtremum(1),
+}
+
+func ratSum(args []*big.Rat) *big.Rat {
+	sum := new(big.Rat)
+	for _, arg := range args {
+		sum.Add(sum, arg)
+	}
+	return sum
+}
+
+// ratExtremum returns the smallest of its arguments for order -1, the
+// largest for 1
+func ratExtremum(order int) func(args []*big.Rat) (*big.Rat, bool) {
+	return func(args []*big.Rat) (*big.Rat, bool) {
+		if len(args) == 0 {
+			return nil, false
+		}
+		v := args[0]
+		for _, arg := range args[1:] {
+			if arg.Cmp(v) == order {
+				v = arg
+			}
+		}
+		return v, true
+	}
+}
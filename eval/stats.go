@@ -0,0 +1,77 @@
+package eval
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// ErrEmptyData ...
+var ErrEmptyData = errors.New("empty data")
+
+// Sum ...
+func Sum(xs ...float64) (float64, error) {
+	sum := 0.0
+	for _, x := range xs {
+		sum += x
+	}
+	return sum, nil
+}
+
+// count of the arguments, the items of lists passed to len
+func count(xs ...float64) (float64, error) { return float64(len(xs)), nil }
+
+// Mean ...
+func Mean(xs []float64) (float64, error) {
+	if len(xs) == 0 {
+		return 0, ErrEmptyData
+	}
+	sum := 0.0
+	for _, x := range xs {
+		sum += x
+	}
+	return sum / float64(len(xs)), nil
+}
+
+// Median ...
+func Median(xs []float64) (float64, error) {
+	return Percentile(xs, 50)
+}
+
+// Variance is the sample variance, dividing by n-1
+func Variance(xs []float64) (float64, error) {
+	if len(xs) < 2 {
+		return 0, fmt.Errorf("variance needs at least 2 values, got %v", len(xs))
+	}
+	mean, _ := Mean(xs)
+	sum := 0.0
+	for _, x := range xs {
+		sum += (x - mean) * (x - mean)
+	}
+	return sum / float64(len(xs)-1), nil
+}
+
+// StdDev is the sample standard deviation
+func StdDev(xs []float64) (float64, error) {
+	v, err := Variance(xs)
+	return math.Sqrt(v), err
+}
+
+// Percentile p in [0, 100], interpolating linearly between closest ranks
+func Percentile(xs []float64, p float64) (float64, error) {
+	if len(xs) == 0 {
+		return 0, ErrEmptyData
+	}
+	if p < 0 || p > 100 || math.IsNaN(p) {
+		return 0, fmt.Errorf("percentile %v out of range [0, 100]", p)
+	}
+	sorted := append([]float64{}, xs...)
+	sort.Float64s(sorted)
+	rank := p / 100 * float64(len(sorted)-1)
+	lo := int(math.Floor(rank))
+	if lo == len(sorted)-1 {
+		return sorted[lo], nil
+	}
+	return sorted[lo] + (rank-float64(lo))*(sorted[lo+1]-sorted[lo]), nil
+}
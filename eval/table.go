@@ -0,0 +1,174 @@
+package eval
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/fmarmol/lexp/lexer"
+	"github.com/fmarmol/lexp/parser"
+)
+
+// maxTableRows protects against typos like a step of 0.0001 over 0..1000
+const maxTableRows = 100000
+
+// TableSpec describes the tabulation of an expression over a variable range
+type TableSpec struct {
+	Expr           string
+	Var            string
+	From, To, Step float64
+}
+
+// ParseTableSpec parses: expr for var in from..to [step s]
+func ParseTableSpec(env parser.Env, spec string) (TableSpec, error) {
+	usage := fmt.Errorf("expected: expression for variable in from..to [step s]")
+	i := strings.LastIndex(spec, " for ")
+	if i < 0 {
+		return TableSpec{}, usage
+	}
+	ret := TableSpec{Expr: strings.TrimSpace(spec[:i]), Step: 1}
+	fields := strings.Fields(spec[i+len(" for "):])
+	if len(fields) != 3 && len(fields) != 5 || fields[1] != "in" {
+		return TableSpec{}, usage
+	}
+	ret.Var = fields[0]
+	from, to, ok := strings.Cut(fields[2], "..")
+	if !ok {
+		return TableSpec{}, usage
+	}
+	var err error
+	if ret.From, err = evalNumber(env, from); err != nil {
+		return TableSpec{}, err
+	}
+	if ret.To, err = evalNumber(env, to); err != nil {
+		return TableSpec{}, err
+	}
+	if len(fields) == 5 {
+		if fields[3] != "step" {
+			return TableSpec{}, usage
+		}
+		if ret.Step, err = evalNumber(env, fields[4]); err != nil {
+			return TableSpec{}, err
+		}
+	}
+	return ret, ret.check()
+}
+
+// check the range of s
+func (s TableSpec) check() error {
+	if s.Step <= 0 {
+		return fmt.Errorf("step must be positive, got %v", s.Step)
+	}
+	if (s.To-s.From)/s.Step >= maxTableRows {
+		return fmt.Errorf("too many rows, more than %v", maxTableRows)
+	}
+	return nil
+}
+
+func evalNumber(env parser.Env, text string) (float64, error) {
+	_, v, err := EvalLine(copyEnv(env), "table", text)
+	return v, err
+}
+
+func copyEnv(env parser.Env) parser.Env {
+	ret := parser.Env{}
+	for k, v := range env {
+		ret[k] = v
+	}
+	return ret
+}
+
+// Tabulate evaluates the expression of spec for each value of its range,
+// env is left untouched
+func Tabulate(env parser.Env, spec TableSpec) ([][2]float64, error) {
+	env = copyEnv(env)
+	env[spec.Var] = spec.From
+	tokens, err := lexer.New("table", spec.Expr).MakeTokens()
+	if err != nil {
+		return nil, err
+	}
+	p := parser.New(tokens)
+	p.Env = env
+	p.Funcs = Builtins
+	p.Consts = Constants
+	expr, err := p.Parse()
+	if err != nil {
+		return nil, err
+	}
+	if undefined := env.Undefined(expr); len(undefined) > 0 {
+		return nil, parser.UndefinedVariable(undefined[0], env.Names(), env.Assigned(expr), Constants.Names())
+	}
+	return tabulate(spec, func(x float64) float64 {
+		env[spec.Var] = x
+		v, err := expr.Eval()
+		if err != nil {
+			// a division by zero at a single point should not hide the table
+			v = math.NaN()
+		}
+		return v
+	}), nil
+}
+
+// tabulate f over the range of spec
+func tabulate(spec TableSpec, f func(float64) float64) [][2]float64 {
+	rows := [][2]float64{}
+	// x is computed from the row index to avoid accumulating rounding errors
+	for i := 0; ; i++ {
+		x := spec.From + float64(i)*spec.Step
+		if x > spec.To+spec.Step*1e-9 {
+			break
+		}
+		rows = append(rows, [2]float64{x, f(x)})
+	}
+	return rows
+}
+
+// table evaluates the call n to table(f, from, to, step), the list of the
+// [x, f(x)] rows
+func (e *numberEval) table(n parser.CallNode) (Number, error) {
+	f, args, err := e.realFunc(n, 3)
+	if err != nil {
+		return Number{}, err
+	}
+	spec := TableSpec{From: args[0], To: args[1], Step: args[2]}
+	if err := spec.check(); err != nil {
+		return Number{}, fmt.Errorf("%v: %w at %v", n.Name(), err, lexer.SpanOf(n).Start)
+	}
+	rows := tabulate(spec, f.eval)
+	if f.aborted() {
+		return Number{}, f.err
+	}
+	list := make([]Number, len(rows))
+	for i, row := range rows {
+		list[i] = ListNumber([]Number{exact(row[0]), exact(row[1])})
+	}
+	return ListNumber(list), nil
+}
+
+// WriteTable writes rows as aligned columns
+func WriteTable(w io.Writer, spec TableSpec, rows [][2]float64, format Format) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "%v\t%v\t\n", spec.Var, spec.Expr)
+	for _, row := range rows {
+		fmt.Fprintf(tw, "%v\t%v\t\n", format.Result(row[0]), format.Result(row[1]))
+	}
+	tw.Flush()
+}
+
+// WriteCSV writes rows as comma separated values with a header
+func WriteCSV(w io.Writer, spec TableSpec, rows [][2]float64) {
+	fmt.Fprintf(w, "%v,%v\n", csvField(spec.Var), csvField(spec.Expr))
+	for _, row := range rows {
+		fmt.Fprintf(w, "%v,%v\n", strconv.FormatFloat(row[0], 'g', -1, 64), strconv.FormatFloat(row[1], 'g', -1, 64))
+	}
+}
+
+func csvField(s string) string {
+	if strings.ContainsAny(s, ",\"\n") {
+		return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+	}
+	return s
+}
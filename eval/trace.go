@@ -0,0 +1,90 @@
+package eval
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/fmarmol/lexp/lexer"
+	"github.com/fmarmol/lexp/parser"
+)
+
+// Step of a traced evaluation: Node, an operation, a call or a variable,
+// reduced to Value. Operands are the values of its operands, the right
+// operand of a logical operation being missing if the left one decides.
+type Step struct {
+	Node     lexer.IExpression
+	Operands []Number
+	Value    Number
+}
+
+// Tracer is called for each step of an evaluation, returning an error
+// aborts it
+type Tracer func(Step) error
+
+// EvalNumberWithTrace evaluates node like EvalNumber, calling tracer after
+// each reduction, innermost operations first
+func EvalNumberWithTrace(node lexer.IExpression, tracer Tracer) (Number, error) {
+	return (&numberEval{nil, tracer, nil}).eval(node)
+}
+
+func (s Step) String() string { return s.Describe(Number.String) }
+
+// Describe s as the reduced expression, its operands being replaced by
+// their values, and its value like 2 * 3 => 6, numbers being formatted with
+// format
+func (s Step) Describe(format func(Number) string) string {
+	operand := func(i int) string {
+		if i >= len(s.Operands) {
+			return parser.Source(parser.Children(s.Node)[i])
+		}
+		if s.Operands[i].Kind == KindString {
+			return strconv.Quote(s.Operands[i].Str)
+		}
+		return format(s.Operands[i])
+	}
+	var reduced string
+	switch n := s.Node.(type) {
+	case parser.BinOpNode:
+		reduced = fmt.Sprintf("%v %v %v", operand(0), lexer.Symbols[parser.OpType(n.Op)], operand(1))
+	case parser.UnaryOpNode:
+		reduced = lexer.Symbols[parser.OpType(n.Op)] + operand(0)
+		if _, ok := n.Op.(lexer.TokenNot); ok {
+			reduced = lexer.Symbols[parser.OpType(n.Op)] + " " + operand(0)
+		}
+	case parser.CallNode:
+		args := make([]string, len(n.Args))
+		for i := range args {
+			args[i] = operand(i)
+		}
+		reduced = n.Name() + "(" + strings.Join(args, ", ") + ")"
+	default:
+		reduced = parser.Source(s.Node)
+	}
+	value := format(s.Value)
+	if s.Value.Kind == KindString {
+		value = strconv.Quote(s.Value.Str)
+	}
+	return reduced + " => " + value
+}
+
+// step reports the reduction of node to v if e is traced
+func (e *numberEval) step(node lexer.IExpression, v Number, operands ...Number) error {
+	if e.trace == nil {
+		return nil
+	}
+	// operands is copied so that the variadic calls do not allocate it
+	if err := e.trace(Step{node, slices.Clone(operands), v}); err != nil {
+		return aborted{err}
+	}
+	return nil
+}
+
+// aborted wraps the errors of the hooks aborting an evaluation, which try
+// does not catch
+type aborted struct{ err error }
+
+func (a aborted) Error() string { return a.err.Error() }
+
+func (a aborted) Unwrap() error { return a.err }
@@ -0,0 +1,55 @@
+package lexp
+
+import (
+	"github.com/fmarmol/lexp/eval"
+	"github.com/fmarmol/lexp/lexer"
+	"github.com/fmarmol/lexp/parser"
+)
+
+// Expr is a compiled expression. It is immutable: Eval can be called from
+// several goroutines at once, each call with its own environment.
+type Expr struct {
+	text    string
+	program *eval.Program
+	// free variables, read before being assigned
+	free []string
+	// steps an evaluation can run, 0 for no limit
+	steps int
+}
+
+// Compile text for evaluating it many times
+func Compile(text string) (*Expr, error) {
+	return CompileWithLimits(text, Limits{})
+}
+
+func compile(text string, node lexer.IExpression) *Expr {
+	free, seen := []string{}, map[string]bool{}
+	for _, name := range (parser.Env{}).Undefined(node) {
+		if !seen[name] {
+			free, seen[name] = append(free, name), true
+		}
+	}
+	return &Expr{text, eval.Compile(node), free, 0}
+}
+
+// Eval evaluates e with the variables of env, which may be nil. env is only
+// read, the assignments of e lasting for the call, so the same env can be
+// used by concurrent calls as long as it is not modified meanwhile.
+func (e *Expr) Eval(env parser.Env) (float64, error) {
+	for _, name := range e.free {
+		if _, ok := env[name]; !ok {
+			return 0, EvalError{runtimeError(parser.UndefinedVariable(name, env.Names()))}
+		}
+	}
+	result, err := e.program.EvalLimited(env, e.steps)
+	if err != nil {
+		return 0, EvalError{runtimeError(err)}
+	}
+	return result, nil
+}
+
+// Vars returns the names of the variables e reads before assigning them,
+// in order of first use
+func (e *Expr) Vars() []string { return append([]string{}, e.free...) }
+
+func (e *Expr) String() string { return e.text }
@@ -0,0 +1,89 @@
+package lexp
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/fmarmol/lexp/parser"
+)
+
+func TestExprEval(t *testing.T) {
+	tests := []struct {
+		text string
+		env  parser.Env
+		want float64
+	}{
+		{"x * (y + 2)", parser.Env{"x": 3, "y": 1}, 9},
+		{"match x { 0 => 10, _ => 20 }", parser.Env{"x": 0}, 10},
+		{"[x, 2][0]", parser.Env{"x": 7}, 7},
+		{"x ?? 3", parser.Env{"x": 4}, 4},
+		{"sum([x, 1])", parser.Env{"x": 4}, 5},
+		{"sum(1..10)", nil, 55},
+		{"y = [1, 2, x]; sum(y)", parser.Env{"x": 3}, 6},
+		{"y + 10%", parser.Env{"y": 50}, 55},
+	}
+	for _, test := range tests {
+		e, err := Compile(test.text)
+		if err != nil {
+			t.Fatalf("Compile(%q): %v", test.text, err)
+		}
+		got, err := e.Eval(test.env)
+		if err != nil || got != test.want {
+			t.Errorf("%q with %v = %v, %v, want %v", test.text, test.env, got, err, test.want)
+		}
+	}
+}
+
+func TestExprEvalLimited(t *testing.T) {
+	for _, text := range []string{"while true { 1 }", "while true { [1] }"} {
+		e, err := CompileWithLimits(text, Limits{MaxSteps: 100})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := e.Eval(nil); err == nil {
+			t.Errorf("%q ran beyond its steps", text)
+		}
+	}
+}
+
+func TestExprCompileTooManyTokens(t *testing.T) {
+	if _, err := CompileWithLimits("1+1+1", Limits{MaxTokens: 3}); !errors.Is(err, ErrTooManyTokens) {
+		t.Errorf("CompileWithLimits(1+1+1) with 3 tokens = %v, want %v", err, ErrTooManyTokens)
+	}
+	if _, err := CompileWithLimits("1+1", Limits{MaxTokens: 3}); err != nil {
+		t.Errorf("CompileWithLimits(1+1) with 3 tokens = %v", err)
+	}
+}
+
+// TestExprConcurrent evaluates the same expressions from many goroutines,
+// meant to be run with -race
+func TestExprConcurrent(t *testing.T) {
+	for _, text := range []string{
+		"y = x * 2; y + 1",
+		"match 1 { _ => { y = x * 2; y + 1 } }",
+		"f = (a) -> a * 2; f(x) + 1",
+		"match 1 { _ => { f = (a) -> a * 2; f(x) + 1 } }",
+		"y = [x, x]; sum(y) + 1",
+	} {
+		e, err := Compile(text)
+		if err != nil {
+			t.Fatalf("Compile(%q): %v", text, err)
+		}
+		var wg sync.WaitGroup
+		for i := range 16 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for range 100 {
+					x := float64(i)
+					if got, err := e.Eval(parser.Env{"x": x}); err != nil || got != x*2+1 {
+						t.Errorf("%q with x = %v: %v, %v, want %v", text, x, got, err, x*2+1)
+						return
+					}
+				}
+			}()
+		}
+		wg.Wait()
+	}
+}
@@ -0,0 +1,3 @@
+module github.com/fmarmol/lexp
+
+go 1.23
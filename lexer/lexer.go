@@ -0,0 +1,603 @@
+package lexer
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Position ...
+type Position struct {
+	// Index and Rune are the offsets of the character in bytes and in runes,
+	// Column counts runes
+	Index, Rune, Line, Column int
+	FileName, FileContent     string
+	// ImportedAt is the import statement of the file, nil if it is not
+	// imported
+	ImportedAt *Position
+}
+
+func (p Position) String() string {
+	s := fmt.Sprintf("%v:%v:%v", p.FileName, p.Line, p.Column)
+	for at := p.ImportedAt; at != nil; at = at.ImportedAt {
+		s += fmt.Sprintf(", imported at %v:%v:%v", at.FileName, at.Line, at.Column)
+	}
+	return s
+}
+
+// Next position, currentChar being a valid rune
+func (p *Position) Next(currentChar rune) {
+	p.advance(currentChar, utf8.RuneLen(currentChar))
+}
+
+// advance past currentChar, encoded in size bytes
+func (p *Position) advance(currentChar rune, size int) {
+	p.Index += size
+	p.Rune++
+	if currentChar == '\n' {
+		p.Column = 0
+		p.Line++
+	}
+	p.Column++
+}
+
+// Copy position
+func (p Position) Copy() Position { return p }
+
+// Lexer ...
+type Lexer struct {
+	Text    string
+	Pos     Position
+	Current rune
+	size    int // of Current in bytes
+	// SourceMap places Text in a host document, nil if Text is the whole file
+	SourceMap *SourceMap
+	// AllErrors skips the invalid characters and literals instead of
+	// stopping at the first one, MakeTokens reporting all the errors joined
+	AllErrors bool
+	// Comments read by MakeTokens, from # to the end of the line, which are
+	// not part of the tokens
+	Comments Tokens
+	// MaxTokens stops MakeTokens beyond as many tokens, the end of input
+	// aside, 0 meaning no limit
+	MaxTokens int
+	// reader the characters are read from instead of Text, nil if the text
+	// is in memory
+	reader  *bufio.Reader
+	readErr error   // first read error other than io.EOF
+	errs    []error // recorded in AllErrors mode
+}
+
+// Position of the current character, in the host document if any
+func (l *Lexer) Position() Position {
+	if l.SourceMap != nil {
+		return l.SourceMap.Map(l.Pos)
+	}
+	return l.Pos
+}
+
+func (l *Lexer) unknownToken(current rune) error {
+	pos := l.Position()
+	if current == utf8.RuneError && l.size == 1 {
+		return l.charError(fmt.Errorf("invalid UTF-8 encoding at file: %v, line: %v, col: %v", pos.FileName, pos.Line, pos.Column))
+	}
+	return l.charError(fmt.Errorf("%w %q at file: %v, line: %v, col:% v", ErrUnknownToken, string(current), pos.FileName, pos.Line, pos.Column))
+}
+
+// spanError locates err from start to the current character
+func (l *Lexer) spanError(start Position, err error) error {
+	return SpanError{Span{start, l.Position()}, err}
+}
+
+// charError locates err at the current character
+func (l *Lexer) charError(err error) error {
+	end := l.Position()
+	end.advance(l.Current, l.size)
+	return SpanError{Span{l.Position(), end}, err}
+}
+
+// Next ...
+func (l *Lexer) Next() bool {
+	l.Pos.advance(l.Current, l.size)
+	if l.reader != nil {
+		c, size, err := l.reader.ReadRune()
+		if err != nil {
+			if err != io.EOF && l.readErr == nil {
+				l.readErr = err
+			}
+			l.Current, l.size = ' ', 1
+			return false
+		}
+		l.Current, l.size = c, size
+		return true
+	}
+	if l.Pos.Index < len(l.Text) {
+		l.Current, l.size = utf8.DecodeRuneInString(l.Text[l.Pos.Index:])
+		return true
+	}
+	l.Current, l.size = ' ', 1
+	return false
+}
+
+// MakeTokens splits the text into tokens. Newlines separate statements
+// like semicolons, unless they are within parentheses, and not in a block
+// within them, or follow a token
+// which cannot end an expression, like an operator, so that expressions
+// can span several lines. The tokens of a text lexed to its end are
+// followed by an EOF token.
+func (l *Lexer) MakeTokens() (Tokens, error) {
+	// most tokens are followed by a space or are at least 2 characters long
+	ret := make(Tokens, 0, len(l.Text)/2)
+	depth := 0 // of parentheses and brackets
+	// depths of the parentheses around the open blocks, which separate
+	// their statements again
+	var outer []int
+	for {
+		if err := l.checkTokens(ret); err != nil {
+			return ret, err
+		}
+		current := l.Current
+		start := l.Position()
+		switch current {
+		case ' ', '\t', '\r':
+		case '\n':
+			if depth == 0 && len(ret) > 0 && endsExpression(ret[len(ret)-1]) {
+				ret = ret.Add(TokenNewline{l.token(TypeNewline, nil, start, "\n")})
+			}
+		case ';':
+			ret = ret.Add(TokenSemi{l.token(TypeSemi, nil, start, ";")})
+		case '#':
+			// makeComment stops on the newline ending the comment
+			l.Comments = l.Comments.Add(l.makeComment(start))
+			continue
+		case '+':
+			ret = ret.Add(TokenPlus{l.token(TypePlus, nil, start, "+")})
+		case '-':
+			if l.peek() == '>' {
+				l.Next()
+				ret = ret.Add(TokenArrow{l.token(TypeArrow, nil, start, "->")})
+			} else {
+				ret = ret.Add(TokenMinus{l.token(TypeMinus, nil, start, "-")})
+			}
+		case '*':
+			if l.peek() == '*' {
+				l.Next()
+				ret = ret.Add(TokenPow{l.token(TypePow, nil, start, "**")})
+			} else {
+				ret = ret.Add(TokenMul{l.token(TypeMul, nil, start, "*")})
+			}
+		case '^':
+			ret = ret.Add(TokenPow{l.token(TypePow, nil, start, "^")})
+		case '/':
+			ret = ret.Add(TokenDiv{l.token(TypeDiv, nil, start, "/")})
+		case '%':
+			ret = ret.Add(TokenPercent{l.token(TypePercent, nil, start, "%")})
+		case '=':
+			if l.peek() == '=' {
+				l.Next()
+				ret = ret.Add(TokenEQ{l.token(TypeEQ, nil, start, "==")})
+			} else if l.peek() == '>' {
+				l.Next()
+				ret = ret.Add(TokenFatArrow{l.token(TypeFatArrow, nil, start, "=>")})
+			} else {
+				ret = ret.Add(TokenAssign{l.token(TypeAssign, nil, start, "=")})
+			}
+		case '!':
+			if l.peek() == '=' {
+				l.Next()
+				ret = ret.Add(TokenNE{l.token(TypeNE, nil, start, "!=")})
+			} else {
+				ret = ret.Add(TokenNot{l.token(TypeNot, nil, start, "!")})
+			}
+		case '&', '|':
+			if l.peek() != current {
+				if err := l.unknownToken(current); l.fail(err) {
+					return ret, err
+				}
+				break
+			}
+			l.Next()
+			if current == '&' {
+				ret = ret.Add(TokenAnd{l.token(TypeAnd, nil, start, "&&")})
+			} else {
+				ret = ret.Add(TokenOr{l.token(TypeOr, nil, start, "||")})
+			}
+		case ',':
+			ret = ret.Add(TokenComma{l.token(TypeComma, nil, start, ",")})
+		case ':':
+			ret = ret.Add(TokenColon{l.token(TypeColon, nil, start, ":")})
+		case '?':
+			if l.peek() != '?' {
+				if err := l.unknownToken(current); l.fail(err) {
+					return ret, err
+				}
+				break
+			}
+			l.Next()
+			ret = ret.Add(TokenCoalesce{l.token(TypeCoalesce, nil, start, "??")})
+		case '.':
+			if l.peek() != '.' {
+				if err := l.unknownToken(current); l.fail(err) {
+					return ret, err
+				}
+				break
+			}
+			l.Next()
+			ret = ret.Add(TokenRange{l.token(TypeRange, nil, start, "..")})
+		case '<', '>':
+			ret = ret.Add(l.makeAngle(current, start))
+		case '"':
+			token, err := l.MakeString()
+			if err != nil && l.fail(err) {
+				return ret, err
+			}
+			if err == nil {
+				ret = ret.Add(token)
+			} else if l.Current != '"' {
+				// the newline ending an unterminated string is lexed as usual
+				continue
+			}
+		case '(':
+			depth++
+			ret = ret.Add(TokenLP{l.token(TypeLP, nil, start, "(")})
+		case ')':
+			depth = max(depth-1, 0)
+			ret = ret.Add(TokenRP{l.token(TypeRP, nil, start, ")")})
+		case '[':
+			depth++
+			ret = ret.Add(TokenLBracket{l.token(TypeLBracket, nil, start, "[")})
+		case ']':
+			depth = max(depth-1, 0)
+			ret = ret.Add(TokenRBracket{l.token(TypeRBracket, nil, start, "]")})
+		case '{':
+			outer, depth = append(outer, depth), 0
+			ret = ret.Add(TokenLBrace{l.token(TypeLBrace, nil, start, "{")})
+		case '}':
+			if len(outer) > 0 {
+				depth, outer = outer[len(outer)-1], outer[:len(outer)-1]
+			}
+			ret = ret.Add(TokenRBrace{l.token(TypeRBrace, nil, start, "}")})
+		case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+			// MakeNumber already moved to the character following the number
+			token, err := l.MakeNumber()
+			if err != nil && l.fail(err) {
+				return ret, err
+			}
+			if err == nil {
+				ret = ret.Add(token)
+			}
+			continue
+		default:
+			if IsLetter(current) {
+				ret = ret.Add(l.MakeIdentifier())
+				continue
+			}
+			if !unicode.IsSpace(current) {
+				if err := l.unknownToken(current); l.fail(err) {
+					return ret, err
+				}
+			}
+		}
+		if !l.Next() {
+			if err := l.checkTokens(ret); err != nil {
+				return ret, err
+			}
+			ret = ret.Add(TokenEOF{l.token(TypeEOF, nil, l.Position(), "")})
+			if len(l.errs) > 0 {
+				return ret, errors.Join(append(l.errs, l.readErr)...)
+			}
+			return ret, l.readErr
+		}
+	}
+}
+
+// checkTokens fails if there are more than MaxTokens tokens
+func (l *Lexer) checkTokens(tokens Tokens) error {
+	if l.MaxTokens > 0 && len(tokens) > l.MaxTokens {
+		return l.charError(fmt.Errorf("%w, at most %v", ErrTooManyTokens, l.MaxTokens))
+	}
+	return nil
+}
+
+// fail records err in AllErrors mode, telling otherwise that lexing stops
+func (l *Lexer) fail(err error) bool {
+	if !l.AllErrors {
+		return true
+	}
+	l.errs = append(l.errs, err)
+	return false
+}
+
+// makeComment reads a comment up to the end of the line
+func (l *Lexer) makeComment(start Position) TokenComment {
+	text := ""
+	for l.Current != '\n' {
+		text += string(l.Current)
+		if !l.Next() {
+			break
+		}
+	}
+	return TokenComment{l.token(TypeComment, text, start, text)}
+}
+
+// endsExpression tells if an expression can end with token
+func endsExpression(token IToken) bool {
+	switch token.(type) {
+	case TokenInt, TokenFloat, TokenBool, TokenString, TokenNil, TokenIdent, TokenRP, TokenRBracket, TokenRBrace, TokenPercent:
+		return true
+	}
+	return false
+}
+
+// makeAngle reads <, <=, <<, >, >= or >>
+func (l *Lexer) makeAngle(current rune, start Position) IToken {
+	next := l.peek()
+	if next == current || next == '=' {
+		l.Next()
+	}
+	switch {
+	case current == '<' && next == '<':
+		return TokenShl{l.token(TypeShl, nil, start, "<<")}
+	case current == '>' && next == '>':
+		return TokenShr{l.token(TypeShr, nil, start, ">>")}
+	case current == '<' && next == '=':
+		return TokenLE{l.token(TypeLE, nil, start, "<=")}
+	case current == '>' && next == '=':
+		return TokenGE{l.token(TypeGE, nil, start, ">=")}
+	case current == '<':
+		return TokenLT{l.token(TypeLT, nil, start, "<")}
+	}
+	return TokenGT{l.token(TypeGT, nil, start, ">")}
+}
+
+// token read from text at start
+func (l *Lexer) token(typ Type, value any, start Position, text string) Token {
+	end := start
+	n := utf8.RuneCountInString(text)
+	end.Column += n
+	if end.Index >= 0 {
+		end.Index += len(text)
+		end.Rune += n
+	}
+	return Token{typ, value, start, end}
+}
+
+// peek returns the character following the current one
+func (l *Lexer) peek() rune {
+	if l.reader != nil {
+		// Peek fails with fewer bytes than asked for near the end
+		if b, _ := l.reader.Peek(utf8.UTFMax); len(b) > 0 {
+			r, _ := utf8.DecodeRune(b)
+			return r
+		}
+		return ' '
+	}
+	if next := l.Pos.Index + l.size; next < len(l.Text) {
+		r, _ := utf8.DecodeRuneInString(l.Text[next:])
+		return r
+	}
+	return ' '
+}
+
+// IsLetter tells if r can start an identifier
+func IsLetter(r rune) bool {
+	return r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r > utf8.RuneSelf && unicode.IsLetter(r))
+}
+
+// MakeString reads a double quoted string with the escape sequences of Go,
+// stopping on the closing quote
+func (l *Lexer) MakeString() (IToken, error) {
+	start := l.Position()
+	raw := &strings.Builder{}
+	raw.WriteRune('"')
+	for escaped := false; ; {
+		if !l.Next() || l.Current == '\n' {
+			return nil, l.spanError(start, fmt.Errorf("unterminated string at file: %v, line: %v, col: %v", start.FileName, start.Line, start.Column))
+		}
+		raw.WriteRune(l.Current)
+		if l.Current == '"' && !escaped {
+			break
+		}
+		escaped = l.Current == '\\' && !escaped
+	}
+	value, err := strconv.Unquote(raw.String())
+	if err != nil {
+		end := l.Position()
+		end.advance(l.Current, l.size) // past the closing quote
+		return nil, SpanError{Span{start, end}, fmt.Errorf("invalid escape sequence in %v at file: %v, line: %v, col: %v", raw, start.FileName, start.Line, start.Column)}
+	}
+	return TokenString{l.token(TypeString, value, start, raw.String())}, nil
+}
+
+// Keywords read as operators or literals instead of identifiers
+var Keywords = []string{"and", "catch", "false", "for", "import", "in", "let", "match", "nil", "not", "or", "true", "try", "while"}
+
+// MakeIdentifier reads a name, which may be qualified by the name of a
+// module, like math.floor
+func (l *Lexer) MakeIdentifier() IToken {
+	start := l.Position()
+	name := ""
+	for IsLetter(l.Current) || IsDigit(l.Current) || (l.Current == '.' && IsLetter(l.peek())) {
+		name += string(l.Current)
+		if !l.Next() {
+			break
+		}
+	}
+	switch name {
+	case "and":
+		return TokenAnd{l.token(TypeAnd, nil, start, name)}
+	case "or":
+		return TokenOr{l.token(TypeOr, nil, start, name)}
+	case "not":
+		return TokenNot{l.token(TypeNot, nil, start, name)}
+	case "true", "false":
+		return TokenBool{l.token(TypeBool, name == "true", start, name)}
+	case "while":
+		return TokenWhile{l.token(TypeWhile, nil, start, name)}
+	case "for":
+		return TokenFor{l.token(TypeFor, nil, start, name)}
+	case "let":
+		return TokenLet{l.token(TypeLet, nil, start, name)}
+	case "in":
+		return TokenIn{l.token(TypeIn, nil, start, name)}
+	case "nil":
+		return TokenNil{l.token(TypeNil, nil, start, name)}
+	case "try":
+		return TokenTry{l.token(TypeTry, nil, start, name)}
+	case "catch":
+		return TokenCatch{l.token(TypeCatch, nil, start, name)}
+	case "match":
+		return TokenMatch{l.token(TypeMatch, nil, start, name)}
+	case "import":
+		return TokenImport{l.token(TypeImport, nil, start, name)}
+	}
+	return TokenIdent{l.token(TypeIdent, name, start, name)}
+}
+
+// IsDigit ...
+func IsDigit(r rune) bool {
+	switch r {
+	case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		return true
+	}
+	return false
+}
+
+// bases of the integer literals by prefix letter
+var bases = map[rune]struct {
+	name string
+	base int
+}{
+	'x': {"hexadecimal", 16}, 'X': {"hexadecimal", 16},
+	'o': {"octal", 8}, 'O': {"octal", 8},
+	'b': {"binary", 2}, 'B': {"binary", 2},
+}
+
+// MakeNumber reads a decimal number or a 0x, 0o or 0b prefixed integer,
+// underscores being allowed between digits or after a base prefix like in Go
+func (l *Lexer) MakeNumber() (IToken, error) {
+	start := l.Position()
+	if b, ok := bases[l.peek()]; ok && l.Current == '0' {
+		return l.makeInteger(start, b.name, b.base)
+	}
+	dotCount := 0
+	numStr := ""
+
+	for {
+		if IsDigit(l.Current) || l.Current == '_' {
+			numStr += string(l.Current)
+		} else if l.Current == '.' && dotCount == 0 && l.peek() != '.' {
+			// the dots of a range like 1..10 end the number instead
+			numStr += "."
+			dotCount++
+		} else {
+			break
+		}
+		l.Next()
+	}
+	if err := l.checkSeparators(start, numStr, numStr); err != nil {
+		return nil, err
+	}
+	digits := strings.ReplaceAll(numStr, "_", "")
+	if dotCount == 0 {
+		n, err := strconv.ParseInt(digits, 10, 64)
+		if err == nil {
+			return TokenInt{l.token(TypeInt, n, start, numStr)}, nil
+		}
+		if !errors.Is(err, strconv.ErrRange) {
+			return nil, l.numberError(start, numStr, err)
+		}
+		// integers too large for an int64 are promoted to floats
+		return l.bigInteger(start, numStr, digits, 10), nil
+	}
+	f, err := strconv.ParseFloat(digits, 64)
+	if err != nil {
+		return nil, l.numberError(start, numStr, err)
+	}
+	return TokenFloat{l.token(TypeFloat, f, start, numStr), nil}, nil
+}
+
+// bigInteger promotes the integer of digits in base, too large for an
+// int64, to a float keeping its exact value
+func (l *Lexer) bigInteger(start Position, numStr, digits string, base int) TokenFloat {
+	n, _ := new(big.Int).SetString(digits, base)
+	f, _ := new(big.Float).SetInt(n).Float64()
+	return TokenFloat{l.token(TypeFloat, f, start, numStr), n}
+}
+
+// makeInteger reads an integer in base, the current character being the 0 of
+// its prefix
+func (l *Lexer) makeInteger(start Position, name string, base int) (IToken, error) {
+	l.Next()
+	prefix := "0" + string(l.Current)
+	l.Next()
+	numStr := ""
+	for IsLetter(l.Current) || IsDigit(l.Current) {
+		if _, err := strconv.ParseUint(string(l.Current), base, 8); err != nil && l.Current != '_' {
+			pos := l.Position()
+			return nil, l.charError(fmt.Errorf("invalid digit %q in %v literal at file: %v, line: %v, col: %v", string(l.Current), name, pos.FileName, pos.Line, pos.Column))
+		}
+		numStr += string(l.Current)
+		l.Next()
+	}
+	if strings.Trim(numStr, "_") == "" {
+		return nil, l.spanError(start, fmt.Errorf("%v literal %v has no digits at file: %v, line: %v, col: %v", name, prefix, start.FileName, start.Line, start.Column))
+	}
+	// a separator may follow the prefix, which is not a digit itself
+	if err := l.checkSeparators(start, prefix+numStr, strings.TrimPrefix(numStr, "_")); err != nil {
+		return nil, err
+	}
+	digits := strings.ReplaceAll(numStr, "_", "")
+	n, err := strconv.ParseInt(digits, base, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		return l.bigInteger(start, prefix+numStr, digits, base), nil
+	}
+	if err != nil {
+		return nil, l.numberError(start, prefix+numStr, err)
+	}
+	return TokenInt{l.token(TypeInt, n, start, prefix+numStr)}, nil
+}
+
+// checkSeparators rejects the underscores of digits which are not between
+// two digits, numStr being the whole literal for error messages
+func (l *Lexer) checkSeparators(start Position, numStr, digits string) error {
+	if strings.HasPrefix(digits, "_") || strings.HasSuffix(digits, "_") ||
+		strings.Contains(digits, "__") || strings.Contains(digits, "_.") || strings.Contains(digits, "._") {
+		return l.spanError(start, fmt.Errorf("invalid digit separator in %v at file: %v, line: %v, col: %v", numStr, start.FileName, start.Line, start.Column))
+	}
+	return nil
+}
+
+func (l *Lexer) numberError(start Position, numStr string, err error) error {
+	if errors.Is(err, strconv.ErrRange) {
+		return l.spanError(start, fmt.Errorf("number %v out of range at file: %v, line: %v, col: %v", numStr, start.FileName, start.Line, start.Column))
+	}
+	return l.spanError(start, fmt.Errorf("invalid number %v at file: %v, line: %v, col: %v", numStr, start.FileName, start.Line, start.Column))
+}
+
+// New ...
+func New(fileName, text string) *Lexer {
+	// lines and columns start at 1 once the lexer moved to the first character
+	return &Lexer{text, Position{-1, -1, 1, 0, fileName, text, nil}, ' ', 1, nil, false, nil, 0, nil, nil, nil}
+}
+
+// NewImported lexes the text of fileName imported at the position at, which
+// the positions of the tokens reference
+func NewImported(fileName, text string, at Position) *Lexer {
+	l := New(fileName, text)
+	l.Pos.ImportedAt = &at
+	return l
+}
+
+// NewLexerFromReader lexes the text read from r, buffering the reads instead
+// of holding the whole text in memory. The positions of the tokens have no
+// FileContent.
+func NewLexerFromReader(fileName string, r io.Reader) *Lexer {
+	return &Lexer{"", Position{-1, -1, 1, 0, fileName, "", nil}, ' ', 1, nil, false, nil, 0, bufio.NewReader(r), nil, nil}
+}
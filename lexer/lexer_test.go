@@ -0,0 +1,47 @@
+package lexer
+
+import (
+	"strings"
+	"testing"
+)
+
+// input repeats unit up to about 1MB
+func input(unit string) string {
+	return strings.Repeat(unit, (1<<20)/len(unit))
+}
+
+var benchmarkInputs = []struct {
+	name, text string
+}{
+	{"line", "1" + input(" + x * (y - 2.5)")},
+	{"program", input("total = total + price * 1.2 # with taxes\n")},
+	{"strings", "0" + input(` + len("a string")`)},
+}
+
+// TestMakeTokensLong lexes a 1MB expression on a single line, which nested
+// a call per token when MakeTokens recursed
+func TestMakeTokensLong(t *testing.T) {
+	text := benchmarkInputs[0].text
+	tokens, err := New("long", text).MakeTokens()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 1 and 8 tokens per unit, with the final EOF
+	if want := 1 + 8*((1<<20)/len(" + x * (y - 2.5)")) + 1; len(tokens) != want {
+		t.Errorf("got %v tokens, want %v", len(tokens), want)
+	}
+}
+
+func BenchmarkMakeTokens(b *testing.B) {
+	for _, input := range benchmarkInputs {
+		b.Run(input.name, func(b *testing.B) {
+			b.SetBytes(int64(len(input.text)))
+			b.ReportAllocs()
+			for range b.N {
+				if _, err := New(input.name, input.text).MakeTokens(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package lexer
+
+import "strings"
+
+// SourceMap places an expression extracted from a larger host document, like
+// a YAML field or a template, so diagnostics point into the host document.
+type SourceMap struct {
+	FileName, FileContent string
+	// Lines holds the host position of the first character of each line of
+	// the expression, following lines keep the indentation of the last one
+	Lines []Position
+}
+
+// NewSourceMap for an expression starting at base in the host document whose
+// following lines, if any, are indented like the first one
+func NewSourceMap(base Position) *SourceMap {
+	return &SourceMap{base.FileName, base.FileContent, []Position{base}}
+}
+
+// Map a position of the expression to the host document
+func (m *SourceMap) Map(p Position) Position {
+	if len(m.Lines) == 0 {
+		return p
+	}
+	line := p.Line - 1
+	start := m.Lines[min(line, len(m.Lines)-1)]
+	ret := Position{
+		Index:       -1, // unknown past the mapped lines
+		Rune:        -1,
+		Line:        start.Line + max(0, line-(len(m.Lines)-1)),
+		Column:      start.Column + p.Column - 1,
+		FileName:    m.FileName,
+		FileContent: m.FileContent,
+	}
+	if line < len(m.Lines) && start.Index >= 0 {
+		ret.Rune = start.Rune + p.Column - 1
+		// the bytes before p on its line, unknown past the first one without
+		// the text read
+		read := p.FileContent[:min(max(p.Index, 0), len(p.FileContent))]
+		if p.Line == 1 || read != "" {
+			ret.Index = start.Index + p.Index - (strings.LastIndexByte(read, '\n') + 1)
+		}
+	}
+	return ret
+}
+
+// NewEmbedded lexes text found in a host document as described by m
+func NewEmbedded(text string, m *SourceMap) *Lexer {
+	l := New(m.FileName, text)
+	l.SourceMap = m
+	return l
+}
@@ -0,0 +1,21 @@
+package lexer
+
+import "testing"
+
+// TestSourceMapMultibyte maps the tokens following multibyte characters to
+// their byte offsets in the host document
+func TestSourceMapMultibyte(t *testing.T) {
+	host := "key: é + ü\n  é2 + x"
+	m := NewSourceMap(Position{5, 5, 1, 6, "host", host, nil})
+	m.Lines = append(m.Lines, Position{15, 13, 2, 3, "host", host, nil})
+	tokens, err := NewEmbedded("é + ü\né2 + x", m).MakeTokens()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []string{"é", "+", "ü", "\n", "é2", "+", "x"} {
+		span := SpanOf(tokens[i])
+		if got := host[span.Start.Index:span.End.Index]; got != want {
+			t.Errorf("token %v mapped to %q, want %q", i, got, want)
+		}
+	}
+}
@@ -0,0 +1,845 @@
+package lexer
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"iter"
+	"math"
+	"math/big"
+)
+
+// Type of token
+type Type string
+
+const (
+	TypeInt      Type = "INT"
+	TypeFloat    Type = "FLOAT"
+	TypePlus     Type = "PLUS"
+	TypeMinus    Type = "MINUS"
+	TypeMul      Type = "MUL"
+	TypeDiv      Type = "DIV"
+	TypeLP       Type = "LP"
+	TypeRP       Type = "RP"
+	TypeIdent    Type = "IDENT"
+	TypeAssign   Type = "ASSIGN"
+	TypeComma    Type = "COMMA"
+	TypeShl      Type = "SHL"
+	TypeShr      Type = "SHR"
+	TypePow      Type = "POW"
+	TypeLT       Type = "LT"
+	TypeLE       Type = "LE"
+	TypeGT       Type = "GT"
+	TypeGE       Type = "GE"
+	TypeEQ       Type = "EQ"
+	TypeNE       Type = "NE"
+	TypeAnd      Type = "AND"
+	TypeOr       Type = "OR"
+	TypeNot      Type = "NOT"
+	TypeBool     Type = "BOOL"
+	TypeString   Type = "STRING"
+	TypeSemi     Type = "SEMI"
+	TypeNewline  Type = "NEWLINE"
+	TypeEOF      Type = "EOF"
+	TypeComment  Type = "COMMENT"
+	TypeLBrace   Type = "LBRACE"
+	TypeRBrace   Type = "RBRACE"
+	TypeWhile    Type = "WHILE"
+	TypeFor      Type = "FOR"
+	TypeArrow    Type = "ARROW"
+	TypeLet      Type = "LET"
+	TypeIn       Type = "IN"
+	TypeLBracket Type = "LBRACKET"
+	TypeRBracket Type = "RBRACKET"
+	TypeColon    Type = "COLON"
+	TypeRange    Type = "RANGE"
+	TypeNil      Type = "NIL"
+	TypeCoalesce Type = "COALESCE"
+	TypeTry      Type = "TRY"
+	TypeCatch    Type = "CATCH"
+	TypeMatch    Type = "MATCH"
+	TypeFatArrow Type = "FATARROW"
+	TypeImport   Type = "IMPORT"
+	TypePercent  Type = "PERCENT"
+)
+
+// Symbols the operators and punctuation are written with
+var Symbols = map[Type]string{
+	TypePlus: "+", TypeMinus: "-", TypeMul: "*", TypeDiv: "/", TypeAssign: "=", TypeComma: ",", TypeSemi: ";",
+	TypeLP: "(", TypeRP: ")", TypeShl: "<<", TypeShr: ">>", TypePow: "^",
+	TypeLT: "<", TypeLE: "<=", TypeGT: ">", TypeGE: ">=", TypeEQ: "==", TypeNE: "!=",
+	TypeAnd: "and", TypeOr: "or", TypeNot: "not",
+	TypeLBrace: "{", TypeRBrace: "}", TypeWhile: "while", TypeFor: "for",
+	TypeArrow: "->", TypeLet: "let", TypeIn: "in", TypeLBracket: "[", TypeRBracket: "]",
+	TypeColon: ":", TypeRange: "..", TypeNil: "nil", TypeCoalesce: "??",
+	TypeTry: "try", TypeCatch: "catch", TypeMatch: "match", TypeFatArrow: "=>",
+	TypeImport: "import", TypePercent: "%",
+}
+
+// ERR_EOF ...
+var ERR_EOF error = errors.New("EOF")
+
+// Token ...
+type Token struct {
+	Type  Type
+	Value interface{}
+	// Pos of the first character of the token and End of the character
+	// following it, zero for tokens not read from a text
+	Pos, End Position
+}
+
+// Span of source text
+type Span struct{ Start, End Position }
+
+// Span of the token
+func (t Token) Span() Span { return Span{t.Pos, t.End} }
+
+// SpanError locates Err at a span of the source, for diagnostics
+type SpanError struct {
+	Span Span
+	Err  error
+}
+
+func (e SpanError) Error() string { return e.Err.Error() }
+
+// Unwrap ...
+func (e SpanError) Unwrap() error { return e.Err }
+
+// SpanOf returns the span of a token or node, zero if unknown
+func SpanOf(x any) Span {
+	if s, ok := x.(interface{ Span() Span }); ok {
+		return s.Span()
+	}
+	return Span{}
+}
+
+// At describes the position of the token for error messages, empty if it
+// is unknown
+func (t Token) At() string {
+	if t.Pos.Line == 0 {
+		return ""
+	}
+	return " at " + t.Pos.String()
+}
+
+// Locate err at the span of the token, if it is known
+func (t Token) Locate(err error) error {
+	if t.Pos.Line == 0 {
+		return err
+	}
+	return SpanError{t.Span(), err}
+}
+
+// IToken ...
+type IToken interface {
+	FToken() // should be a unique random name, just to use polymorphisme
+}
+
+// FToken ...
+func (t Token) FToken() {}
+
+// TokenType ...
+func (t Token) TokenType() Type { return t.Type }
+
+// TokenPlus ...
+type TokenPlus struct{ Token }
+
+// NewTokenPlus ...
+func NewTokenPlus() TokenPlus { return TokenPlus{Token{Type: TypePlus}} }
+
+// Eval ...
+func (t TokenPlus) Eval(left, right IExpression) (float64, error) {
+	l, r, err := Operands(left, right)
+	return l + r, err
+}
+
+// EvalInt ...
+func (t TokenPlus) EvalInt(l, r int64) (int64, bool, error) {
+	v, ok := addInt(l, r)
+	return v, ok, nil
+}
+
+// TokenMinus ...
+type TokenMinus struct{ Token }
+
+// NewTokenMinus ...
+func NewTokenMinus() TokenMinus { return TokenMinus{Token{Type: TypeMinus}} }
+
+// Eval ...
+func (t TokenMinus) Eval(left, right IExpression) (float64, error) {
+	l, r, err := Operands(left, right)
+	return l - r, err
+}
+
+// EvalInt ...
+func (t TokenMinus) EvalInt(l, r int64) (int64, bool, error) {
+	if r == math.MinInt64 {
+		return 0, false, nil
+	}
+	v, ok := addInt(l, -r)
+	return v, ok, nil
+}
+
+// TokenMul ...
+type TokenMul struct{ Token }
+
+// NewTokenMul ...
+func NewTokenMul() TokenMul { return TokenMul{Token{Type: TypeMul}} }
+
+// Eval ...
+func (t TokenMul) Eval(left, right IExpression) (float64, error) {
+	l, r, err := Operands(left, right)
+	return l * r, err
+}
+
+// EvalInt ...
+func (t TokenMul) EvalInt(l, r int64) (int64, bool, error) {
+	v, ok := mulInt(l, r)
+	return v, ok, nil
+}
+
+// TokenDiv ...
+type TokenDiv struct{ Token }
+
+// NewTokenDiv ...
+func NewTokenDiv() TokenDiv { return TokenDiv{Token{Type: TypeDiv}} }
+
+// Eval fails with ErrDivideByZero if right is 0
+func (t TokenDiv) Eval(left, right IExpression) (float64, error) {
+	l, r, err := Operands(left, right)
+	if err != nil {
+		return 0, err
+	}
+	return t.Divide(l, r)
+}
+
+// Divide l by r, failing with ErrDivideByZero at the position of the token
+// if r is 0
+func (t TokenDiv) Divide(l, r float64) (float64, error) {
+	if r == 0 {
+		return 0, t.Locate(fmt.Errorf("%w%v", ErrDivideByZero, t.At()))
+	}
+	return l / r, nil
+}
+
+// EvalInt yields an integer only if l is a multiple of r
+func (t TokenDiv) EvalInt(l, r int64) (int64, bool, error) {
+	if r == 0 {
+		_, err := t.Divide(float64(l), 0)
+		return 0, false, err
+	}
+	if l%r != 0 || (l == math.MinInt64 && r == -1) {
+		return 0, false, nil
+	}
+	return l / r, true, nil
+}
+
+// TokenShl ...
+type TokenShl struct{ Token }
+
+// NewTokenShl ...
+func NewTokenShl() TokenShl { return TokenShl{Token{Type: TypeShl}} }
+
+// Eval shifts the integer part of left by right bits
+func (t TokenShl) Eval(left, right IExpression) (float64, error) {
+	l, r, err := Operands(left, right)
+	return math.Trunc(l) * math.Pow(2, math.Trunc(r)), err
+}
+
+// EvalInt ...
+func (t TokenShl) EvalInt(l, r int64) (int64, bool, error) {
+	if r < 0 || r >= 63 || l<<r>>r != l {
+		return 0, false, nil
+	}
+	return l << r, true, nil
+}
+
+// TokenShr ...
+type TokenShr struct{ Token }
+
+// NewTokenShr ...
+func NewTokenShr() TokenShr { return TokenShr{Token{Type: TypeShr}} }
+
+// Eval shifts the integer part of left by right bits, keeping the sign
+func (t TokenShr) Eval(left, right IExpression) (float64, error) {
+	l, r, err := Operands(left, right)
+	return math.Floor(math.Trunc(l) / math.Pow(2, math.Trunc(r))), err
+}
+
+// EvalInt ...
+func (t TokenShr) EvalInt(l, r int64) (int64, bool, error) {
+	if r < 0 {
+		return 0, false, nil
+	}
+	return l >> min(r, 63), true, nil
+}
+
+// TokenPow ...
+type TokenPow struct{ Token }
+
+// NewTokenPow ...
+func NewTokenPow() TokenPow { return TokenPow{Token{Type: TypePow}} }
+
+// Eval raises left to the power right
+func (t TokenPow) Eval(left, right IExpression) (float64, error) {
+	l, r, err := Operands(left, right)
+	return math.Pow(l, r), err
+}
+
+// EvalInt yields an integer for non negative powers
+func (t TokenPow) EvalInt(l, r int64) (int64, bool, error) {
+	if r < 0 {
+		return 0, false, nil
+	}
+	v, ok := int64(1), true
+	// square and multiply, stopping at the first overflow
+	for ; r > 0 && ok; r >>= 1 {
+		if r&1 == 1 {
+			v, ok = mulInt(v, l)
+		}
+		if r > 1 && ok {
+			l, ok = mulInt(l, l)
+		}
+	}
+	return v, ok, nil
+}
+
+// TokenLT ...
+type TokenLT struct{ Token }
+
+// NewTokenLT ...
+func NewTokenLT() TokenLT { return TokenLT{Token{Type: TypeLT}} }
+
+// Eval ...
+func (t TokenLT) Eval(left, right IExpression) (float64, error) { return compare(t, left, right) }
+
+// Holds ...
+func (t TokenLT) Holds(order int) bool { return order < 0 }
+
+// TokenLE ...
+type TokenLE struct{ Token }
+
+// NewTokenLE ...
+func NewTokenLE() TokenLE { return TokenLE{Token{Type: TypeLE}} }
+
+// Eval ...
+func (t TokenLE) Eval(left, right IExpression) (float64, error) { return compare(t, left, right) }
+
+// Holds ...
+func (t TokenLE) Holds(order int) bool { return order <= 0 }
+
+// TokenGT ...
+type TokenGT struct{ Token }
+
+// NewTokenGT ...
+func NewTokenGT() TokenGT { return TokenGT{Token{Type: TypeGT}} }
+
+// Eval ...
+func (t TokenGT) Eval(left, right IExpression) (float64, error) { return compare(t, left, right) }
+
+// Holds ...
+func (t TokenGT) Holds(order int) bool { return order > 0 }
+
+// TokenGE ...
+type TokenGE struct{ Token }
+
+// NewTokenGE ...
+func NewTokenGE() TokenGE { return TokenGE{Token{Type: TypeGE}} }
+
+// Eval ...
+func (t TokenGE) Eval(left, right IExpression) (float64, error) { return compare(t, left, right) }
+
+// Holds ...
+func (t TokenGE) Holds(order int) bool { return order >= 0 }
+
+// TokenEQ ...
+type TokenEQ struct{ Token }
+
+// NewTokenEQ ...
+func NewTokenEQ() TokenEQ { return TokenEQ{Token{Type: TypeEQ}} }
+
+// Eval ...
+func (t TokenEQ) Eval(left, right IExpression) (float64, error) { return compare(t, left, right) }
+
+// Holds ...
+func (t TokenEQ) Holds(order int) bool { return order == 0 }
+
+// TokenNE ...
+type TokenNE struct{ Token }
+
+// NewTokenNE ...
+func NewTokenNE() TokenNE { return TokenNE{Token{Type: TypeNE}} }
+
+// Eval ...
+func (t TokenNE) Eval(left, right IExpression) (float64, error) { return compare(t, left, right) }
+
+// Holds ...
+func (t TokenNE) Holds(order int) bool { return order != 0 }
+
+// Comparison is an operation yielding a boolean, 1 for true and 0 for false
+// as a float64. Holds tells if the comparison holds for operands of order
+// -1, 0 or +1, like the result of cmp.Compare.
+type Comparison interface {
+	Operation
+	Holds(order int) bool
+}
+
+// Unordered is the order of comparisons involving NaN, only != holding
+const Unordered = 2
+
+// Order of l and r, Unordered if one of them is NaN
+func Order(l, r float64) int {
+	if math.IsNaN(l) || math.IsNaN(r) {
+		return Unordered
+	}
+	return cmp.Compare(l, r)
+}
+
+// Bool is 1 for true and 0 for false
+func Bool(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func compare(c Comparison, left, right IExpression) (float64, error) {
+	l, r, err := Operands(left, right)
+	if err != nil {
+		return 0, err
+	}
+	return Bool(c.Holds(Order(l, r))), nil
+}
+
+// TokenAnd ...
+type TokenAnd struct{ Token }
+
+// NewTokenAnd ...
+func NewTokenAnd() TokenAnd { return TokenAnd{Token{Type: TypeAnd}} }
+
+// Eval only evaluates right if left is true
+func (t TokenAnd) Eval(left, right IExpression) (float64, error) { return logical(t, left, right) }
+
+// Decides ...
+func (t TokenAnd) Decides(left bool) bool { return !left }
+
+// TokenOr ...
+type TokenOr struct{ Token }
+
+// NewTokenOr ...
+func NewTokenOr() TokenOr { return TokenOr{Token{Type: TypeOr}} }
+
+// Eval only evaluates right if left is false
+func (t TokenOr) Eval(left, right IExpression) (float64, error) { return logical(t, left, right) }
+
+// Decides ...
+func (t TokenOr) Decides(left bool) bool { return left }
+
+// Logical is a short-circuit operation: Decides tells if the value of the
+// left operand is the result, the right operand being evaluated otherwise.
+// Non zero operands are true.
+type Logical interface {
+	Operation
+	Decides(left bool) bool
+}
+
+func logical(op Logical, left, right IExpression) (float64, error) {
+	l, err := left.Eval()
+	if err != nil || op.Decides(l != 0) {
+		return Bool(l != 0), err
+	}
+	r, err := right.Eval()
+	return Bool(r != 0), err
+}
+
+// TokenNot ...
+type TokenNot struct{ Token }
+
+// NewTokenNot ...
+func NewTokenNot() TokenNot { return TokenNot{Token{Type: TypeNot}} }
+
+// Eval negates right, left being ignored like for the other unary operators
+func (t TokenNot) Eval(left, right IExpression) (float64, error) {
+	r, err := right.Eval()
+	return Bool(r == 0), err
+}
+
+// TokenSemi separates statements, read from a semicolon
+type TokenSemi struct{ Token }
+
+// NewTokenSemi ...
+func NewTokenSemi() TokenSemi { return TokenSemi{Token{Type: TypeSemi}} }
+
+// TokenNewline separates statements like TokenSemi, read from a newline
+// ending an expression
+type TokenNewline struct{ Token }
+
+// NewTokenNewline ...
+func NewTokenNewline() TokenNewline { return TokenNewline{Token{Type: TypeNewline}} }
+
+// TokenComment is a comment, its text starting with #
+type TokenComment struct{ Token }
+
+// TokenEOF ends the tokens of a text, at its end
+type TokenEOF struct{ Token }
+
+// NewTokenEOF ...
+func NewTokenEOF() TokenEOF { return TokenEOF{Token{Type: TypeEOF}} }
+
+// Separates tells if token separates statements
+func Separates(token IToken) bool {
+	switch token.(type) {
+	case TokenSemi, TokenNewline:
+		return true
+	}
+	return false
+}
+
+// TokenLP ...
+type TokenLP struct{ Token }
+
+// NewTokenLP ...
+func NewTokenLP() TokenLP { return TokenLP{Token{Type: TypeLP}} }
+
+// TokenRP ...
+type TokenRP struct{ Token }
+
+// NewTokenRP ...
+func NewTokenRP() TokenRP { return TokenRP{Token{Type: TypeRP}} }
+
+// TokenLBrace opens a block
+type TokenLBrace struct{ Token }
+
+// NewTokenLBrace ...
+func NewTokenLBrace() TokenLBrace { return TokenLBrace{Token{Type: TypeLBrace}} }
+
+// TokenRBrace closes a block
+type TokenRBrace struct{ Token }
+
+// NewTokenRBrace ...
+func NewTokenRBrace() TokenRBrace { return TokenRBrace{Token{Type: TypeRBrace}} }
+
+// TokenLBracket opens a list or an index
+type TokenLBracket struct{ Token }
+
+// NewTokenLBracket ...
+func NewTokenLBracket() TokenLBracket { return TokenLBracket{Token{Type: TypeLBracket}} }
+
+// TokenRBracket closes a list or an index
+type TokenRBracket struct{ Token }
+
+// NewTokenRBracket ...
+func NewTokenRBracket() TokenRBracket { return TokenRBracket{Token{Type: TypeRBracket}} }
+
+// TokenColon separates the keys of a map from their values
+type TokenColon struct{ Token }
+
+// NewTokenColon ...
+func NewTokenColon() TokenColon { return TokenColon{Token{Type: TypeColon}} }
+
+// TokenRange separates the bounds of a range
+type TokenRange struct{ Token }
+
+// NewTokenRange ...
+func NewTokenRange() TokenRange { return TokenRange{Token{Type: TypeRange}} }
+
+// TokenCoalesce separates a value from the one replacing it if it is nil
+type TokenCoalesce struct{ Token }
+
+// NewTokenCoalesce ...
+func NewTokenCoalesce() TokenCoalesce { return TokenCoalesce{Token{Type: TypeCoalesce}} }
+
+// TokenPercent follows a percentage, 10% being 0.1
+type TokenPercent struct{ Token }
+
+// NewTokenPercent ...
+func NewTokenPercent() TokenPercent { return TokenPercent{Token{Type: TypePercent}} }
+
+// TokenTry is the try keyword
+type TokenTry struct{ Token }
+
+// NewTokenTry ...
+func NewTokenTry() TokenTry { return TokenTry{Token{Type: TypeTry}} }
+
+// TokenCatch is the catch keyword
+type TokenCatch struct{ Token }
+
+// NewTokenCatch ...
+func NewTokenCatch() TokenCatch { return TokenCatch{Token{Type: TypeCatch}} }
+
+// TokenMatch is the match keyword
+type TokenMatch struct{ Token }
+
+// NewTokenMatch ...
+func NewTokenMatch() TokenMatch { return TokenMatch{Token{Type: TypeMatch}} }
+
+// TokenImport is the import keyword
+type TokenImport struct{ Token }
+
+// NewTokenImport ...
+func NewTokenImport() TokenImport { return TokenImport{Token{Type: TypeImport}} }
+
+// TokenWhile is the while keyword
+type TokenWhile struct{ Token }
+
+// NewTokenWhile ...
+func NewTokenWhile() TokenWhile { return TokenWhile{Token{Type: TypeWhile}} }
+
+// TokenFor is the for keyword
+type TokenFor struct{ Token }
+
+// NewTokenFor ...
+func NewTokenFor() TokenFor { return TokenFor{Token{Type: TypeFor}} }
+
+// TokenArrow separates the parameters of a lambda from its body
+type TokenArrow struct{ Token }
+
+// NewTokenArrow ...
+func NewTokenArrow() TokenArrow { return TokenArrow{Token{Type: TypeArrow}} }
+
+// TokenFatArrow separates the pattern of a match arm from its value
+type TokenFatArrow struct{ Token }
+
+// NewTokenFatArrow ...
+func NewTokenFatArrow() TokenFatArrow { return TokenFatArrow{Token{Type: TypeFatArrow}} }
+
+// TokenLet is the let keyword
+type TokenLet struct{ Token }
+
+// NewTokenLet ...
+func NewTokenLet() TokenLet { return TokenLet{Token{Type: TypeLet}} }
+
+// TokenIn is the in keyword
+type TokenIn struct{ Token }
+
+// NewTokenIn ...
+func NewTokenIn() TokenIn { return TokenIn{Token{Type: TypeIn}} }
+
+// TokenInt ...
+type TokenInt struct{ Token }
+
+// NewTokenInt ...
+func NewTokenInt(value int64) TokenInt { return TokenInt{Token{Type: TypeInt, Value: value}} }
+
+// TokenBool is the true or false literal
+type TokenBool struct{ Token }
+
+// NewTokenBool ...
+func NewTokenBool(value bool) TokenBool { return TokenBool{Token{Type: TypeBool, Value: value}} }
+
+// TokenNil is the nil literal, the value of nothing
+type TokenNil struct{ Token }
+
+// NewTokenNil ...
+func NewTokenNil() TokenNil { return TokenNil{Token{Type: TypeNil}} }
+
+// TokenString is a string literal, Value holding the unquoted string
+type TokenString struct{ Token }
+
+// NewTokenString ...
+func NewTokenString(value string) TokenString {
+	return TokenString{Token{Type: TypeString, Value: value}}
+}
+
+// ErrNotANumber is reported when a string is used as a number
+var ErrNotANumber = errors.New("not a number")
+
+// ErrNil is reported when nil is used as a number
+var ErrNil = errors.New("nil is not a number")
+
+// TokenFloat ...
+type TokenFloat struct {
+	Token
+	// Int is the exact value of the integer literals too large for an int64,
+	// which are promoted to floats, nil for the other floats
+	Int *big.Int
+}
+
+// NewTokenFloat ...
+func NewTokenFloat(value float64) TokenFloat {
+	return TokenFloat{Token{Type: TypeFloat, Value: value}, nil}
+}
+
+// TokenAssign ...
+type TokenAssign struct{ Token }
+
+// NewTokenAssign ...
+func NewTokenAssign() TokenAssign { return TokenAssign{Token{Type: TypeAssign}} }
+
+// TokenComma ...
+type TokenComma struct{ Token }
+
+// NewTokenComma ...
+func NewTokenComma() TokenComma { return TokenComma{Token{Type: TypeComma}} }
+
+// TokenIdent ...
+type TokenIdent struct{ Token }
+
+// NewTokenIdent ...
+func NewTokenIdent(name string) TokenIdent { return TokenIdent{Token{Type: TypeIdent, Value: name}} }
+
+// IExpression ...
+type IExpression interface {
+	Eval() (float64, error)
+}
+
+// Eval ...
+func (t TokenInt) Eval() (float64, error) { return float64(t.Value.(int64)), nil }
+
+// Eval ...
+func (t TokenFloat) Eval() (float64, error) { return t.Value.(float64), nil }
+
+// Eval fails, strings having no numeric value
+func (t TokenString) Eval() (float64, error) {
+	return 0, t.Locate(fmt.Errorf("string %q is %w%v", t.Value, ErrNotANumber, t.At()))
+}
+
+// Eval ...
+func (t TokenBool) Eval() (float64, error) { return Bool(t.Value.(bool)), nil }
+
+// Eval fails, nil having no numeric value
+func (t TokenNil) Eval() (float64, error) { return 0, t.Locate(fmt.Errorf("%w%v", ErrNil, t.At())) }
+
+// Operation ...
+type Operation interface {
+	Eval(left, right IExpression) (float64, error)
+}
+
+// IntOperation is an operation with an integer arithmetic. EvalInt returns
+// false when the result is not an integer or overflows an int64, for the
+// caller to fall back to Eval.
+type IntOperation interface {
+	EvalInt(l, r int64) (int64, bool, error)
+}
+
+func addInt(l, r int64) (int64, bool) {
+	v := l + r
+	return v, (v > l) == (r > 0)
+}
+
+func mulInt(l, r int64) (int64, bool) {
+	if l == 0 || r == 0 {
+		return 0, true
+	}
+	v := l * r
+	return v, v/r == l && !(l == -1 && r == math.MinInt64) && !(r == -1 && l == math.MinInt64)
+}
+
+// ErrDivideByZero is reported by divisions by zero
+var ErrDivideByZero = errors.New("division by zero")
+
+// ErrUnknownToken is reported for the characters starting no token
+var ErrUnknownToken = errors.New("unknown token")
+
+// ErrTooManyTokens is reported for texts of more than MaxTokens tokens
+var ErrTooManyTokens = errors.New("too many tokens")
+
+// Operands evaluates left then right, stopping at the first error
+func Operands(left, right IExpression) (float64, float64, error) {
+	l, err := left.Eval()
+	if err != nil {
+		return 0, 0, err
+	}
+	r, err := right.Eval()
+	if err != nil {
+		return 0, 0, err
+	}
+	return l, r, nil
+}
+
+// String ...
+func (t Token) String() string {
+	if t.Value == nil {
+		return string(t.Type)
+
+	}
+	switch t.Type {
+	case TypeFloat:
+		return fmt.Sprintf("%v:%.3f", t.Type, t.Value)
+	case TypeString:
+		return fmt.Sprintf("%v:%q", t.Type, t.Value)
+	}
+	return fmt.Sprintf("%v:%v", t.Type, t.Value)
+}
+
+// Tokens ...
+type Tokens []IToken
+
+// Add ...
+func (t Tokens) Add(tokens ...IToken) Tokens {
+	return append(t, tokens...)
+}
+
+// Filter returns the tokens of type typ
+func (t Tokens) Filter(typ Type) Tokens {
+	ret := Tokens{}
+	for _, token := range t {
+		if TypeOf(token) == typ {
+			ret = append(ret, token)
+		}
+	}
+	return ret
+}
+
+// All iterates over the tokens
+func (t Tokens) All() iter.Seq[IToken] {
+	return func(yield func(IToken) bool) {
+		for _, token := range t {
+			if !yield(token) {
+				return
+			}
+		}
+	}
+}
+
+// Incomplete tells if the tokens are an expression cut short: with
+// unclosed parentheses, brackets or braces, a try without catch, or ending
+// with an operator
+func (t Tokens) Incomplete() bool {
+	if n := len(t); n > 0 {
+		if _, ok := t[n-1].(TokenEOF); ok {
+			t = t[:n-1]
+		}
+	}
+	depth, blocks, tries := 0, 0, 0
+	for _, token := range t {
+		switch token.(type) {
+		case TokenLP, TokenLBracket:
+			depth++
+		case TokenRP, TokenRBracket:
+			depth--
+		case TokenLBrace:
+			blocks++
+		case TokenRBrace:
+			blocks--
+		case TokenTry:
+			tries++
+		case TokenCatch:
+			tries--
+		}
+	}
+	if depth > 0 || blocks > 0 || tries > 0 {
+		return true
+	}
+	if len(t) == 0 {
+		return false
+	}
+	return !Separates(t[len(t)-1]) && !endsExpression(t[len(t)-1])
+}
+
+// Positions of the tokens
+func (t Tokens) Positions() []Position {
+	ret := make([]Position, len(t))
+	for i, token := range t {
+		ret[i] = SpanOf(token).Start
+	}
+	return ret
+}
+
+// TypeOf returns the type of a token, empty if unknown
+func TypeOf(token IToken) Type {
+	if t, ok := token.(interface{ TokenType() Type }); ok {
+		return t.TokenType()
+	}
+	return ""
+}
@@ -0,0 +1,109 @@
+// Package lexp evaluates arithmetic expressions, see the lexer, parser and
+// eval packages for the details of each step.
+package lexp
+
+import (
+	"errors"
+
+	"github.com/fmarmol/lexp/eval"
+	"github.com/fmarmol/lexp/lexer"
+	"github.com/fmarmol/lexp/parser"
+)
+
+// ErrDivideByZero is reported by divisions by zero
+var ErrDivideByZero = lexer.ErrDivideByZero
+
+// ErrUnknownToken is reported for the characters starting no token
+var ErrUnknownToken = lexer.ErrUnknownToken
+
+// SyntaxError is the error of a LexError or a ParseError, at Pos in the
+// text if known. Err is the underlying error, like ErrUnknownToken.
+type SyntaxError struct {
+	Pos lexer.Position
+	Msg string
+	Err error
+}
+
+func (e SyntaxError) Error() string { return e.Msg }
+
+// Unwrap ...
+func (e SyntaxError) Unwrap() error { return e.Err }
+
+// RuntimeError is the error of an EvalError, at Pos in the text if known.
+// Err is the underlying error, like ErrDivideByZero.
+type RuntimeError struct {
+	Pos lexer.Position
+	Msg string
+	Err error
+}
+
+func (e RuntimeError) Error() string { return e.Msg }
+
+// Unwrap ...
+func (e RuntimeError) Unwrap() error { return e.Err }
+
+// position of err, zero if unknown
+func position(err error) lexer.Position {
+	var located lexer.SpanError
+	errors.As(err, &located)
+	return located.Span.Start
+}
+
+func syntaxError(err error) SyntaxError {
+	return SyntaxError{position(err), err.Error(), err}
+}
+
+func runtimeError(err error) RuntimeError {
+	return RuntimeError{position(err), err.Error(), err}
+}
+
+// LexError is returned when the text cannot be split into tokens
+type LexError struct{ Err error }
+
+func (e LexError) Error() string { return "lex: " + e.Err.Error() }
+
+// Unwrap ...
+func (e LexError) Unwrap() error { return e.Err }
+
+// ParseError is returned when the tokens do not form an expression
+type ParseError struct{ Err error }
+
+func (e ParseError) Error() string { return "parse: " + e.Err.Error() }
+
+// Unwrap ...
+func (e ParseError) Unwrap() error { return e.Err }
+
+// EvalError is returned when the evaluation fails, like for an undefined
+// variable
+type EvalError struct{ Err error }
+
+func (e EvalError) Error() string { return "eval: " + e.Err.Error() }
+
+// Unwrap ...
+func (e EvalError) Unwrap() error { return e.Err }
+
+// Eval lexes, parses and evaluates text in an empty environment. The
+// compiled expressions are kept in DefaultCache.
+func Eval(text string) (float64, error) {
+	return DefaultCache.Eval(text)
+}
+
+// parse text with the builtins and constants, within the depth and tokens
+// of limits
+func parse(text string, limits Limits) (lexer.IExpression, error) {
+	l := lexer.New("expr", text)
+	l.MaxTokens = limits.MaxTokens
+	tokens, err := l.MakeTokens()
+	if err != nil {
+		return nil, LexError{syntaxError(err)}
+	}
+	p := parser.New(tokens)
+	p.Funcs = eval.Builtins
+	p.Consts = eval.Constants
+	p.MaxDepth, p.MaxTokens = limits.MaxDepth, limits.MaxTokens
+	expr, err := p.Parse()
+	if err != nil {
+		return nil, ParseError{syntaxError(err)}
+	}
+	return expr, nil
+}
@@ -0,0 +1,36 @@
+package lexp
+
+import (
+	"github.com/fmarmol/lexp/eval"
+	"github.com/fmarmol/lexp/parser"
+)
+
+// ErrTooDeep is reported for expressions nested deeper than MaxDepth
+var ErrTooDeep = parser.ErrTooDeep
+
+// ErrTooManyTokens is reported for texts of more than MaxTokens tokens
+var ErrTooManyTokens = parser.ErrTooManyTokens
+
+// ErrTooManySteps is reported by evaluations of more than MaxSteps steps
+var ErrTooManySteps = eval.ErrTooManySteps
+
+// Limits guard a service evaluating untrusted expressions against
+// pathological input like deeply nested parentheses, 0 meaning no limit
+type Limits struct {
+	MaxDepth  int // nesting of the expressions
+	MaxTokens int // tokens of the text
+	MaxSteps  int // instructions run by an evaluation
+}
+
+// CompileWithLimits compiles text like Compile, failing if it exceeds the
+// depth or tokens of limits. Its evaluations fail beyond the steps of
+// limits.
+func CompileWithLimits(text string, limits Limits) (*Expr, error) {
+	node, err := parse(text, limits)
+	if err != nil {
+		return nil, err
+	}
+	expr := compile(text, node)
+	expr.steps = limits.MaxSteps
+	return expr, nil
+}
@@ -0,0 +1,484 @@
+package parser
+
+import (
+	"fmt"
+	"maps"
+	"sort"
+	"strings"
+
+	"github.com/fmarmol/lexp/lexer"
+)
+
+// BinOpNode ...
+type BinOpNode struct {
+	Left, Right lexer.IExpression
+	Op          lexer.Operation
+}
+
+func (b BinOpNode) String() string {
+	return fmt.Sprintf("(%v,%v,%v)", b.Left, b.Op, b.Right)
+}
+
+// Span from the left operand to the right one
+func (b BinOpNode) Span() lexer.Span {
+	return lexer.Span{Start: lexer.SpanOf(b.Left).Start, End: lexer.SpanOf(b.Right).End}
+}
+
+// Eval ...
+func (b BinOpNode) Eval() (float64, error) {
+	return b.Op.Eval(b.Left, b.Right)
+}
+
+// UnaryOpNode applies a prefix +, - or not to its operand, Op being
+// evaluated with 0 as its left operand so integer width wrapping applies
+type UnaryOpNode struct {
+	Op      lexer.Operation
+	Operand lexer.IExpression
+}
+
+func (u UnaryOpNode) String() string {
+	return fmt.Sprintf("(%v,%v)", u.Op, u.Operand)
+}
+
+// Span from the operator to the operand
+func (u UnaryOpNode) Span() lexer.Span {
+	return lexer.Span{Start: lexer.SpanOf(u.Op).Start, End: lexer.SpanOf(u.Operand).End}
+}
+
+// Eval ...
+func (u UnaryOpNode) Eval() (float64, error) {
+	return u.Op.Eval(lexer.NewTokenInt(0), u.Operand)
+}
+
+// OpType returns the type of an operation, empty if unknown
+func OpType(op lexer.Operation) lexer.Type {
+	if t, ok := op.(interface{ TokenType() lexer.Type }); ok {
+		return t.TokenType()
+	}
+	return ""
+}
+
+// Env maps variable names to their values
+type Env map[string]float64
+
+// Register adds consts to e qualified by the name of their module, like
+// math.pi
+func (e Env) Register(module string, consts Env) {
+	for name, v := range consts {
+		e[module+"."+name] = v
+	}
+}
+
+// Names of the variables, sorted
+func (e Env) Names() []string {
+	names := make([]string, 0, len(e))
+	for name := range e {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Undefined returns the names of the variables of node missing from env
+// and not assigned before being used
+func (e Env) Undefined(node lexer.IExpression) []string {
+	return e.undefined(node, map[string]bool{})
+}
+
+// Assigned returns the names of the variables and the functions node
+// assigns outside of functions, sorted, to suggest besides the defined ones
+func (e Env) Assigned(node lexer.IExpression) []string {
+	assigned := map[string]bool{}
+	Walk(node, func(node lexer.IExpression) bool {
+		switch n := node.(type) {
+		case AssignNode:
+			assigned[n.Name] = true
+		case DefNode:
+			assigned[n.Name] = true
+			return false
+		case ForNode:
+			assigned[n.Var] = true
+		case LambdaNode:
+			return false
+		}
+		return true
+	})
+	names := make([]string, 0, len(assigned))
+	for name := range assigned {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (e Env) undefined(node lexer.IExpression, assigned map[string]bool) []string {
+	switch n := node.(type) {
+	case VarNode:
+		if _, ok := e[n.Name()]; !ok && !assigned[n.Name()] {
+			_, value := n.Values[n.Name()]
+			if _, f := n.Funcs[n.Name()]; !value && !f {
+				return []string{n.Name()}
+			}
+		}
+	case BinOpNode:
+		return append(e.undefined(n.Left, assigned), e.undefined(n.Right, assigned)...)
+	case UnaryOpNode:
+		return e.undefined(n.Operand, assigned)
+	case CallNode:
+		undefined := []string{}
+		args := n.Args
+		if f := n.Funcs[n.Name()]; f.Binds && len(args) == f.MaxArgs {
+			if v, ok := args[1].(VarNode); ok {
+				local := maps.Clone(assigned)
+				local[v.Name()] = true
+				undefined = e.undefined(args[0], local)
+				args = args[2:]
+			}
+		}
+		for _, arg := range args {
+			if v, ok := arg.(VarNode); ok && n.Funcs[v.Name()].Call != nil {
+				// a function passed to another one
+				continue
+			}
+			undefined = append(undefined, e.undefined(arg, assigned)...)
+		}
+		return undefined
+	case ListNode:
+		undefined := []string{}
+		for _, item := range n.Items {
+			undefined = append(undefined, e.undefined(item, assigned)...)
+		}
+		return undefined
+	case CoalesceNode:
+		return append(e.undefined(n.Left, assigned), e.undefined(n.Right, assigned)...)
+	case TryNode:
+		// catching the variables undefined in the body
+		e.undefined(n.Body, assigned)
+		return e.undefined(n.Catch, assigned)
+	case ImportNode:
+		// the variables assigned in the file are defined after the import
+		return e.undefined(n.Program, assigned)
+	case MatchNode:
+		undefined := []string{}
+		for _, child := range Children(n) {
+			undefined = append(undefined, e.undefined(child, assigned)...)
+		}
+		return undefined
+	case RangeNode:
+		undefined := []string{}
+		for _, bound := range Children(n) {
+			undefined = append(undefined, e.undefined(bound, assigned)...)
+		}
+		return undefined
+	case PercentNode:
+		undefined := []string{}
+		for _, child := range Children(n) {
+			undefined = append(undefined, e.undefined(child, assigned)...)
+		}
+		return undefined
+	case MapNode:
+		undefined := []string{}
+		for _, item := range n.Items {
+			undefined = append(undefined, e.undefined(item, assigned)...)
+		}
+		return undefined
+	case IndexNode:
+		return append(e.undefined(n.Target, assigned), e.undefined(n.Index, assigned)...)
+	case AssignNode:
+		undefined := e.undefined(n.Value, assigned)
+		assigned[n.Name] = true
+		return undefined
+	case SeqNode:
+		undefined := []string{}
+		for _, item := range n.Items {
+			undefined = append(undefined, e.undefined(item, assigned)...)
+		}
+		return undefined
+	case ProgramNode:
+		undefined := []string{}
+		for _, statement := range n.Statements {
+			undefined = append(undefined, e.undefined(statement, assigned)...)
+		}
+		return undefined
+	case WhileNode:
+		return append(e.undefined(n.Cond, assigned), e.undefined(n.Body, assigned)...)
+	case ForNode:
+		undefined := append(e.undefined(n.Start, assigned), e.undefined(n.End, assigned)...)
+		if n.Step != nil {
+			undefined = append(undefined, e.undefined(n.Step, assigned)...)
+		}
+		assigned[n.Var] = true
+		return append(undefined, e.undefined(n.Body, assigned)...)
+	case LambdaNode:
+		// the assignments of the body are local to its calls
+		local := maps.Clone(assigned)
+		for _, param := range n.Params {
+			local[param] = true
+		}
+		return e.undefined(n.Body, local)
+	case DefNode:
+		undefined := e.undefined(n.Lambda, assigned)
+		// the function can be passed to others by name
+		assigned[n.Name] = true
+		return undefined
+	case LetNode:
+		undefined := e.undefined(n.Value, assigned)
+		// the variable is bound for the body only
+		was := assigned[n.Name]
+		assigned[n.Name] = true
+		undefined = append(undefined, e.undefined(n.Body, assigned)...)
+		assigned[n.Name] = was
+		return undefined
+	}
+	return nil
+}
+
+// VarNode is a variable, or a function passed by its name in Funcs
+type VarNode struct {
+	lexer.Token
+	Env    Env
+	Values Values
+	Funcs  Funcs
+}
+
+// Name of the variable
+func (v VarNode) Name() string { return v.Value.(string) }
+
+// Eval fails if the variable is not defined or is not a number
+func (v VarNode) Eval() (float64, error) {
+	if value, ok := v.Env[v.Name()]; ok {
+		return value, nil
+	}
+	if value, ok := v.Values[v.Name()]; ok && value == nil {
+		return 0, v.Locate(fmt.Errorf("%w, %v is nil%v", lexer.ErrNil, v.Name(), v.At()))
+	} else if ok {
+		return 0, v.Locate(fmt.Errorf("%v is not a number%v", v.Name(), v.At()))
+	}
+	if _, ok := v.Funcs[v.Name()]; ok {
+		return 0, v.Locate(fmt.Errorf("%w, %v is a function%v", ErrFunctionValue, v.Name(), v.At()))
+	}
+	return 0, undefinedVariable(v)
+}
+
+// Func is a function callable from expressions
+type Func struct {
+	MinArgs, MaxArgs int // MaxArgs is -1 for no limit
+	Call             func(args ...float64) (float64, error)
+	// Closure of the functions defined by expressions, nil for the others
+	Closure *Closure
+	// Binds tells if, called with MaxArgs arguments, the first one is an
+	// expression in the variable the second one names: integrate(x^2, x, 0, 1)
+	Binds bool
+	// Impure tells if the calls have side effects or results varying between
+	// calls with the same arguments, so that they are not cached
+	Impure bool
+	// Value implements the functions taking values which are not numbers,
+	// like strings or lists, for the evaluator it belongs to, nil for the
+	// others. Call only takes their numbers, if any.
+	Value any
+}
+
+// Funcs maps function names to their implementation
+type Funcs map[string]Func
+
+// Names of the functions, sorted
+func (f Funcs) Names() []string {
+	names := make([]string, 0, len(f))
+	for name := range f {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Register adds funcs to f qualified by the name of their module, floor of
+// the math module being called as math.floor
+func (f Funcs) Register(module string, funcs Funcs) {
+	for name, fn := range funcs {
+		f[module+"."+name] = fn
+	}
+}
+
+// CallNode calls the function named by its token
+type CallNode struct {
+	lexer.Token
+	Args  []lexer.IExpression
+	Funcs Funcs
+	Close lexer.Position // end of the closing parenthesis
+}
+
+// Name of the function
+func (c CallNode) Name() string { return c.Value.(string) }
+
+func (c CallNode) String() string {
+	args := make([]string, len(c.Args))
+	for i, arg := range c.Args {
+		args[i] = fmt.Sprint(arg)
+	}
+	return c.Name() + "(" + strings.Join(args, ",") + ")"
+}
+
+// Span from the name to the closing parenthesis
+func (c CallNode) Span() lexer.Span {
+	return lexer.Span{Start: c.Pos, End: c.Close}
+}
+
+// Eval evaluates the arguments from left to right then calls the function
+func (c CallNode) Eval() (float64, error) {
+	args := make([]float64, len(c.Args))
+	for i, arg := range c.Args {
+		var err error
+		if args[i], err = arg.Eval(); err != nil {
+			return 0, err
+		}
+	}
+	return c.Apply(args)
+}
+
+// Apply calls the function with evaluated arguments, failing if it is not
+// defined or args do not match its arity
+func (c CallNode) Apply(args []float64) (float64, error) {
+	f, err := c.Resolve(len(args))
+	if err != nil {
+		return 0, err
+	}
+	return f.Call(args...)
+}
+
+// Resolve the function called with n arguments, failing if it is not
+// defined or cannot take them
+func (c CallNode) Resolve(n int) (Func, error) {
+	f, ok := c.Funcs[c.Name()]
+	if !ok {
+		return Func{}, c.Locate(fmt.Errorf("undefined function %v%v%v", c.Name(), c.At(), DidYouMean(c.Name(), c.Funcs.Names())))
+	}
+	if err := f.CheckArgs(c.Name(), n); err != nil {
+		return Func{}, c.Locate(fmt.Errorf("%w%v", err, c.At()))
+	}
+	return f, nil
+}
+
+// CheckArgs fails if f, called name, cannot take n arguments
+func (f Func) CheckArgs(name string, n int) error {
+	if n < f.MinArgs || (f.MaxArgs >= 0 && n > f.MaxArgs) {
+		return fmt.Errorf("%v takes %v, got %v", name, f.arity(), n)
+	}
+	return nil
+}
+
+// arity describes the number of arguments f takes
+func (f Func) arity() string {
+	switch {
+	case f.MaxArgs < 0:
+		return "at least " + arguments(f.MinArgs)
+	case f.MinArgs == f.MaxArgs:
+		return arguments(f.MinArgs)
+	}
+	return fmt.Sprintf("%v to %v", f.MinArgs, arguments(f.MaxArgs))
+}
+
+func arguments(n int) string {
+	if n == 1 {
+		return "1 argument"
+	}
+	return fmt.Sprintf("%v arguments", n)
+}
+
+// AssignNode binds the value of an expression to a name
+type AssignNode struct {
+	Name    string
+	Value   lexer.IExpression
+	Env     Env
+	NamePos lexer.Position
+	Values  Values
+	Funcs   Funcs // where the functions assigned are bound
+}
+
+func (a AssignNode) String() string {
+	return fmt.Sprintf("(%v,%v,%v)", lexer.NewTokenIdent(a.Name), lexer.TypeAssign, a.Value)
+}
+
+// Span from the name to the value
+func (a AssignNode) Span() lexer.Span {
+	return lexer.Span{Start: a.NamePos, End: lexer.SpanOf(a.Value).End}
+}
+
+// Eval ...
+func (a AssignNode) Eval() (float64, error) {
+	v, err := a.Value.Eval()
+	if err != nil {
+		return 0, err
+	}
+	a.Env[a.Name] = v
+	delete(a.Values, a.Name)
+	return v, nil
+}
+
+// SeqNode evaluates expressions from left to right, yielding the last value
+type SeqNode struct {
+	Items []lexer.IExpression
+}
+
+func (s SeqNode) String() string {
+	items := make([]string, len(s.Items))
+	for i, item := range s.Items {
+		items[i] = fmt.Sprint(item)
+	}
+	return "(" + strings.Join(items, ","+string(lexer.TypeComma)+",") + ")"
+}
+
+// Span from the first item to the last one
+func (s SeqNode) Span() lexer.Span {
+	if len(s.Items) == 0 {
+		return lexer.Span{}
+	}
+	return lexer.Span{Start: lexer.SpanOf(s.Items[0]).Start, End: lexer.SpanOf(s.Items[len(s.Items)-1]).End}
+}
+
+// Eval ...
+func (s SeqNode) Eval() (float64, error) {
+	var v float64
+	for _, item := range s.Items {
+		var err error
+		if v, err = item.Eval(); err != nil {
+			return 0, err
+		}
+	}
+	return v, nil
+}
+
+// ProgramNode evaluates statements in order, yielding the last value
+type ProgramNode struct {
+	Statements []lexer.IExpression
+}
+
+func (p ProgramNode) String() string {
+	statements := make([]string, len(p.Statements))
+	for i, statement := range p.Statements {
+		statements[i] = fmt.Sprint(statement)
+	}
+	return "(" + strings.Join(statements, ","+string(lexer.TypeSemi)+",") + ")"
+}
+
+// Span from the first statement to the last one
+func (p ProgramNode) Span() lexer.Span {
+	if len(p.Statements) == 0 {
+		return lexer.Span{}
+	}
+	return lexer.Span{Start: lexer.SpanOf(p.Statements[0]).Start, End: lexer.SpanOf(p.Statements[len(p.Statements)-1]).End}
+}
+
+// Eval ...
+func (p ProgramNode) Eval() (float64, error) {
+	var v float64
+	for _, statement := range p.Statements {
+		var err error
+		if v, err = statement.Eval(); err != nil {
+			return 0, err
+		}
+	}
+	return v, nil
+}
+
+// NumberNode ...
+type NumberNode struct{ lexer.Token }
@@ -0,0 +1,102 @@
+package parser
+
+import "github.com/fmarmol/lexp/lexer"
+
+// Rebind returns a deep copy of node whose variables, assignments, loops,
+// lets and lambdas are bound to the environments env returns for the ones they are
+// bound to, and whose calls and function definitions to the tables funcs
+// returns, nil keeping them. Literals are shared, being immutable.
+func Rebind(node lexer.IExpression, env func(Env) Env, funcs func(Funcs) Funcs) lexer.IExpression {
+	return RebindValues(node, env, nil, funcs)
+}
+
+// RebindValues is Rebind, also binding the values of the variables,
+// assignments and lets to the tables values returns for theirs, nil keeping
+// them
+func RebindValues(node lexer.IExpression, env func(Env) Env, values func(Values) Values, funcs func(Funcs) Funcs) lexer.IExpression {
+	if values == nil {
+		values = func(v Values) Values { return v }
+	}
+	if funcs == nil {
+		funcs = func(f Funcs) Funcs { return f }
+	}
+	return (&binder{env, values, funcs}).bind(node)
+}
+
+type binder struct {
+	env    func(Env) Env
+	values func(Values) Values
+	funcs  func(Funcs) Funcs
+}
+
+func (b *binder) bind(node lexer.IExpression) lexer.IExpression {
+	switch n := node.(type) {
+	case VarNode:
+		return VarNode{n.Token, b.env(n.Env), b.values(n.Values), b.funcs(n.Funcs)}
+	case BinOpNode:
+		return BinOpNode{b.bind(n.Left), b.bind(n.Right), n.Op}
+	case UnaryOpNode:
+		return UnaryOpNode{n.Op, b.bind(n.Operand)}
+	case CallNode:
+		return CallNode{n.Token, b.all(n.Args), b.funcs(n.Funcs), n.Close}
+	case AssignNode:
+		return AssignNode{n.Name, b.bind(n.Value), b.env(n.Env), n.NamePos, b.values(n.Values), b.funcs(n.Funcs)}
+	case SeqNode:
+		return SeqNode{b.all(n.Items)}
+	case ProgramNode:
+		return ProgramNode{b.all(n.Statements)}
+	case WhileNode:
+		return WhileNode{n.Token, b.bind(n.Cond), b.bind(n.Body), n.Close}
+	case ForNode:
+		n.Start, n.End, n.Body, n.Env = b.bind(n.Start), b.bind(n.End), b.bind(n.Body), b.env(n.Env)
+		if n.Step != nil {
+			n.Step = b.bind(n.Step)
+		}
+		return n
+	case LambdaNode:
+		return LambdaNode{n.Token, n.Params, b.bind(n.Body), b.env(n.Env), b.funcs(n.Funcs)}
+	case DefNode:
+		return DefNode{n.Name, b.bind(n.Lambda).(LambdaNode), b.funcs(n.Funcs), n.NamePos}
+	case ListNode:
+		return ListNode{n.Token, b.all(n.Items), n.Close}
+	case IndexNode:
+		return IndexNode{n.Token, b.bind(n.Target), b.bind(n.Index), n.Close}
+	case MapNode:
+		return MapNode{n.Token, n.Keys, b.all(n.Items), n.Close}
+	case CoalesceNode:
+		return CoalesceNode{n.Token, b.bind(n.Left), b.bind(n.Right)}
+	case TryNode:
+		return TryNode{n.Token, b.bind(n.Body), b.bind(n.Catch)}
+	case ImportNode:
+		return ImportNode{n.Token, n.Path, b.bind(n.Program), n.Close}
+	case PercentNode:
+		return PercentNode{n.Token, b.bind(n.Percent), b.bind(n.Base), n.Op}
+	case MatchNode:
+		return MatchNode{n.Token, b.bind(n.Subject), b.arms(n.Arms), b.bind(n.Default), n.Close}
+	case RangeNode:
+		n.Start, n.End = b.bind(n.Start), b.bind(n.End)
+		if n.Step != nil {
+			n.Step = b.bind(n.Step)
+		}
+		return n
+	case LetNode:
+		return LetNode{n.Token, n.Name, n.NamePos, b.bind(n.Value), b.bind(n.Body), b.env(n.Env), b.values(n.Values)}
+	}
+	return node
+}
+
+func (b *binder) arms(arms []MatchArm) []MatchArm {
+	bound := make([]MatchArm, len(arms))
+	for i, arm := range arms {
+		bound[i] = MatchArm{b.bind(arm.Pattern), b.bind(arm.Value)}
+	}
+	return bound
+}
+
+func (b *binder) all(nodes []lexer.IExpression) []lexer.IExpression {
+	bound := make([]lexer.IExpression, len(nodes))
+	for i, node := range nodes {
+		bound[i] = b.bind(node)
+	}
+	return bound
+}
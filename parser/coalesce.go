@@ -0,0 +1,55 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/fmarmol/lexp/lexer"
+)
+
+// coalescePower is the binding power of ??, below the one of the infix
+// operators
+const coalescePower = 1
+
+// CoalesceNode is the value of Left, or the value of Right if nil is used
+// for Left, like in x ?? 0 or (x + 1) ?? 0 with x nil
+type CoalesceNode struct {
+	lexer.Token // ??
+	Left, Right lexer.IExpression
+}
+
+func (c CoalesceNode) String() string {
+	return fmt.Sprintf("(%v,%v,%v)", lexer.TypeCoalesce, c.Left, c.Right)
+}
+
+// Span from the left operand to the right one
+func (c CoalesceNode) Span() lexer.Span {
+	return lexer.Span{Start: lexer.SpanOf(c.Left).Start, End: lexer.SpanOf(c.Right).End}
+}
+
+// Eval only evaluates Right if Left fails because of nil
+func (c CoalesceNode) Eval() (float64, error) {
+	v, err := c.Left.Eval()
+	if errors.Is(err, lexer.ErrNil) {
+		return c.Right.Eval()
+	}
+	return v, err
+}
+
+// coalescing parses a chain of operators separated by ??, binding looser
+// than any operator
+func (p *Parser) coalescing() (lexer.IExpression, error) {
+	node, err := p.Binary(0)
+	for err == nil {
+		token, ok := p.CurrentToken.(lexer.TokenCoalesce)
+		if !ok {
+			return node, nil
+		}
+		p.Next()
+		var right lexer.IExpression
+		if right, err = p.Binary(0); err == nil {
+			node = CoalesceNode{token.Token, node, right}
+		}
+	}
+	return nil, err
+}
@@ -0,0 +1,196 @@
+package parser
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/fmarmol/lexp/lexer"
+)
+
+// Formula is a named expression
+type Formula struct {
+	Name string
+	Expr lexer.IExpression
+}
+
+// Variables returns the sorted names of the variables node refers to, the
+// parameters of its lambdas and the variables of its lets aside
+func Variables(node lexer.IExpression) []string {
+	seen := map[string]bool{}
+	Walk(node, func(node lexer.IExpression) bool {
+		switch n := node.(type) {
+		case VarNode:
+			seen[n.Name()] = true
+		case LambdaNode:
+			for _, name := range Variables(n.Body) {
+				if !slices.Contains(n.Params, name) {
+					seen[name] = true
+				}
+			}
+			return false
+		case LetNode:
+			for _, name := range Variables(n.Value) {
+				seen[name] = true
+			}
+			for _, name := range Variables(n.Body) {
+				if name != n.Name {
+					seen[name] = true
+				}
+			}
+			return false
+		}
+		return true
+	})
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// DependencyGraph of a set of formulas, variables which are not formulas
+// themselves are inputs
+type DependencyGraph struct {
+	Formulas map[string]Formula
+	Deps     map[string][]string
+}
+
+// NewDependencyGraph ...
+func NewDependencyGraph(formulas []Formula) (*DependencyGraph, error) {
+	g := &DependencyGraph{map[string]Formula{}, map[string][]string{}}
+	for _, f := range formulas {
+		if _, ok := g.Formulas[f.Name]; ok {
+			return nil, fmt.Errorf("formula %v defined twice", f.Name)
+		}
+		g.Formulas[f.Name] = f
+		g.Deps[f.Name] = Variables(f.Expr)
+	}
+	return g, nil
+}
+
+// Inputs returns the sorted variables which are not defined by a formula
+func (g *DependencyGraph) Inputs() []string {
+	seen := map[string]bool{}
+	inputs := []string{}
+	for _, name := range g.names() {
+		for _, dep := range g.Deps[name] {
+			if _, ok := g.Formulas[dep]; !ok && !seen[dep] {
+				seen[dep] = true
+				inputs = append(inputs, dep)
+			}
+		}
+	}
+	sort.Strings(inputs)
+	return inputs
+}
+
+// CycleError ...
+type CycleError struct {
+	Cycle []string
+}
+
+func (e CycleError) Error() string {
+	return fmt.Sprintf("dependency cycle: %v", strings.Join(e.Cycle, " -> "))
+}
+
+// Order returns the formulas names in a valid evaluation order, each
+// formula coming after the ones it depends on, or a CycleError
+func (g *DependencyGraph) Order() ([]string, error) {
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := map[string]int{}
+	order := []string{}
+	path := []string{}
+
+	var visit func(name string) error
+	visit = func(name string) error {
+		if _, ok := g.Formulas[name]; !ok {
+			return nil
+		}
+		switch state[name] {
+		case done:
+			return nil
+		case visiting:
+			for i, n := range path {
+				if n == name {
+					return CycleError{append(append([]string{}, path[i:]...), name)}
+				}
+			}
+		}
+		state[name] = visiting
+		path = append(path, name)
+		for _, dep := range g.Deps[name] {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[name] = done
+		order = append(order, name)
+		return nil
+	}
+	for _, name := range g.names() {
+		if err := visit(name); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// DOT exports the graph in the graphviz format, edges going from a formula
+// to its dependencies
+func (g *DependencyGraph) DOT() string {
+	b := &strings.Builder{}
+	fmt.Fprintln(b, "digraph formulas {")
+	for _, input := range g.Inputs() {
+		fmt.Fprintf(b, "\t%q [shape=box];\n", input)
+	}
+	for _, name := range g.names() {
+		fmt.Fprintf(b, "\t%q;\n", name)
+		for _, dep := range g.Deps[name] {
+			fmt.Fprintf(b, "\t%q -> %q;\n", name, dep)
+		}
+	}
+	fmt.Fprintln(b, "}")
+	return b.String()
+}
+
+func (g *DependencyGraph) names() []string {
+	names := make([]string, 0, len(g.Formulas))
+	for name := range g.Formulas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParseFormulas parses one formula per non blank line of text, replacing
+// the names of consts by their value
+func ParseFormulas(fileName, text string, consts Env) ([]Formula, error) {
+	formulas := []Formula{}
+	for i, line := range strings.Split(text, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		tokens, err := lexer.New(fileName, line).MakeTokens()
+		if err != nil {
+			return nil, fmt.Errorf("%v:%v: %w", fileName, i+1, err)
+		}
+		if len(tokens) == 1 {
+			continue // a comment alone
+		}
+		p := New(tokens)
+		p.Consts = consts
+		formula, err := p.ParseFormula()
+		if err != nil {
+			return nil, fmt.Errorf("%v:%v: %w", fileName, i+1, err)
+		}
+		formulas = append(formulas, formula)
+	}
+	return formulas, nil
+}
@@ -0,0 +1,80 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/fmarmol/lexp/lexer"
+)
+
+// Division semantics of the / operator
+type Division int
+
+const (
+	// DivTrue always divides as floats
+	DivTrue Division = iota
+	// DivTrunc truncates toward zero when both operands are integers
+	DivTrunc
+	// DivExact fails when integer operands do not divide evenly
+	DivExact
+)
+
+var divisionNames = map[Division]string{DivTrue: "true", DivTrunc: "trunc", DivExact: "exact"}
+
+// ErrInexactDivision is reported by DivExact when integers do not divide evenly
+var ErrInexactDivision = errors.New("inexact integer division")
+
+// ParseDivision parses true, trunc or exact
+func ParseDivision(spec string) (Division, error) {
+	for d, name := range divisionNames {
+		if name == spec {
+			return d, nil
+		}
+	}
+	return DivTrue, fmt.Errorf("unsupported division %q, expected true, trunc or exact", spec)
+}
+
+func (d Division) String() string { return divisionNames[d] }
+
+// DivOp divides according to the division semantics. Operands are integers
+// when they are lexer.TokenInt, 7.0 / 2 dividing as floats, variables
+// holding no static type being integers when their values are.
+type DivOp struct {
+	lexer.TokenDiv
+	Mode Division
+}
+
+// Eval ...
+func (o DivOp) Eval(left, right lexer.IExpression) (float64, error) {
+	l, r, err := lexer.Operands(left, right)
+	if err != nil {
+		return 0, err
+	}
+	v, err := o.Divide(l, r)
+	_, lInt := left.(lexer.TokenInt)
+	_, rInt := right.(lexer.TokenInt)
+	if err != nil || o.Mode == DivTrue || !lInt || !rInt {
+		return v, err
+	}
+	if o.Mode == DivExact && math.Mod(l, r) != 0 {
+		return 0, o.Locate(fmt.Errorf("%w: %v / %v%v", ErrInexactDivision, l, r, o.At()))
+	}
+	return math.Trunc(v), nil
+}
+
+// EvalInt divides integers, truncating toward zero in DivTrunc mode
+func (o DivOp) EvalInt(l, r int64) (int64, bool, error) {
+	if o.Mode == DivTrue || r == 0 || (l == math.MinInt64 && r == -1) {
+		return o.TokenDiv.EvalInt(l, r)
+	}
+	if o.Mode == DivExact && l%r != 0 {
+		return 0, false, o.Locate(fmt.Errorf("%w: %v / %v%v", ErrInexactDivision, l, r, o.At()))
+	}
+	return l / r, true, nil
+}
+
+func (o DivOp) String() string {
+	return fmt.Sprint(o.TokenDiv) + ":" + strings.ToUpper(o.Mode.String())
+}
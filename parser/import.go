@@ -0,0 +1,113 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/fmarmol/lexp/lexer"
+)
+
+// ErrImportCycle is reported by the files importing themselves, directly or
+// through other files
+var ErrImportCycle = errors.New("import cycle")
+
+// Importer reads the file at path, for the import statements
+type Importer func(path string) (string, error)
+
+// importError is a failure reading the tokens or the statements of File,
+// imported at At
+type importError struct {
+	File string
+	At   lexer.Position
+	Err  error
+}
+
+func (e importError) Error() string {
+	return fmt.Sprintf("%v, in %v imported at %v", e.Err, e.File, e.At)
+}
+
+func (e importError) Unwrap() error { return e.Err }
+
+// imported locates err in file imported at at, unless it is already located
+// in a file imported by file
+func imported(file string, at lexer.Position, err error) error {
+	if errors.As(err, new(importError)) {
+		return err
+	}
+	return importError{file, at, err}
+}
+
+// ImportNode evaluates the statements of the file at Path, sharing the
+// functions and the variables it defines
+type ImportNode struct {
+	lexer.Token // import keyword
+	Path        string
+	Program     lexer.IExpression
+	Close       lexer.Position // end of the path
+}
+
+func (i ImportNode) String() string {
+	return fmt.Sprintf("(%v,%q)", lexer.TypeImport, i.Path)
+}
+
+// Span from the keyword to the path
+func (i ImportNode) Span() lexer.Span {
+	return lexer.Span{Start: i.Pos, End: i.Close}
+}
+
+// Eval evaluates the statements of the file, yielding 0
+func (i ImportNode) Eval() (float64, error) {
+	if _, err := i.Program.Eval(); err != nil {
+		return 0, err
+	}
+	return 0, nil
+}
+
+// importFile parses import "path", path being relative to the directory of
+// the importing file, and the statements of the file with the settings of p
+func (p *Parser) importFile() (lexer.IExpression, error) {
+	keyword := p.CurrentToken.(lexer.TokenImport)
+	p.Next()
+	path, ok := p.CurrentToken.(lexer.TokenString)
+	if !ok {
+		return nil, p.errorf("expected the path of the imported file, got %v", p.current())
+	}
+	if p.Importer == nil {
+		return nil, p.errorf("import is only available in scripts, at line %v, col %v", keyword.Pos.Line, keyword.Pos.Column)
+	}
+	file := path.Value.(string)
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(filepath.Dir(keyword.Pos.FileName), file)
+	}
+	chain := p.imports
+	if len(chain) == 0 {
+		chain = []string{filepath.Clean(keyword.Pos.FileName)}
+	}
+	if slices.Contains(chain, file) {
+		return nil, p.errorf("%w %v", ErrImportCycle, strings.Join(append(slices.Clip(chain), file), " -> "))
+	}
+	text, err := p.Importer(file)
+	if err != nil {
+		return nil, p.errorf("cannot import %v: %w", strconv.Quote(path.Value.(string)), err)
+	}
+	tokens, err := lexer.NewImported(file, text, keyword.Pos).MakeTokens()
+	if err != nil {
+		return nil, imported(file, keyword.Pos, err)
+	}
+	sub := *p
+	sub.Tokens, sub.TokenIndex, sub.depth = tokens, -1, 0
+	sub.imports = append(slices.Clip(chain), file)
+	sub.Next()
+	var program lexer.IExpression = ProgramNode{[]lexer.IExpression{}}
+	if !sub.atEnd() {
+		if program, err = sub.Parse(); err != nil {
+			return nil, imported(file, keyword.Pos, err)
+		}
+	}
+	p.Next()
+	return ImportNode{keyword.Token, path.Value.(string), program, path.End}, nil
+}
@@ -0,0 +1,199 @@
+package parser
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/fmarmol/lexp/lexer"
+)
+
+// IntWidth of the fixed width integer mode, arithmetic wraps around like
+// Go's sized integer types
+type IntWidth struct {
+	Bits   int
+	Signed bool
+}
+
+// ParseIntWidth parses i8, i16, i32, i64, u8, u16, u32 or u64, and off as nil
+func ParseIntWidth(spec string) (*IntWidth, error) {
+	if spec == "off" {
+		return nil, nil
+	}
+	if len(spec) > 1 && (spec[0] == 'i' || spec[0] == 'u') {
+		bits, err := strconv.Atoi(spec[1:])
+		if err == nil && (bits == 8 || bits == 16 || bits == 32 || bits == 64) {
+			return &IntWidth{bits, spec[0] == 'i'}, nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported integer width %q, expected off, i8..i64 or u8..u64", spec)
+}
+
+func (w IntWidth) String() string {
+	if w.Signed {
+		return "i" + strconv.Itoa(w.Bits)
+	}
+	return "u" + strconv.Itoa(w.Bits)
+}
+
+// Wrap truncates v to an integer and wraps it around the width
+func (w IntWidth) Wrap(v float64) float64 {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return v
+	}
+	return w.Float(w.WrapFloat(v))
+}
+
+// WrapFloat truncates v to an integer and wraps it around the width, see
+// WrapInt
+func (w IntWidth) WrapFloat(v float64) int64 {
+	t := math.Trunc(v)
+	var u uint64
+	if math.Abs(t) < 1<<63 {
+		u = uint64(int64(t))
+	} else {
+		// beyond int64 the low bits are lost anyway, keep the modulo
+		m := math.Mod(t, 1<<64)
+		if m < 0 {
+			m += 1 << 64
+		}
+		if m >= 1<<63 {
+			u = uint64(int64(m - (1 << 64)))
+		} else {
+			u = uint64(m)
+		}
+	}
+	return w.WrapInt(u)
+}
+
+// WrapInt keeps the low bits of u within the width, sign extended for the
+// signed widths. The u64 integers from 2^63 are held by the negative int64
+// of the same bits.
+func (w IntWidth) WrapInt(u uint64) int64 {
+	if w.Bits < 64 {
+		u &= 1<<w.Bits - 1
+	}
+	if w.Signed && w.Bits < 64 && u&(1<<(w.Bits-1)) != 0 {
+		return int64(u) - 1<<w.Bits
+	}
+	return int64(u)
+}
+
+// Float value of the integer v of the width
+func (w IntWidth) Float(v int64) float64 {
+	if !w.Signed && v < 0 {
+		return float64(uint64(v))
+	}
+	return float64(v)
+}
+
+// WrappedOp applies an integer width to the result of an operation,
+// divisions truncating toward zero
+type WrappedOp struct {
+	lexer.Operation
+	Width IntWidth
+}
+
+// Eval ...
+func (o WrappedOp) Eval(left, right lexer.IExpression) (float64, error) {
+	v, err := o.Operation.Eval(left, right)
+	return o.Width.Wrap(v), err
+}
+
+// EvalInt computes the operation on integers of the width, l and r being
+// wrapped around it already. It is exact for all the integers of the width,
+// u64 ones included, and yields false if the operation has no integer
+// arithmetic, like a negative power.
+func (o WrappedOp) EvalInt(l, r int64) (int64, bool, error) {
+	w, ul, ur := o.Width, uint64(l), uint64(r)
+	switch OpType(o.Operation) {
+	case lexer.TypePlus:
+		return w.WrapInt(ul + ur), true, nil
+	case lexer.TypeMinus:
+		return w.WrapInt(ul - ur), true, nil
+	case lexer.TypeMul:
+		return w.WrapInt(ul * ur), true, nil
+	case lexer.TypeDiv:
+		switch {
+		case r == 0:
+			_, err := o.Operation.Eval(lexer.NewTokenInt(l), lexer.NewTokenInt(0))
+			return 0, false, err
+		case w.Signed:
+			// MinInt64 / -1 wraps around to MinInt64
+			return w.WrapInt(uint64(l / r)), true, nil
+		}
+		return w.WrapInt(ul / ur), true, nil
+	case lexer.TypeShl:
+		if w.Signed && r < 0 {
+			return 0, false, nil
+		}
+		if ur >= 64 {
+			return 0, true, nil
+		}
+		return w.WrapInt(ul << ur), true, nil
+	case lexer.TypeShr:
+		switch {
+		case w.Signed && r < 0:
+			return 0, false, nil
+		case w.Signed:
+			return l >> min(r, 63), true, nil
+		case ur >= 64:
+			return 0, true, nil
+		}
+		return w.WrapInt(ul >> ur), true, nil
+	case lexer.TypePow:
+		if w.Signed && r < 0 {
+			return 0, false, nil
+		}
+		// square and multiply, the low bits being exact
+		v := uint64(1)
+		for ; ur > 0; ur >>= 1 {
+			if ur&1 == 1 {
+				v *= ul
+			}
+			ul *= ul
+		}
+		return w.WrapInt(v), true, nil
+	}
+	return 0, false, nil
+}
+
+// Span of the wrapped operator
+func (o WrappedOp) Span() lexer.Span { return lexer.SpanOf(o.Operation) }
+
+// TokenType ...
+func (o WrappedOp) TokenType() lexer.Type { return OpType(o.Operation) }
+
+func (o WrappedOp) String() string {
+	return fmt.Sprint(o.Operation) + ":" + strings.ToUpper(o.Width.String())
+}
+
+// UnsignedComparison compares the integers of the u64 width, the ones from
+// 2^63 being held by negative int64s
+type UnsignedComparison struct {
+	lexer.Comparison
+}
+
+// Span of the comparison operator
+func (c UnsignedComparison) Span() lexer.Span { return lexer.SpanOf(c.Comparison) }
+
+// TokenType ...
+func (c UnsignedComparison) TokenType() lexer.Type { return OpType(c.Comparison) }
+
+// BitsView renders the integer v of the width as binary grouped by
+// nibbles, followed by its signed and unsigned interpretations
+func (w IntWidth) BitsView(v int64) string {
+	u := uint64(v)
+	if w.Bits < 64 {
+		u &= 1<<w.Bits - 1
+	}
+	digits := strconv.FormatUint(u, 2)
+	digits = strings.Repeat("0", w.Bits-len(digits)) + digits
+	groups := make([]string, 0, w.Bits/4)
+	for i := 0; i < len(digits); i += 4 {
+		groups = append(groups, digits[i:i+4])
+	}
+	signed := IntWidth{w.Bits, true}.WrapInt(u)
+	return fmt.Sprintf("%v  (i%v: %v, u%v: %v)", strings.Join(groups, " "), w.Bits, signed, w.Bits, u)
+}
@@ -0,0 +1,165 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/fmarmol/lexp/lexer"
+)
+
+// jsonNode is the JSON form of a node, see MarshalNode
+type jsonNode struct {
+	Kind     Kind       `json:"kind"`
+	Op       string     `json:"op,omitempty"`
+	Name     string     `json:"name,omitempty"`
+	Value    any        `json:"value,omitempty"`
+	Span     *jsonSpan  `json:"span,omitempty"`
+	Children []jsonNode `json:"children,omitempty"`
+}
+
+type jsonSpan struct {
+	Start jsonPosition `json:"start"`
+	End   jsonPosition `json:"end"`
+}
+
+type jsonPosition struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+	Offset int `json:"offset"` // in bytes
+}
+
+// MarshalNode encodes node, literals included, as a tree of objects with
+// the kind of the node, the symbol of its operator, the name of its
+// variable, function, assignment, loop or imported file, its literal value,
+// the parameters of a lambda or the keys of a map, its span in the source
+// and its children in evaluation order:
+//
+//	{"kind":"binary","op":"+","span":{...},"children":[...]}
+func MarshalNode(node lexer.IExpression) ([]byte, error) {
+	n, err := toJSON(node)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(n)
+}
+
+func toJSON(node lexer.IExpression) (jsonNode, error) {
+	n := jsonNode{Kind: KindOf(node)}
+	if n.Kind == "" {
+		return jsonNode{}, fmt.Errorf("cannot encode %T as JSON", node)
+	}
+	if op := Operator(node); op != nil {
+		n.Op = lexer.Symbols[OpType(op)]
+	}
+	switch t := node.(type) {
+	case VarNode:
+		n.Name = t.Name()
+	case CallNode:
+		n.Name = t.Name()
+	case AssignNode:
+		n.Name = t.Name
+	case ForNode:
+		n.Name = t.Var
+	case LambdaNode:
+		n.Value = t.Params
+	case DefNode:
+		n.Name = t.Name
+	case LetNode:
+		n.Name = t.Name
+	case MapNode:
+		n.Value = t.Keys
+	case ImportNode:
+		n.Name = t.Path
+	case PercentNode:
+		// the operator adding the percentage to its base, if any
+		if t.Relative() {
+			n.Op = lexer.Symbols[OpType(t.Op)]
+		}
+	case lexer.TokenInt:
+		n.Value = t.Value
+	case lexer.TokenFloat:
+		n.Value = t.Value
+	case lexer.TokenBool:
+		n.Value = t.Value
+	case lexer.TokenString:
+		n.Value = t.Value
+	}
+	if span := lexer.SpanOf(node); span.Start.Line > 0 {
+		n.Span = &jsonSpan{position(span.Start), position(span.End)}
+	}
+	for _, child := range Children(node) {
+		c, err := toJSON(child)
+		if err != nil {
+			return jsonNode{}, err
+		}
+		n.Children = append(n.Children, c)
+	}
+	return n, nil
+}
+
+func position(p lexer.Position) jsonPosition {
+	return jsonPosition{p.Line, p.Column, p.Index}
+}
+
+// MarshalJSON ...
+func (b BinOpNode) MarshalJSON() ([]byte, error) { return MarshalNode(b) }
+
+// MarshalJSON ...
+func (u UnaryOpNode) MarshalJSON() ([]byte, error) { return MarshalNode(u) }
+
+// MarshalJSON ...
+func (v VarNode) MarshalJSON() ([]byte, error) { return MarshalNode(v) }
+
+// MarshalJSON ...
+func (c CallNode) MarshalJSON() ([]byte, error) { return MarshalNode(c) }
+
+// MarshalJSON ...
+func (a AssignNode) MarshalJSON() ([]byte, error) { return MarshalNode(a) }
+
+// MarshalJSON ...
+func (s SeqNode) MarshalJSON() ([]byte, error) { return MarshalNode(s) }
+
+// MarshalJSON ...
+func (p ProgramNode) MarshalJSON() ([]byte, error) { return MarshalNode(p) }
+
+// MarshalJSON ...
+func (w WhileNode) MarshalJSON() ([]byte, error) { return MarshalNode(w) }
+
+// MarshalJSON ...
+func (f ForNode) MarshalJSON() ([]byte, error) { return MarshalNode(f) }
+
+// MarshalJSON ...
+func (l LambdaNode) MarshalJSON() ([]byte, error) { return MarshalNode(l) }
+
+// MarshalJSON ...
+func (d DefNode) MarshalJSON() ([]byte, error) { return MarshalNode(d) }
+
+// MarshalJSON ...
+func (l LetNode) MarshalJSON() ([]byte, error) { return MarshalNode(l) }
+
+// MarshalJSON ...
+func (l ListNode) MarshalJSON() ([]byte, error) { return MarshalNode(l) }
+
+// MarshalJSON ...
+func (n IndexNode) MarshalJSON() ([]byte, error) { return MarshalNode(n) }
+
+// MarshalJSON ...
+func (m MapNode) MarshalJSON() ([]byte, error) { return MarshalNode(m) }
+
+// MarshalJSON ...
+func (r RangeNode) MarshalJSON() ([]byte, error) { return MarshalNode(r) }
+
+// MarshalJSON ...
+func (c CoalesceNode) MarshalJSON() ([]byte, error) { return MarshalNode(c) }
+
+// MarshalJSON ...
+func (n PercentNode) MarshalJSON() ([]byte, error) { return MarshalNode(n) }
+
+// MarshalJSON ...
+func (t TryNode) MarshalJSON() ([]byte, error) { return MarshalNode(t) }
+
+// MarshalJSON ...
+func (m MatchNode) MarshalJSON() ([]byte, error) { return MarshalNode(m) }
+
+// MarshalJSON ...
+func (i ImportNode) MarshalJSON() ([]byte, error) { return MarshalNode(i) }
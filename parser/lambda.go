@@ -0,0 +1,283 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"strings"
+
+	"github.com/fmarmol/lexp/lexer"
+)
+
+// ErrFunctionValue is reported by the functions evaluated as numbers,
+// instead of being called
+var ErrFunctionValue = errors.New("a function is not a number")
+
+// ErrRecursion is reported by the calls nested deeper than MaxCallDepth
+var ErrRecursion = errors.New("functions nested too deeply")
+
+// MaxCallDepth is the number of nested calls of a function, guarding
+// against infinite recursion
+const MaxCallDepth = 1000
+
+// LambdaNode is an anonymous function of Params evaluating Body, the other
+// variables of Body being those of Env and its functions those of Funcs when
+// it is called
+type LambdaNode struct {
+	lexer.Token // opening parenthesis of the parameters
+	Params      []string
+	Body        lexer.IExpression
+	Env         Env
+	Funcs       Funcs
+}
+
+func (l LambdaNode) String() string {
+	return fmt.Sprintf("((%v),%v,%v)", strings.Join(l.Params, ","), lexer.TypeArrow, l.Body)
+}
+
+// Span from the parameters to the body
+func (l LambdaNode) Span() lexer.Span {
+	return lexer.Span{Start: l.Pos, End: lexer.SpanOf(l.Body).End}
+}
+
+// Eval fails, a function being called rather than evaluated
+func (l LambdaNode) Eval() (float64, error) {
+	return 0, l.Locate(fmt.Errorf("%w%v, assign it to a name to call it", ErrFunctionValue, l.At()))
+}
+
+// Closure of l, in the scope it is defined in
+func (l LambdaNode) Closure() *Closure {
+	return &Closure{Lambda: l, Env: l.Env, Funcs: l.Funcs}
+}
+
+// DefNode defines the function Name as a closure of Lambda, registering it
+// in Funcs when evaluated
+type DefNode struct {
+	Name    string
+	Lambda  LambdaNode
+	Funcs   Funcs
+	NamePos lexer.Position
+}
+
+func (d DefNode) String() string {
+	return fmt.Sprintf("(%v,%v,%v)", lexer.NewTokenIdent(d.Name), lexer.TypeAssign, d.Lambda)
+}
+
+// Span from the name to the body
+func (d DefNode) Span() lexer.Span {
+	return lexer.Span{Start: d.NamePos, End: d.Lambda.Span().End}
+}
+
+// Signature of the function, like f(x, y)
+func (d DefNode) Signature() string {
+	return d.Name + "(" + strings.Join(d.Lambda.Params, ", ") + ")"
+}
+
+// Eval registers the function, yielding 0
+func (d DefNode) Eval() (float64, error) {
+	d.Funcs[d.Name] = (&Closure{Lambda: d.Lambda, Env: d.Lambda.Env, Funcs: d.Funcs}).Func()
+	return 0, nil
+}
+
+// Closure is a lambda with the environment and the functions of the scope
+// it is defined in
+type Closure struct {
+	Lambda LambdaNode
+	Env    Env
+	Funcs  Funcs
+	calls  int // nested calls being evaluated
+}
+
+// Func calling c
+func (c *Closure) Func() Func {
+	n := len(c.Lambda.Params)
+	return Func{MinArgs: n, MaxArgs: n, Call: c.Call, Closure: c}
+}
+
+// Call evaluates the body of c for args, the calls of closures it ends with
+// being made once it is left so that tail recursion does not nest
+func (c *Closure) Call(args ...float64) (float64, error) {
+	for {
+		body, err := c.Enter(args)
+		if err != nil {
+			c.Leave()
+			return 0, err
+		}
+		v, next, err := tail(body)
+		c.Leave()
+		if err != nil || next == nil {
+			return v, err
+		}
+		c, args = next.Closure, next.Args
+	}
+}
+
+// TailCall is the call of a closure ending the body of another one, made by
+// the caller of the body
+type TailCall struct {
+	Closure *Closure
+	Args    []float64
+}
+
+// TailClosure returns the closure called by c if it can be called in tail
+// position with its arguments, nil otherwise
+func (c CallNode) TailClosure() *Closure {
+	f, ok := c.Funcs[c.Name()]
+	if !ok || f.Closure == nil || f.CheckArgs(c.Name(), len(c.Args)) != nil {
+		return nil
+	}
+	return f.Closure
+}
+
+// tail evaluates node, but for the call of a closure it ends with, returned
+// instead of being made
+func tail(node lexer.IExpression) (float64, *TailCall, error) {
+	switch n := node.(type) {
+	case CallNode:
+		c := n.TailClosure()
+		if c == nil {
+			break
+		}
+		args := make([]float64, len(n.Args))
+		for i, arg := range n.Args {
+			var err error
+			if args[i], err = arg.Eval(); err != nil {
+				return 0, nil, err
+			}
+		}
+		return 0, &TailCall{c, args}, nil
+	case MatchNode:
+		arm, err := n.Arm()
+		if err != nil {
+			return 0, nil, err
+		}
+		return tail(arm)
+	case SeqNode:
+		return tailSeq(n.Items)
+	case ProgramNode:
+		return tailSeq(n.Statements)
+	case LetNode:
+		v, err := n.Value.Eval()
+		if err != nil {
+			return 0, nil, err
+		}
+		defer n.Bind(v)()
+		return tail(n.Body)
+	}
+	v, err := node.Eval()
+	return v, nil, err
+}
+
+// tailSeq evaluates items in order, the last one with tail
+func tailSeq(items []lexer.IExpression) (float64, *TailCall, error) {
+	if len(items) == 0 {
+		return 0, nil, nil
+	}
+	for _, item := range items[:len(items)-1] {
+		if _, err := item.Eval(); err != nil {
+			return 0, nil, err
+		}
+	}
+	return tail(items[len(items)-1])
+}
+
+// Enter a call of c, returning its body bound to a new environment holding
+// the variables of the scope of c and the parameters set to args, so that
+// the assignments of the body last for the call. It fails beyond
+// MaxCallDepth nested calls. Leave must be called once the body is
+// evaluated.
+func (c *Closure) Enter(args []float64) (lexer.IExpression, error) {
+	return c.EnterFuncs(args, nil)
+}
+
+// EnterFuncs enters a call of c like Enter, the parameters named in funcs
+// being bound to these functions for the call instead of to numbers
+func (c *Closure) EnterFuncs(args []float64, funcs Funcs) (lexer.IExpression, error) {
+	c.calls++
+	if c.calls > MaxCallDepth {
+		return nil, fmt.Errorf("%w, at most %v calls", ErrRecursion, MaxCallDepth)
+	}
+	frame := maps.Clone(c.Env)
+	if frame == nil {
+		frame = Env{}
+	}
+	for i, param := range c.Lambda.Params {
+		frame[param] = args[i]
+	}
+	scope := c.Funcs
+	if len(funcs) > 0 {
+		scope = maps.Clone(c.Funcs)
+		for param, f := range funcs {
+			delete(frame, param)
+			scope[param] = f
+		}
+	}
+	return Rebind(c.Lambda.Body, func(Env) Env { return frame }, func(Funcs) Funcs { return scope }), nil
+}
+
+// Leave a call of c
+func (c *Closure) Leave() { c.calls-- }
+
+// lambdaAhead tells if the current token opens the parameters of a lambda,
+// like (x, y) ->
+func (p *Parser) lambdaAhead() bool {
+	if _, ok := p.CurrentToken.(lexer.TokenLP); !ok {
+		return false
+	}
+	i := 1
+	if _, ok := p.Peek(i).(lexer.TokenRP); !ok {
+		for {
+			if _, ok := p.Peek(i).(lexer.TokenIdent); !ok {
+				return false
+			}
+			i++
+			if _, ok := p.Peek(i).(lexer.TokenComma); !ok {
+				break
+			}
+			i++
+		}
+		if _, ok := p.Peek(i).(lexer.TokenRP); !ok {
+			return false
+		}
+	}
+	_, ok := p.Peek(i + 1).(lexer.TokenArrow)
+	return ok
+}
+
+// lambda parses (params) -> body, the body being an expression or a block
+func (p *Parser) lambda() (LambdaNode, error) {
+	open := p.CurrentToken.(lexer.TokenLP)
+	params := []string{}
+	for p.Next(); ; p.Next() {
+		switch token := p.CurrentToken.(type) {
+		case lexer.TokenIdent:
+			name := token.Value.(string)
+			if _, ok := p.Consts[name]; ok {
+				return LambdaNode{}, p.errorf("cannot use constant %v as a parameter", name)
+			}
+			for _, param := range params {
+				if param == name {
+					return LambdaNode{}, p.errorf("duplicate parameter %v", name)
+				}
+			}
+			params = append(params, name)
+			continue
+		case lexer.TokenComma:
+			continue
+		}
+		break
+	}
+	p.Next()
+	p.Next()
+	var body lexer.IExpression
+	var err error
+	if _, ok := p.CurrentToken.(lexer.TokenLBrace); ok {
+		body, _, err = p.block()
+	} else {
+		body, err = p.Expression()
+	}
+	if err != nil {
+		return LambdaNode{}, err
+	}
+	return LambdaNode{open.Token, params, body, p.Env, p.Funcs}, nil
+}
@@ -0,0 +1,137 @@
+package parser
+
+import (
+	"fmt"
+
+	"github.com/fmarmol/lexp/lexer"
+)
+
+// LetNode binds Name to Value while evaluating Body, the variable getting
+// back its previous value, or being removed, once Body is evaluated
+type LetNode struct {
+	lexer.Token // let keyword
+	Name        string
+	NamePos     lexer.Position
+	Value, Body lexer.IExpression
+	Env         Env
+	Values      Values
+}
+
+func (l LetNode) String() string {
+	return fmt.Sprintf("(%v,%v,%v,%v)", lexer.TypeLet, lexer.NewTokenIdent(l.Name), l.Value, l.Body)
+}
+
+// Span from the keyword to the body
+func (l LetNode) Span() lexer.Span {
+	return lexer.Span{Start: l.Pos, End: lexer.SpanOf(l.Body).End}
+}
+
+// Eval ...
+func (l LetNode) Eval() (float64, error) {
+	v, err := l.Value.Eval()
+	if err != nil {
+		return 0, err
+	}
+	defer l.Bind(v)()
+	return l.Body.Eval()
+}
+
+// Bind the variable to v, returning the function restoring its previous
+// value
+func (l LetNode) Bind(v float64) func() {
+	restore := l.save()
+	l.Env[l.Name] = v
+	delete(l.Values, l.Name)
+	return restore
+}
+
+// BindValue binds the variable to a value which is not a number, see
+// Values, returning the function restoring its previous value
+func (l LetNode) BindValue(v any) func() {
+	restore := l.save()
+	delete(l.Env, l.Name)
+	l.Values[l.Name] = v
+	return restore
+}
+
+// save the value of the variable, returning the function restoring it
+func (l LetNode) save() func() {
+	number, isNumber := l.Env[l.Name]
+	value, isValue := l.Values[l.Name]
+	return func() {
+		delete(l.Env, l.Name)
+		delete(l.Values, l.Name)
+		if isNumber {
+			l.Env[l.Name] = number
+		}
+		if isValue {
+			l.Values[l.Name] = value
+		}
+	}
+}
+
+// let parses let name = value[, name = value ...] in body, the body being an
+// assignment or an expression and the bindings being nested. If open, the
+// innermost body is left nil when the bindings are not followed by in,
+// binding them for the rest of a block.
+func (p *Parser) let(open bool) (lexer.IExpression, error) {
+	keyword := p.CurrentToken.(lexer.TokenLet)
+	bindings := []LetNode{}
+	for {
+		p.Next()
+		name, ok := p.CurrentToken.(lexer.TokenIdent)
+		if !ok {
+			return nil, p.errorf("expected let variable, got %v", p.current())
+		}
+		if _, ok := p.Consts[name.Value.(string)]; ok {
+			return nil, p.errorf("cannot assign to constant %v at line %v, col %v", name.Value, name.Pos.Line, name.Pos.Column)
+		}
+		p.Next()
+		if _, err := p.Expect(lexer.TypeAssign); err != nil {
+			return nil, err
+		}
+		value, err := p.Expression()
+		if err != nil {
+			return nil, err
+		}
+		bindings = append(bindings, LetNode{keyword.Token, name.Value.(string), name.Pos, value, nil, p.Env, p.Values})
+		if _, ok := p.CurrentToken.(lexer.TokenComma); !ok {
+			break
+		}
+	}
+	var body lexer.IExpression
+	if _, ok := p.CurrentToken.(lexer.TokenIn); ok {
+		p.Next()
+		var err error
+		if body, err = p.Assignment(); err != nil {
+			return nil, err
+		}
+	} else if !open {
+		return nil, p.errorf("expected in after let, got %v", p.current())
+	}
+	return nestLets(bindings, body), nil
+}
+
+// nestLets returns the first binding evaluating the next ones, the last one
+// evaluating body
+func nestLets(bindings []LetNode, body lexer.IExpression) LetNode {
+	if len(bindings) > 1 {
+		body = nestLets(bindings[1:], body)
+	}
+	bindings[0].Body = body
+	return bindings[0]
+}
+
+// openLet sets the innermost body of the bindings of let parsed without in
+func openLet(let LetNode, body lexer.IExpression) (LetNode, bool) {
+	if let.Body == nil {
+		let.Body = body
+		return let, true
+	}
+	inner, ok := let.Body.(LetNode)
+	if !ok {
+		return let, false
+	}
+	let.Body, ok = openLet(inner, body)
+	return let, ok
+}
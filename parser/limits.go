@@ -0,0 +1,40 @@
+package parser
+
+import (
+	"errors"
+
+	"github.com/fmarmol/lexp/lexer"
+)
+
+// ErrTooDeep is reported for expressions nested deeper than MaxDepth
+var ErrTooDeep = errors.New("expression nested too deeply")
+
+// ErrTooManyTokens is reported for texts of more than MaxTokens tokens
+var ErrTooManyTokens = lexer.ErrTooManyTokens
+
+// checkTokens fails if there are more than MaxTokens tokens, the end of
+// input aside
+func (p *Parser) checkTokens() error {
+	n := len(p.Tokens)
+	if n > 0 {
+		if _, eof := p.Tokens[n-1].(lexer.TokenEOF); eof {
+			n--
+		}
+	}
+	if p.MaxTokens > 0 && n > p.MaxTokens {
+		return p.errorf("%w: %v, at most %v", ErrTooManyTokens, n, p.MaxTokens)
+	}
+	return nil
+}
+
+// enter a nested expression, failing beyond MaxDepth. leave must be called
+// when it is parsed.
+func (p *Parser) enter() error {
+	p.depth++
+	if p.MaxDepth > 0 && p.depth > p.MaxDepth {
+		return p.errorf("%w, at most %v levels", ErrTooDeep, p.MaxDepth)
+	}
+	return nil
+}
+
+func (p *Parser) leave() { p.depth-- }
@@ -0,0 +1,262 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/fmarmol/lexp/lexer"
+)
+
+// Rules of the warnings reported by Lint
+const (
+	RuleParens     = "parens"             // parentheses changing nothing
+	RuleConstant   = "constant-condition" // conditions known before evaluation
+	RuleShadow     = "shadow"             // let bindings and parameters hiding a variable
+	RuleUnused     = "unused"             // let bindings never read
+	RuleZeroDivide = "zero-division"      // divisions by a literal 0
+)
+
+// Warning about a suspicious but valid part of a program, reported by Lint
+type Warning struct {
+	Rule    string
+	Message string
+	Span    lexer.Span
+}
+
+func (w Warning) String() string {
+	return fmt.Sprintf("%v: %v (%v)", w.Span.Start, w.Message, w.Rule)
+}
+
+// MarshalJSON encodes the warning with the file and the span of its source,
+// for editors:
+//
+//	{"rule":"unused","message":"...","file":"a.lx","span":{...}}
+func (w Warning) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Rule    string   `json:"rule"`
+		Message string   `json:"message"`
+		File    string   `json:"file"`
+		Span    jsonSpan `json:"span"`
+	}{w.Rule, w.Message, w.Span.Start.FileName, jsonSpan{position(w.Span.Start), position(w.Span.End)}})
+}
+
+// Lint returns the warnings about node, parsed from tokens, sorted by
+// position
+func Lint(node lexer.IExpression, tokens lexer.Tokens) []Warning {
+	l := &linter{defined: map[string]bool{}}
+	l.parens(tokens)
+	l.walk(node)
+	slices.SortStableFunc(l.warnings, func(a, b Warning) int {
+		return a.Span.Start.Index - b.Span.Start.Index
+	})
+	return l.warnings
+}
+
+// binding of a let or a parameter, used once read
+type binding struct {
+	name string
+	pos  lexer.Position
+	used bool
+}
+
+type linter struct {
+	scopes   []*binding      // innermost last
+	defined  map[string]bool // assigned variables and functions
+	warnings []Warning
+}
+
+func (l *linter) warn(rule string, span lexer.Span, format string, args ...any) {
+	l.warnings = append(l.warnings, Warning{rule, fmt.Sprintf(format, args...), span})
+}
+
+// lookup returns the innermost binding of name, nil if it is not bound
+func (l *linter) lookup(name string) *binding {
+	for i := len(l.scopes) - 1; i >= 0; i-- {
+		if l.scopes[i].name == name {
+			return l.scopes[i]
+		}
+	}
+	return nil
+}
+
+// bind name at pos while walking body, returning its binding
+func (l *linter) bind(name string, pos lexer.Position, body lexer.IExpression) *binding {
+	span := nameSpan(name, pos)
+	if outer := l.lookup(name); outer != nil {
+		l.warn(RuleShadow, span, "%v shadows the binding at %v", name, outer.pos)
+	} else if l.defined[name] {
+		l.warn(RuleShadow, span, "%v shadows a variable", name)
+	}
+	b := &binding{name: name, pos: pos}
+	l.scopes = append(l.scopes, b)
+	l.walk(body)
+	l.scopes = l.scopes[:len(l.scopes)-1]
+	return b
+}
+
+// nameSpan returns the span of name written at pos
+func nameSpan(name string, pos lexer.Position) lexer.Span {
+	end := pos
+	for _, r := range name {
+		end.Next(r)
+	}
+	return lexer.Span{Start: pos, End: end}
+}
+
+func (l *linter) use(name string) {
+	if b := l.lookup(name); b != nil {
+		b.used = true
+	}
+}
+
+func (l *linter) walk(node lexer.IExpression) {
+	switch n := node.(type) {
+	case VarNode:
+		l.use(n.Name())
+	case CallNode:
+		l.use(n.Name())
+	case AssignNode:
+		l.walk(n.Value)
+		l.use(n.Name)
+		l.defined[n.Name] = true
+		return
+	case DefNode:
+		l.defined[n.Name] = true
+	case ForNode:
+		l.defined[n.Var] = true
+	case LambdaNode:
+		l.params(n.Params, n.Token.Span(), n.Body)
+		return
+	case LetNode:
+		l.walk(n.Value)
+		if b := l.bind(n.Name, n.NamePos, n.Body); !b.used {
+			l.warn(RuleUnused, nameSpan(n.Name, n.NamePos), "%v is bound but never used", n.Name)
+		}
+		return
+	case WhileNode:
+		l.constant(n.Cond, true)
+	case BinOpNode:
+		if _, ok := n.Op.(lexer.Logical); ok {
+			l.constant(n.Left, false)
+			l.constant(n.Right, false)
+		}
+		if OpType(n.Op) == lexer.TypeDiv && zero(n.Right) {
+			l.warn(RuleZeroDivide, lexer.SpanOf(n), "division by zero")
+		}
+	}
+	for _, child := range Children(node) {
+		l.walk(child)
+	}
+}
+
+// params binds the parameters of a lambda one after the other, the first
+// binding the second and so on, while walking body
+func (l *linter) params(params []string, paren lexer.Span, body lexer.IExpression) {
+	if len(params) == 0 {
+		l.walk(body)
+		return
+	}
+	// the positions of the parameters are not kept, the parenthesis is
+	if outer := l.lookup(params[0]); outer != nil {
+		l.warn(RuleShadow, paren, "parameter %v shadows the binding at %v", params[0], outer.pos)
+	} else if l.defined[params[0]] {
+		l.warn(RuleShadow, paren, "parameter %v shadows a variable", params[0])
+	}
+	l.scopes = append(l.scopes, &binding{name: params[0], pos: paren.Start})
+	l.params(params[1:], paren, body)
+	l.scopes = l.scopes[:len(l.scopes)-1]
+}
+
+// constant warns if cond is known before evaluation, numbers being
+// conditions of the while loops only
+func (l *linter) constant(cond lexer.IExpression, numbers bool) {
+	var holds bool
+	switch c := Optimize(cond).(type) {
+	case lexer.TokenBool:
+		holds = c.Value.(bool)
+	case lexer.TokenInt:
+		if !numbers {
+			return
+		}
+		holds = c.Value.(int64) != 0
+	case lexer.TokenFloat:
+		if !numbers {
+			return
+		}
+		holds = c.Value.(float64) != 0
+	default:
+		return
+	}
+	l.warn(RuleConstant, lexer.SpanOf(cond), "condition is always %v", holds)
+}
+
+// zero tells if node is the literal 0
+func zero(node lexer.IExpression) bool {
+	switch n := node.(type) {
+	case lexer.TokenInt:
+		return n.Value.(int64) == 0
+	case lexer.TokenFloat:
+		return n.Value.(float64) == 0
+	}
+	return false
+}
+
+// parens warns about the parentheses around a single literal or variable,
+// and around a whole statement, operand or item, like (1 + 2) or ((x)),
+// leaving the parameters of the lambdas and the arguments of the calls
+func (l *linter) parens(tokens lexer.Tokens) {
+	types := make([]lexer.Type, len(tokens))
+	for i, token := range tokens {
+		types[i] = lexer.TypeOf(token)
+	}
+	at := func(i int) lexer.Type {
+		if i < 0 || i >= len(types) {
+			return lexer.TypeEOF
+		}
+		return types[i]
+	}
+	open := []int{}
+	for i, t := range types {
+		switch t {
+		case lexer.TypeLP:
+			open = append(open, i)
+			continue
+		case lexer.TypeRP:
+		default:
+			continue
+		}
+		if len(open) == 0 {
+			continue
+		}
+		start := open[len(open)-1]
+		open = open[:len(open)-1]
+		before, after := at(start-1), at(i+1)
+		if after == lexer.TypeArrow {
+			continue
+		}
+		atom := i == start+2 && slices.Contains(atoms, types[start+1]) && !slices.Contains(callees, before)
+		if atom || slices.Contains(openers, before) && slices.Contains(closers, after) {
+			span := lexer.Span{Start: lexer.SpanOf(tokens[start]).Start, End: lexer.SpanOf(tokens[i]).End}
+			l.warn(RuleParens, span, "useless parentheses")
+		}
+	}
+}
+
+var (
+	// atoms need no parentheses
+	atoms = []lexer.Type{lexer.TypeInt, lexer.TypeFloat, lexer.TypeBool, lexer.TypeString, lexer.TypeNil, lexer.TypeIdent}
+	// callees are followed by the parentheses of the arguments
+	callees = []lexer.Type{lexer.TypeIdent, lexer.TypeRP, lexer.TypeRBracket, lexer.TypeRBrace}
+	// openers and closers delimit whole expressions
+	openers = []lexer.Type{
+		lexer.TypeEOF, lexer.TypeNewline, lexer.TypeSemi, lexer.TypeAssign, lexer.TypeComma, lexer.TypeColon,
+		lexer.TypeLP, lexer.TypeLBrace, lexer.TypeLBracket, lexer.TypeArrow, lexer.TypeFatArrow,
+		lexer.TypeIn, lexer.TypeWhile, lexer.TypeMatch, lexer.TypeTry, lexer.TypeCatch, lexer.TypeImport,
+	}
+	closers = []lexer.Type{
+		lexer.TypeEOF, lexer.TypeNewline, lexer.TypeSemi, lexer.TypeComma, lexer.TypeColon,
+		lexer.TypeRP, lexer.TypeRBrace, lexer.TypeRBracket, lexer.TypeLBrace, lexer.TypeFatArrow,
+		lexer.TypeIn, lexer.TypeCatch,
+	}
+)
@@ -0,0 +1,180 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/fmarmol/lexp/lexer"
+)
+
+// ErrListValue is reported by the lists evaluated as numbers
+var ErrListValue = errors.New("a list is not a number")
+
+// Values maps the variables bound to values which are not float64, like
+// lists or arbitrary precision numbers, to these values, nil for the
+// variables bound to nil. They are set and read by the evaluators supporting
+// them only, a variable of Env taking precedence.
+type Values map[string]any
+
+// ListNode is a list of the values of Items
+type ListNode struct {
+	lexer.Token // opening bracket
+	Items       []lexer.IExpression
+	Close       lexer.Position // end of the closing bracket
+}
+
+func (l ListNode) String() string {
+	items := make([]string, len(l.Items))
+	for i, item := range l.Items {
+		items[i] = fmt.Sprint(item)
+	}
+	return "[" + strings.Join(items, ",") + "]"
+}
+
+// Span from the opening bracket to the closing one
+func (l ListNode) Span() lexer.Span {
+	return lexer.Span{Start: l.Pos, End: l.Close}
+}
+
+// Eval fails, a list only being indexed or passed to a function
+func (l ListNode) Eval() (float64, error) {
+	return 0, l.Locate(fmt.Errorf("%w%v", ErrListValue, l.At()))
+}
+
+// Values of the items, from left to right
+func (l ListNode) Values() ([]float64, error) {
+	values := make([]float64, len(l.Items))
+	for i, item := range l.Items {
+		var err error
+		if values[i], err = item.Eval(); err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+// IndexNode is the item of the list Target at Index, counting from 0,
+// negative indexes counting from the end, or the item of the map Target
+// at the key Index
+type IndexNode struct {
+	lexer.Token   // opening bracket
+	Target, Index lexer.IExpression
+	Close         lexer.Position // end of the closing bracket
+}
+
+func (n IndexNode) String() string {
+	return fmt.Sprintf("%v[%v]", n.Target, n.Index)
+}
+
+// Span from the list to the closing bracket
+func (n IndexNode) Span() lexer.Span {
+	return lexer.Span{Start: lexer.SpanOf(n.Target).Start, End: n.Close}
+}
+
+// Eval ...
+func (n IndexNode) Eval() (float64, error) {
+	var items []float64
+	var err error
+	switch target := n.Target.(type) {
+	case ListNode:
+		items, err = target.Values()
+	case RangeNode:
+		items, err = target.Values()
+	case MapNode:
+		if key, ok := n.Index.(lexer.TokenString); ok {
+			return target.Item(key.Value.(string), n)
+		}
+		err = n.Locate(fmt.Errorf("map keys are strings, got %v%v", Source(n.Index), n.At()))
+	default:
+		if _, err = n.Target.Eval(); err == nil {
+			err = n.Locate(fmt.Errorf("cannot index %v, not a list%v", Source(n.Target), n.At()))
+		}
+	}
+	if err != nil {
+		return 0, err
+	}
+	i, err := n.Index.Eval()
+	if err != nil {
+		return 0, err
+	}
+	offset, err := n.Offset(i, len(items))
+	if err != nil {
+		return 0, err
+	}
+	return items[offset], nil
+}
+
+// Offset of the item at index i of a list of n items
+func (n IndexNode) Offset(i float64, items int) (int, error) {
+	if i != math.Trunc(i) {
+		return 0, n.Locate(fmt.Errorf("index %v is not an integer%v", i, n.At()))
+	}
+	offset := i
+	if i < 0 {
+		offset += float64(items)
+	}
+	if offset < 0 || offset >= float64(items) {
+		return 0, n.Locate(fmt.Errorf("index %v out of range for %v items%v", i, items, n.At()))
+	}
+	return int(offset), nil
+}
+
+// MissingKey is the error of indexing a map without key
+func (n IndexNode) MissingKey(key string) error {
+	return n.Locate(fmt.Errorf("key %v not found%v", strconv.Quote(key), n.At()))
+}
+
+// list parses [item, ...]
+func (p *Parser) list() (lexer.IExpression, error) {
+	open := p.CurrentToken.(lexer.TokenLBracket)
+	p.Next()
+	items, end, err := p.items(lexer.TypeRBracket)
+	if err != nil {
+		return nil, err
+	}
+	return ListNode{open.Token, items, end}, nil
+}
+
+// index parses the [index] following node
+func (p *Parser) index(node lexer.IExpression) (lexer.IExpression, error) {
+	open := p.CurrentToken.(lexer.TokenLBracket)
+	p.Next()
+	index, err := p.Expression()
+	if err != nil {
+		return nil, err
+	}
+	close, err := p.Expect(lexer.TypeRBracket)
+	if err != nil {
+		return nil, err
+	}
+	return IndexNode{open.Token, node, index, lexer.SpanOf(close).End}, nil
+}
+
+// items parses comma separated expressions up to the closing token typ,
+// returning the position of its end
+func (p *Parser) items(typ lexer.Type) ([]lexer.IExpression, lexer.Position, error) {
+	items := []lexer.IExpression{}
+	for {
+		if lexer.TypeOf(p.CurrentToken) == typ && len(items) == 0 {
+			break
+		}
+		item, err := p.Expression()
+		if err != nil {
+			return nil, lexer.Position{}, err
+		}
+		items = append(items, item)
+		if _, ok := p.CurrentToken.(lexer.TokenComma); !ok {
+			break
+		}
+		p.Next()
+	}
+	if lexer.TypeOf(p.CurrentToken) != typ {
+		return nil, lexer.Position{}, p.errorf("expected ',' or %v, got %v", spellings[typ], p.current())
+	}
+	end := lexer.SpanOf(p.CurrentToken).End
+	p.Next()
+	return items, end, nil
+}
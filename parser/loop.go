@@ -0,0 +1,230 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/fmarmol/lexp/lexer"
+)
+
+// ErrZeroStep is reported by the for loops counting by 0
+var ErrZeroStep = errors.New("for loop step is 0")
+
+// WhileNode evaluates its body as long as its condition is not 0, yielding
+// the last value of the body, 0 if it never runs
+type WhileNode struct {
+	lexer.Token // while keyword
+	Cond, Body  lexer.IExpression
+	Close       lexer.Position // end of the closing brace
+}
+
+func (w WhileNode) String() string {
+	return fmt.Sprintf("(%v,%v,%v)", lexer.TypeWhile, w.Cond, w.Body)
+}
+
+// Span from the keyword to the closing brace
+func (w WhileNode) Span() lexer.Span {
+	return lexer.Span{Start: w.Pos, End: w.Close}
+}
+
+// Eval ...
+func (w WhileNode) Eval() (float64, error) {
+	var v float64
+	for {
+		cond, err := w.Cond.Eval()
+		if err != nil || cond == 0 {
+			return v, err
+		}
+		if v, err = w.Body.Eval(); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// ForNode assigns its variable the numbers from Start to End included,
+// counting by Step, and evaluates its body for each of them. End and Step
+// are evaluated once, before the first iteration, Step being 1 if nil. The
+// variable keeps its last value after the loop, which yields the last value
+// of the body, 0 if it never runs.
+type ForNode struct {
+	lexer.Token // for keyword
+	Var         string
+	NamePos     lexer.Position
+	Start, End  lexer.IExpression
+	Step        lexer.IExpression
+	Body        lexer.IExpression
+	Env         Env
+	Close       lexer.Position // end of the closing brace
+}
+
+func (f ForNode) String() string {
+	return fmt.Sprintf("(%v,%v,%v,%v,%v,%v)", lexer.TypeFor, lexer.NewTokenIdent(f.Var), f.Start, f.End, f.By(), f.Body)
+}
+
+// By returns Step, or 1 if it is nil
+func (f ForNode) By() lexer.IExpression {
+	if f.Step == nil {
+		return lexer.NewTokenInt(1)
+	}
+	return f.Step
+}
+
+// Span from the keyword to the closing brace
+func (f ForNode) Span() lexer.Span {
+	return lexer.Span{Start: f.Pos, End: f.Close}
+}
+
+// Eval ...
+func (f ForNode) Eval() (float64, error) {
+	start, err := f.Start.Eval()
+	if err != nil {
+		return 0, err
+	}
+	end, err := f.End.Eval()
+	if err != nil {
+		return 0, err
+	}
+	step, err := f.By().Eval()
+	if err != nil {
+		return 0, err
+	}
+	if err := f.CheckStep(step); err != nil {
+		return 0, err
+	}
+	var v float64
+	for i := start; Within(i, end, step); i += step {
+		f.Env[f.Var] = i
+		if v, err = f.Body.Eval(); err != nil {
+			return 0, err
+		}
+	}
+	return v, nil
+}
+
+// CheckStep fails if the loop cannot count by step
+func (f ForNode) CheckStep(step float64) error {
+	if step == 0 {
+		return f.Locate(fmt.Errorf("%w%v", ErrZeroStep, f.At()))
+	}
+	return nil
+}
+
+// Within tells if a for loop counting by step to end runs for i
+func Within(i, end, step float64) bool {
+	if step > 0 {
+		return i <= end
+	}
+	return i >= end
+}
+
+// loop parses while cond { body } or for name = start, end[, step] { body }
+func (p *Parser) loop() (lexer.IExpression, error) {
+	keyword := p.CurrentToken
+	p.Next()
+	if _, ok := keyword.(lexer.TokenWhile); ok {
+		cond, err := p.Expression()
+		if err != nil {
+			return nil, err
+		}
+		body, end, err := p.block()
+		if err != nil {
+			return nil, err
+		}
+		return WhileNode{keyword.(lexer.TokenWhile).Token, cond, body, end}, nil
+	}
+	name, ok := p.CurrentToken.(lexer.TokenIdent)
+	if !ok {
+		return nil, p.errorf("expected loop variable, got %v", p.current())
+	}
+	if _, ok := p.Consts[name.Value.(string)]; ok {
+		return nil, p.errorf("cannot assign to constant %v at line %v, col %v", name.Value, name.Pos.Line, name.Pos.Column)
+	}
+	p.Next()
+	if _, err := p.Expect(lexer.TypeAssign); err != nil {
+		return nil, err
+	}
+	bounds := []lexer.IExpression{}
+	for {
+		bound, err := p.Expression()
+		if err != nil {
+			return nil, err
+		}
+		bounds = append(bounds, bound)
+		if _, ok := p.CurrentToken.(lexer.TokenComma); !ok || len(bounds) == 3 {
+			break
+		}
+		p.Next()
+	}
+	if len(bounds) < 2 {
+		return nil, p.errorf("expected ',' and the end of the loop, got %v", p.current())
+	}
+	body, end, err := p.block()
+	if err != nil {
+		return nil, err
+	}
+	loop := ForNode{keyword.(lexer.TokenFor).Token, name.Value.(string), name.Pos, bounds[0], bounds[1], nil, body, p.Env, end}
+	if len(bounds) == 3 {
+		loop.Step = bounds[2]
+	}
+	return loop, nil
+}
+
+// block parses statements between braces, separated like in a Program,
+// returning the position of the end of the closing brace. A let without in
+// binds its variables for the rest of the block.
+func (p *Parser) block() (lexer.IExpression, lexer.Position, error) {
+	if _, err := p.Expect(lexer.TypeLBrace); err != nil {
+		return nil, lexer.Position{}, err
+	}
+	return p.statements()
+}
+
+// statements of a block up to its closing brace
+func (p *Parser) statements() (lexer.IExpression, lexer.Position, error) {
+	statements := []lexer.IExpression{}
+	for {
+		for lexer.Separates(p.CurrentToken) {
+			p.Next()
+		}
+		if brace, ok := p.CurrentToken.(lexer.TokenRBrace); ok {
+			p.Next()
+			if len(statements) == 1 {
+				return statements[0], brace.End, nil
+			}
+			return ProgramNode{statements}, brace.End, nil
+		}
+		var statement lexer.IExpression
+		var err error
+		if _, ok := p.CurrentToken.(lexer.TokenLet); ok {
+			statement, err = p.let(true)
+		} else {
+			statement, err = p.Sequence()
+		}
+		if err != nil {
+			return nil, lexer.Position{}, err
+		}
+		if let, ok := statement.(LetNode); ok {
+			if _, open := openLet(let, nil); open {
+				if !lexer.Separates(p.CurrentToken) {
+					return nil, lexer.Position{}, p.errorf("expected in, newline or ';' after let, got %v", p.current())
+				}
+				rest, end, err := p.statements()
+				if err != nil {
+					return nil, lexer.Position{}, err
+				}
+				if program, ok := rest.(ProgramNode); ok && len(program.Statements) == 0 {
+					return nil, lexer.Position{}, p.errorf("expected statements after let %v in the block", let.Name)
+				}
+				let, _ = openLet(let, rest)
+				if statements = append(statements, let); len(statements) == 1 {
+					return let, end, nil
+				}
+				return ProgramNode{statements}, end, nil
+			}
+		}
+		statements = append(statements, statement)
+		if _, ok := p.CurrentToken.(lexer.TokenRBrace); !ok && !lexer.Separates(p.CurrentToken) {
+			return nil, lexer.Position{}, p.errorf("expected newline, ';' or '}', got %v", p.current())
+		}
+	}
+}
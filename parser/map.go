@@ -0,0 +1,118 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/fmarmol/lexp/lexer"
+)
+
+// ErrMapValue is reported by the maps evaluated as numbers
+var ErrMapValue = errors.New("a map is not a number")
+
+// MapNode maps each of its keys to the value of the item at the same index
+type MapNode struct {
+	lexer.Token // opening brace
+	Keys        []string
+	Items       []lexer.IExpression
+	Close       lexer.Position // end of the closing brace
+}
+
+func (m MapNode) String() string {
+	entries := make([]string, len(m.Keys))
+	for i, key := range m.Keys {
+		entries[i] = fmt.Sprintf("%q:%v", key, m.Items[i])
+	}
+	return "{" + strings.Join(entries, ",") + "}"
+}
+
+// Span from the opening brace to the closing one
+func (m MapNode) Span() lexer.Span {
+	return lexer.Span{Start: m.Pos, End: m.Close}
+}
+
+// Eval fails, a map only being indexed or passed to a function
+func (m MapNode) Eval() (float64, error) {
+	return 0, m.Locate(fmt.Errorf("%w%v", ErrMapValue, m.At()))
+}
+
+// Item evaluates the value of key, index being the node indexing the map
+func (m MapNode) Item(key string, index IndexNode) (float64, error) {
+	for i, k := range m.Keys {
+		if k == key {
+			return m.Items[i].Eval()
+		}
+	}
+	return 0, index.MissingKey(key)
+}
+
+// mapAhead tells if the current brace opens a map, like {} or {"a": 1},
+// rather than a block
+func (p *Parser) mapAhead() bool {
+	switch p.Peek(1).(type) {
+	case lexer.TokenRBrace:
+		return true
+	case lexer.TokenString:
+		_, ok := p.Peek(2).(lexer.TokenColon)
+		return ok
+	}
+	return false
+}
+
+// mapLiteral parses {"key": value, ...}, newlines being allowed around the
+// entries
+func (p *Parser) mapLiteral() (lexer.IExpression, error) {
+	open := p.CurrentToken.(lexer.TokenLBrace)
+	m := MapNode{open.Token, []string{}, []lexer.IExpression{}, lexer.Position{}}
+	p.Next()
+	for {
+		p.newlines()
+		if brace, ok := p.CurrentToken.(lexer.TokenRBrace); ok && len(m.Keys) == 0 {
+			p.Next()
+			m.Close = brace.End
+			return m, nil
+		}
+		key, ok := p.CurrentToken.(lexer.TokenString)
+		if !ok {
+			return nil, p.errorf("expected a string key, got %v", p.current())
+		}
+		name := key.Value.(string)
+		for _, k := range m.Keys {
+			if k == name {
+				return nil, p.errorf("duplicate key %v", strconv.Quote(name))
+			}
+		}
+		p.Next()
+		if _, err := p.Expect(lexer.TypeColon); err != nil {
+			return nil, err
+		}
+		value, err := p.Expression()
+		if err != nil {
+			return nil, err
+		}
+		m.Keys, m.Items = append(m.Keys, name), append(m.Items, value)
+		p.newlines()
+		switch token := p.CurrentToken.(type) {
+		case lexer.TokenComma:
+			p.Next()
+		case lexer.TokenRBrace:
+			p.Next()
+			m.Close = token.End
+			return m, nil
+		default:
+			return nil, p.errorf("expected ',' or '}', got %v", p.current())
+		}
+	}
+}
+
+// newlines skips the newline tokens
+func (p *Parser) newlines() {
+	for {
+		if _, ok := p.CurrentToken.(lexer.TokenNewline); !ok {
+			return
+		}
+		p.Next()
+	}
+}
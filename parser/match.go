@@ -0,0 +1,156 @@
+package parser
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/fmarmol/lexp/lexer"
+)
+
+// MatchNode is the value of the first of Arms matching Subject, tried from
+// top to bottom, or the value of Default, the _ arm, if none does
+type MatchNode struct {
+	lexer.Token // match keyword
+	Subject     lexer.IExpression
+	Arms        []MatchArm
+	Default     lexer.IExpression
+	Close       lexer.Position // end of the closing brace
+}
+
+// MatchArm of a match, matching the subjects equal to Pattern, or being one
+// of its items if it is a range
+type MatchArm struct {
+	Pattern, Value lexer.IExpression
+}
+
+func (m MatchNode) String() string {
+	arms := make([]string, len(m.Arms), len(m.Arms)+1)
+	for i, arm := range m.Arms {
+		arms[i] = fmt.Sprintf("%v:%v", arm.Pattern, arm.Value)
+	}
+	arms = append(arms, fmt.Sprintf("_:%v", m.Default))
+	return fmt.Sprintf("(%v,%v,{%v})", lexer.TypeMatch, m.Subject, strings.Join(arms, ","))
+}
+
+// Span from the keyword to the closing brace
+func (m MatchNode) Span() lexer.Span {
+	return lexer.Span{Start: m.Pos, End: m.Close}
+}
+
+// Eval evaluates the value of the arm matching the subject only
+func (m MatchNode) Eval() (float64, error) {
+	arm, err := m.Arm()
+	if err != nil {
+		return 0, err
+	}
+	return arm.Eval()
+}
+
+// Arm returns the value of the first arm matching the value of the subject,
+// the patterns after it not being evaluated
+func (m MatchNode) Arm() (lexer.IExpression, error) {
+	v, err := m.Subject.Eval()
+	if err != nil {
+		return nil, err
+	}
+	for _, arm := range m.Arms {
+		var ok bool
+		if r, isRange := arm.Pattern.(RangeNode); isRange {
+			ok, err = r.Has(v)
+		} else {
+			var p float64
+			p, err = arm.Pattern.Eval()
+			ok = p == v
+		}
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return arm.Value, nil
+		}
+	}
+	return m.Default, nil
+}
+
+// Has tells if v is one of the items of the range
+func (r RangeNode) Has(v float64) (bool, error) {
+	bounds := make([]float64, 3)
+	for i, bound := range []lexer.IExpression{r.Start, r.End, r.By()} {
+		var err error
+		if bounds[i], err = bound.Eval(); err != nil {
+			return false, err
+		}
+	}
+	return r.Contains(v, bounds[0], bounds[1], bounds[2])
+}
+
+// Contains tells if v is one of the items from start to end counting by
+// step, computed like Items computes them
+func (r RangeNode) Contains(v, start, end, step float64) (bool, error) {
+	if step == 0 {
+		return false, r.Locate(fmt.Errorf("%w%v", ErrRangeStep, r.At()))
+	}
+	if !Within(start, v, step) || !Within(v, end, step) {
+		return false, nil
+	}
+	return start+math.Round((v-start)/step)*step == v, nil
+}
+
+// match parses match subject { pattern => value, ..., _ => value }, the
+// arms being separated by commas or newlines and the _ arm ending them
+func (p *Parser) match() (lexer.IExpression, error) {
+	keyword := p.CurrentToken.(lexer.TokenMatch)
+	p.Next()
+	subject, err := p.coalescing()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.Expect(lexer.TypeLBrace); err != nil {
+		return nil, err
+	}
+	m := MatchNode{keyword.Token, subject, []MatchArm{}, nil, lexer.Position{}}
+	for {
+		p.newlines()
+		if _, ok := p.CurrentToken.(lexer.TokenRBrace); ok {
+			return nil, p.errorf("expected a _ arm ending the match, got %v", p.current())
+		}
+		var pattern lexer.IExpression
+		if name, ok := p.CurrentToken.(lexer.TokenIdent); ok && name.Value == "_" {
+			p.Next()
+		} else if pattern, err = p.Expression(); err != nil {
+			return nil, err
+		}
+		if _, err := p.Expect(lexer.TypeFatArrow); err != nil {
+			return nil, err
+		}
+		value, err := p.Expression()
+		if err != nil {
+			return nil, err
+		}
+		if pattern == nil {
+			m.Default = value
+			break
+		}
+		m.Arms = append(m.Arms, MatchArm{pattern, value})
+		switch p.CurrentToken.(type) {
+		case lexer.TokenComma:
+			p.Next()
+		case lexer.TokenNewline, lexer.TokenRBrace:
+		default:
+			return nil, p.errorf("expected ',' or a newline after the arm, got %v", p.current())
+		}
+	}
+	p.newlines()
+	if _, ok := p.CurrentToken.(lexer.TokenComma); ok {
+		p.Next()
+		p.newlines()
+	}
+	brace, ok := p.CurrentToken.(lexer.TokenRBrace)
+	if !ok {
+		return nil, p.errorf("expected '}' after the _ arm, the arms after it being unreachable, got %v", p.current())
+	}
+	p.Next()
+	m.Close = brace.End
+	return m, nil
+}
@@ -0,0 +1,119 @@
+package parser
+
+import "github.com/fmarmol/lexp/lexer"
+
+// Binding of an operator in an operator table
+type Binding struct {
+	// Power of the operator, operators of higher power binding tighter
+	Power int
+	// Right associativity, a ^ b ^ c being a ^ (b ^ c)
+	Right bool
+	// Arith operators follow the division semantics and the fixed width
+	// integer mode, unlike comparisons and logical operators
+	Arith bool
+}
+
+// Operators maps the token types of operators to their binding, the
+// tokens implementing lexer.Operation
+type Operators map[lexer.Type]Binding
+
+// InfixOperators of the grammar, from the loosest to the tightest
+var InfixOperators = Operators{
+	lexer.TypeOr:    {10, false, false},
+	lexer.TypeAnd:   {20, false, false},
+	lexer.TypeLT:    {40, false, false},
+	lexer.TypeLE:    {40, false, false},
+	lexer.TypeGT:    {40, false, false},
+	lexer.TypeGE:    {40, false, false},
+	lexer.TypeEQ:    {40, false, false},
+	lexer.TypeNE:    {40, false, false},
+	lexer.TypeShl:   {50, false, true},
+	lexer.TypeShr:   {50, false, true},
+	lexer.TypePlus:  {60, false, true},
+	lexer.TypeMinus: {60, false, true},
+	lexer.TypeMul:   {70, false, true},
+	lexer.TypeDiv:   {70, false, true},
+	lexer.TypePow:   {90, true, true},
+}
+
+// PrefixOperators of the grammar, their operand being made of the
+// operators of higher power: not a < b is not (a < b) and -2^2 is -(2^2)
+var PrefixOperators = Operators{
+	lexer.TypeNot:   {30, false, false},
+	lexer.TypePlus:  {80, false, true},
+	lexer.TypeMinus: {80, false, true},
+}
+
+// operator of the current token and its binding in table, if any
+func (p *Parser) operator(table Operators) (lexer.Operation, Binding, bool) {
+	binding, ok := table[lexer.TypeOf(p.CurrentToken)]
+	op, isOp := p.CurrentToken.(lexer.Operation)
+	return op, binding, ok && isOp
+}
+
+// Expression parses operators and their operands, or a range of them
+func (p *Parser) Expression() (lexer.IExpression, error) {
+	node, err := p.coalescing()
+	if _, ok := p.CurrentToken.(lexer.TokenRange); ok && err == nil {
+		return p.rangeFrom(node)
+	}
+	return node, err
+}
+
+// Binary parses a chain of the infix operators binding tighter than power,
+// climbing their precedence in the Infix table
+func (p *Parser) Binary(power int) (lexer.IExpression, error) {
+	defer p.leave()
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	node, err := p.Unary()
+	for err == nil {
+		op, binding, ok := p.operator(p.Infix)
+		if !ok || binding.Power <= power {
+			return node, nil
+		}
+		p.Next()
+		next := binding.Power
+		if binding.Right {
+			next--
+		}
+		var right lexer.IExpression
+		if right, err = p.Binary(next); err != nil {
+			break
+		}
+		if percent, ok := relative(node, right, op); ok {
+			percent.Op = p.bind(op, binding)
+			node = percent
+		} else {
+			node = BinOpNode{node, right, p.bind(op, binding)}
+		}
+	}
+	return nil, err
+}
+
+// Unary parses a prefix operator of the Prefix table applied to its
+// operand, or a Factor
+func (p *Parser) Unary() (lexer.IExpression, error) {
+	op, binding, ok := p.operator(p.Prefix)
+	if !ok {
+		return p.Factor()
+	}
+	p.Next()
+	operand, err := p.Binary(binding.Power)
+	if err != nil {
+		return nil, err
+	}
+	return UnaryOpNode{p.bind(op, binding), operand}, nil
+}
+
+// bind op according to its binding
+func (p *Parser) bind(op lexer.Operation, binding Binding) lexer.Operation {
+	if c, ok := op.(lexer.Comparison); ok && p.Width != nil && !p.Width.Signed && p.Width.Bits == 64 {
+		return UnsignedComparison{c}
+	}
+	if !binding.Arith {
+		return op
+	}
+	return p.operation(op)
+}
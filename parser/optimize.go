@@ -0,0 +1,191 @@
+package parser
+
+import (
+	"cmp"
+	"math"
+
+	"github.com/fmarmol/lexp/lexer"
+)
+
+// Optimize returns node with its constant subtrees computed, 2*3+x becoming
+// 6+x. Operations on integer literals stay exact, comparisons of numbers
+// and logical operations on booleans yield booleans, like they do when
+// evaluated. Operations failing or yielding Inf or NaN are kept so that the
+// evaluation reports them, and calls are kept as functions may not be
+// pure. The literals computed have no position. node is not modified.
+func Optimize(node lexer.IExpression) lexer.IExpression {
+	switch n := node.(type) {
+	case BinOpNode:
+		n.Left, n.Right = Optimize(n.Left), Optimize(n.Right)
+		if folded, ok := foldBinary(n.Op, n.Left, n.Right); ok {
+			return folded
+		}
+		return n
+	case UnaryOpNode:
+		n.Operand = Optimize(n.Operand)
+		if _, not := n.Op.(lexer.TokenNot); not {
+			if b, ok := n.Operand.(lexer.TokenBool); ok {
+				return lexer.NewTokenBool(!b.Value.(bool))
+			}
+			return n
+		}
+		if folded, ok := foldBinary(n.Op, lexer.NewTokenInt(0), n.Operand); ok {
+			return folded
+		}
+		return n
+	case CallNode:
+		n.Args = optimizeAll(n.Args)
+		return n
+	case AssignNode:
+		n.Value = Optimize(n.Value)
+		return n
+	case SeqNode:
+		n.Items = optimizeAll(n.Items)
+		return n
+	case ProgramNode:
+		n.Statements = optimizeAll(n.Statements)
+		return n
+	case WhileNode:
+		n.Cond, n.Body = Optimize(n.Cond), Optimize(n.Body)
+		return n
+	case ForNode:
+		n.Start, n.End, n.Body = Optimize(n.Start), Optimize(n.End), Optimize(n.Body)
+		if n.Step != nil {
+			n.Step = Optimize(n.Step)
+		}
+		return n
+	case LambdaNode:
+		n.Body = Optimize(n.Body)
+		return n
+	case DefNode:
+		n.Lambda.Body = Optimize(n.Lambda.Body)
+		return n
+	case LetNode:
+		n.Value, n.Body = Optimize(n.Value), Optimize(n.Body)
+		return n
+	case ListNode:
+		n.Items = optimizeAll(n.Items)
+		return n
+	case IndexNode:
+		n.Target, n.Index = Optimize(n.Target), Optimize(n.Index)
+		return n
+	case MapNode:
+		n.Items = optimizeAll(n.Items)
+		return n
+	case CoalesceNode:
+		n.Left, n.Right = Optimize(n.Left), Optimize(n.Right)
+		switch n.Left.(type) {
+		case lexer.TokenNil:
+			return n.Right
+		case lexer.TokenInt, lexer.TokenFloat, lexer.TokenBool, lexer.TokenString:
+			return n.Left
+		}
+		return n
+	case TryNode:
+		n.Body, n.Catch = Optimize(n.Body), Optimize(n.Catch)
+		if isNumber(n.Body) {
+			return n.Body
+		}
+		return n
+	case ImportNode:
+		n.Program = Optimize(n.Program)
+		return n
+	case MatchNode:
+		arms := make([]MatchArm, len(n.Arms))
+		for i, arm := range n.Arms {
+			arms[i] = MatchArm{Optimize(arm.Pattern), Optimize(arm.Value)}
+		}
+		n.Subject, n.Arms, n.Default = Optimize(n.Subject), arms, Optimize(n.Default)
+		return n
+	case RangeNode:
+		n.Start, n.End = Optimize(n.Start), Optimize(n.End)
+		if n.Step != nil {
+			n.Step = Optimize(n.Step)
+		}
+		return n
+	case PercentNode:
+		n.Percent = Optimize(n.Percent)
+		if n.Base != nil {
+			n.Base = Optimize(n.Base)
+		}
+		if folded, ok := foldPercent(n); ok {
+			return folded
+		}
+		return n
+	}
+	return node
+}
+
+func optimizeAll(nodes []lexer.IExpression) []lexer.IExpression {
+	optimized := make([]lexer.IExpression, len(nodes))
+	for i, node := range nodes {
+		optimized[i] = Optimize(node)
+	}
+	return optimized
+}
+
+// foldBinary computes op on two literals, reporting false if they are not
+// or if the result is left to the evaluation
+func foldBinary(op lexer.Operation, left, right lexer.IExpression) (lexer.IExpression, bool) {
+	if _, ok := op.(lexer.Logical); ok {
+		l, lok := left.(lexer.TokenBool)
+		r, rok := right.(lexer.TokenBool)
+		if !lok || !rok {
+			return nil, false
+		}
+		v, err := op.Eval(l, r)
+		return lexer.NewTokenBool(v != 0), err == nil
+	}
+	if !isNumber(left) || !isNumber(right) {
+		return nil, false
+	}
+	l, lint := left.(lexer.TokenInt)
+	r, rint := right.(lexer.TokenInt)
+	if c, ok := op.(lexer.Comparison); ok {
+		if lint && rint {
+			return lexer.NewTokenBool(c.Holds(cmp.Compare(l.Value.(int64), r.Value.(int64)))), true
+		}
+		lv, _ := left.Eval()
+		rv, _ := right.Eval()
+		return lexer.NewTokenBool(c.Holds(lexer.Order(lv, rv))), true
+	}
+	if intOp, ok := op.(lexer.IntOperation); ok && lint && rint {
+		v, exact, err := intOp.EvalInt(l.Value.(int64), r.Value.(int64))
+		if err != nil {
+			return nil, false
+		}
+		if exact {
+			return lexer.NewTokenInt(v), true
+		}
+	}
+	v, err := op.Eval(left, right)
+	if err != nil || math.IsInf(v, 0) || math.IsNaN(v) {
+		return nil, false
+	}
+	return lexer.NewTokenFloat(v), true
+}
+
+// foldPercent computes a percentage of literals like the evaluation does,
+// 200 + 10% being the integer 220
+func foldPercent(n PercentNode) (lexer.IExpression, bool) {
+	var base lexer.IExpression = lexer.NewTokenInt(1)
+	if n.Base != nil {
+		base = n.Base
+	}
+	part, ok := foldBinary(n.Mul(), base, n.Percent)
+	if ok {
+		part, ok = foldBinary(n.Div(), part, lexer.NewTokenInt(100))
+	}
+	if !ok || !n.Relative() {
+		return part, ok
+	}
+	return foldBinary(n.Op, base, part)
+}
+
+func isNumber(node lexer.IExpression) bool {
+	switch node.(type) {
+	case lexer.TokenInt, lexer.TokenFloat:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,408 @@
+package parser
+
+import (
+	"fmt"
+	"maps"
+	"math"
+	"math/big"
+
+	"github.com/fmarmol/lexp/lexer"
+)
+
+// Parser ...
+type Parser struct {
+	Tokens       lexer.Tokens
+	TokenIndex   int
+	CurrentToken lexer.IToken
+	Env          Env       // environment variables are resolved against
+	Width        *IntWidth // fixed width integer mode, nil for floats
+	Division     Division  // semantics of /
+	Funcs        Funcs     // functions calls are resolved against
+	Consts       Env       // named constants, replaced by their value
+	Values       Values    // values of the variables which are not numbers
+	// Infix and Prefix operator tables, copies of InfixOperators and
+	// PrefixOperators which embedders can extend with their own operators
+	Infix, Prefix Operators
+	// MaxDepth bounds the nesting of the expressions and MaxTokens the
+	// number of tokens parsed, 0 for no limit, guarding against untrusted
+	// input
+	MaxDepth, MaxTokens int
+	depth               int // current nesting
+	// Importer reads the files of the import statements, nil disabling them
+	Importer Importer
+	imports  []string // files being imported, the importing ones first
+}
+
+// New ...
+func New(tokens lexer.Tokens) *Parser {
+	p := &Parser{tokens, -1, lexer.Token{}, Env{}, nil, DivTrue, Funcs{}, Env{}, Values{}, maps.Clone(InfixOperators), maps.Clone(PrefixOperators), 0, 0, 0, nil, nil}
+	p.Next()
+	return p
+}
+
+// Next ...
+func (p *Parser) Next() bool {
+	p.TokenIndex++
+	if p.TokenIndex < len(p.Tokens) {
+		p.CurrentToken = p.Tokens[p.TokenIndex]
+		return true
+	}
+	p.CurrentToken = lexer.Token{}
+	return false
+}
+
+// Peek returns the token n positions after the current one without
+// consuming it, Peek(0) being the current token and lexer.Token{} past the
+// end
+func (p *Parser) Peek(n int) lexer.IToken {
+	if i := p.TokenIndex + n; i >= 0 && i < len(p.Tokens) {
+		return p.Tokens[i]
+	}
+	return lexer.Token{}
+}
+
+// spellings of the token types for error messages
+var spellings = map[lexer.Type]string{
+	lexer.TypeLP: "'('", lexer.TypeRP: "')'", lexer.TypeComma: "','", lexer.TypeAssign: "'='",
+	lexer.TypeLBrace: "'{'", lexer.TypeLBracket: "'['", lexer.TypeRBracket: "']'",
+	lexer.TypeFatArrow: "'=>'",
+}
+
+// Expect consumes the current token if it is of type typ, failing
+// otherwise
+func (p *Parser) Expect(typ lexer.Type) (lexer.IToken, error) {
+	token := p.CurrentToken
+	if lexer.TypeOf(token) != typ {
+		spelling, ok := spellings[typ]
+		if !ok {
+			spelling = string(typ)
+		}
+		return nil, p.errorf("expected %v, got %v", spelling, p.current())
+	}
+	p.Next()
+	return token, nil
+}
+
+// Parse ...
+func (p *Parser) Parse() (lexer.IExpression, error) {
+	if err := p.checkTokens(); err != nil {
+		return nil, err
+	}
+	expr, err := p.Program()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.end(); err != nil {
+		return nil, err
+	}
+	return expr, nil
+}
+
+// atEnd tells if the tokens are consumed, up to the EOF token if any
+func (p *Parser) atEnd() bool {
+	_, eof := p.CurrentToken.(lexer.TokenEOF)
+	return eof || p.TokenIndex >= len(p.Tokens)
+}
+
+// end reports the tokens left after a complete expression
+func (p *Parser) end() error {
+	if !p.atEnd() {
+		return p.errorf("unexpected token %v after expression", p.CurrentToken)
+	}
+	return nil
+}
+
+// Program parses statements separated by newlines or semicolons, a single
+// statement being returned as is
+func (p *Parser) Program() (lexer.IExpression, error) {
+	statements := []lexer.IExpression{}
+	for {
+		for lexer.Separates(p.CurrentToken) {
+			p.Next()
+		}
+		if p.atEnd() && len(statements) > 0 {
+			break
+		}
+		var statement lexer.IExpression
+		var err error
+		if _, ok := p.CurrentToken.(lexer.TokenImport); ok {
+			statement, err = p.importFile()
+		} else {
+			statement, err = p.Sequence()
+		}
+		if err != nil {
+			return nil, err
+		}
+		statements = append(statements, statement)
+		if !lexer.Separates(p.CurrentToken) {
+			break
+		}
+	}
+	if len(statements) == 1 {
+		return statements[0], nil
+	}
+	return ProgramNode{statements}, nil
+}
+
+// Sequence parses comma separated assignments or expressions
+func (p *Parser) Sequence() (lexer.IExpression, error) {
+	first, err := p.Assignment()
+	if err != nil {
+		return nil, err
+	}
+	items := []lexer.IExpression{first}
+	for p.TokenIndex < len(p.Tokens) {
+		if _, ok := p.CurrentToken.(lexer.TokenComma); !ok {
+			break
+		}
+		p.Next()
+		item, err := p.Assignment()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	if len(items) == 1 {
+		return first, nil
+	}
+	return SeqNode{items}, nil
+}
+
+// Assignment parses name = expression, name = (params) -> body defining a
+// function, or an expression
+func (p *Parser) Assignment() (lexer.IExpression, error) {
+	name, ok := p.CurrentToken.(lexer.TokenIdent)
+	if _, assign := p.Peek(1).(lexer.TokenAssign); !ok || !assign {
+		return p.Expression()
+	}
+	if _, ok := p.Consts[name.Value.(string)]; ok {
+		return nil, p.errorf("cannot assign to constant %v at line %v, col %v", name.Value, name.Pos.Line, name.Pos.Column)
+	}
+	p.Next()
+	p.Next()
+	if p.lambdaAhead() {
+		lambda, err := p.lambda()
+		if err != nil {
+			return nil, err
+		}
+		return DefNode{name.Value.(string), lambda, p.Funcs, name.Pos}, nil
+	}
+	value, err := p.Assignment()
+	if err != nil {
+		return nil, err
+	}
+	return AssignNode{name.Value.(string), value, p.Env, name.Pos, p.Values, p.Funcs}, nil
+}
+
+// ParseFormula parses a named formula: name = expression
+func (p *Parser) ParseFormula() (Formula, error) {
+	name, ok := p.CurrentToken.(lexer.TokenIdent)
+	if !ok || len(p.Tokens) < 3 {
+		return Formula{}, fmt.Errorf("expected formula name = expression")
+	}
+	if _, ok := p.Consts[name.Value.(string)]; ok {
+		return Formula{}, fmt.Errorf("cannot define constant %v as a formula", name.Value)
+	}
+	p.Next()
+	if _, err := p.Expect(lexer.TypeAssign); err != nil {
+		return Formula{}, fmt.Errorf("expected = after formula name %v", name.Value)
+	}
+	expr, err := p.Expression()
+	if err == nil {
+		err = p.end()
+	}
+	if err != nil {
+		return Formula{}, err
+	}
+	return Formula{name.Value.(string), expr}, nil
+}
+
+// Factor parses a primary expression followed by any number of [index],
+// and % if it is a percentage
+func (p *Parser) Factor() (lexer.IExpression, error) {
+	node, err := p.primary()
+	for err == nil {
+		if _, ok := p.CurrentToken.(lexer.TokenLBracket); !ok {
+			break
+		}
+		node, err = p.index(node)
+	}
+	if _, ok := p.CurrentToken.(lexer.TokenPercent); ok && err == nil {
+		return p.percent(node)
+	}
+	return node, err
+}
+
+// primary parses a number, a boolean, a string, nil, a constant, a variable, a
+// function call, a list, a map, a loop, a let, a try, a lambda, a block or
+// a parenthesized expression
+func (p *Parser) primary() (lexer.IExpression, error) {
+	var node lexer.IExpression
+	switch token := p.CurrentToken.(type) {
+	case lexer.TokenFloat, lexer.TokenInt:
+		node = p.literal(token.(lexer.IExpression))
+	case lexer.TokenBool, lexer.TokenString, lexer.TokenNil:
+		node = token.(lexer.IExpression)
+	case lexer.TokenIdent:
+		if _, ok := p.Peek(1).(lexer.TokenLP); ok {
+			return p.call(token)
+		}
+		if v, ok := p.Consts[token.Value.(string)]; ok {
+			c := lexer.NewTokenFloat(v)
+			c.Pos, c.End = token.Pos, token.End
+			node = p.literal(c)
+		} else {
+			node = VarNode{token.Token, p.Env, p.Values, p.Funcs}
+		}
+	case lexer.TokenWhile, lexer.TokenFor:
+		return p.loop()
+	case lexer.TokenLBracket:
+		return p.list()
+	case lexer.TokenLet:
+		return p.let(false)
+	case lexer.TokenTry:
+		return p.try()
+	case lexer.TokenMatch:
+		return p.match()
+	case lexer.TokenLBrace:
+		if p.mapAhead() {
+			return p.mapLiteral()
+		}
+		node, _, err := p.block()
+		return node, err
+	case lexer.TokenLP:
+		if p.lambdaAhead() {
+			return p.lambda()
+		}
+		p.Next()
+		var err error
+		if node, err = p.Expression(); err != nil {
+			return nil, err
+		}
+		if _, err := p.closing(lexer.TypeRP, token); err != nil {
+			return nil, err
+		}
+		return node, nil
+	default:
+		return nil, p.errorf("expected expression, got %v", p.current())
+	}
+	p.Next()
+	return node, nil
+}
+
+// literal wraps numbers to integers in fixed width integer mode, keeping
+// their span
+func (p *Parser) literal(node lexer.IExpression) lexer.IExpression {
+	if p.Width == nil {
+		return node
+	}
+	var v int64
+	switch n := node.(type) {
+	case lexer.TokenInt:
+		v = p.Width.WrapInt(uint64(n.Value.(int64)))
+	case lexer.TokenFloat:
+		if n.Int != nil {
+			low := new(big.Int).And(n.Int, new(big.Int).SetUint64(math.MaxUint64))
+			v = p.Width.WrapInt(low.Uint64())
+		} else {
+			v = p.Width.WrapFloat(n.Value.(float64))
+		}
+	}
+	wrapped := lexer.NewTokenInt(v)
+	wrapped.Pos, wrapped.End = lexer.SpanOf(node).Start, lexer.SpanOf(node).End
+	return wrapped
+}
+
+// call parses the comma separated arguments of a call to name
+func (p *Parser) call(name lexer.TokenIdent) (lexer.IExpression, error) {
+	p.Next()
+	open := p.CurrentToken
+	p.Next()
+	args := []lexer.IExpression{}
+	for {
+		if rp, ok := p.CurrentToken.(lexer.TokenRP); ok && len(args) == 0 {
+			p.Next()
+			return CallNode{name.Token, args, p.Funcs, rp.End}, nil
+		}
+		arg, err := p.Expression()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		switch token := p.CurrentToken.(type) {
+		case lexer.TokenComma:
+			p.Next()
+		case lexer.TokenRP:
+			p.Next()
+			return CallNode{name.Token, args, p.Funcs, token.End}, nil
+		default:
+			if p.atEnd() {
+				return nil, p.unclosed(lexer.TypeRP, open)
+			}
+			return nil, p.errorf("expected ',' or ')', got %v", p.current())
+		}
+	}
+}
+
+// closing consumes the token of type typ closing open, the error pointing
+// at open if the input ends before it
+func (p *Parser) closing(typ lexer.Type, open lexer.IToken) (lexer.IToken, error) {
+	if p.atEnd() {
+		return nil, p.unclosed(typ, open)
+	}
+	return p.Expect(typ)
+}
+
+// unclosed reports the input ending before the token of type typ closing
+// open
+func (p *Parser) unclosed(typ lexer.Type, open lexer.IToken) error {
+	pos := lexer.SpanOf(open).Start
+	err := fmt.Errorf("expected %v closing the %v at line %v, col %v, got end of input", spellings[typ], spellings[lexer.TypeOf(open)], pos.Line, pos.Column)
+	if pos.Line == 0 {
+		return err
+	}
+	return lexer.SpanError{Span: lexer.SpanOf(open), Err: err}
+}
+
+// errorf formats an error located at the current token, or right after the
+// last one at the end of the input
+func (p *Parser) errorf(format string, args ...any) error {
+	err := fmt.Errorf(format, args...)
+	span := lexer.SpanOf(p.CurrentToken)
+	if p.atEnd() {
+		for i := min(p.TokenIndex, len(p.Tokens)) - 1; i >= 0; i-- {
+			if _, eof := p.Tokens[i].(lexer.TokenEOF); !eof {
+				end := lexer.SpanOf(p.Tokens[i]).End
+				span = lexer.Span{Start: end, End: end}
+				break
+			}
+		}
+	}
+	if span.Start.Line == 0 {
+		return err
+	}
+	return lexer.SpanError{Span: span, Err: err}
+}
+
+// current describes the current token for error messages
+func (p *Parser) current() string {
+	if p.atEnd() {
+		return "end of input"
+	}
+	if pos := lexer.SpanOf(p.CurrentToken).Start; pos.Line > 0 {
+		return fmt.Sprintf("%v at line %v, col %v", p.CurrentToken, pos.Line, pos.Column)
+	}
+	return fmt.Sprint(p.CurrentToken)
+}
+
+// operation applies the division semantics and wraps op results in fixed
+// width integer mode
+func (p *Parser) operation(op lexer.Operation) lexer.Operation {
+	if div, ok := op.(lexer.TokenDiv); ok && p.Division != DivTrue {
+		op = DivOp{div, p.Division}
+	}
+	if p.Width == nil {
+		return op
+	}
+	return WrappedOp{op, *p.Width}
+}
@@ -0,0 +1,137 @@
+package parser
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/fmarmol/lexp/lexer"
+)
+
+// percentPower is the binding power of the postfix %, above the one of the
+// prefix operators and below the one of the leaves and the indexes
+const percentPower = math.MaxInt - 1
+
+// PercentNode is Percent hundredths of Base, or of 1 if Base is nil, like in
+// 15% of 80 or 10%. With Op, + or -, it is Base Op Percent hundredths of
+// Base instead, 200 + 10% being 220 and 200 - 10% 180, like on handheld
+// calculators.
+//
+// % is a postfix operator binding like an index, tighter than the prefix
+// ones: -10% is -(10%) and 2^10% is 2^(10%). The percentage of of is a
+// factor and its base is made of the operators binding tighter than *, so
+// 15% of 80 + 1 is (15% of 80) + 1 and 15% of 2^3 is 15% of (2^3). A
+// percentage is relative to the left operand of + and - only if it is
+// their whole right operand, with its signs: 200 + 10% * 2 is 200 + 0.2,
+// 200 * 10% is 20 and 200 + -10% is 180.
+type PercentNode struct {
+	lexer.Token   // %
+	Percent, Base lexer.IExpression
+	Op            lexer.Operation
+}
+
+func (n PercentNode) String() string {
+	switch {
+	case n.Base == nil:
+		return fmt.Sprintf("(%v,%v)", lexer.TypePercent, n.Percent)
+	case n.Op == nil:
+		return fmt.Sprintf("(%v,%v,%v)", lexer.TypePercent, n.Percent, n.Base)
+	}
+	return fmt.Sprintf("(%v,%v,(%v,%v))", OpType(n.Op), n.Base, lexer.TypePercent, n.Percent)
+}
+
+// Span from the first operand to the last
+func (n PercentNode) Span() lexer.Span {
+	switch {
+	case n.Base == nil:
+		return lexer.Span{Start: lexer.SpanOf(n.Percent).Start, End: n.End}
+	case n.Op == nil:
+		return lexer.Span{Start: lexer.SpanOf(n.Percent).Start, End: lexer.SpanOf(n.Base).End}
+	}
+	return lexer.Span{Start: lexer.SpanOf(n.Base).Start, End: n.End}
+}
+
+// Relative tells if the percentage is added to or subtracted from its base
+func (n PercentNode) Relative() bool { return n.Op != nil }
+
+// Mul and Div compute the part of the base, located at %
+func (n PercentNode) Mul() lexer.TokenMul { return lexer.TokenMul{Token: n.at(lexer.TypeMul)} }
+
+// Div see Mul
+func (n PercentNode) Div() lexer.TokenDiv { return lexer.TokenDiv{Token: n.at(lexer.TypeDiv)} }
+
+func (n PercentNode) at(t lexer.Type) lexer.Token {
+	return lexer.Token{Type: t, Pos: n.Pos, End: n.End}
+}
+
+// Eval evaluates the base of a relative percentage first, the percentage of
+// of first otherwise, as they are written
+func (n PercentNode) Eval() (float64, error) {
+	base, percent := 1.0, 0.0
+	var err error
+	if n.Relative() {
+		if base, err = n.Base.Eval(); err != nil {
+			return 0, err
+		}
+	}
+	if percent, err = n.Percent.Eval(); err != nil {
+		return 0, err
+	}
+	if n.Base != nil && !n.Relative() {
+		if base, err = n.Base.Eval(); err != nil {
+			return 0, err
+		}
+	}
+	part := base * percent / 100
+	if !n.Relative() {
+		return part, nil
+	}
+	return n.Op.Eval(lexer.NewTokenFloat(base), lexer.NewTokenFloat(part))
+}
+
+// percent parses % after the percentage node, and of base if it follows
+func (p *Parser) percent(node lexer.IExpression) (lexer.IExpression, error) {
+	n := PercentNode{p.CurrentToken.(lexer.TokenPercent).Token, node, nil, nil}
+	p.Next()
+	if of, ok := p.CurrentToken.(lexer.TokenIdent); !ok || of.Value != "of" {
+		return n, nil
+	}
+	p.Next()
+	base, err := p.Binary(InfixOperators[lexer.TypeMul].Power)
+	if err != nil {
+		return nil, err
+	}
+	n.Base = base
+	return n, nil
+}
+
+// relative turns right, the right operand of op applied to left, into a
+// percentage of left if op is + or - and right a bare percentage, which may
+// be signed: 200 + -10% is 200 + (-10)% of 200
+func relative(left, right lexer.IExpression, op lexer.Operation) (PercentNode, bool) {
+	if !additive(op) {
+		return PercentNode{}, false
+	}
+	var signs []UnaryOpNode
+	for {
+		u, ok := right.(UnaryOpNode)
+		if !ok || !additive(u.Op) {
+			break
+		}
+		signs, right = append(signs, u), u.Operand
+	}
+	n, ok := right.(PercentNode)
+	if !ok || n.Base != nil {
+		return PercentNode{}, false
+	}
+	for i := len(signs) - 1; i >= 0; i-- {
+		n.Percent = UnaryOpNode{signs[i].Op, n.Percent}
+	}
+	n.Base, n.Op = left, op
+	return n, true
+}
+
+// additive tells if op is + or -
+func additive(op lexer.Operation) bool {
+	t := OpType(op)
+	return t == lexer.TypePlus || t == lexer.TypeMinus
+}
@@ -0,0 +1,46 @@
+package parser
+
+import (
+	"math"
+	"testing"
+
+	"github.com/fmarmol/lexp/lexer"
+)
+
+func TestPercentPrecedence(t *testing.T) {
+	tests := []struct {
+		text   string
+		want   float64
+		source string
+	}{
+		{"200 + 10%", 220, "200 + 10%"},
+		{"200 - 10%", 180, "200 - 10%"},
+		{"200 + -10%", 180, "200 + -10%"},
+		{"200 - -10%", 220, "200 - -10%"},
+		{"200 + --10%", 220, "200 + --10%"},
+		{"200 * 10%", 20, "200 * 10%"},
+		{"200 * -10%", -20, "200 * -10%"},
+		{"200 + 10% * 2", 200.2, "200 + 10% * 2"},
+		{"15% of 80 + 1", 13, "15% of 80 + 1"},
+		{"15% of 2^3", 1.2, "15% of 2 ^ 3"},
+		{"-10%", -0.1, "-10%"},
+		{"2^10%", math.Pow(2, 0.1), "2 ^ 10%"},
+	}
+	for _, test := range tests {
+		tokens, err := lexer.New("test", test.text).MakeTokens()
+		if err != nil {
+			t.Fatalf("MakeTokens(%q): %v", test.text, err)
+		}
+		node, err := New(tokens).Parse()
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", test.text, err)
+		}
+		got, err := node.Eval()
+		if err != nil || math.Abs(got-test.want) > 1e-12 {
+			t.Errorf("%q = %v, %v, want %v", test.text, got, err, test.want)
+		}
+		if source := Source(node); source != test.source {
+			t.Errorf("Source(%q) = %q, want %q", test.text, source, test.source)
+		}
+	}
+}
@@ -0,0 +1,353 @@
+package parser
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/fmarmol/lexp/lexer"
+)
+
+// Pretty prints node as an indented tree, one node per line:
+//
+//	+
+//	  1
+//	  *
+//	    2
+//	    3
+func Pretty(node lexer.IExpression) string {
+	b := &strings.Builder{}
+	var print func(node lexer.IExpression, depth int)
+	print = func(node lexer.IExpression, depth int) {
+		fmt.Fprintf(b, "%v%v\n", strings.Repeat("  ", depth), label(node))
+		for _, child := range Children(node) {
+			print(child, depth+1)
+		}
+	}
+	print(node, 0)
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// SExpr prints node as an S-expression, like (+ 1 (* 2 3))
+func SExpr(node lexer.IExpression) string {
+	children := Children(node)
+	if kind := KindOf(node); len(children) == 0 && kind != KindCall && kind != KindList && kind != KindMap {
+		return label(node)
+	}
+	items := []string{symbol(node)}
+	for _, child := range children {
+		items = append(items, SExpr(child))
+	}
+	return "(" + strings.Join(items, " ") + ")"
+}
+
+// label of node in Pretty
+func label(node lexer.IExpression) string {
+	switch n := node.(type) {
+	case CallNode:
+		return "call " + n.Name()
+	case AssignNode:
+		return "assign " + n.Name
+	case SeqNode:
+		return "seq"
+	case ProgramNode:
+		return "program"
+	case ForNode:
+		return "for " + n.Var
+	case DefNode:
+		return "def " + n.Name
+	case LetNode:
+		return "let " + n.Name
+	case ListNode:
+		return "list"
+	case MapNode:
+		return "map"
+	case RangeNode:
+		return "range"
+	case CoalesceNode:
+		return "coalesce"
+	case TryNode:
+		return "try"
+	case MatchNode:
+		return "match"
+	case ImportNode:
+		return "import " + strconv.Quote(n.Path)
+	case IndexNode:
+		return "index"
+	case PercentNode:
+		if n.Relative() {
+			return "percent " + lexer.Symbols[OpType(n.Op)]
+		}
+		return "percent"
+	}
+	return symbol(node)
+}
+
+// symbol of the operator of node, or its literal text for the leaves
+func symbol(node lexer.IExpression) string {
+	if op := Operator(node); op != nil {
+		return lexer.Symbols[OpType(op)]
+	}
+	switch n := node.(type) {
+	case VarNode:
+		return n.Name()
+	case CallNode:
+		return n.Name()
+	case AssignNode:
+		return "= " + n.Name
+	case SeqNode:
+		return lexer.Symbols[lexer.TypeComma]
+	case ProgramNode:
+		return lexer.Symbols[lexer.TypeSemi]
+	case WhileNode:
+		return lexer.Symbols[lexer.TypeWhile]
+	case ForNode:
+		return lexer.Symbols[lexer.TypeFor] + " " + n.Var
+	case LambdaNode:
+		return "lambda (" + strings.Join(n.Params, " ") + ")"
+	case DefNode:
+		return "= " + n.Name
+	case LetNode:
+		return lexer.Symbols[lexer.TypeLet] + " " + n.Name
+	case ListNode:
+		return lexer.Symbols[lexer.TypeLBracket] + lexer.Symbols[lexer.TypeRBracket]
+	case IndexNode:
+		return lexer.Symbols[lexer.TypeLBracket]
+	case RangeNode:
+		return lexer.Symbols[lexer.TypeRange]
+	case CoalesceNode:
+		return lexer.Symbols[lexer.TypeCoalesce]
+	case TryNode:
+		return lexer.Symbols[lexer.TypeTry]
+	case MatchNode:
+		return lexer.Symbols[lexer.TypeMatch]
+	case ImportNode:
+		return lexer.Symbols[lexer.TypeImport]
+	case PercentNode:
+		return lexer.Symbols[lexer.TypePercent]
+	case MapNode:
+		keys := make([]string, len(n.Keys))
+		for i, key := range n.Keys {
+			keys[i] = strconv.Quote(key)
+		}
+		return lexer.Symbols[lexer.TypeLBrace] + strings.Join(keys, " ") + lexer.Symbols[lexer.TypeRBrace]
+	case lexer.TokenInt:
+		return strconv.FormatInt(n.Value.(int64), 10)
+	case lexer.TokenFloat:
+		return strconv.FormatFloat(n.Value.(float64), 'f', -1, 64)
+	case lexer.TokenBool:
+		return strconv.FormatBool(n.Value.(bool))
+	case lexer.TokenString:
+		return strconv.Quote(n.Value.(string))
+	case lexer.TokenNil:
+		return lexer.Symbols[lexer.TypeNil]
+	}
+	return fmt.Sprint(node)
+}
+
+// Source prints node back as source text with a space around binary
+// operators and the parentheses the precedence of the operators needs,
+// statements being on their own line. Literals are written as in the
+// source if known.
+func Source(node lexer.IExpression) string {
+	switch n := node.(type) {
+	case BinOpNode:
+		b := InfixOperators[OpType(n.Op)]
+		left, right := nested(n.Left), nested(n.Right)
+		if power := bindingPower(n.Left); power < b.Power || (power == b.Power && b.Right) {
+			left = "(" + left + ")"
+		}
+		if power := bindingPower(n.Right); power < b.Power || (power == b.Power && !b.Right) {
+			right = "(" + right + ")"
+		}
+		return left + " " + symbol(n) + " " + right
+	case UnaryOpNode:
+		// the operand of a prefix operator extends to the operators binding
+		// tighter, prefix operators included
+		operand, power := nested(n.Operand), PrefixOperators[OpType(n.Op)].Power
+		if _, unary := n.Operand.(UnaryOpNode); bindingPower(n.Operand) < power || (!unary && bindingPower(n.Operand) == power) {
+			operand = "(" + operand + ")"
+		}
+		if _, ok := n.Op.(lexer.TokenNot); ok {
+			return symbol(n) + " " + operand
+		}
+		return symbol(n) + operand
+	case CallNode:
+		args := make([]string, len(n.Args))
+		for i, arg := range n.Args {
+			args[i] = nested(arg)
+		}
+		return n.Name() + "(" + strings.Join(args, ", ") + ")"
+	case AssignNode:
+		return n.Name + " = " + nested(n.Value)
+	case SeqNode:
+		items := make([]string, len(n.Items))
+		for i, item := range n.Items {
+			items[i] = Source(item)
+		}
+		return strings.Join(items, ", ")
+	case ProgramNode:
+		statements := make([]string, len(n.Statements))
+		for i, statement := range n.Statements {
+			statements[i] = Source(statement)
+		}
+		return strings.Join(statements, "\n")
+	case WhileNode:
+		return "while " + nested(n.Cond) + " " + blockSource(n.Body)
+	case ForNode:
+		bounds := nested(n.Start) + ", " + nested(n.End)
+		if n.Step != nil {
+			bounds += ", " + nested(n.Step)
+		}
+		return "for " + n.Var + " = " + bounds + " " + blockSource(n.Body)
+	case LambdaNode:
+		return "(" + strings.Join(n.Params, ", ") + ") -> " + nested(n.Body)
+	case DefNode:
+		return n.Name + " = " + Source(n.Lambda)
+	case LetNode:
+		return "let " + n.Name + " = " + nested(n.Value) + " in " + nested(n.Body)
+	case ListNode:
+		items := make([]string, len(n.Items))
+		for i, item := range n.Items {
+			items[i] = nested(item)
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case TryNode:
+		return "try " + nested(n.Body) + " catch " + nested(n.Catch)
+	case ImportNode:
+		return "import " + strconv.Quote(n.Path)
+	case MatchNode:
+		subject := nested(n.Subject)
+		if bindingPower(n.Subject) == 0 {
+			subject = "(" + subject + ")"
+		}
+		arms := make([]string, len(n.Arms), len(n.Arms)+1)
+		for i, arm := range n.Arms {
+			arms[i] = nested(arm.Pattern) + " => " + nested(arm.Value)
+		}
+		arms = append(arms, "_ => "+nested(n.Default))
+		return "match " + subject + " { " + strings.Join(arms, ", ") + " }"
+	case CoalesceNode:
+		left, right := nested(n.Left), nested(n.Right)
+		if bindingPower(n.Left) < coalescePower {
+			left = "(" + left + ")"
+		}
+		if bindingPower(n.Right) <= coalescePower {
+			right = "(" + right + ")"
+		}
+		return left + " " + symbol(n) + " " + right
+	case RangeNode:
+		bounds := []string{}
+		for _, bound := range Children(n) {
+			s := nested(bound)
+			if bindingPower(bound) == 0 {
+				s = "(" + s + ")"
+			}
+			bounds = append(bounds, s)
+		}
+		if n.Step == nil {
+			return bounds[0] + ".." + bounds[1]
+		}
+		return bounds[0] + ".." + bounds[1] + " step " + bounds[2]
+	case MapNode:
+		entries := make([]string, len(n.Keys))
+		for i, key := range n.Keys {
+			entries[i] = strconv.Quote(key) + ": " + nested(n.Items[i])
+		}
+		return "{" + strings.Join(entries, ", ") + "}"
+	case IndexNode:
+		target := nested(n.Target)
+		if bindingPower(n.Target) < math.MaxInt {
+			target = "(" + target + ")"
+		}
+		return target + "[" + nested(n.Index) + "]"
+	case PercentNode:
+		// the signs of a relative percentage are written before it, as
+		// they are parsed: 200 + -10%
+		signs, inner := "", n.Percent
+		for u, ok := inner.(UnaryOpNode); ok && n.Relative() && additive(u.Op); u, ok = inner.(UnaryOpNode) {
+			signs, inner = signs+symbol(u), u.Operand
+		}
+		percent := nested(inner)
+		if bindingPower(inner) < math.MaxInt {
+			percent = "(" + percent + ")"
+		}
+		percent = signs + percent + symbol(n)
+		if n.Base == nil {
+			return percent
+		}
+		base := nested(n.Base)
+		if !n.Relative() {
+			if bindingPower(n.Base) <= InfixOperators[lexer.TypeMul].Power {
+				base = "(" + base + ")"
+			}
+			return percent + " of " + base
+		}
+		if bindingPower(n.Base) < InfixOperators[OpType(n.Op)].Power {
+			base = "(" + base + ")"
+		}
+		return base + " " + lexer.Symbols[OpType(n.Op)] + " " + percent
+	}
+	span := lexer.SpanOf(node)
+	if content := span.Start.FileContent; span.Start.Line > 0 && span.Start.Index >= 0 && span.End.Index <= len(content) {
+		return content[span.Start.Index:span.End.Index]
+	}
+	return symbol(node)
+}
+
+// nested prints a node within another one, blocks of statements between
+// braces
+func nested(node lexer.IExpression) string {
+	switch node.(type) {
+	case ProgramNode, SeqNode:
+		return blockSource(node)
+	}
+	return Source(node)
+}
+
+// blockSource prints the body of a loop on one line, its statements
+// separated by semicolons
+func blockSource(body lexer.IExpression) string {
+	statements := []lexer.IExpression{body}
+	if program, ok := body.(ProgramNode); ok {
+		statements = program.Statements
+	}
+	if len(statements) == 0 {
+		return "{}"
+	}
+	sources := make([]string, len(statements))
+	for i, statement := range statements {
+		sources[i] = Source(statement)
+	}
+	return "{ " + strings.Join(sources, "; ") + " }"
+}
+
+// bindingPower of the operator of node, leaves binding the tightest but
+// negative literals, written with a prefix -
+func bindingPower(node lexer.IExpression) int {
+	switch n := node.(type) {
+	case lexer.TokenInt, lexer.TokenFloat:
+		if v, _ := n.Eval(); v < 0 || math.Signbit(v) {
+			return PrefixOperators[lexer.TypeMinus].Power
+		}
+	case BinOpNode:
+		return InfixOperators[OpType(n.Op)].Power
+	case UnaryOpNode:
+		return PrefixOperators[OpType(n.Op)].Power
+	case LambdaNode, LetNode, RangeNode, TryNode:
+		// the body extends as far as possible
+		return 0
+	case CoalesceNode:
+		return coalescePower
+	case PercentNode:
+		switch {
+		case n.Base == nil:
+			return percentPower
+		case n.Relative():
+			return InfixOperators[OpType(n.Op)].Power
+		}
+		return InfixOperators[lexer.TypeMul].Power
+	}
+	return math.MaxInt
+}
@@ -0,0 +1,102 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/fmarmol/lexp/lexer"
+)
+
+// ErrRangeStep is reported by the ranges counting by 0
+var ErrRangeStep = errors.New("range step is 0")
+
+// MaxRangeItems is the number of items of the longest range
+const MaxRangeItems = 10_000_000
+
+// RangeNode is the list of the numbers from Start to End included, counting
+// by Step, 1 if nil
+type RangeNode struct {
+	lexer.Token // ..
+	Start, End  lexer.IExpression
+	Step        lexer.IExpression
+}
+
+func (r RangeNode) String() string {
+	return fmt.Sprintf("(%v,%v,%v,%v)", lexer.TypeRange, r.Start, r.End, r.By())
+}
+
+// By returns Step, or 1 if it is nil
+func (r RangeNode) By() lexer.IExpression {
+	if r.Step == nil {
+		return lexer.NewTokenInt(1)
+	}
+	return r.Step
+}
+
+// Span from the start to the end or the step
+func (r RangeNode) Span() lexer.Span {
+	last := r.End
+	if r.Step != nil {
+		last = r.Step
+	}
+	return lexer.Span{Start: lexer.SpanOf(r.Start).Start, End: lexer.SpanOf(last).End}
+}
+
+// Eval fails, a range only being indexed or passed to a function
+func (r RangeNode) Eval() (float64, error) {
+	return 0, r.Locate(fmt.Errorf("%w%v", ErrListValue, r.At()))
+}
+
+// Values of the items, from start to end
+func (r RangeNode) Values() ([]float64, error) {
+	bounds := make([]float64, 3)
+	for i, bound := range []lexer.IExpression{r.Start, r.End, r.By()} {
+		var err error
+		if bounds[i], err = bound.Eval(); err != nil {
+			return nil, err
+		}
+	}
+	return r.Items(bounds[0], bounds[1], bounds[2])
+}
+
+// Items from start to end counting by step, failing for a step of 0 or
+// beyond MaxRangeItems
+func (r RangeNode) Items(start, end, step float64) ([]float64, error) {
+	if step == 0 {
+		return nil, r.Locate(fmt.Errorf("%w%v", ErrRangeStep, r.At()))
+	}
+	if !Within(start, end, step) {
+		return []float64{}, nil
+	}
+	n := (end-start)/step + 1
+	if n > MaxRangeItems || math.IsNaN(n) {
+		return nil, r.Locate(fmt.Errorf("range of %.0f items, at most %v%v", n, MaxRangeItems, r.At()))
+	}
+	items := make([]float64, 0, int(n))
+	// multiplying rather than adding the step not to accumulate rounding
+	// errors
+	for i := 0; Within(start+float64(i)*step, end, step); i++ {
+		items = append(items, start+float64(i)*step)
+	}
+	return items, nil
+}
+
+// rangeFrom parses the ..end[ step by] following start, step being a
+// contextual keyword
+func (p *Parser) rangeFrom(start lexer.IExpression) (lexer.IExpression, error) {
+	dots := p.CurrentToken.(lexer.TokenRange)
+	p.Next()
+	end, err := p.coalescing()
+	if err != nil {
+		return nil, err
+	}
+	r := RangeNode{dots.Token, start, end, nil}
+	if name, ok := p.CurrentToken.(lexer.TokenIdent); ok && name.Value == "step" {
+		p.Next()
+		if r.Step, err = p.coalescing(); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
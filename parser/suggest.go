@@ -0,0 +1,82 @@
+package parser
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Suggest returns the name of candidates closest to name by edit distance,
+// empty if none is close enough to be a misspelling of it, no name being
+// replaced entirely. The ties are broken by the alphabetical order.
+func Suggest(name string, candidates ...[]string) string {
+	runes := len([]rune(name))
+	best, bestDistance := "", min(max(1, runes/3)+1, runes)
+	for _, names := range candidates {
+		for _, candidate := range names {
+			if candidate == name {
+				continue
+			}
+			d := distance(name, candidate)
+			if d < bestDistance || d == bestDistance && candidate < best {
+				best, bestDistance = candidate, d
+			}
+		}
+	}
+	return best
+}
+
+// distance of Levenshtein between a and b, counting the runes inserted,
+// deleted or substituted to turn a into b
+func distance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev, cur := make([]int, len(rb)+1), make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := range ra {
+		cur[0] = i + 1
+		for j := range rb {
+			cost := 1
+			if ra[i] == rb[j] {
+				cost = 0
+			}
+			cur[j+1] = min(prev[j+1]+1, cur[j]+1, prev[j]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+// UndefinedVariable reports name not being defined, suggesting the closest
+// of the defined names
+func UndefinedVariable(name string, defined ...[]string) error {
+	return fmt.Errorf("undefined variable %v%v", name, DidYouMean(name, defined...))
+}
+
+// undefinedVariable reports the variable of v not being defined, the
+// suggestion being searched when the error is printed only, as the compiled
+// programs load their variables before running
+type undefinedVariable VarNode
+
+func (v undefinedVariable) Error() string {
+	return UndefinedVariable(VarNode(v).Name(), v.Env.Names(), v.Values.Names()).Error()
+}
+
+// DidYouMean returns ", did you mean x?" for the suggestion x of Suggest,
+// to end error messages with, empty if there is none
+func DidYouMean(name string, candidates ...[]string) string {
+	if s := Suggest(name, candidates...); s != "" {
+		return fmt.Sprintf(", did you mean %v?", s)
+	}
+	return ""
+}
+
+// Names of the values, sorted
+func (v Values) Names() []string {
+	names := make([]string, 0, len(v))
+	for name := range v {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
@@ -0,0 +1,57 @@
+package parser
+
+import (
+	"fmt"
+
+	"github.com/fmarmol/lexp/lexer"
+)
+
+// TryNode is the value of Body, or the value of Catch if the evaluation of
+// Body fails
+type TryNode struct {
+	lexer.Token // try keyword
+	Body, Catch lexer.IExpression
+}
+
+func (t TryNode) String() string {
+	return fmt.Sprintf("(%v,%v,%v)", lexer.TypeTry, t.Body, t.Catch)
+}
+
+// Span from the keyword to the fallback
+func (t TryNode) Span() lexer.Span {
+	return lexer.Span{Start: t.Pos, End: lexer.SpanOf(t.Catch).End}
+}
+
+// Eval only evaluates Catch if Body fails
+func (t TryNode) Eval() (float64, error) {
+	v, err := t.Body.Eval()
+	if err != nil {
+		return t.Catch.Eval()
+	}
+	return v, nil
+}
+
+// try parses try body catch fallback, the body and the fallback being
+// assignments or expressions, catch being allowed on the next line
+func (p *Parser) try() (lexer.IExpression, error) {
+	keyword := p.CurrentToken.(lexer.TokenTry)
+	p.Next()
+	body, err := p.Assignment()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := p.CurrentToken.(lexer.TokenNewline); ok {
+		if _, ok := p.Peek(1).(lexer.TokenCatch); ok {
+			p.Next()
+		}
+	}
+	if _, ok := p.CurrentToken.(lexer.TokenCatch); !ok {
+		return nil, p.errorf("expected catch after try, got %v", p.current())
+	}
+	p.Next()
+	fallback, err := p.Assignment()
+	if err != nil {
+		return nil, err
+	}
+	return TryNode{keyword.Token, body, fallback}, nil
+}
@@ -0,0 +1,275 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/fmarmol/lexp/lexer"
+)
+
+// Type of the values of an expression, as inferred by TypeCheck
+type Type string
+
+const (
+	TypeInt    Type = "int"
+	TypeFloat  Type = "float"
+	TypeBool   Type = "bool"
+	TypeString Type = "string"
+	// TypeAny is the type of the lists, maps, nil and the expressions whose
+	// type depends on their evaluation
+	TypeAny Type = "any"
+)
+
+// number tells if t is int or float
+func (t Type) number() bool { return t == TypeInt || t == TypeFloat }
+
+// TypeCheck infers the type of node and reports the mismatches found before
+// evaluation, like "a" * 2, joined. The variables of env are numbers and
+// the other ones are typed by their last assignment, any if unknown.
+func TypeCheck(node lexer.IExpression, env Env) (Type, error) {
+	c := &checker{vars: map[string]Type{}}
+	for name, v := range env {
+		c.vars[name] = TypeFloat
+		if v == float64(int64(v)) {
+			c.vars[name] = TypeInt
+		}
+	}
+	t := c.check(node)
+	return t, errors.Join(c.errs...)
+}
+
+type checker struct {
+	vars map[string]Type
+	errs []error
+}
+
+// mismatch records the error located at the span of at
+func (c *checker) mismatch(at any, format string, args ...any) {
+	err := fmt.Errorf(format, args...)
+	if t, ok := at.(interface{ At() string }); ok {
+		err = fmt.Errorf("%w%v", err, t.At())
+	}
+	if t, ok := at.(interface{ Locate(error) error }); ok {
+		err = t.Locate(err)
+	}
+	c.errs = append(c.errs, err)
+}
+
+// locator returns node if errors can be located at it, fallback otherwise
+func locator(node, fallback any) any {
+	if _, ok := node.(interface{ Locate(error) error }); ok {
+		return node
+	}
+	return fallback
+}
+
+// all checks nodes, returning the type of the last one
+func (c *checker) all(nodes []lexer.IExpression) Type {
+	t := TypeAny
+	for _, node := range nodes {
+		t = c.check(node)
+	}
+	return t
+}
+
+// same returns the type of all the nodes if they share it, any otherwise
+func (c *checker) same(nodes ...lexer.IExpression) Type {
+	var ret Type
+	for i, node := range nodes {
+		if t := c.check(node); i == 0 {
+			ret = t
+		} else if t != ret {
+			ret = TypeAny
+		}
+	}
+	return ret
+}
+
+// bind t to name while checking body
+func (c *checker) bind(name string, t Type, body lexer.IExpression) Type {
+	old, ok := c.vars[name]
+	c.vars[name] = t
+	ret := c.check(body)
+	if ok {
+		c.vars[name] = old
+	} else {
+		delete(c.vars, name)
+	}
+	return ret
+}
+
+func (c *checker) check(node lexer.IExpression) Type {
+	switch n := node.(type) {
+	case lexer.TokenInt:
+		return TypeInt
+	case lexer.TokenFloat:
+		return TypeFloat
+	case lexer.TokenBool:
+		return TypeBool
+	case lexer.TokenString:
+		return TypeString
+	case VarNode:
+		if t, ok := c.vars[n.Name()]; ok {
+			return t
+		}
+		return TypeAny
+	case BinOpNode:
+		return c.binary(n.Op, c.check(n.Left), c.check(n.Right))
+	case UnaryOpNode:
+		t := c.check(n.Operand)
+		if _, not := n.Op.(lexer.TokenNot); not {
+			if t != TypeBool && t != TypeAny {
+				c.mismatch(n.Op, "%v needs booleans, got %v", n.Op, t)
+			}
+			return TypeBool
+		}
+		return c.binary(n.Op, TypeInt, t)
+	case CallNode:
+		return c.call(n)
+	case AssignNode:
+		t := c.check(n.Value)
+		c.vars[n.Name] = t
+		return t
+	case SeqNode:
+		return c.all(n.Items)
+	case ProgramNode:
+		return c.all(n.Statements)
+	case ForNode:
+		t := TypeInt
+		for _, bound := range []lexer.IExpression{n.Start, n.End, n.By()} {
+			switch bt := c.check(bound); {
+			case bt == TypeFloat || bt == TypeAny:
+				t = TypeFloat
+			case !bt.number():
+				c.mismatch(locator(bound, n), "for needs numbers, got a %v", bt)
+			}
+		}
+		c.vars[n.Var] = t
+		c.check(n.Body)
+		return TypeAny
+	case LambdaNode:
+		// the parameters are unknown until the function is called
+		outer := c.vars
+		c.vars = map[string]Type{}
+		for name, t := range outer {
+			c.vars[name] = t
+		}
+		for _, param := range n.Params {
+			c.vars[param] = TypeAny
+		}
+		c.check(n.Body)
+		c.vars = outer
+		return TypeAny
+	case DefNode:
+		c.check(n.Lambda)
+		return TypeString
+	case LetNode:
+		return c.bind(n.Name, c.check(n.Value), n.Body)
+	case RangeNode:
+		for _, bound := range Children(n) {
+			if t := c.check(bound); !t.number() && t != TypeAny {
+				c.mismatch(locator(bound, n), "range needs numbers, got a %v", t)
+			}
+		}
+		return TypeAny
+	case CoalesceNode:
+		if _, ok := n.Left.(lexer.TokenNil); ok {
+			return c.check(n.Right)
+		}
+		return c.same(n.Left, n.Right)
+	case TryNode:
+		return c.same(n.Body, n.Catch)
+	case MatchNode:
+		c.check(n.Subject)
+		values := []lexer.IExpression{}
+		for _, arm := range n.Arms {
+			c.check(arm.Pattern)
+			values = append(values, arm.Value)
+		}
+		return c.same(append(values, n.Default)...)
+	case ImportNode:
+		c.check(n.Program)
+		return TypeInt
+	case PercentNode:
+		base, percent := TypeInt, TypeInt
+		if n.Relative() {
+			base = c.check(n.Base)
+		}
+		percent = c.check(n.Percent)
+		if n.Base != nil && !n.Relative() {
+			base = c.check(n.Base)
+		}
+		part := c.binary(n.Div(), c.binary(n.Mul(), base, percent), TypeInt)
+		if n.Relative() {
+			return c.binary(n.Op, base, part)
+		}
+		return part
+	}
+	// while loops, lists, maps, indexes and nil
+	for _, child := range Children(node) {
+		c.check(child)
+	}
+	return TypeAny
+}
+
+// binary infers the type of op applied to operands of types left and right,
+// like the evaluation does
+func (c *checker) binary(op lexer.Operation, left, right Type) Type {
+	if _, ok := op.(lexer.Logical); ok {
+		for _, t := range []Type{left, right} {
+			if t != TypeBool && t != TypeAny {
+				c.mismatch(op, "%v needs booleans, got %v", op, t)
+				break
+			}
+		}
+		return TypeBool
+	}
+	if left == TypeAny || right == TypeAny {
+		if _, ok := op.(lexer.Comparison); ok {
+			return TypeBool
+		}
+		return TypeAny
+	}
+	if cmp, ok := op.(lexer.Comparison); ok {
+		switch {
+		case (left == TypeBool) != (right == TypeBool) || (left == TypeString) != (right == TypeString):
+			c.mismatch(op, "cannot compare %v and %v with %v", left, right, op)
+		case left == TypeBool && cmp.Holds(-1) != cmp.Holds(1):
+			c.mismatch(op, "cannot order booleans with %v", op)
+		}
+		return TypeBool
+	}
+	if left == TypeString && right == TypeString && OpType(op) == lexer.TypePlus {
+		return TypeString
+	}
+	if !left.number() || !right.number() {
+		c.mismatch(op, "cannot apply %v to %v and %v", op, left, right)
+		return TypeAny
+	}
+	if _, ok := op.(lexer.IntOperation); ok && left == TypeInt && right == TypeInt && OpType(op) != lexer.TypeDiv {
+		return TypeInt
+	}
+	return TypeFloat
+}
+
+// call infers the type of the result of n, the builtins taking numbers
+// yielding floats
+func (c *checker) call(n CallNode) Type {
+	f, ok := n.Funcs[n.Name()]
+	// the functions of modules like string take values which are not
+	// numbers, and the functions defined in the session any value
+	numeric := ok && f.Closure == nil && !strings.Contains(n.Name(), ".")
+	for _, arg := range n.Args {
+		if v, ok := arg.(VarNode); ok && n.Funcs[v.Name()].Call != nil {
+			continue
+		}
+		if t := c.check(arg); numeric && (t == TypeString || t == TypeBool) {
+			c.mismatch(locator(arg, n), "%v expects numbers, got a %v", n.Name(), t)
+		}
+	}
+	if numeric {
+		return TypeFloat
+	}
+	return TypeAny
+}
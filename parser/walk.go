@@ -0,0 +1,176 @@
+package parser
+
+import "github.com/fmarmol/lexp/lexer"
+
+// Kind of AST node
+type Kind string
+
+const (
+	KindNumber   Kind = "number"   // lexer.TokenInt or lexer.TokenFloat
+	KindBool     Kind = "bool"     // lexer.TokenBool
+	KindNil      Kind = "nil"      // lexer.TokenNil
+	KindString   Kind = "string"   // lexer.TokenString
+	KindVar      Kind = "var"      // VarNode
+	KindBinary   Kind = "binary"   // BinOpNode
+	KindUnary    Kind = "unary"    // UnaryOpNode
+	KindCall     Kind = "call"     // CallNode
+	KindAssign   Kind = "assign"   // AssignNode
+	KindSeq      Kind = "seq"      // SeqNode
+	KindProgram  Kind = "program"  // ProgramNode
+	KindWhile    Kind = "while"    // WhileNode
+	KindFor      Kind = "for"      // ForNode
+	KindLambda   Kind = "lambda"   // LambdaNode
+	KindDef      Kind = "def"      // DefNode
+	KindLet      Kind = "let"      // LetNode
+	KindList     Kind = "list"     // ListNode
+	KindIndex    Kind = "index"    // IndexNode
+	KindMap      Kind = "map"      // MapNode
+	KindRange    Kind = "range"    // RangeNode
+	KindCoalesce Kind = "coalesce" // CoalesceNode
+	KindTry      Kind = "try"      // TryNode
+	KindMatch    Kind = "match"    // MatchNode
+	KindImport   Kind = "import"   // ImportNode
+	KindPercent  Kind = "percent"  // PercentNode
+)
+
+// KindOf returns the kind of node, empty if it is not an AST node
+func KindOf(node lexer.IExpression) Kind {
+	switch node.(type) {
+	case lexer.TokenInt, lexer.TokenFloat:
+		return KindNumber
+	case lexer.TokenBool:
+		return KindBool
+	case lexer.TokenNil:
+		return KindNil
+	case lexer.TokenString:
+		return KindString
+	case VarNode:
+		return KindVar
+	case BinOpNode:
+		return KindBinary
+	case UnaryOpNode:
+		return KindUnary
+	case CallNode:
+		return KindCall
+	case AssignNode:
+		return KindAssign
+	case SeqNode:
+		return KindSeq
+	case ProgramNode:
+		return KindProgram
+	case WhileNode:
+		return KindWhile
+	case ForNode:
+		return KindFor
+	case LambdaNode:
+		return KindLambda
+	case DefNode:
+		return KindDef
+	case LetNode:
+		return KindLet
+	case ListNode:
+		return KindList
+	case IndexNode:
+		return KindIndex
+	case MapNode:
+		return KindMap
+	case RangeNode:
+		return KindRange
+	case CoalesceNode:
+		return KindCoalesce
+	case TryNode:
+		return KindTry
+	case MatchNode:
+		return KindMatch
+	case ImportNode:
+		return KindImport
+	case PercentNode:
+		return KindPercent
+	}
+	return ""
+}
+
+// Children of node in evaluation order, none for leaves
+func Children(node lexer.IExpression) []lexer.IExpression {
+	switch n := node.(type) {
+	case BinOpNode:
+		return []lexer.IExpression{n.Left, n.Right}
+	case UnaryOpNode:
+		return []lexer.IExpression{n.Operand}
+	case CallNode:
+		return n.Args
+	case AssignNode:
+		return []lexer.IExpression{n.Value}
+	case SeqNode:
+		return n.Items
+	case ProgramNode:
+		return n.Statements
+	case WhileNode:
+		return []lexer.IExpression{n.Cond, n.Body}
+	case ForNode:
+		if n.Step == nil {
+			return []lexer.IExpression{n.Start, n.End, n.Body}
+		}
+		return []lexer.IExpression{n.Start, n.End, n.Step, n.Body}
+	case LambdaNode:
+		return []lexer.IExpression{n.Body}
+	case DefNode:
+		return []lexer.IExpression{n.Lambda}
+	case LetNode:
+		return []lexer.IExpression{n.Value, n.Body}
+	case ListNode:
+		return n.Items
+	case IndexNode:
+		return []lexer.IExpression{n.Target, n.Index}
+	case MapNode:
+		return n.Items
+	case CoalesceNode:
+		return []lexer.IExpression{n.Left, n.Right}
+	case TryNode:
+		return []lexer.IExpression{n.Body, n.Catch}
+	case ImportNode:
+		return []lexer.IExpression{n.Program}
+	case PercentNode:
+		switch {
+		case n.Base == nil:
+			return []lexer.IExpression{n.Percent}
+		case n.Relative():
+			return []lexer.IExpression{n.Base, n.Percent}
+		}
+		return []lexer.IExpression{n.Percent, n.Base}
+	case MatchNode:
+		children := []lexer.IExpression{n.Subject}
+		for _, arm := range n.Arms {
+			children = append(children, arm.Pattern, arm.Value)
+		}
+		return append(children, n.Default)
+	case RangeNode:
+		if n.Step == nil {
+			return []lexer.IExpression{n.Start, n.End}
+		}
+		return []lexer.IExpression{n.Start, n.End, n.Step}
+	}
+	return nil
+}
+
+// Operator of a binary or unary node, nil for the other nodes
+func Operator(node lexer.IExpression) lexer.Operation {
+	switch n := node.(type) {
+	case BinOpNode:
+		return n.Op
+	case UnaryOpNode:
+		return n.Op
+	}
+	return nil
+}
+
+// Walk visits node then its children depth first, skipping the children of
+// the nodes for which visitor returns false
+func Walk(node lexer.IExpression, visitor func(lexer.IExpression) bool) {
+	if node == nil || !visitor(node) {
+		return
+	}
+	for _, child := range Children(node) {
+		Walk(child, visitor)
+	}
+}